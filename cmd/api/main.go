@@ -55,6 +55,11 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 // @in header
 // @name Authorization
 // @description Type "Bearer {your_jwt_token}" to authenticate.
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description Server-to-server api key created by an admin, scoped per endpoint group.
 func main() {
 	server := server.NewServer()
 
@@ -13,6 +13,12 @@ type Env struct {
 	Port                 int    `mapstructure:"PORT"`
 	MaxOTPRequestsPerDay int    `mapstructure:"MAX_OTP_REQUESTS_PER_DAY"`
 	OTPExpInMin          int    `mapstructure:"OTP_EXP_IN_MIN"`
+	// how long soft deleted products, categories, colors and sizes can be restored before they're purged.
+	SoftDeleteRetentionDays int `mapstructure:"SOFT_DELETE_RETENTION_DAYS"`
+	// the stock a variant is alerted at or below, unless it has a threshold of its own.
+	LowStockThreshold int `mapstructure:"LOW_STOCK_THRESHOLD"`
+	// the percentage a wishlisted product price has to drop by at once for its wishlisters to be notified.
+	PriceDropPercent int `mapstructure:"PRICE_DROP_PERCENT"`
 
 	// DB
 	DBHost     string `mapstructure:"DB_HOST"`
@@ -24,11 +30,32 @@ type Env struct {
 	SSLMode    string `mapstructure:"SSL_MODE"`
 	DBUrl      string
 
+	// Storage
+	// s3 or local, the local storage keeps the files in LocalStorageDir and the API serves them.
+	StorageDriver string `mapstructure:"STORAGE_DRIVER"`
+	// the base of the stored files urls, a CDN in front of the bucket for example.
+	StoragePublicURL string `mapstructure:"STORAGE_PUBLIC_URL"`
+	// the bases the stored files urls had before the public url or the bucket moved,
+	// the files under them are still found.
+	StorageLegacyURLs []string `mapstructure:"STORAGE_LEGACY_URLS"`
+	LocalStorageDir   string   `mapstructure:"LOCAL_STORAGE_DIR"`
+	// how long an uploaded object can stay unreferenced before it's deleted, it covers the
+	// time between an upload and the commit of the rows referencing it.
+	OrphanUploadGraceHours int `mapstructure:"ORPHAN_UPLOAD_GRACE_HOURS"`
+
 	// S3
 	S3AccessKey       string `mapstructure:"S3_ACCESS_KEY_ID"`
 	S3SecretAccessKey string `mapstructure:"S3_SECRET_ACCESS_KEY"`
 	S3Region          string `mapstructure:"S3_REGION"`
 	S3Bucket          string `mapstructure:"S3_BUCKET"`
+	// S3Endpoint points the client at an S3 compatible store like MinIO.
+	S3Endpoint     string `mapstructure:"S3_ENDPOINT"`
+	S3UsePathStyle bool   `mapstructure:"S3_USE_PATH_STYLE"`
+
+	// Images
+	// the widths of the WebP renditions made out of every product image.
+	ImageWidths      []int `mapstructure:"IMAGE_WIDTHS"`
+	ImageWebPQuality int   `mapstructure:"IMAGE_WEBP_QUALITY"`
 
 	// Oauth
 	SessionKey         string `mapstructure:"SESSION_KEY"`
@@ -53,6 +80,14 @@ func NewEnv() *Env {
 	env := Env{}
 	viper.AutomaticEnv()
 	viper.SetDefault("APP_ENV", "dev")
+	viper.SetDefault("SOFT_DELETE_RETENTION_DAYS", 30)
+	viper.SetDefault("LOW_STOCK_THRESHOLD", 5)
+	viper.SetDefault("PRICE_DROP_PERCENT", 10)
+	viper.SetDefault("STORAGE_DRIVER", "s3")
+	viper.SetDefault("LOCAL_STORAGE_DIR", "./uploads")
+	viper.SetDefault("ORPHAN_UPLOAD_GRACE_HOURS", 24)
+	viper.SetDefault("IMAGE_WIDTHS", "160,480,960,1600")
+	viper.SetDefault("IMAGE_WEBP_QUALITY", 80)
 
 	bindEnvVariables()
 
@@ -61,6 +96,10 @@ func NewEnv() *Env {
 		log.Fatal("Environment can't be loaded:", err)
 	}
 
+	if len(env.ImageWidths) == 0 {
+		log.Fatal("IMAGE_WIDTHS must list at least one width")
+	}
+
 	switch env.Environment {
 	case "dev":
 		fmt.Println("You're running your application in development mode")
@@ -99,6 +138,9 @@ func bindEnvVariables() {
 		"PORT",
 		"MAX_OTP_REQUESTS_PER_DAY",
 		"OTP_EXP_IN_MIN",
+		"SOFT_DELETE_RETENTION_DAYS",
+		"LOW_STOCK_THRESHOLD",
+		"PRICE_DROP_PERCENT",
 		// DB
 		"DB_HOST",
 		"DB_PORT",
@@ -107,11 +149,22 @@ func bindEnvVariables() {
 		"DB_PASSWORD",
 		"DB_SCHEMA",
 		"SSL_MODE",
+		// Storage
+		"STORAGE_DRIVER",
+		"STORAGE_PUBLIC_URL",
+		"STORAGE_LEGACY_URLS",
+		"LOCAL_STORAGE_DIR",
+		"ORPHAN_UPLOAD_GRACE_HOURS",
 		// S3
 		"S3_ACCESS_KEY_ID",
 		"S3_SECRET_ACCESS_KEY",
 		"S3_REGION",
 		"S3_BUCKET",
+		"S3_ENDPOINT",
+		"S3_USE_PATH_STYLE",
+		// Images
+		"IMAGE_WIDTHS",
+		"IMAGE_WEBP_QUALITY",
 		// Oauth
 		"SESSION_KEY",
 		"GOOGLE_CLIENT_ID",
@@ -137,31 +190,39 @@ func bindEnvVariables() {
 
 func NewTestEnv() *Env {
 	env := &Env{
-		Environment:           "test",
-		Port:                  8081,
-		MaxOTPRequestsPerDay:  5,
-		OTPExpInMin:           10,
-		DBHost:                "localhost",
-		DBPort:                "5433",
-		DBName:                "testdb",
-		DBUsername:            "testuser",
-		DBPassword:            "testpass",
-		DBSchema:              "public",
-		SSLMode:               "disable",
-		S3AccessKey:           "fake-access-key",
-		S3SecretAccessKey:     "fake-secret-key",
-		S3Region:              "us-east-1",
-		S3Bucket:              "test-bucket",
-		SessionKey:            "test-session-key",
-		GoogleClientID:        "test-google-id",
-		GoogleClientSecret:    "test-google-secret",
-		AccessTokenSecret:     "test-access-secret",
-		RefreshTokenSecret:    "test-refresh-secret",
-		AccessTokenExpInMin:   15,
-		RefreshTokenExpInDays: 7,
-		HashSecret:            "test-hash-secret",
-		Email:                 "test@example.com",
-		Password:              "emailpassword",
+		Environment:             "test",
+		Port:                    8081,
+		MaxOTPRequestsPerDay:    5,
+		OTPExpInMin:             10,
+		SoftDeleteRetentionDays: 30,
+		LowStockThreshold:       5,
+		PriceDropPercent:        10,
+		DBHost:                  "localhost",
+		DBPort:                  "5433",
+		DBName:                  "testdb",
+		DBUsername:              "testuser",
+		DBPassword:              "testpass",
+		DBSchema:                "public",
+		SSLMode:                 "disable",
+		StorageDriver:           "local",
+		LocalStorageDir:         "uploads",
+		OrphanUploadGraceHours:  24,
+		S3AccessKey:             "fake-access-key",
+		S3SecretAccessKey:       "fake-secret-key",
+		S3Region:                "us-east-1",
+		S3Bucket:                "test-bucket",
+		ImageWidths:             []int{160, 480, 960, 1600},
+		ImageWebPQuality:        80,
+		SessionKey:              "test-session-key",
+		GoogleClientID:          "test-google-id",
+		GoogleClientSecret:      "test-google-secret",
+		AccessTokenSecret:       "test-access-secret",
+		RefreshTokenSecret:      "test-refresh-secret",
+		AccessTokenExpInMin:     15,
+		RefreshTokenExpInDays:   7,
+		HashSecret:              "test-hash-secret",
+		Email:                   "test@example.com",
+		Password:                "emailpassword",
 	}
 
 	env.DBUrl = fmt.Sprintf(
@@ -3,6 +3,7 @@ package auth
 import (
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -12,9 +13,28 @@ import (
 
 type AccessClaims struct {
 	Role string `json:"role"`
+	// Scopes and APIKeyID are only set when the request was authenticated
+	// with an X-API-Key header, they are never part of a signed token.
+	Scopes   []string `json:"-"`
+	APIKeyID int32    `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// IsAPIKey reports whether the claims were produced from an api key
+// instead of a user access token.
+func (c *AccessClaims) IsAPIKey() bool {
+	return c.APIKeyID != 0
+}
+
+// HasScope reports whether the claims are allowed to use the given scope,
+// user access tokens aren't scoped so they always are.
+func (c *AccessClaims) HasScope(scope string) bool {
+	if !c.IsAPIKey() {
+		return true
+	}
+	return slices.Contains(c.Scopes, scope)
+}
+
 func GetAccessClaims(c *gin.Context) *AccessClaims {
 	claimsInterface, exists := c.Get("claims")
 	if !exists {
@@ -63,6 +83,23 @@ func GetAccessClaimsFromAuthHeader(c *gin.Context, accessTokenSecret string) *Ac
 	return claims
 }
 
+// GetOptionalAccessClaims returns the claims of the access token in the Authorization header,
+// or nil when there is no valid one. Unlike GetAccessClaimsFromAuthHeader it never fails the request,
+// it's meant for the public routes that treat a guest differently from a user.
+func GetOptionalAccessClaims(c *gin.Context, accessTokenSecret string) *AccessClaims {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil
+	}
+
+	claims, err := ParseAccessToken(strings.TrimPrefix(authHeader, "Bearer "), accessTokenSecret)
+	if err != nil {
+		return nil
+	}
+
+	return claims
+}
+
 func GetClaimsFromAuthHeader(c *gin.Context, refreshTokenSecret string) *jwt.RegisteredClaims {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
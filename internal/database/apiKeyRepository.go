@@ -0,0 +1,167 @@
+package database
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type APIKeyRepository interface {
+	// This method will create an api key,
+	// columns required: name, prefix, key_hash, scopes, expires_at, created_by.
+	// Returns: id.
+	Create(ctx *gin.Context, db Querier, key *models.APIKey) (int32, error)
+
+	// Get api key by prefix.
+	GetByPrefix(ctx *gin.Context, db Querier, prefix string) (*models.APIKey, error)
+
+	// Get all api keys, newest first.
+	GetAll(ctx *gin.Context, db Querier) ([]models.APIKey, error)
+
+	// This method will set the revoked_at column to now,
+	// by id, only if the key isn't already revoked.
+	Revoke(ctx *gin.Context, db Querier, id int32) error
+
+	// This method will set the last_used_at column to now,
+	// by id. It skips the write if the key was already used in the last minute.
+	UpdateLastUsed(ctx *gin.Context, db Querier, id int32) error
+}
+
+type apiKeyRepo struct{}
+
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepo{}
+}
+
+func (r *apiKeyRepo) Create(ctx *gin.Context, db Querier, key *models.APIKey) (int32, error) {
+	query := `
+		INSERT INTO api_keys(name, prefix, key_hash, scopes, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := db.QueryRow(
+		ctx,
+		query,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		key.Scopes,
+		key.ExpiresAt,
+		key.CreatedBy,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return 0, Parse(err, "API Key", "Create", Constraints{
+			UniqueViolationCode:     "prefix",
+			ForeignKeyViolationCode: "created_by",
+			NotNullViolationCode:    "name or prefix or key_hash or created_by",
+		})
+	}
+
+	return key.ID, nil
+}
+
+func (r *apiKeyRepo) GetByPrefix(
+	ctx *gin.Context,
+	db Querier,
+	prefix string,
+) (*models.APIKey, error) {
+	query := `
+		SELECT id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at, created_by
+		FROM api_keys
+		WHERE prefix = $1
+	`
+
+	var k models.APIKey
+	err := db.QueryRow(ctx, query, prefix).Scan(
+		&k.ID,
+		&k.Name,
+		&k.Prefix,
+		&k.KeyHash,
+		&k.Scopes,
+		&k.ExpiresAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+		&k.CreatedAt,
+		&k.CreatedBy,
+	)
+	if err != nil {
+		return nil, Parse(err, "API Key", "GetByPrefix", make(Constraints))
+	}
+
+	return &k, nil
+}
+
+func (r *apiKeyRepo) GetAll(ctx *gin.Context, db Querier) ([]models.APIKey, error) {
+	query := `
+		SELECT id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at, created_by
+		FROM api_keys
+		ORDER BY created_at DESC
+	`
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, Parse(err, "API Key", "GetAll", make(Constraints))
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		err = rows.Scan(
+			&k.ID,
+			&k.Name,
+			&k.Prefix,
+			&k.Scopes,
+			&k.ExpiresAt,
+			&k.LastUsedAt,
+			&k.RevokedAt,
+			&k.CreatedAt,
+			&k.CreatedBy,
+		)
+		if err != nil {
+			return nil, Parse(err, "API Key", "GetAll", make(Constraints))
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "API Key", "GetAll", make(Constraints))
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepo) Revoke(ctx *gin.Context, db Querier, id int32) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := db.Exec(ctx, query, id)
+	if err != nil {
+		return Parse(err, "API Key", "Revoke", make(Constraints))
+	}
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "API Key", "Revoke", make(Constraints))
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepo) UpdateLastUsed(ctx *gin.Context, db Querier, id int32) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1
+		AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+
+	_, err := db.Exec(ctx, query, id)
+	if err != nil {
+		return Parse(err, "API Key", "UpdateLastUsed", make(Constraints))
+	}
+
+	return nil
+}
@@ -0,0 +1,465 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type AttributeRepository interface {
+	// This method will get the attributes along with their categories and options,
+	// ordered by position. A categoryID other than 0 keeps the attributes the
+	// products of that category have, the unscoped ones and the ones scoped to
+	// the category or one of its ancestors.
+	GetAll(c *gin.Context, db Querier, categoryID int32) ([]models.Attribute, error)
+
+	// This method will get the filterable attributes along with their options.
+	GetFilterable(c *gin.Context, db Querier) ([]models.Attribute, error)
+
+	// This method will get an attribute along with its categories and options, by id.
+	Get(c *gin.Context, db Querier, id int32) (*models.Attribute, error)
+
+	// This method will create an attribute, its categories and options are set separately.
+	//
+	// Columns required: code, name, type, unit, filterable, position.
+	// Returns: id set on the attribute.
+	Create(c *gin.Context, db Querier, attribute *models.Attribute) error
+
+	// This method will update the attribute, its code and type can't change.
+	//
+	// Columns required: name, unit, filterable, position.
+	// By: id.
+	Update(c *gin.Context, db Querier, attribute *models.Attribute) error
+
+	// This method will replace the categories the attribute is scoped to,
+	// none makes it apply to every product.
+	SetCategories(c *gin.Context, db Querier, id int32, categoryIDs []int32) error
+
+	// This method will delete an attribute along with its options and the product values, by id.
+	Delete(c *gin.Context, db Querier, id int32) error
+
+	// This method will add an option to an enum attribute, placed after its other options.
+	//
+	// Columns required: value, attribute_id.
+	// Returns: id and position set on the option.
+	CreateOption(c *gin.Context, db Querier, option *models.AttributeOption) error
+
+	// This method will delete an option of the attribute, by id.
+	// It fails with a foreign key violation while products have it.
+	DeleteOption(c *gin.Context, db Querier, attributeID, optionID int32) error
+
+	// This method will get the attribute values of the product, ordered by the attribute position.
+	GetValuesOfProduct(c *gin.Context, db Querier, productID int32) ([]ProductAttributeValue, error)
+
+	// This method will replace the attribute values of the product.
+	//
+	// Columns required: attribute_id, text_value, option_id, number_value.
+	SetValuesOfProduct(
+		c *gin.Context,
+		db Querier,
+		productID int32,
+		values []models.ProductAttribute,
+	) error
+}
+
+type attributeRepo struct{}
+
+func NewAttributeRepository() AttributeRepository {
+	return &attributeRepo{}
+}
+
+// getAttributes gets the attributes matching the condition, then their options.
+func getAttributes(
+	c *gin.Context,
+	db Querier,
+	method, condition string,
+	args ...any,
+) ([]models.Attribute, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			a.id, a.code, a.name, a.type, a.unit, a.filterable, a.position,
+			COALESCE((
+				SELECT array_agg(ac.category_id ORDER BY ac.category_id)
+				FROM attribute_categories ac
+				WHERE ac.attribute_id = a.id
+			), '{}')
+		FROM attributes a
+		WHERE %s
+		ORDER BY a.position, a.id
+	`, condition)
+
+	rows, err := db.Query(c, query, args...)
+	if err != nil {
+		return nil, Parse(err, "Attribute", method, make(Constraints))
+	}
+	defer rows.Close()
+
+	var (
+		attributes []models.Attribute
+		ids        []int32
+	)
+	for rows.Next() {
+		var a models.Attribute
+		err = rows.Scan(
+			&a.ID,
+			&a.Code,
+			&a.Name,
+			&a.Type,
+			&a.Unit,
+			&a.Filterable,
+			&a.Position,
+			&a.CategoryIDs,
+		)
+		if err != nil {
+			return nil, Parse(err, "Attribute", method, make(Constraints))
+		}
+		a.Options = []models.AttributeOption{}
+		attributes = append(attributes, a)
+		ids = append(ids, a.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Attribute", method, make(Constraints))
+	}
+
+	if len(attributes) == 0 {
+		return attributes, nil
+	}
+
+	query = `
+		SELECT id, value, position, attribute_id
+		FROM attribute_options
+		WHERE attribute_id = ANY($1)
+		ORDER BY position, id
+	`
+
+	optionRows, err := db.Query(c, query, ids)
+	if err != nil {
+		return nil, Parse(err, "Attribute", method, make(Constraints))
+	}
+	defer optionRows.Close()
+
+	byID := make(map[int32]*models.Attribute, len(attributes))
+	for i := range attributes {
+		byID[attributes[i].ID] = &attributes[i]
+	}
+	for optionRows.Next() {
+		var o models.AttributeOption
+		if err = optionRows.Scan(&o.ID, &o.Value, &o.Position, &o.AttributeID); err != nil {
+			return nil, Parse(err, "Attribute", method, make(Constraints))
+		}
+		a := byID[o.AttributeID]
+		a.Options = append(a.Options, o)
+	}
+	if err = optionRows.Err(); err != nil {
+		return nil, Parse(err, "Attribute", method, make(Constraints))
+	}
+
+	return attributes, nil
+}
+
+func (repo *attributeRepo) GetAll(
+	c *gin.Context,
+	db Querier,
+	categoryID int32,
+) ([]models.Attribute, error) {
+	return getAttributes(c, db, "GetAll", `
+		$1 = 0
+		OR NOT EXISTS (SELECT 1 FROM attribute_categories ac WHERE ac.attribute_id = a.id)
+		OR EXISTS (
+			SELECT 1 FROM attribute_categories ac
+			WHERE ac.attribute_id = a.id AND $1 IN (SELECT category_subtree_ids(ac.category_id))
+		)
+	`, categoryID)
+}
+
+func (repo *attributeRepo) GetFilterable(c *gin.Context, db Querier) ([]models.Attribute, error) {
+	return getAttributes(c, db, "GetFilterable", "a.filterable")
+}
+
+func (repo *attributeRepo) Get(c *gin.Context, db Querier, id int32) (*models.Attribute, error) {
+	attributes, err := getAttributes(c, db, "Get", "a.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(attributes) == 0 {
+		return nil, Parse(pgx.ErrNoRows, "Attribute", "Get", make(Constraints))
+	}
+
+	return &attributes[0], nil
+}
+
+func (repo *attributeRepo) Create(c *gin.Context, db Querier, attribute *models.Attribute) error {
+	query := `
+		INSERT INTO attributes (code, name, type, unit, filterable, position)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	err := db.QueryRow(
+		c,
+		query,
+		attribute.Code,
+		attribute.Name,
+		attribute.Type,
+		attribute.Unit,
+		attribute.Filterable,
+		attribute.Position,
+	).Scan(&attribute.ID)
+	if err != nil {
+		return Parse(err, "Attribute", "Create", Constraints{
+			UniqueViolationCode:  "code",
+			NotNullViolationCode: "code or name or type",
+			CheckViolationCode:   "code or unit",
+		})
+	}
+
+	return nil
+}
+
+func (repo *attributeRepo) Update(c *gin.Context, db Querier, attribute *models.Attribute) error {
+	query := `
+		UPDATE attributes
+		SET name = $2, unit = $3, filterable = $4, position = $5
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(
+		c,
+		query,
+		attribute.ID,
+		attribute.Name,
+		attribute.Unit,
+		attribute.Filterable,
+		attribute.Position,
+	)
+	if err != nil {
+		return Parse(err, "Attribute", "Update", Constraints{
+			NotNullViolationCode: "name",
+			CheckViolationCode:   "unit",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Attribute", "Update", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *attributeRepo) SetCategories(
+	c *gin.Context,
+	db Querier,
+	id int32,
+	categoryIDs []int32,
+) error {
+	query := `
+		DELETE FROM attribute_categories
+		WHERE attribute_id = $1
+	`
+
+	_, err := db.Exec(c, query, id)
+	if err != nil {
+		return Parse(err, "Attribute", "SetCategories", make(Constraints))
+	}
+
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	query = `
+		INSERT INTO attribute_categories (attribute_id, category_id)
+		SELECT $1, category_id FROM unnest($2::int[]) AS category_id
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err = db.Exec(c, query, id, categoryIDs)
+	if err != nil {
+		return Parse(err, "Attribute", "SetCategories", Constraints{
+			ForeignKeyViolationCode: "attribute or category",
+		})
+	}
+
+	return nil
+}
+
+func (repo *attributeRepo) Delete(c *gin.Context, db Querier, id int32) error {
+	query := `
+		DELETE FROM attributes
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(c, query, id)
+	if err != nil {
+		return Parse(err, "Attribute", "Delete", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Attribute", "Delete", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *attributeRepo) CreateOption(
+	c *gin.Context,
+	db Querier,
+	option *models.AttributeOption,
+) error {
+	query := `
+		INSERT INTO attribute_options (value, attribute_id, position)
+		VALUES ($1, $2, (
+			SELECT COALESCE(MAX(position) + 1, 0) FROM attribute_options WHERE attribute_id = $2
+		))
+		RETURNING id, position
+	`
+
+	err := db.QueryRow(c, query, option.Value, option.AttributeID).
+		Scan(&option.ID, &option.Position)
+	if err != nil {
+		return Parse(err, "Attribute", "CreateOption", Constraints{
+			UniqueViolationCode:     "option value",
+			ForeignKeyViolationCode: "attribute",
+			NotNullViolationCode:    "option value",
+		})
+	}
+
+	return nil
+}
+
+func (repo *attributeRepo) DeleteOption(
+	c *gin.Context,
+	db Querier,
+	attributeID, optionID int32,
+) error {
+	query := `
+		DELETE FROM attribute_options
+		WHERE id = $1 AND attribute_id = $2
+	`
+
+	result, err := db.Exec(c, query, optionID, attributeID)
+	if err != nil {
+		return Parse(err, "Attribute", "DeleteOption", Constraints{
+			ForeignKeyViolationCode: "option",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Attribute", "DeleteOption", make(Constraints))
+	}
+
+	return nil
+}
+
+// ProductAttributeValue is an attribute value of a product as it's shown, text and
+// enum attributes have a Value, number attributes a Number in their Unit.
+type ProductAttributeValue struct {
+	AttributeID int32                `json:"attributeId"`
+	Code        string               `json:"code"`
+	Name        string               `json:"name"`
+	Type        models.AttributeType `json:"type"`
+	Unit        pgtype.Text          `json:"unit"`
+	Value       pgtype.Text          `json:"value"`
+	OptionID    pgtype.Int4          `json:"optionId"`
+	Number      pgtype.Float8        `json:"number"`
+}
+
+func (repo *attributeRepo) GetValuesOfProduct(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+) ([]ProductAttributeValue, error) {
+	query := `
+		SELECT
+			a.id, a.code, a.name, a.type, a.unit,
+			COALESCE(ao.value, pa.text_value), pa.option_id, pa.number_value
+		FROM product_attributes pa
+		JOIN attributes a ON a.id = pa.attribute_id
+		LEFT JOIN attribute_options ao ON ao.id = pa.option_id
+		WHERE pa.product_id = $1
+		ORDER BY a.position, a.id
+	`
+
+	rows, err := db.Query(c, query, productID)
+	if err != nil {
+		return nil, Parse(err, "Attribute", "GetValuesOfProduct", make(Constraints))
+	}
+	defer rows.Close()
+
+	values := []ProductAttributeValue{}
+	for rows.Next() {
+		var v ProductAttributeValue
+		err = rows.Scan(
+			&v.AttributeID,
+			&v.Code,
+			&v.Name,
+			&v.Type,
+			&v.Unit,
+			&v.Value,
+			&v.OptionID,
+			&v.Number,
+		)
+		if err != nil {
+			return nil, Parse(err, "Attribute", "GetValuesOfProduct", make(Constraints))
+		}
+		values = append(values, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Attribute", "GetValuesOfProduct", make(Constraints))
+	}
+
+	return values, nil
+}
+
+func (repo *attributeRepo) SetValuesOfProduct(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+	values []models.ProductAttribute,
+) error {
+	query := `
+		DELETE FROM product_attributes
+		WHERE product_id = $1
+	`
+
+	_, err := db.Exec(c, query, productID)
+	if err != nil {
+		return Parse(err, "Attribute", "SetValuesOfProduct", make(Constraints))
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	attributeIDs := make([]int32, len(values))
+	textValues := make([]pgtype.Text, len(values))
+	optionIDs := make([]pgtype.Int4, len(values))
+	numberValues := make([]pgtype.Float8, len(values))
+	for i, v := range values {
+		attributeIDs[i] = v.AttributeID
+		textValues[i] = v.TextValue
+		optionIDs[i] = v.OptionID
+		numberValues[i] = v.NumberValue
+	}
+
+	query = `
+		INSERT INTO product_attributes (product_id, attribute_id, text_value, option_id, number_value)
+		SELECT $1, v.attribute_id, v.text_value, v.option_id, v.number_value
+		FROM unnest($2::int[], $3::text[], $4::int[], $5::float8[])
+			AS v(attribute_id, text_value, option_id, number_value)
+	`
+
+	_, err = db.Exec(c, query, productID, attributeIDs, textValues, optionIDs, numberValues)
+	if err != nil {
+		return Parse(err, "Attribute", "SetValuesOfProduct", Constraints{
+			UniqueViolationCode:     "attribute",
+			ForeignKeyViolationCode: "product or attribute or option",
+			CheckViolationCode:      "value",
+		})
+	}
+
+	return nil
+}
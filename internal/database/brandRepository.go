@@ -1,9 +1,69 @@
 package database
 
-type BrandRepository interface{}
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type BrandRepository interface {
+	// This method will get all the brands ordered by name.
+	GetAll(c *gin.Context, db Querier) ([]models.Brand, error)
+
+	// This method will create a brand, by its name.
+	// Returns: the id of the created brand.
+	Create(c *gin.Context, db Querier, brand string) (int32, error)
+}
 
 type brandRepo struct{}
 
 func NewBrandRepository() BrandRepository {
 	return &brandRepo{}
 }
+
+func (repo *brandRepo) GetAll(c *gin.Context, db Querier) ([]models.Brand, error) {
+	query := `
+		SELECT id, brand
+		FROM brands
+		ORDER BY brand
+	`
+
+	rows, err := db.Query(c, query)
+	if err != nil {
+		return nil, Parse(err, "Brand", "GetAll", make(Constraints))
+	}
+	defer rows.Close()
+
+	var brands []models.Brand
+	for rows.Next() {
+		var b models.Brand
+		if err = rows.Scan(&b.ID, &b.Brand); err != nil {
+			return nil, Parse(err, "Brand", "GetAll", make(Constraints))
+		}
+		brands = append(brands, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Brand", "GetAll", make(Constraints))
+	}
+
+	return brands, nil
+}
+
+func (repo *brandRepo) Create(c *gin.Context, db Querier, brand string) (int32, error) {
+	query := `
+		INSERT INTO brands (brand)
+		VALUES ($1)
+		RETURNING id
+	`
+
+	var id int32
+	err := db.QueryRow(c, query, brand).Scan(&id)
+	if err != nil {
+		return 0, Parse(err, "Brand", "Create", Constraints{
+			UniqueViolationCode:  "brand",
+			NotNullViolationCode: "brand",
+		})
+	}
+
+	return id, nil
+}
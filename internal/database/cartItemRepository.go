@@ -74,7 +74,8 @@ func (r *cartItemRepo) GetPriceQuantityByCartID(
 			COALESCE(SUM(cart_items.quantity), 0) AS total_quantity
 		FROM cart_items
 		JOIN product_variants on cart_items.product_id = product_variants.id
-		WHERE cart_id = $1
+		JOIN products on products.id = product_variants.product_id
+		WHERE cart_id = $1 AND product_variants.deleted_at IS NULL AND products.deleted_at IS NULL
 	`
 
 	var totalPrice int
@@ -120,7 +121,8 @@ func (r cartItemRepo) GetAll(
 		FROM cart_items
 		JOIN product_variants on product_variants.id = cart_items.product_id
 		JOIN products on products.id = product_variants.product_id
-		WHERE cart_items.cart_id = $1	
+		WHERE cart_items.cart_id = $1
+		AND product_variants.deleted_at IS NULL AND products.deleted_at IS NULL
 	`
 	var cartItems []GetCartItems
 	rows, err := db.Query(ctx, query, cartID)
@@ -1,24 +1,61 @@
 package database
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/models"
 )
 
 type CategoryRepository interface {
 	// Create new category,
 	// required columns: name, parent_id.
+	// The slug is generated out of the name by the database.
 	Create(ctx *gin.Context, db Querier, category *models.Category) (int32, error)
 
 	// Get all categories
 	GetAll(ctx *gin.Context, db Querier) (*[]models.Category, error)
 
-	// Delete category by id.
+	// Get category by its current slug.
+	GetBySlug(ctx *gin.Context, db Querier, slug string) (*models.Category, error)
+
+	// Get all categories nested under their parents,
+	// siblings are sorted by name.
+	GetTree(ctx *gin.Context, db Querier) ([]*CategoryNode, error)
+
+	// Get the category and its ancestors by id,
+	// ordered from the root down to the category itself.
+	GetPath(ctx *gin.Context, db Querier, id int32) ([]models.Category, error)
+
+	// Soft delete category by id,
+	// it fails with a foreign key violation while the category still has subcategories or products.
 	Delete(ctx *gin.Context, db Querier, id int32) error
 
-	// Update category name by id
+	// Restore a soft deleted category by id,
+	// it fails with a foreign key violation while its parent is deleted.
+	Restore(ctx *gin.Context, db Querier, id int32) error
+
+	// This method will permanently delete the categories soft deleted before the given time,
+	// a category is kept as long as any product or subcategory, even a deleted one, is still under it.
+	// Returns: the number of purged categories.
+	PurgeDeleted(ctx context.Context, db Querier, before time.Time) (int64, error)
+
+	// This method will move the subcategories and the products of a category
+	// to the category's parent, by id.
+	// The products of a root category have no parent to move to, so it fails with a not null violation.
+	Reparent(ctx *gin.Context, db Querier, id int32) error
+
+	// Update category name by id,
+	// the category gets a new slug and the old one keeps redirecting to it.
 	Update(ctx *gin.Context, db Querier, id int32, newName string) error
+
+	// This method will update the parent_id column, a null parent makes it a root category.
+	// It fails with a check violation if the new parent is the category itself or one of its descendants.
+	// By: id.
+	Move(ctx *gin.Context, db Querier, id int32, parentID pgtype.Int4) error
 }
 
 type categoryRepo struct{}
@@ -53,8 +90,9 @@ func (r *categoryRepo) Create(
 
 func (r *categoryRepo) GetAll(ctx *gin.Context, db Querier) (*[]models.Category, error) {
 	query := `
-		SELECT id, name, parent_id
+		SELECT id, name, slug, parent_id
 		FROM categories
+		WHERE deleted_at IS NULL
 	`
 
 	rows, err := db.Query(ctx, query)
@@ -68,6 +106,7 @@ func (r *categoryRepo) GetAll(ctx *gin.Context, db Querier) (*[]models.Category,
 		err = rows.Scan(
 			&i.ID,
 			&i.Name,
+			&i.Slug,
 			&i.ParentID,
 		)
 		if err != nil {
@@ -83,10 +122,129 @@ func (r *categoryRepo) GetAll(ctx *gin.Context, db Querier) (*[]models.Category,
 	return &categories, nil
 }
 
+func (r *categoryRepo) GetBySlug(
+	ctx *gin.Context,
+	db Querier,
+	slug string,
+) (*models.Category, error) {
+	query := `
+		SELECT id, name, slug, parent_id
+		FROM categories
+		WHERE slug = $1 AND deleted_at IS NULL
+	`
+
+	var c models.Category
+	err := db.QueryRow(ctx, query, slug).Scan(&c.ID, &c.Name, &c.Slug, &c.ParentID)
+	if err != nil {
+		return nil, Parse(err, "Category", "GetBySlug", make(Constraints))
+	}
+
+	return &c, nil
+}
+
+type CategoryNode struct {
+	ID       int32           `json:"id"`
+	Name     string          `json:"name"`
+	Slug     string          `json:"slug"`
+	ParentID pgtype.Int4     `json:"parentId"`
+	Depth    int             `json:"depth"`
+	Children []*CategoryNode `json:"children"`
+}
+
+func (r *categoryRepo) GetTree(ctx *gin.Context, db Querier) ([]*CategoryNode, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, name, slug, parent_id, 0 AS depth, ARRAY[name]::VARCHAR[] AS path
+			FROM categories
+			WHERE parent_id IS NULL AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id, c.name, c.slug, c.parent_id, t.depth + 1, t.path || c.name
+			FROM categories c
+			JOIN tree t ON c.parent_id = t.id
+			WHERE c.deleted_at IS NULL
+		)
+		SELECT id, name, slug, parent_id, depth
+		FROM tree
+		ORDER BY path
+	`
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, Parse(err, "Category", "GetTree", make(Constraints))
+	}
+	defer rows.Close()
+
+	// ordering by path puts every parent before its children,
+	// so a child's parent node always exists by the time we reach it.
+	var roots []*CategoryNode
+	nodes := make(map[int32]*CategoryNode)
+	for rows.Next() {
+		node := &CategoryNode{Children: []*CategoryNode{}}
+		err = rows.Scan(&node.ID, &node.Name, &node.Slug, &node.ParentID, &node.Depth)
+		if err != nil {
+			return nil, Parse(err, "Category", "GetTree", make(Constraints))
+		}
+		nodes[node.ID] = node
+
+		if parent, ok := nodes[node.ParentID.Int32]; node.ParentID.Valid && ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Category", "GetTree", make(Constraints))
+	}
+
+	return roots, nil
+}
+
+func (r *categoryRepo) GetPath(ctx *gin.Context, db Querier, id int32) ([]models.Category, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, name, slug, parent_id, 0 AS depth
+			FROM categories
+			WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id, c.name, c.slug, c.parent_id, a.depth + 1
+			FROM categories c
+			JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id, name, slug, parent_id
+		FROM ancestors
+		ORDER BY depth DESC
+	`
+
+	rows, err := db.Query(ctx, query, id)
+	if err != nil {
+		return nil, Parse(err, "Category", "GetPath", make(Constraints))
+	}
+	defer rows.Close()
+
+	var path []models.Category
+	for rows.Next() {
+		var c models.Category
+		if err = rows.Scan(&c.ID, &c.Name, &c.Slug, &c.ParentID); err != nil {
+			return nil, Parse(err, "Category", "GetPath", make(Constraints))
+		}
+		path = append(path, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Category", "GetPath", make(Constraints))
+	}
+
+	if len(path) == 0 {
+		return nil, Parse(pgx.ErrNoRows, "Category", "GetPath", make(Constraints))
+	}
+
+	return path, nil
+}
+
 func (r *categoryRepo) Delete(ctx *gin.Context, db Querier, id int32) error {
 	query := `
-		DELETE FROM categories 
-		WHERE id = $1
+		UPDATE categories
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	result, err := db.Exec(ctx, query, id)
 	if err != nil {
@@ -100,13 +258,84 @@ func (r *categoryRepo) Delete(ctx *gin.Context, db Querier, id int32) error {
 	return nil
 }
 
+func (r *categoryRepo) Restore(ctx *gin.Context, db Querier, id int32) error {
+	query := `
+		UPDATE categories
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+	result, err := db.Exec(ctx, query, id)
+	if err != nil {
+		return Parse(err, "Category", "Restore", Constraints{
+			ForeignKeyViolationCode: "parent, the parent category is deleted",
+			UniqueViolationCode:     "name",
+		})
+	}
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Category", "Restore", make(Constraints))
+	}
+	return nil
+}
+
+func (r *categoryRepo) PurgeDeleted(
+	ctx context.Context,
+	db Querier,
+	before time.Time,
+) (int64, error) {
+	query := `
+		DELETE FROM categories c
+		WHERE c.deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM categories child WHERE child.parent_id = c.id)
+		AND NOT EXISTS (SELECT 1 FROM products p WHERE p.product_category = c.id)
+	`
+
+	// a purged category may have been the last thing keeping its deleted parent,
+	// so it goes on level by level until there's nothing left to purge.
+	var purged int64
+	for {
+		result, err := db.Exec(ctx, query, before)
+		if err != nil {
+			return purged, Parse(err, "Category", "PurgeDeleted", make(Constraints))
+		}
+		if result.RowsAffected() == 0 {
+			return purged, nil
+		}
+		purged += result.RowsAffected()
+	}
+}
+
+func (r *categoryRepo) Reparent(ctx *gin.Context, db Querier, id int32) error {
+	query := `
+		WITH category AS (
+			SELECT parent_id FROM categories WHERE id = $1
+		), moved_children AS (
+			UPDATE categories
+			SET parent_id = (SELECT parent_id FROM category)
+			WHERE parent_id = $1
+		)
+		UPDATE products
+		SET product_category = (SELECT parent_id FROM category)
+		WHERE product_category = $1
+	`
+
+	_, err := db.Exec(ctx, query, id)
+	if err != nil {
+		return Parse(err, "Category", "Reparent", Constraints{
+			NotNullViolationCode: "parent category",
+			UniqueViolationCode:  "name",
+		})
+	}
+
+	return nil
+}
+
 func (r *categoryRepo) Update(
 	ctx *gin.Context, db Querier, id int32, newName string,
 ) error {
 	query := `
 		UPDATE categories
 		SET name = $2
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	result, err := db.Exec(ctx, query, id, newName)
@@ -121,3 +350,29 @@ func (r *categoryRepo) Update(
 	}
 	return nil
 }
+
+func (r *categoryRepo) Move(
+	ctx *gin.Context,
+	db Querier,
+	id int32,
+	parentID pgtype.Int4,
+) error {
+	query := `
+		UPDATE categories
+		SET parent_id = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := db.Exec(ctx, query, id, parentID)
+	if err != nil {
+		return Parse(err, "Category", "Move", Constraints{
+			CheckViolationCode:      "parent, a category can't be moved under itself or its subcategories",
+			ForeignKeyViolationCode: "parent, it doesn't exist or is deleted",
+			UniqueViolationCode:     "name",
+		})
+	}
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Category", "Move", make(Constraints))
+	}
+	return nil
+}
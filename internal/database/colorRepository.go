@@ -1,6 +1,9 @@
 package database
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/refine-software/afrad-api/internal/models"
@@ -16,8 +19,17 @@ type ColorRepository interface {
 	// Update color by id
 	Update(ctx *gin.Context, db Querier, id int32, color string) error
 
-	// Delete color by id
+	// Soft delete color by id,
+	// it fails with a foreign key violation while variants still use the color.
 	Delete(ctx *gin.Context, db Querier, id int32) error
+
+	// Restore a soft deleted color by id
+	Restore(ctx *gin.Context, db Querier, id int32) error
+
+	// This method will permanently delete the colors soft deleted before the given time,
+	// a color is kept as long as any variant, even a deleted one, still uses it.
+	// Returns: the number of purged colors.
+	PurgeDeleted(ctx context.Context, db Querier, before time.Time) (int64, error)
 }
 
 type colorRepo struct{}
@@ -48,6 +60,7 @@ func (r *colorRepo) GetAll(ctx *gin.Context, db Querier) (*[]models.Color, error
 	query := `
 		SELECT id, color
 		FROM colors
+		WHERE deleted_at IS NULL
 	`
 	rows, err := db.Query(ctx, query)
 	if err != nil {
@@ -77,7 +90,7 @@ func (r *colorRepo) Update(ctx *gin.Context, db Querier, id int32, color string)
 	query := `
 		UPDATE colors
 		SET color = $2
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	result, err := db.Exec(ctx, query, id, color)
 	if err != nil {
@@ -96,15 +109,45 @@ func (r *colorRepo) Update(ctx *gin.Context, db Querier, id int32, color string)
 
 func (r *colorRepo) Delete(ctx *gin.Context, db Querier, id int32) error {
 	query := `
-		DELETE FROM colors
-		WHERE id = $1
+		UPDATE colors
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	result, err := db.Exec(ctx, query, id)
 	if err != nil {
-		return Parse(err, "Color", "Delete", Constraints{ForeignKeyViolationCode: "id"})
+		return Parse(err, "Color", "Delete", Constraints{ForeignKeyViolationCode: "color"})
 	}
 	if result.RowsAffected() == 0 {
 		return Parse(pgx.ErrNoRows, "Color", "Delete", make(Constraints))
 	}
 	return nil
 }
+
+func (r *colorRepo) Restore(ctx *gin.Context, db Querier, id int32) error {
+	query := `
+		UPDATE colors
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+	result, err := db.Exec(ctx, query, id)
+	if err != nil {
+		return Parse(err, "Color", "Restore", Constraints{UniqueViolationCode: "color"})
+	}
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Color", "Restore", make(Constraints))
+	}
+	return nil
+}
+
+func (r *colorRepo) PurgeDeleted(ctx context.Context, db Querier, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM colors c
+		WHERE c.deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM product_variants pv WHERE pv.color_id = c.id)
+	`
+	result, err := db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, Parse(err, "Color", "PurgeDeleted", make(Constraints))
+	}
+	return result.RowsAffected(), nil
+}
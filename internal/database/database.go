@@ -42,6 +42,18 @@ type Service interface {
 	User() UserRepository
 	Session() SessionRepository
 	Wishlist() WishlistRepository
+	APIKey() APIKeyRepository
+	SlugRedirect() SlugRedirectRepository
+	ImageJob() ImageJobRepository
+	Upload() UploadRepository
+	Attribute() AttributeRepository
+	SizeChart() SizeChartRepository
+	Recommendation() RecommendationRepository
+	ProductEvent() ProductEventRepository
+	Notification() NotificationRepository
+	Question() QuestionRepository
+	Stock() StockRepository
+	StockSubscription() StockSubscriptionRepository
 	Pool() *pgxpool.Pool
 	// Make sure to use this method when all errors being returned are db errors.
 	// you can use it when other errors are being returned but still.
@@ -77,6 +89,18 @@ type service struct {
 	oAuthRepo                   OAuthRepository
 	userRepo                    UserRepository
 	wishlistRepo                WishlistRepository
+	apiKeyRepo                  APIKeyRepository
+	slugRedirectRepo            SlugRedirectRepository
+	imageJobRepository          ImageJobRepository
+	uploadRepository            UploadRepository
+	attributeRepository         AttributeRepository
+	sizeChartRepository         SizeChartRepository
+	recommendationRepository    RecommendationRepository
+	productEventRepo            ProductEventRepository
+	notificationRepo            NotificationRepository
+	questionRepo                QuestionRepository
+	stockRepo                   StockRepository
+	stockSubscriptionRepo       StockSubscriptionRepository
 	db                          *pgxpool.Pool
 }
 
@@ -122,6 +146,18 @@ func New(env *config.Env) Service {
 		orderRepo:                   NewOrderRepository(),
 		orderDetailsRepo:            NewOrderDetailsRepository(),
 		cityRepo:                    NewCityRepository(),
+		apiKeyRepo:                  NewAPIKeyRepository(),
+		slugRedirectRepo:            NewSlugRedirectRepository(),
+		imageJobRepository:          NewImageJobRepository(),
+		uploadRepository:            NewUploadRepository(),
+		attributeRepository:         NewAttributeRepository(),
+		sizeChartRepository:         NewSizeChartRepository(),
+		recommendationRepository:    NewRecommendationRepository(),
+		productEventRepo:            NewProductEventRepository(),
+		notificationRepo:            NewNotificationRepository(),
+		questionRepo:                NewQuestionRepository(),
+		stockRepo:                   NewStockRepository(),
+		stockSubscriptionRepo:       NewStockSubscriptionRepository(),
 	}
 
 	return dbInstance
@@ -207,6 +243,54 @@ func (s *service) City() CityRepository {
 	return s.cityRepo
 }
 
+func (s *service) APIKey() APIKeyRepository {
+	return s.apiKeyRepo
+}
+
+func (s *service) SlugRedirect() SlugRedirectRepository {
+	return s.slugRedirectRepo
+}
+
+func (s *service) ImageJob() ImageJobRepository {
+	return s.imageJobRepository
+}
+
+func (s *service) Upload() UploadRepository {
+	return s.uploadRepository
+}
+
+func (s *service) Attribute() AttributeRepository {
+	return s.attributeRepository
+}
+
+func (s *service) SizeChart() SizeChartRepository {
+	return s.sizeChartRepository
+}
+
+func (s *service) Recommendation() RecommendationRepository {
+	return s.recommendationRepository
+}
+
+func (s *service) ProductEvent() ProductEventRepository {
+	return s.productEventRepo
+}
+
+func (s *service) Notification() NotificationRepository {
+	return s.notificationRepo
+}
+
+func (s *service) Question() QuestionRepository {
+	return s.questionRepo
+}
+
+func (s *service) Stock() StockRepository {
+	return s.stockRepo
+}
+
+func (s *service) StockSubscription() StockSubscriptionRepository {
+	return s.stockSubscriptionRepo
+}
+
 func (s *service) Pool() *pgxpool.Pool {
 	return s.db
 }
@@ -139,3 +139,35 @@ func IsDBNotFoundErr(err error) bool {
 
 	return dbErr.Message == ErrNotFound
 }
+
+func IsDBForeignKeyErr(err error) bool {
+	var dbErr DBError
+	ok := errors.As(err, &dbErr)
+	if !ok {
+		return false
+	}
+
+	return dbErr.Message == ErrForeignKey
+}
+
+func IsDBCheckErr(err error) bool {
+	var dbErr DBError
+	ok := errors.As(err, &dbErr)
+	if !ok {
+		return false
+	}
+
+	return dbErr.Message == ErrCheckViolation
+}
+
+// IsDBConstraintErr reports whether the error is a violation of the named constraint,
+// for a table with several constraints of the same kind.
+func IsDBConstraintErr(err error, constraint string) bool {
+	var dbErr DBError
+	if !errors.As(err, &dbErr) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	return errors.As(dbErr.Err, &pgErr) && pgErr.ConstraintName == constraint
+}
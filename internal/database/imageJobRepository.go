@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type ImageJobRepository interface {
+	// This method will queue the processing of an image, by its id.
+	Create(c *gin.Context, db Querier, imageID int32) error
+
+	// This method will claim the next due job for lockFor, a job isn't claimed by another
+	// worker until then, so a crashed worker's job is picked up again once it passes.
+	// Every claim counts as an attempt, jobs that used up maxAttempts aren't claimed.
+	// Returns: the job along with the url of the image original, or its source url
+	// when it's an imported image that isn't fetched yet.
+	Claim(ctx context.Context, db Querier, lockFor time.Duration, maxAttempts int) (ImageJob, error)
+
+	// This method will stop the jobs that used up maxAttempts without a worker reporting back,
+	// their lock passed since the worker crashed, and mark their images failed.
+	// Returns: the number of failed jobs.
+	FailExhausted(ctx context.Context, db Querier, maxAttempts int) (int64, error)
+
+	// This method will delete a done job, by id.
+	Delete(ctx context.Context, db Querier, jobID int32) error
+
+	// This method will record the job error and schedule another attempt with a growing
+	// backoff. Once maxAttempts are used up the job isn't scheduled anymore and its
+	// image is marked failed.
+	// Returns: whether the image failed for good.
+	Fail(
+		ctx context.Context,
+		db Querier,
+		jobID int32,
+		lastError string,
+		maxAttempts int,
+	) (failed bool, err error)
+
+	// This method will reschedule the job of a failed image of the product right away,
+	// with its attempts reset, and mark the image processing again.
+	Retry(c *gin.Context, db Querier, productID, imageID int32) error
+}
+
+type imageJobRepo struct{}
+
+func NewImageJobRepository() ImageJobRepository {
+	return &imageJobRepo{}
+}
+
+type ImageJob struct {
+	ID        int32
+	ImageID   int32
+	Attempts  int
+	Original  string
+	SourceURL string
+}
+
+func (repo *imageJobRepo) Create(c *gin.Context, db Querier, imageID int32) error {
+	query := `
+		INSERT INTO image_jobs (image_id)
+		VALUES ($1)
+	`
+
+	_, err := db.Exec(c, query, imageID)
+	if err != nil {
+		return Parse(err, "Image Job", "Create", Constraints{
+			UniqueViolationCode:     "image_id",
+			ForeignKeyViolationCode: "image",
+		})
+	}
+
+	return nil
+}
+
+func (repo *imageJobRepo) Claim(
+	ctx context.Context,
+	db Querier,
+	lockFor time.Duration,
+	maxAttempts int,
+) (ImageJob, error) {
+	// SKIP LOCKED lets several workers claim different jobs at the same time.
+	query := `
+		UPDATE image_jobs
+		SET attempts = attempts + 1, locked_until = NOW() + make_interval(secs => $1)
+		FROM images
+		WHERE images.id = image_jobs.image_id AND image_jobs.id = (
+			SELECT id FROM image_jobs
+			WHERE run_at <= NOW() AND (locked_until IS NULL OR locked_until < NOW())
+				AND attempts < $2
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING
+			image_jobs.id,
+			image_jobs.image_id,
+			image_jobs.attempts,
+			COALESCE(images.original, ''),
+			COALESCE(images.source_url, '')
+	`
+
+	var job ImageJob
+	err := db.QueryRow(ctx, query, lockFor.Seconds(), maxAttempts).
+		Scan(&job.ID, &job.ImageID, &job.Attempts, &job.Original, &job.SourceURL)
+	if err != nil {
+		return ImageJob{}, Parse(err, "Image Job", "Claim", make(Constraints))
+	}
+
+	return job, nil
+}
+
+func (repo *imageJobRepo) FailExhausted(
+	ctx context.Context,
+	db Querier,
+	maxAttempts int,
+) (int64, error) {
+	query := `
+		WITH job AS (
+			UPDATE image_jobs
+			SET
+				last_error = COALESCE(last_error, 'the worker stopped before finishing'),
+				locked_until = NULL,
+				run_at = NULL
+			WHERE run_at IS NOT NULL AND attempts >= $1
+				AND (locked_until IS NULL OR locked_until < NOW())
+			RETURNING image_id
+		)
+		UPDATE images
+		SET status = 'failed'
+		FROM job
+		WHERE images.id = job.image_id
+	`
+
+	result, err := db.Exec(ctx, query, maxAttempts)
+	if err != nil {
+		return 0, Parse(err, "Image Job", "FailExhausted", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (repo *imageJobRepo) Delete(ctx context.Context, db Querier, jobID int32) error {
+	query := `
+		DELETE FROM image_jobs
+		WHERE id = $1
+	`
+
+	_, err := db.Exec(ctx, query, jobID)
+	if err != nil {
+		return Parse(err, "Image Job", "Delete", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *imageJobRepo) Fail(
+	ctx context.Context,
+	db Querier,
+	jobID int32,
+	lastError string,
+	maxAttempts int,
+) (failed bool, err error) {
+	query := `
+		WITH job AS (
+			UPDATE image_jobs
+			SET
+				last_error = $2,
+				locked_until = NULL,
+				run_at = CASE
+					WHEN attempts >= $3 THEN NULL
+					ELSE NOW() + make_interval(mins => attempts * attempts)
+				END
+			WHERE id = $1
+			RETURNING image_id, run_at
+		), failed_image AS (
+			UPDATE images
+			SET status = 'failed'
+			FROM job
+			WHERE images.id = job.image_id AND job.run_at IS NULL
+		)
+		SELECT run_at IS NULL FROM job
+	`
+
+	err = db.QueryRow(ctx, query, jobID, lastError, maxAttempts).Scan(&failed)
+	if err != nil {
+		return false, Parse(err, "Image Job", "Fail", make(Constraints))
+	}
+
+	return failed, nil
+}
+
+func (repo *imageJobRepo) Retry(c *gin.Context, db Querier, productID, imageID int32) error {
+	query := `
+		WITH retried AS (
+			UPDATE images
+			SET status = 'processing'
+			WHERE id = $1 AND product_id = $2 AND status = 'failed'
+			RETURNING id
+		)
+		UPDATE image_jobs
+		SET attempts = 0, run_at = NOW(), locked_until = NULL
+		FROM retried
+		WHERE image_jobs.image_id = retried.id
+	`
+
+	result, err := db.Exec(c, query, imageID, productID)
+	if err != nil {
+		return Parse(err, "Image Job", "Retry", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Image Job", "Retry", make(Constraints))
+	}
+
+	return nil
+}
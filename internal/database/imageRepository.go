@@ -1,21 +1,58 @@
 package database
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/models"
 )
 
 type ImageRepository interface {
+	// This method will get the product gallery images ordered by their position,
+	// along with their renditions. readyOnly leaves out the images still processing or failed.
 	GetAllOfProduct(
 		c *gin.Context,
 		db Querier,
 		productID int32,
+		readyOnly bool,
 	) ([]models.Image, error)
 
-	// This method will create a record in the images table.
+	// This method will get an image of the product along with its renditions, by id.
+	Get(c *gin.Context, db Querier, productID, imageID int32) (models.Image, error)
+
+	// This method will create a record in the images table for an original upload,
+	// or the source url of an imported image, placed after the product's other images.
+	// It's processing until its renditions are made.
 	//
-	// Columns required: image, low_res_image, product_id.
+	// Columns required: original or source_url, alt_text, color_id, product_id.
+	// Returns: id, position and status set on the image.
 	Create(*gin.Context, Querier, *models.Image) error
+
+	// This method will set the original of a processing image fetched from its source url.
+	SetOriginal(ctx context.Context, db Querier, imageID int32, original string) error
+
+	// This method will set the urls and placeholder of a processing image, create
+	// a record in the image_renditions table for each of its srcset and mark it ready.
+	// The original is cleared, the caller deletes its object. A product whose thumbnail
+	// is still the image source url gets the image as its thumbnail.
+	//
+	// Columns required: image, low_res_image, blurhash, dominant_color.
+	// By: id.
+	CompleteProcessing(ctx context.Context, db Querier, i *models.Image) error
+
+	// This method will update the image alt text and the color it shows.
+	//
+	// Columns required: alt_text, color_id.
+	// By: id, product_id.
+	Update(*gin.Context, Querier, *models.Image) error
+
+	// This method will order the product images as given, the first id gets position 0.
+	Reorder(c *gin.Context, db Querier, productID int32, imageIDs []int32) error
+
+	// This method will delete an image of the product, by id.
+	Delete(c *gin.Context, db Querier, productID, imageID int32) error
 }
 
 type imageRepo struct{}
@@ -28,38 +65,114 @@ func (repo *imageRepo) GetAllOfProduct(
 	c *gin.Context,
 	db Querier,
 	productID int32,
+	readyOnly bool,
 ) ([]models.Image, error) {
 	query := `
-		SELECT id, image, low_res_image
-		FROM images
-		WHERE product_id = $1
+		SELECT
+			i.id, i.status, COALESCE(i.image, ''), COALESCE(i.low_res_image, ''), i.original,
+			i.blurhash, i.dominant_color, i.position, i.alt_text, i.color_id, i.product_id,
+			r.url, r.width, r.height
+		FROM images i
+		LEFT JOIN image_renditions r ON r.image_id = i.id
+		WHERE i.product_id = $1 AND (NOT $2 OR i.status = 'ready')
+		ORDER BY i.position, i.id, r.width
 	`
 
-	rows, err := db.Query(c, query, productID)
+	rows, err := db.Query(c, query, productID, readyOnly)
 	if err != nil {
 		return nil, Parse(err, "Image", "GetAllOfProduct", make(Constraints))
 	}
+
+	imgs, err := scanImagesWithRenditions(rows)
+	if err != nil {
+		return nil, Parse(err, "Image", "GetAllOfProduct", make(Constraints))
+	}
+
+	return imgs, nil
+}
+
+// scanImagesWithRenditions folds the rows of images joined with their renditions,
+// ordered by image, into the images with their srcset.
+func scanImagesWithRenditions(rows pgx.Rows) ([]models.Image, error) {
 	defer rows.Close()
 
 	var imgs []models.Image
 	for rows.Next() {
-		var img models.Image
-		err = rows.Scan(
+		var (
+			img    models.Image
+			url    pgtype.Text
+			width  pgtype.Int4
+			height pgtype.Int4
+		)
+		err := rows.Scan(
 			&img.ID,
+			&img.Status,
 			&img.Image,
 			&img.LowResImage,
+			&img.Original,
+			&img.Blurhash,
+			&img.DominantColor,
+			&img.Position,
+			&img.AltText,
+			&img.ColorID,
+			&img.ProductID,
+			&url,
+			&width,
+			&height,
 		)
 		if err != nil {
-			return nil, Parse(err, "Image", "GetAllOfProduct", make(Constraints))
+			return nil, err
+		}
+
+		if len(imgs) == 0 || imgs[len(imgs)-1].ID != img.ID {
+			img.Srcset = []models.ImageRendition{}
+			imgs = append(imgs, img)
+		}
+
+		if url.Valid {
+			last := &imgs[len(imgs)-1]
+			last.Srcset = append(last.Srcset, models.ImageRendition{
+				URL:    url.String,
+				Width:  width.Int32,
+				Height: height.Int32,
+			})
 		}
-		imgs = append(imgs, img)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, Parse(err, "Image", "GetAllOfProduct", make(Constraints))
+	return imgs, rows.Err()
+}
+
+func (repo *imageRepo) Get(
+	c *gin.Context,
+	db Querier,
+	productID, imageID int32,
+) (models.Image, error) {
+	query := `
+		SELECT
+			i.id, i.status, COALESCE(i.image, ''), COALESCE(i.low_res_image, ''), i.original,
+			i.blurhash, i.dominant_color, i.position, i.alt_text, i.color_id, i.product_id,
+			r.url, r.width, r.height
+		FROM images i
+		LEFT JOIN image_renditions r ON r.image_id = i.id
+		WHERE i.id = $1 AND i.product_id = $2
+		ORDER BY r.width
+	`
+
+	rows, err := db.Query(c, query, imageID, productID)
+	if err != nil {
+		return models.Image{}, Parse(err, "Image", "Get", make(Constraints))
 	}
 
-	return imgs, nil
+	imgs, err := scanImagesWithRenditions(rows)
+	if err != nil {
+		return models.Image{}, Parse(err, "Image", "Get", make(Constraints))
+	}
+
+	if len(imgs) == 0 {
+		return models.Image{}, Parse(pgx.ErrNoRows, "Image", "Get", make(Constraints))
+	}
+
+	return imgs[0], nil
 }
 
 func (repo *imageRepo) Create(
@@ -68,18 +181,180 @@ func (repo *imageRepo) Create(
 	i *models.Image,
 ) error {
 	query := `
-		INSERT INTO images (image, low_res_image, product_id)
-		VALUES ($1, $2, $3)
+		INSERT INTO images (original, source_url, alt_text, color_id, product_id, position)
+		VALUES (
+			$1, $2, $3, $4, $5,
+			(SELECT COALESCE(MAX(position) + 1, 0) FROM images WHERE product_id = $5)
+		)
+		RETURNING id, position, status
 	`
 
-	_, err := db.Exec(c, query, i.Image, i.LowResImage, i.ProductID)
+	err := db.QueryRow(c, query, i.Original, i.SourceURL, i.AltText, i.ColorID, i.ProductID).
+		Scan(&i.ID, &i.Position, &i.Status)
 	if err != nil {
 		return Parse(err, "Image", "Create", Constraints{
-			UniqueViolationCode:     "image or low_res_image",
-			ForeignKeyViolationCode: "product",
-			NotNullViolationCode:    "image or low_res_image or product_id",
+			UniqueViolationCode:     "original",
+			ForeignKeyViolationCode: "product or color",
+			NotNullViolationCode:    "product_id",
+		})
+	}
+
+	return nil
+}
+
+func (repo *imageRepo) SetOriginal(
+	ctx context.Context,
+	db Querier,
+	imageID int32,
+	original string,
+) error {
+	query := `
+		UPDATE images
+		SET original = $2
+		WHERE id = $1 AND status = 'processing'
+	`
+
+	result, err := db.Exec(ctx, query, imageID, original)
+	if err != nil {
+		return Parse(err, "Image", "SetOriginal", Constraints{UniqueViolationCode: "original"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Image", "SetOriginal", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *imageRepo) CompleteProcessing(
+	ctx context.Context,
+	db Querier,
+	i *models.Image,
+) error {
+	query := `
+		WITH completed AS (
+			UPDATE images
+			SET
+				image = $2,
+				low_res_image = $3,
+				blurhash = $4,
+				dominant_color = $5,
+				original = NULL,
+				status = 'ready'
+			WHERE id = $1 AND status = 'processing'
+			RETURNING id, product_id, source_url
+		), thumbnail AS (
+			UPDATE products
+			SET thumbnail = $2
+			FROM completed
+			WHERE products.id = completed.product_id AND products.thumbnail = completed.source_url
+		), renditions AS (
+			INSERT INTO image_renditions (image_id, url, width, height)
+			SELECT completed.id, r.url, r.width, r.height
+			FROM completed, unnest($6::text[], $7::int[], $8::int[]) AS r(url, width, height)
+		)
+		SELECT id FROM completed
+	`
+
+	urls := make([]string, len(i.Srcset))
+	widths := make([]int32, len(i.Srcset))
+	heights := make([]int32, len(i.Srcset))
+	for idx, r := range i.Srcset {
+		urls[idx], widths[idx], heights[idx] = r.URL, r.Width, r.Height
+	}
+
+	err := db.QueryRow(
+		ctx,
+		query,
+		i.ID,
+		i.Image,
+		i.LowResImage,
+		i.Blurhash,
+		i.DominantColor,
+		urls,
+		widths,
+		heights,
+	).Scan(&i.ID)
+	if err != nil {
+		return Parse(err, "Image", "CompleteProcessing", Constraints{
+			UniqueViolationCode: "image or low_res_image or rendition",
+			CheckViolationCode:  "rendition width or height",
+		})
+	}
+
+	i.Status = models.ImageReady
+	i.Original = pgtype.Text{}
+	return nil
+}
+
+func (repo *imageRepo) Update(
+	c *gin.Context,
+	db Querier,
+	i *models.Image,
+) error {
+	query := `
+		UPDATE images
+		SET alt_text = $3, color_id = $4
+		WHERE id = $1 AND product_id = $2
+	`
+
+	result, err := db.Exec(c, query, i.ID, i.ProductID, i.AltText, i.ColorID)
+	if err != nil {
+		return Parse(err, "Image", "Update", Constraints{
+			ForeignKeyViolationCode: "color",
 		})
 	}
 
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Image", "Update", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *imageRepo) Reorder(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+	imageIDs []int32,
+) error {
+	query := `
+		UPDATE images
+		SET position = ordered.position - 1
+		FROM unnest($2::int[]) WITH ORDINALITY AS ordered(id, position)
+		WHERE images.id = ordered.id AND images.product_id = $1
+	`
+
+	result, err := db.Exec(c, query, productID, imageIDs)
+	if err != nil {
+		return Parse(err, "Image", "Reorder", make(Constraints))
+	}
+
+	if result.RowsAffected() != int64(len(imageIDs)) {
+		return Parse(pgx.ErrNoRows, "Image", "Reorder", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *imageRepo) Delete(
+	c *gin.Context,
+	db Querier,
+	productID, imageID int32,
+) error {
+	query := `
+		DELETE FROM images
+		WHERE id = $1 AND product_id = $2
+	`
+
+	result, err := db.Exec(c, query, imageID, productID)
+	if err != nil {
+		return Parse(err, "Image", "Delete", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Image", "Delete", make(Constraints))
+	}
+
 	return nil
 }
@@ -0,0 +1,96 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Folds the Arabic letter variants people mix up when typing (ا/أ/إ/آ, ي/ى, ه/ة),
+-- and drops tashkeel and tatweel, so both the stored text and the search term
+-- end up in the same form.
+CREATE OR REPLACE FUNCTION normalize_arabic(input TEXT)
+RETURNS TEXT AS $$
+	SELECT translate(lower(input), 'أإآٱىةـًٌٍَُِّْٰ', 'اااايه');
+$$ LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE;
+
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION products_search_vector_update()
+RETURNS TRIGGER AS $$
+DECLARE
+	brand_name TEXT;
+	category_name TEXT;
+BEGIN
+	SELECT brand INTO brand_name FROM brands WHERE id = NEW.brand_id;
+	SELECT name INTO category_name FROM categories WHERE id = NEW.product_category;
+
+	NEW.search_vector :=
+		setweight(to_tsvector('simple', normalize_arabic(COALESCE(NEW.name, ''))), 'A') ||
+		setweight(to_tsvector('simple', normalize_arabic(COALESCE(brand_name, ''))), 'B') ||
+		setweight(to_tsvector('simple', normalize_arabic(COALESCE(category_name, ''))), 'B') ||
+		setweight(to_tsvector('simple', normalize_arabic(COALESCE(NEW.details, ''))), 'C');
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_product_search_vector ON products;
+
+CREATE TRIGGER trigger_update_product_search_vector
+BEFORE INSERT OR UPDATE OF name, details, brand_id, product_category ON products
+FOR EACH ROW
+EXECUTE FUNCTION products_search_vector_update();
+
+-- Renaming a brand or a category has to be reflected in the products using it,
+-- touching the name column is enough to fire the trigger above.
+CREATE OR REPLACE FUNCTION refresh_brand_products_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+	UPDATE products SET name = name WHERE brand_id = NEW.id;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION refresh_category_products_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+	UPDATE products SET name = name WHERE product_category = NEW.id;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_refresh_brand_products_search_vector ON brands;
+DROP TRIGGER IF EXISTS trigger_refresh_category_products_search_vector ON categories;
+
+CREATE TRIGGER trigger_refresh_brand_products_search_vector
+AFTER UPDATE OF brand ON brands
+FOR EACH ROW
+WHEN (OLD.brand IS DISTINCT FROM NEW.brand)
+EXECUTE FUNCTION refresh_brand_products_search_vector();
+
+CREATE TRIGGER trigger_refresh_category_products_search_vector
+AFTER UPDATE OF name ON categories
+FOR EACH ROW
+WHEN (OLD.name IS DISTINCT FROM NEW.name)
+EXECUTE FUNCTION refresh_category_products_search_vector();
+
+-- backfill the existing products.
+UPDATE products SET name = name;
+
+CREATE INDEX IF NOT EXISTS products_search_vector_idx
+ON products USING GIN (search_vector);
+
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx
+ON products USING GIN (normalize_arabic(name) gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS products_name_trgm_idx;
+DROP INDEX IF EXISTS products_search_vector_idx;
+DROP TRIGGER IF EXISTS trigger_refresh_category_products_search_vector ON categories;
+DROP TRIGGER IF EXISTS trigger_refresh_brand_products_search_vector ON brands;
+DROP TRIGGER IF EXISTS trigger_update_product_search_vector ON products;
+DROP FUNCTION IF EXISTS refresh_category_products_search_vector;
+DROP FUNCTION IF EXISTS refresh_brand_products_search_vector;
+DROP FUNCTION IF EXISTS products_search_vector_update;
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
+DROP FUNCTION IF EXISTS normalize_arabic;
+-- +goose StatementEnd
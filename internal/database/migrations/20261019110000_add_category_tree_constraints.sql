@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE categories
+DROP CONSTRAINT IF EXISTS categories_parent_not_self;
+
+ALTER TABLE categories
+ADD CONSTRAINT categories_parent_not_self CHECK (parent_id IS NULL OR parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
+CREATE INDEX IF NOT EXISTS products_product_category_idx ON products (product_category);
+
+-- deleting a category used to delete all of its products,
+-- now the category has to be emptied or reparented first.
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS products_product_category_fkey;
+
+ALTER TABLE products
+ADD CONSTRAINT products_product_category_fkey
+FOREIGN KEY (product_category) REFERENCES categories(id) ON DELETE RESTRICT;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- category_subtree_ids returns the category and all of its descendants.
+CREATE OR REPLACE FUNCTION category_subtree_ids(root_id INT)
+RETURNS SETOF INT AS $$
+  WITH RECURSIVE subtree AS (
+    SELECT id FROM categories WHERE id = root_id
+    UNION ALL
+    SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+  )
+  SELECT id FROM subtree;
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- a category can't be moved under one of its own descendants,
+-- the tree stays acyclic so walking up the ancestors always ends.
+CREATE OR REPLACE FUNCTION categories_prevent_cycle()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF NEW.parent_id IS NOT NULL AND EXISTS (
+    SELECT 1 FROM category_subtree_ids(NEW.id) AS descendant_id
+    WHERE descendant_id = NEW.parent_id
+  ) THEN
+    RAISE EXCEPTION 'category % can not be moved under its own subtree', NEW.id
+      USING ERRCODE = 'check_violation';
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS categories_prevent_cycle ON categories;
+
+CREATE TRIGGER categories_prevent_cycle
+BEFORE UPDATE OF parent_id ON categories
+FOR EACH ROW
+EXECUTE FUNCTION categories_prevent_cycle();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS categories_prevent_cycle ON categories;
+DROP FUNCTION IF EXISTS categories_prevent_cycle();
+DROP FUNCTION IF EXISTS category_subtree_ids(INT);
+
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS products_product_category_fkey;
+
+ALTER TABLE products
+ADD CONSTRAINT products_product_category_fkey
+FOREIGN KEY (product_category) REFERENCES categories(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS products_product_category_idx;
+DROP INDEX IF EXISTS categories_parent_id_idx;
+
+ALTER TABLE categories
+DROP CONSTRAINT IF EXISTS categories_parent_not_self;
+-- +goose StatementEnd
@@ -0,0 +1,151 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Turns a name into a url safe slug, Latin letters are lowercased while Arabic letters are
+-- kept as is without tashkeel and tatweel, anything else becomes a single hyphen.
+CREATE OR REPLACE FUNCTION slugify(input TEXT)
+RETURNS TEXT AS $$
+	SELECT trim(BOTH '-' FROM regexp_replace(
+		regexp_replace(lower(input), '[\u0640\u064B-\u065F\u0670]', '', 'g'),
+		'[^a-z0-9\u0621-\u064A\u0660-\u0669\u06F0-\u06F9]+',
+		'-',
+		'g'
+	));
+$$ LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE;
+
+-- Old slugs keep resolving to the product or category they used to name.
+CREATE TABLE IF NOT EXISTS slug_redirects (
+	id SERIAL PRIMARY KEY,
+	entity_type VARCHAR NOT NULL,
+	slug VARCHAR NOT NULL,
+	entity_id INT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+	UNIQUE (entity_type, slug)
+);
+
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS slug VARCHAR;
+
+ALTER TABLE categories
+ADD COLUMN IF NOT EXISTS slug VARCHAR;
+
+-- Gives a row a unique slug out of its name whenever the name changes, a taken slug gets
+-- a -2, -3... suffix. The slug it had before is kept in slug_redirects.
+CREATE OR REPLACE FUNCTION set_slug()
+RETURNS TRIGGER AS $$
+DECLARE
+	base TEXT;
+	candidate TEXT;
+	suffix INT := 1;
+	taken BOOLEAN;
+BEGIN
+	IF TG_OP = 'UPDATE' AND NEW.name IS NOT DISTINCT FROM OLD.name AND NEW.slug IS NOT NULL THEN
+		RETURN NEW;
+	END IF;
+
+	base := COALESCE(NULLIF(slugify(NEW.name), ''), NEW.id::TEXT);
+	candidate := base;
+
+	LOOP
+		EXECUTE format(
+			'SELECT EXISTS (SELECT 1 FROM %I WHERE slug = $1 AND id <> $2)',
+			TG_TABLE_NAME
+		) INTO taken USING candidate, NEW.id;
+
+		IF NOT taken THEN
+			SELECT EXISTS (
+				SELECT 1 FROM slug_redirects
+				WHERE entity_type = TG_TABLE_NAME AND slug = candidate AND entity_id <> NEW.id
+			) INTO taken;
+		END IF;
+
+		EXIT WHEN NOT taken;
+
+		suffix := suffix + 1;
+		candidate := base || '-' || suffix;
+	END LOOP;
+
+	NEW.slug := candidate;
+
+	IF TG_OP = 'UPDATE' AND OLD.slug IS NOT NULL AND OLD.slug <> NEW.slug THEN
+		INSERT INTO slug_redirects (entity_type, slug, entity_id)
+		VALUES (TG_TABLE_NAME, OLD.slug, NEW.id)
+		ON CONFLICT (entity_type, slug) DO UPDATE SET entity_id = EXCLUDED.entity_id;
+
+		-- renaming back to an old name gives the old slug back.
+		DELETE FROM slug_redirects
+		WHERE entity_type = TG_TABLE_NAME AND slug = NEW.slug;
+	END IF;
+
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION delete_slug_redirects()
+RETURNS TRIGGER AS $$
+BEGIN
+	DELETE FROM slug_redirects
+	WHERE entity_type = TG_TABLE_NAME AND entity_id = OLD.id;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_set_slug ON products;
+CREATE TRIGGER products_set_slug
+BEFORE INSERT OR UPDATE OF name, slug ON products
+FOR EACH ROW
+EXECUTE FUNCTION set_slug();
+
+DROP TRIGGER IF EXISTS categories_set_slug ON categories;
+CREATE TRIGGER categories_set_slug
+BEFORE INSERT OR UPDATE OF name, slug ON categories
+FOR EACH ROW
+EXECUTE FUNCTION set_slug();
+
+DROP TRIGGER IF EXISTS products_delete_slug_redirects ON products;
+CREATE TRIGGER products_delete_slug_redirects
+AFTER DELETE ON products
+FOR EACH ROW
+EXECUTE FUNCTION delete_slug_redirects();
+
+DROP TRIGGER IF EXISTS categories_delete_slug_redirects ON categories;
+CREATE TRIGGER categories_delete_slug_redirects
+AFTER DELETE ON categories
+FOR EACH ROW
+EXECUTE FUNCTION delete_slug_redirects();
+
+-- backfill the existing rows, the trigger fills in a slug wherever it's missing.
+UPDATE products SET slug = NULL;
+UPDATE categories SET slug = NULL;
+
+ALTER TABLE products
+ALTER COLUMN slug SET NOT NULL;
+
+ALTER TABLE categories
+ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_slug_key ON products (slug);
+CREATE UNIQUE INDEX IF NOT EXISTS categories_slug_key ON categories (slug);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS categories_delete_slug_redirects ON categories;
+DROP TRIGGER IF EXISTS products_delete_slug_redirects ON products;
+DROP TRIGGER IF EXISTS categories_set_slug ON categories;
+DROP TRIGGER IF EXISTS products_set_slug ON products;
+DROP FUNCTION IF EXISTS delete_slug_redirects();
+DROP FUNCTION IF EXISTS set_slug();
+
+DROP INDEX IF EXISTS categories_slug_key;
+DROP INDEX IF EXISTS products_slug_key;
+
+ALTER TABLE categories
+DROP COLUMN IF EXISTS slug;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS slug;
+
+DROP TABLE IF EXISTS slug_redirects;
+DROP FUNCTION IF EXISTS slugify(TEXT);
+-- +goose StatementEnd
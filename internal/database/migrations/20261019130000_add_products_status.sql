@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'product_status') THEN
+        CREATE TYPE product_status AS ENUM ('draft', 'scheduled', 'published', 'archived');
+    END IF;
+END
+$$;
+
+-- the products that already exist are live, they're published as of their creation.
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS status product_status NOT NULL DEFAULT 'published';
+
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS published_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE products SET published_at = created_at WHERE published_at IS NULL;
+
+-- new products start as drafts until they're published or scheduled.
+ALTER TABLE products
+ALTER COLUMN status SET DEFAULT 'draft';
+
+-- a scheduled product needs the time it goes live, and a published one the time it went live.
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS products_published_at_required;
+
+ALTER TABLE products
+ADD CONSTRAINT products_published_at_required
+CHECK (status NOT IN ('scheduled', 'published') OR published_at IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS products_status_published_at_idx ON products (status, published_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS products_status_published_at_idx;
+
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS products_published_at_required;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS published_at;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS product_status;
+-- +goose StatementEnd
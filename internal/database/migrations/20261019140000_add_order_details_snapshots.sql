@@ -0,0 +1,98 @@
+-- +goose Up
+-- +goose StatementBegin
+-- an order line keeps what was bought as it was at checkout,
+-- so the order renders the same whatever happens to the catalog afterwards.
+ALTER TABLE order_details
+ADD COLUMN IF NOT EXISTS product_name VARCHAR,
+ADD COLUMN IF NOT EXISTS brand VARCHAR,
+ADD COLUMN IF NOT EXISTS color VARCHAR,
+ADD COLUMN IF NOT EXISTS size VARCHAR,
+ADD COLUMN IF NOT EXISTS thumbnail TEXT,
+ADD COLUMN IF NOT EXISTS unit_price INT,
+ADD COLUMN IF NOT EXISTS discount_type VARCHAR,
+ADD COLUMN IF NOT EXISTS discount_value DECIMAL(10, 2);
+
+-- backfill the existing lines out of the catalog as it is now.
+UPDATE order_details od
+SET
+	product_name = p.name,
+	brand = b.brand,
+	color = c.color,
+	size = s.size || ' (' || s.label || ')',
+	thumbnail = p.thumbnail
+FROM product_variants pv
+JOIN products p ON p.id = pv.product_id
+JOIN brands b ON b.id = p.brand_id
+JOIN colors c ON c.id = pv.color_id
+JOIN sizes s ON s.id = pv.size_id
+WHERE pv.id = od.product_id;
+
+UPDATE order_details
+SET unit_price = total_price / NULLIF(quantity, 0)
+WHERE unit_price IS NULL;
+
+UPDATE order_details
+SET
+	product_name = COALESCE(product_name, ''),
+	brand = COALESCE(brand, ''),
+	color = COALESCE(color, ''),
+	size = COALESCE(size, ''),
+	thumbnail = COALESCE(thumbnail, ''),
+	unit_price = COALESCE(unit_price, 0);
+
+ALTER TABLE order_details
+ALTER COLUMN product_name SET NOT NULL,
+ALTER COLUMN brand SET NOT NULL,
+ALTER COLUMN color SET NOT NULL,
+ALTER COLUMN size SET NOT NULL,
+ALTER COLUMN thumbnail SET NOT NULL,
+ALTER COLUMN unit_price SET NOT NULL;
+
+-- product_id points at the variant that was bought, it's set to null when the variant is deleted,
+-- and the lines of an order go along with it.
+ALTER TABLE order_details
+ALTER COLUMN product_id DROP NOT NULL;
+
+ALTER TABLE order_details
+DROP CONSTRAINT IF EXISTS order_details_product_id_fkey;
+
+ALTER TABLE order_details
+ADD CONSTRAINT order_details_product_id_fkey
+FOREIGN KEY (product_id) REFERENCES product_variants(id) ON DELETE SET NULL;
+
+ALTER TABLE order_details
+DROP CONSTRAINT IF EXISTS order_details_order_id_fkey;
+
+ALTER TABLE order_details
+ADD CONSTRAINT order_details_order_id_fkey
+FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS order_details_order_id_idx ON order_details (order_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS order_details_order_id_idx;
+
+ALTER TABLE order_details
+DROP CONSTRAINT IF EXISTS order_details_order_id_fkey;
+
+ALTER TABLE order_details
+ADD CONSTRAINT order_details_order_id_fkey
+FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL;
+
+DELETE FROM order_details WHERE product_id IS NULL;
+
+ALTER TABLE order_details
+ALTER COLUMN product_id SET NOT NULL;
+
+ALTER TABLE order_details
+DROP COLUMN IF EXISTS discount_value,
+DROP COLUMN IF EXISTS discount_type,
+DROP COLUMN IF EXISTS unit_price,
+DROP COLUMN IF EXISTS thumbnail,
+DROP COLUMN IF EXISTS size,
+DROP COLUMN IF EXISTS color,
+DROP COLUMN IF EXISTS brand,
+DROP COLUMN IF EXISTS product_name;
+-- +goose StatementEnd
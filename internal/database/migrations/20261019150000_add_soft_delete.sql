@@ -0,0 +1,192 @@
+-- +goose Up
+-- +goose StatementBegin
+-- deleting a product, variant, category, color or size only marks it as deleted,
+-- it can be restored until the retention purge deletes it for good.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE product_variants ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE colors ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE sizes ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS products_deleted_at_idx ON products (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS product_variants_deleted_at_idx ON product_variants (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS categories_deleted_at_idx ON categories (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS colors_deleted_at_idx ON colors (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS sizes_deleted_at_idx ON sizes (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- a deleted row doesn't hold on to its name, restoring it fails if the name was taken meanwhile.
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS products_name_key ON products (name) WHERE deleted_at IS NULL;
+
+ALTER TABLE product_variants DROP CONSTRAINT IF EXISTS product_variants_product_id_color_id_size_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_product_id_color_id_size_id_key
+ON product_variants (product_id, color_id, size_id) WHERE deleted_at IS NULL;
+
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_parent_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS categories_name_parent_id_key
+ON categories (name, parent_id) WHERE deleted_at IS NULL;
+
+ALTER TABLE colors DROP CONSTRAINT IF EXISTS colors_color_key;
+CREATE UNIQUE INDEX IF NOT EXISTS colors_color_key ON colors (color) WHERE deleted_at IS NULL;
+
+ALTER TABLE sizes DROP CONSTRAINT IF EXISTS sizes_size_label_key;
+CREATE UNIQUE INDEX IF NOT EXISTS sizes_size_label_key ON sizes (size, label) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- prevent_delete_in_use blocks soft deleting a row while rows that aren't deleted still reference it,
+-- the same way a foreign key blocks deleting it. Takes the referencing table and column.
+CREATE OR REPLACE FUNCTION prevent_delete_in_use()
+RETURNS TRIGGER AS $$
+DECLARE
+  in_use BOOLEAN;
+BEGIN
+  EXECUTE format(
+    'SELECT EXISTS (SELECT 1 FROM %I WHERE %I = $1 AND deleted_at IS NULL)',
+    TG_ARGV[0],
+    TG_ARGV[1]
+  ) INTO in_use USING NEW.id;
+
+  IF in_use THEN
+    RAISE EXCEPTION '% % is still referenced by %', TG_TABLE_NAME, NEW.id, TG_ARGV[0]
+      USING ERRCODE = 'foreign_key_violation';
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- prevent_deleted_reference blocks pointing a row that isn't deleted at a deleted row, so a row
+-- can't be restored, created or moved under a deleted one. Takes the referenced table and the column.
+CREATE OR REPLACE FUNCTION prevent_deleted_reference()
+RETURNS TRIGGER AS $$
+DECLARE
+  referenced_deleted BOOLEAN;
+BEGIN
+  IF NEW.deleted_at IS NOT NULL THEN
+    RETURN NEW;
+  END IF;
+
+  EXECUTE format(
+    'SELECT EXISTS (SELECT 1 FROM %I WHERE id = ($1).%I AND deleted_at IS NOT NULL)',
+    TG_ARGV[0],
+    TG_ARGV[1]
+  ) INTO referenced_deleted USING NEW;
+
+  IF referenced_deleted THEN
+    RAISE EXCEPTION '% references a deleted row of %', TG_TABLE_NAME, TG_ARGV[0]
+      USING ERRCODE = 'foreign_key_violation';
+  END IF;
+
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS categories_prevent_delete_with_subcategories ON categories;
+CREATE TRIGGER categories_prevent_delete_with_subcategories
+BEFORE UPDATE OF deleted_at ON categories
+FOR EACH ROW WHEN (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL)
+EXECUTE FUNCTION prevent_delete_in_use('categories', 'parent_id');
+
+DROP TRIGGER IF EXISTS categories_prevent_delete_with_products ON categories;
+CREATE TRIGGER categories_prevent_delete_with_products
+BEFORE UPDATE OF deleted_at ON categories
+FOR EACH ROW WHEN (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL)
+EXECUTE FUNCTION prevent_delete_in_use('products', 'product_category');
+
+DROP TRIGGER IF EXISTS colors_prevent_delete_in_use ON colors;
+CREATE TRIGGER colors_prevent_delete_in_use
+BEFORE UPDATE OF deleted_at ON colors
+FOR EACH ROW WHEN (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL)
+EXECUTE FUNCTION prevent_delete_in_use('product_variants', 'color_id');
+
+DROP TRIGGER IF EXISTS sizes_prevent_delete_in_use ON sizes;
+CREATE TRIGGER sizes_prevent_delete_in_use
+BEFORE UPDATE OF deleted_at ON sizes
+FOR EACH ROW WHEN (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL)
+EXECUTE FUNCTION prevent_delete_in_use('product_variants', 'size_id');
+
+DROP TRIGGER IF EXISTS categories_prevent_deleted_parent ON categories;
+CREATE TRIGGER categories_prevent_deleted_parent
+BEFORE INSERT OR UPDATE OF parent_id, deleted_at ON categories
+FOR EACH ROW
+EXECUTE FUNCTION prevent_deleted_reference('categories', 'parent_id');
+
+DROP TRIGGER IF EXISTS products_prevent_deleted_category ON products;
+CREATE TRIGGER products_prevent_deleted_category
+BEFORE INSERT OR UPDATE OF product_category, deleted_at ON products
+FOR EACH ROW
+EXECUTE FUNCTION prevent_deleted_reference('categories', 'product_category');
+
+DROP TRIGGER IF EXISTS product_variants_prevent_deleted_product ON product_variants;
+CREATE TRIGGER product_variants_prevent_deleted_product
+BEFORE INSERT OR UPDATE OF product_id, deleted_at ON product_variants
+FOR EACH ROW
+EXECUTE FUNCTION prevent_deleted_reference('products', 'product_id');
+
+DROP TRIGGER IF EXISTS product_variants_prevent_deleted_color ON product_variants;
+CREATE TRIGGER product_variants_prevent_deleted_color
+BEFORE INSERT OR UPDATE OF color_id, deleted_at ON product_variants
+FOR EACH ROW
+EXECUTE FUNCTION prevent_deleted_reference('colors', 'color_id');
+
+DROP TRIGGER IF EXISTS product_variants_prevent_deleted_size ON product_variants;
+CREATE TRIGGER product_variants_prevent_deleted_size
+BEFORE INSERT OR UPDATE OF size_id, deleted_at ON product_variants
+FOR EACH ROW
+EXECUTE FUNCTION prevent_deleted_reference('sizes', 'size_id');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS product_variants_prevent_deleted_size ON product_variants;
+DROP TRIGGER IF EXISTS product_variants_prevent_deleted_color ON product_variants;
+DROP TRIGGER IF EXISTS product_variants_prevent_deleted_product ON product_variants;
+DROP TRIGGER IF EXISTS products_prevent_deleted_category ON products;
+DROP TRIGGER IF EXISTS categories_prevent_deleted_parent ON categories;
+DROP TRIGGER IF EXISTS sizes_prevent_delete_in_use ON sizes;
+DROP TRIGGER IF EXISTS colors_prevent_delete_in_use ON colors;
+DROP TRIGGER IF EXISTS categories_prevent_delete_with_products ON categories;
+DROP TRIGGER IF EXISTS categories_prevent_delete_with_subcategories ON categories;
+DROP FUNCTION IF EXISTS prevent_deleted_reference();
+DROP FUNCTION IF EXISTS prevent_delete_in_use();
+
+-- the soft deleted rows are gone for good once the column is.
+DELETE FROM product_variants WHERE deleted_at IS NOT NULL;
+DELETE FROM products WHERE deleted_at IS NOT NULL;
+DELETE FROM categories WHERE deleted_at IS NOT NULL;
+DELETE FROM colors WHERE deleted_at IS NOT NULL;
+DELETE FROM sizes WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS sizes_size_label_key;
+ALTER TABLE sizes ADD CONSTRAINT sizes_size_label_key UNIQUE (size, label);
+
+DROP INDEX IF EXISTS colors_color_key;
+ALTER TABLE colors ADD CONSTRAINT colors_color_key UNIQUE (color);
+
+DROP INDEX IF EXISTS categories_name_parent_id_key;
+ALTER TABLE categories ADD CONSTRAINT categories_name_parent_id_key UNIQUE (name, parent_id);
+
+DROP INDEX IF EXISTS product_variants_product_id_color_id_size_id_key;
+ALTER TABLE product_variants
+ADD CONSTRAINT product_variants_product_id_color_id_size_id_key UNIQUE (product_id, color_id, size_id);
+
+DROP INDEX IF EXISTS products_name_key;
+ALTER TABLE products ADD CONSTRAINT products_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS sizes_deleted_at_idx;
+DROP INDEX IF EXISTS colors_deleted_at_idx;
+DROP INDEX IF EXISTS categories_deleted_at_idx;
+DROP INDEX IF EXISTS product_variants_deleted_at_idx;
+DROP INDEX IF EXISTS products_deleted_at_idx;
+
+ALTER TABLE sizes DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE colors DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE categories DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE product_variants DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the gallery is ordered by position, an image can be linked to the color it shows.
+ALTER TABLE images ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS alt_text TEXT;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS color_id INT REFERENCES colors(id) ON DELETE SET NULL;
+
+UPDATE images
+SET position = ordered.position
+FROM (
+	SELECT id, ROW_NUMBER() OVER (PARTITION BY product_id ORDER BY id) - 1 AS position
+	FROM images
+) ordered
+WHERE images.id = ordered.id;
+
+CREATE INDEX IF NOT EXISTS images_product_id_position_idx ON images (product_id, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS images_product_id_position_idx;
+
+ALTER TABLE images DROP COLUMN IF EXISTS color_id;
+ALTER TABLE images DROP COLUMN IF EXISTS alt_text;
+ALTER TABLE images DROP COLUMN IF EXISTS position;
+-- +goose StatementEnd
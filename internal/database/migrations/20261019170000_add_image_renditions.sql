@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- images.image and images.low_res_image keep pointing at the largest and smallest renditions.
+ALTER TABLE images ADD COLUMN IF NOT EXISTS blurhash TEXT;
+ALTER TABLE images ADD COLUMN IF NOT EXISTS dominant_color TEXT;
+
+CREATE TABLE IF NOT EXISTS image_renditions (
+	id SERIAL PRIMARY KEY,
+	url TEXT UNIQUE NOT NULL,
+	width INT NOT NULL CHECK (width > 0),
+	height INT NOT NULL CHECK (height > 0),
+
+	image_id INT NOT NULL,
+	FOREIGN KEY(image_id) REFERENCES images(id) ON DELETE CASCADE,
+	UNIQUE(image_id, width)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS image_renditions;
+
+ALTER TABLE images DROP COLUMN IF EXISTS dominant_color;
+ALTER TABLE images DROP COLUMN IF EXISTS blurhash;
+-- +goose StatementEnd
@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'image_status') THEN
+        CREATE TYPE image_status AS ENUM ('processing', 'ready', 'failed');
+    END IF;
+END
+$$;
+
+-- the images that already exist have their renditions.
+ALTER TABLE images
+ADD COLUMN IF NOT EXISTS status image_status NOT NULL DEFAULT 'ready';
+
+ALTER TABLE images
+ALTER COLUMN status SET DEFAULT 'processing';
+
+-- the original upload is kept until its renditions are made, the urls are set then.
+ALTER TABLE images ADD COLUMN IF NOT EXISTS original TEXT UNIQUE;
+ALTER TABLE images ALTER COLUMN image DROP NOT NULL;
+ALTER TABLE images ALTER COLUMN low_res_image DROP NOT NULL;
+
+ALTER TABLE images DROP CONSTRAINT IF EXISTS images_ready_has_urls;
+ALTER TABLE images
+ADD CONSTRAINT images_ready_has_urls
+CHECK (status <> 'ready' OR (image IS NOT NULL AND low_res_image IS NOT NULL));
+
+-- a job is claimed by a worker until locked_until, a crashed worker's job is claimed again
+-- after that. A job that used up its attempts stays with run_at NULL until it's retried.
+CREATE TABLE IF NOT EXISTS image_jobs (
+	id SERIAL PRIMARY KEY,
+	attempts INT NOT NULL DEFAULT 0,
+	run_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	locked_until TIMESTAMP WITH TIME ZONE,
+	last_error TEXT,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	image_id INT UNIQUE NOT NULL,
+	FOREIGN KEY(image_id) REFERENCES images(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS image_jobs_run_at_idx ON image_jobs (run_at) WHERE run_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS image_jobs;
+
+-- the images without renditions can't be kept once the urls are required again.
+DELETE FROM images WHERE image IS NULL OR low_res_image IS NULL;
+
+ALTER TABLE images DROP CONSTRAINT IF EXISTS images_ready_has_urls;
+ALTER TABLE images ALTER COLUMN low_res_image SET NOT NULL;
+ALTER TABLE images ALTER COLUMN image SET NOT NULL;
+ALTER TABLE images DROP COLUMN IF EXISTS original;
+ALTER TABLE images DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS image_status;
+-- +goose StatementEnd
@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'upload_owner') THEN
+        CREATE TYPE upload_owner AS ENUM ('product', 'image', 'user', 'order_detail');
+    END IF;
+END
+$$;
+
+-- every url the rows of the database can point to, with the row pointing to it.
+-- The order lines keep the thumbnail of the product at checkout time.
+CREATE OR REPLACE VIEW upload_references AS
+	SELECT thumbnail AS url, 'product'::upload_owner AS owner_type, id AS owner_id FROM products
+	UNION ALL
+	SELECT image, 'image', id FROM images WHERE image IS NOT NULL
+	UNION ALL
+	SELECT low_res_image, 'image', id FROM images WHERE low_res_image IS NOT NULL
+	UNION ALL
+	SELECT original, 'image', id FROM images WHERE original IS NOT NULL
+	UNION ALL
+	SELECT url, 'image', image_id FROM image_renditions
+	UNION ALL
+	SELECT image, 'user', id FROM users WHERE image IS NOT NULL
+	UNION ALL
+	SELECT thumbnail, 'order_detail', id FROM order_details WHERE thumbnail <> '';
+
+-- every uploaded object is recorded before the rows referencing it are committed, the owner
+-- is the kind of row it's uploaded for and owner_id is set once a row references it.
+-- checked_at is when its object was last found in the storage, missing_since when it wasn't.
+CREATE TABLE IF NOT EXISTS uploads (
+	id SERIAL PRIMARY KEY,
+	url TEXT UNIQUE NOT NULL,
+	owner_type upload_owner NOT NULL,
+	owner_id INT,
+	checked_at TIMESTAMP WITH TIME ZONE,
+	missing_since TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS uploads_checked_at_idx ON uploads (checked_at NULLS FIRST);
+
+-- the objects uploaded before the ledger.
+INSERT INTO uploads (url, owner_type, owner_id)
+SELECT DISTINCT ON (url) url, owner_type, owner_id
+FROM upload_references
+ON CONFLICT (url) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS uploads;
+DROP VIEW IF EXISTS upload_references;
+DROP TYPE IF EXISTS upload_owner;
+-- +goose StatementEnd
@@ -0,0 +1,69 @@
+-- +goose NO TRANSACTION
+-- a new enum value can't be used in the transaction adding it.
+
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE upload_owner ADD VALUE IF NOT EXISTS 'review';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- the size and content type a presigned upload was signed for, they're checked
+-- against the uploaded object when it's confirmed.
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS expected_size BIGINT;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS expected_content_type TEXT;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS review_images (
+	id SERIAL PRIMARY KEY,
+	image TEXT UNIQUE NOT NULL,
+	position INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	review_id INT NOT NULL,
+	FOREIGN KEY(review_id) REFERENCES rating_review(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS review_images_review_id_idx ON review_images (review_id, position);
+
+CREATE OR REPLACE VIEW upload_references AS
+	SELECT thumbnail AS url, 'product'::upload_owner AS owner_type, id AS owner_id FROM products
+	UNION ALL
+	SELECT image, 'image', id FROM images WHERE image IS NOT NULL
+	UNION ALL
+	SELECT low_res_image, 'image', id FROM images WHERE low_res_image IS NOT NULL
+	UNION ALL
+	SELECT original, 'image', id FROM images WHERE original IS NOT NULL
+	UNION ALL
+	SELECT url, 'image', image_id FROM image_renditions
+	UNION ALL
+	SELECT image, 'user', id FROM users WHERE image IS NOT NULL
+	UNION ALL
+	SELECT thumbnail, 'order_detail', id FROM order_details WHERE thumbnail <> ''
+	UNION ALL
+	SELECT image, 'review', review_id FROM review_images;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE VIEW upload_references AS
+	SELECT thumbnail AS url, 'product'::upload_owner AS owner_type, id AS owner_id FROM products
+	UNION ALL
+	SELECT image, 'image', id FROM images WHERE image IS NOT NULL
+	UNION ALL
+	SELECT low_res_image, 'image', id FROM images WHERE low_res_image IS NOT NULL
+	UNION ALL
+	SELECT original, 'image', id FROM images WHERE original IS NOT NULL
+	UNION ALL
+	SELECT url, 'image', image_id FROM image_renditions
+	UNION ALL
+	SELECT image, 'user', id FROM users WHERE image IS NOT NULL
+	UNION ALL
+	SELECT thumbnail, 'order_detail', id FROM order_details WHERE thumbnail <> '';
+
+DROP TABLE IF EXISTS review_images;
+
+ALTER TABLE uploads DROP COLUMN IF EXISTS confirmed_at;
+ALTER TABLE uploads DROP COLUMN IF EXISTS expected_content_type;
+ALTER TABLE uploads DROP COLUMN IF EXISTS expected_size;
+-- the 'review' value stays, postgres can't drop an enum value.
+-- +goose StatementEnd
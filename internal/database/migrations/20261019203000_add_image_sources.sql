@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- an imported image is fetched from the url of the sheet by the image worker,
+-- which stores its original before making the renditions.
+ALTER TABLE images ADD COLUMN IF NOT EXISTS source_url TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the imported images that were never fetched have nothing to be processed from.
+DELETE FROM images WHERE status = 'processing' AND original IS NULL;
+
+ALTER TABLE images DROP COLUMN IF EXISTS source_url;
+-- +goose StatementEnd
@@ -0,0 +1,79 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'attribute_type') THEN
+        CREATE TYPE attribute_type AS ENUM ('text', 'enum', 'number');
+    END IF;
+END
+$$;
+
+-- the code names the attribute in the product filters, e.g. ?attr_material=cotton.
+CREATE TABLE IF NOT EXISTS attributes (
+	id SERIAL PRIMARY KEY,
+	code TEXT UNIQUE NOT NULL CHECK (code ~ '^[a-z][a-z0-9_]*$'),
+	name TEXT NOT NULL,
+	type attribute_type NOT NULL,
+	unit TEXT CHECK (unit IS NULL OR type = 'number'),
+	filterable BOOLEAN NOT NULL DEFAULT FALSE,
+	position INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS trigger_update_attribute_updated_at ON attributes;
+
+CREATE TRIGGER trigger_update_attribute_updated_at
+BEFORE UPDATE ON attributes
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+-- the values an enum attribute can take.
+CREATE TABLE IF NOT EXISTS attribute_options (
+	id SERIAL PRIMARY KEY,
+	value TEXT NOT NULL,
+	position INT NOT NULL DEFAULT 0,
+
+	attribute_id INT NOT NULL,
+	FOREIGN KEY(attribute_id) REFERENCES attributes(id) ON DELETE CASCADE,
+	UNIQUE(attribute_id, value),
+	UNIQUE(attribute_id, id)
+);
+
+-- an attribute with categories only applies to the products under them,
+-- one without any applies to every product.
+CREATE TABLE IF NOT EXISTS attribute_categories (
+	attribute_id INT NOT NULL,
+	category_id INT NOT NULL,
+	PRIMARY KEY (attribute_id, category_id),
+	FOREIGN KEY(attribute_id) REFERENCES attributes(id) ON DELETE CASCADE,
+	FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+-- a product has a single value per attribute, in the column of the attribute type.
+-- The option has to be one of the attribute's own options.
+CREATE TABLE IF NOT EXISTS product_attributes (
+	product_id INT NOT NULL,
+	attribute_id INT NOT NULL,
+	text_value TEXT,
+	option_id INT,
+	number_value DOUBLE PRECISION,
+	PRIMARY KEY (product_id, attribute_id),
+	FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY(attribute_id) REFERENCES attributes(id) ON DELETE CASCADE,
+	FOREIGN KEY(attribute_id, option_id) REFERENCES attribute_options(attribute_id, id),
+	CHECK (num_nonnulls(text_value, option_id, number_value) = 1)
+);
+
+CREATE INDEX IF NOT EXISTS product_attributes_attribute_id_idx
+ON product_attributes (attribute_id, option_id, number_value);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS product_attributes;
+DROP TABLE IF EXISTS attribute_categories;
+DROP TABLE IF EXISTS attribute_options;
+DROP TABLE IF EXISTS attributes;
+DROP TYPE IF EXISTS attribute_type;
+-- +goose StatementEnd
@@ -0,0 +1,76 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a brand's measurements of its sizes for a category, the products of the
+-- category's subcategories use it too unless they have a chart of their own.
+CREATE TABLE IF NOT EXISTS size_charts (
+	id SERIAL PRIMARY KEY,
+	note TEXT,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	brand_id INT NOT NULL,
+	category_id INT NOT NULL,
+	FOREIGN KEY(brand_id) REFERENCES brands(id) ON DELETE CASCADE,
+	FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE,
+	UNIQUE(brand_id, category_id)
+);
+
+DROP TRIGGER IF EXISTS trigger_update_size_chart_updated_at ON size_charts;
+
+CREATE TRIGGER trigger_update_size_chart_updated_at
+BEFORE UPDATE ON size_charts
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+-- the measurements are in cm, a row has at least one of them.
+CREATE TABLE IF NOT EXISTS size_chart_rows (
+	chest_cm NUMERIC(5, 1) CHECK (chest_cm > 0),
+	waist_cm NUMERIC(5, 1) CHECK (waist_cm > 0),
+	length_cm NUMERIC(5, 1) CHECK (length_cm > 0),
+	position INT NOT NULL DEFAULT 0,
+
+	size_chart_id INT NOT NULL,
+	size_id INT NOT NULL,
+	PRIMARY KEY (size_chart_id, size_id),
+	FOREIGN KEY(size_chart_id) REFERENCES size_charts(id) ON DELETE CASCADE,
+	FOREIGN KEY(size_id) REFERENCES sizes(id) ON DELETE CASCADE,
+	CHECK (num_nonnulls(chest_cm, waist_cm, length_cm) > 0)
+);
+
+-- the shoe sizes are EU sizes, this is their US and UK equivalents.
+CREATE TABLE IF NOT EXISTS shoe_size_conversions (
+	size_id INT PRIMARY KEY,
+	uk NUMERIC(3, 1) NOT NULL CHECK (uk > 0),
+	us_men NUMERIC(3, 1) NOT NULL CHECK (us_men > 0),
+	us_women NUMERIC(3, 1) NOT NULL CHECK (us_women > 0),
+	foot_length_cm NUMERIC(4, 1) CHECK (foot_length_cm > 0),
+	FOREIGN KEY(size_id) REFERENCES sizes(id) ON DELETE CASCADE
+);
+
+INSERT INTO shoe_size_conversions (size_id, uk, us_men, us_women, foot_length_cm)
+SELECT sizes.id, c.uk, c.us_men, c.us_women, c.foot_length_cm
+FROM (
+	VALUES
+		('36', 3.5, 4.5, 6, 22.5),
+		('37', 4, 5, 6.5, 23),
+		('38', 5, 6, 7.5, 23.5),
+		('39', 6, 6.5, 8, 24.5),
+		('40', 6.5, 7, 8.5, 25),
+		('41', 7, 8, 9.5, 26),
+		('42', 8, 8.5, 10, 26.5),
+		('43', 9, 9.5, 11, 27.5),
+		('44', 9.5, 10, 11.5, 28),
+		('45', 10.5, 11, 12.5, 29),
+		('46', 11, 12, 13.5, 29.5),
+		('47', 12, 13, 14.5, 30.5)
+) AS c(eu, uk, us_men, us_women, foot_length_cm)
+JOIN sizes ON sizes.size = c.eu AND sizes.label = 'حذاء'
+ON CONFLICT (size_id) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shoe_size_conversions;
+DROP TABLE IF EXISTS size_chart_rows;
+DROP TABLE IF EXISTS size_charts;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a product could only be on a single wishlist, so no two customers could wishlist the same product.
+ALTER TABLE wishlists DROP CONSTRAINT IF EXISTS wishlists_product_id_key;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'recommendation_reason') THEN
+        CREATE TYPE recommendation_reason AS ENUM ('bought_together', 'wishlisted_together', 'similar');
+    END IF;
+END
+$$;
+
+-- the recommendations are precomputed by the server periodically, the reason is
+-- the signal that contributed the most to the score.
+CREATE TABLE IF NOT EXISTS product_recommendations (
+	product_id INT NOT NULL,
+	recommended_id INT NOT NULL,
+	score DOUBLE PRECISION NOT NULL,
+	reason recommendation_reason NOT NULL,
+	computed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (product_id, recommended_id),
+	FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY(recommended_id) REFERENCES products(id) ON DELETE CASCADE,
+	CHECK (product_id <> recommended_id)
+);
+
+CREATE INDEX IF NOT EXISTS product_recommendations_score_idx
+ON product_recommendations (product_id, score DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS product_recommendations;
+DROP TYPE IF EXISTS recommendation_reason;
+-- the unique constraint on wishlists.product_id isn't put back, the wishlists may violate it by now.
+-- +goose StatementEnd
@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'product_event_type') THEN
+        CREATE TYPE product_event_type AS ENUM ('view', 'add_to_cart');
+    END IF;
+END
+$$;
+
+-- the events are written in batches by the server, a guest is told apart by
+-- the anonymous id the server gave it instead of a user id.
+CREATE TABLE IF NOT EXISTS product_events (
+	id BIGSERIAL PRIMARY KEY,
+	event product_event_type NOT NULL,
+	anonymous_id VARCHAR(64),
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	product_id INT NOT NULL,
+	user_id INT,
+
+	FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	CHECK (user_id IS NOT NULL OR anonymous_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS product_events_created_at_idx
+ON product_events (created_at);
+
+CREATE INDEX IF NOT EXISTS product_events_user_idx
+ON product_events (user_id, created_at DESC) WHERE user_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS product_events_anonymous_idx
+ON product_events (anonymous_id, created_at DESC) WHERE anonymous_id IS NOT NULL;
+
+-- the trending scores are precomputed by the server periodically, a row per product and day,
+-- so the trending products of any window are the sum of its days.
+CREATE TABLE IF NOT EXISTS product_trend_scores (
+	product_id INT NOT NULL,
+	day DATE NOT NULL,
+	score DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (product_id, day),
+	FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS product_trend_scores_day_idx
+ON product_trend_scores (day);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS product_trend_scores;
+DROP TABLE IF EXISTS product_events;
+DROP TYPE IF EXISTS product_event_type;
+-- +goose StatementEnd
@@ -0,0 +1,118 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'moderation_status') THEN
+        CREATE TYPE moderation_status AS ENUM ('pending', 'approved', 'rejected');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'answer_author') THEN
+        CREATE TYPE answer_author AS ENUM ('admin', 'buyer');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'notification_type') THEN
+        CREATE TYPE notification_type AS ENUM ('question_answered');
+    END IF;
+END
+$$;
+
+-- the questions customers ask about a product, only the approved ones are public.
+CREATE TABLE IF NOT EXISTS product_questions (
+	id SERIAL PRIMARY KEY,
+	question TEXT NOT NULL,
+	status moderation_status NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	product_id INT NOT NULL,
+	user_id INT NOT NULL,
+
+	FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS product_questions_product_idx
+ON product_questions (product_id, status);
+
+DROP TRIGGER IF EXISTS trigger_update_product_question_updated_at ON product_questions;
+
+CREATE TRIGGER trigger_update_product_question_updated_at
+BEFORE UPDATE ON product_questions
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+-- the answers of the admins are approved right away, the ones of the buyers are moderated.
+CREATE TABLE IF NOT EXISTS product_answers (
+	id SERIAL PRIMARY KEY,
+	answer TEXT NOT NULL,
+	author answer_author NOT NULL,
+	status moderation_status NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	question_id INT NOT NULL,
+	user_id INT NOT NULL,
+
+	FOREIGN KEY(question_id) REFERENCES product_questions(id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS product_answers_question_idx
+ON product_answers (question_id, status);
+
+DROP TRIGGER IF EXISTS trigger_update_product_answer_updated_at ON product_answers;
+
+CREATE TRIGGER trigger_update_product_answer_updated_at
+BEFORE UPDATE ON product_answers
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS question_votes (
+	question_id INT NOT NULL,
+	user_id INT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (question_id, user_id),
+	FOREIGN KEY(question_id) REFERENCES product_questions(id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS answer_votes (
+	answer_id INT NOT NULL,
+	user_id INT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (answer_id, user_id),
+	FOREIGN KEY(answer_id) REFERENCES product_answers(id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- the in-app notifications of the users, data holds the ids the client needs to link the notification.
+CREATE TABLE IF NOT EXISTS notifications (
+	id SERIAL PRIMARY KEY,
+	type notification_type NOT NULL,
+	message TEXT NOT NULL,
+	data JSONB NOT NULL DEFAULT '{}',
+	read_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	user_id INT NOT NULL,
+	product_id INT,
+
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_idx
+ON notifications (user_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS answer_votes;
+DROP TABLE IF EXISTS question_votes;
+DROP TABLE IF EXISTS product_answers;
+DROP TABLE IF EXISTS product_questions;
+DROP TYPE IF EXISTS notification_type;
+DROP TYPE IF EXISTS answer_author;
+DROP TYPE IF EXISTS moderation_status;
+-- +goose StatementEnd
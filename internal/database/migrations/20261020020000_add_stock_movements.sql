@@ -0,0 +1,90 @@
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'stock_movement_reason') THEN
+        CREATE TYPE stock_movement_reason AS ENUM (
+            'restock', 'sale', 'cancellation_return', 'adjustment', 'damage'
+        );
+    END IF;
+END
+$$;
+
+-- the quantity is only changed along with a movement from now on, it can't go below 0.
+UPDATE product_variants SET quantity = 0 WHERE quantity < 0;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'product_variants_quantity_check'
+    ) THEN
+        ALTER TABLE product_variants
+        ADD CONSTRAINT product_variants_quantity_check CHECK (quantity >= 0);
+    END IF;
+END
+$$;
+
+-- null uses the LOW_STOCK_THRESHOLD of the server.
+ALTER TABLE product_variants
+ADD COLUMN IF NOT EXISTS low_stock_threshold INT CHECK (low_stock_threshold >= 0);
+
+-- the ledger of the stock changes, quantity_after is the variant quantity right after the change.
+-- The actor is the user or else the api key that made the change, the customer for a sale.
+-- The ledger outlives the variants, purging a variant keeps its movements and their variant id.
+CREATE TABLE IF NOT EXISTS stock_movements (
+	id BIGSERIAL PRIMARY KEY,
+	change INT NOT NULL CHECK (change <> 0),
+	quantity_after INT NOT NULL,
+	reason stock_movement_reason NOT NULL,
+	note TEXT,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	variant_id INT NOT NULL,
+	actor_id INT,
+	api_key_id INT,
+	order_id INT,
+
+	FOREIGN KEY(actor_id) REFERENCES users(id) ON DELETE SET NULL,
+	FOREIGN KEY(api_key_id) REFERENCES api_keys(id) ON DELETE SET NULL,
+	FOREIGN KEY(order_id) REFERENCES orders(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_variant_idx
+ON stock_movements (variant_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS stock_movements_order_idx
+ON stock_movements (order_id) WHERE order_id IS NOT NULL;
+
+-- the stock the variants had before the ledger, so their history adds up to their quantity.
+INSERT INTO stock_movements (change, quantity_after, reason, note, variant_id)
+SELECT quantity, quantity, 'adjustment', 'opening balance', id
+FROM product_variants
+WHERE quantity > 0 AND NOT EXISTS (
+	SELECT 1 FROM stock_movements sm WHERE sm.variant_id = product_variants.id
+);
+
+-- a variant has a single open alert, it's resolved once the variant is restocked above its threshold.
+CREATE TABLE IF NOT EXISTS stock_alerts (
+	id SERIAL PRIMARY KEY,
+	quantity INT NOT NULL,
+	threshold INT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	resolved_at TIMESTAMP WITH TIME ZONE,
+
+	variant_id INT NOT NULL,
+
+	FOREIGN KEY(variant_id) REFERENCES product_variants(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stock_alerts_open_idx
+ON stock_alerts (variant_id) WHERE resolved_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_alerts;
+DROP TABLE IF EXISTS stock_movements;
+ALTER TABLE product_variants DROP COLUMN IF EXISTS low_stock_threshold;
+ALTER TABLE product_variants DROP CONSTRAINT IF EXISTS product_variants_quantity_check;
+DROP TYPE IF EXISTS stock_movement_reason;
+-- +goose StatementEnd
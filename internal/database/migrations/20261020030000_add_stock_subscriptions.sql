@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE notification_type ADD VALUE IF NOT EXISTS 'back_in_stock';
+
+-- the users waiting for an out of stock variant. A restock queues as many of them as there is stock
+-- for, the oldest first, and the server notifies the queued ones in batches.
+CREATE TABLE IF NOT EXISTS stock_subscriptions (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	queued_at TIMESTAMP WITH TIME ZONE,
+	notified_at TIMESTAMP WITH TIME ZONE,
+
+	variant_id INT NOT NULL,
+	user_id INT NOT NULL,
+
+	UNIQUE (variant_id, user_id),
+	FOREIGN KEY(variant_id) REFERENCES product_variants(id) ON DELETE CASCADE,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS stock_subscriptions_waiting_idx
+ON stock_subscriptions (variant_id, created_at) WHERE notified_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS stock_subscriptions_queued_idx
+ON stock_subscriptions (queued_at) WHERE queued_at IS NOT NULL AND notified_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_subscriptions;
+-- enum values can't be dropped, back_in_stock stays in notification_type.
+DELETE FROM notifications WHERE type = 'back_in_stock';
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE notification_type ADD VALUE IF NOT EXISTS 'price_drop';
+
+-- every price a variant had, an entry is added whenever its price changes.
+CREATE TABLE IF NOT EXISTS variant_prices (
+	id BIGSERIAL PRIMARY KEY,
+	price INT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+	variant_id INT NOT NULL,
+
+	FOREIGN KEY(variant_id) REFERENCES product_variants(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS variant_prices_variant_id_created_at_idx
+ON variant_prices (variant_id, created_at DESC);
+
+INSERT INTO variant_prices (price, variant_id)
+SELECT price, id FROM product_variants;
+
+-- the lowest price of the product when it was wishlisted, and the one its wishlister was last notified of.
+ALTER TABLE wishlists
+ADD COLUMN IF NOT EXISTS added_price INT,
+ADD COLUMN IF NOT EXISTS notified_price INT;
+
+UPDATE wishlists w
+SET added_price = (
+	SELECT MIN(pv.price) FROM product_variants pv
+	WHERE pv.product_id = w.product_id AND pv.deleted_at IS NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE wishlists
+DROP COLUMN IF EXISTS notified_price,
+DROP COLUMN IF EXISTS added_price;
+
+DROP TABLE IF EXISTS variant_prices;
+-- enum values can't be dropped, price_drop stays in notification_type.
+DELETE FROM notifications WHERE type = 'price_drop';
+-- +goose StatementEnd
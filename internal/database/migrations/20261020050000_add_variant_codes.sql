@@ -0,0 +1,62 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product_variants
+ADD COLUMN IF NOT EXISTS sku VARCHAR(64),
+ADD COLUMN IF NOT EXISTS barcode VARCHAR(13);
+
+-- variant_sku generates the sku of a variant from the product id and the color and size codes,
+-- e.g. P12-BLA-42EU. The ids of the color and size are added when another variant has it already,
+-- which happens when two colors or sizes share a code, the color and size are unique per product.
+CREATE OR REPLACE FUNCTION variant_sku(product_id INT, color_id INT, size_id INT)
+RETURNS VARCHAR AS $$
+DECLARE
+	sku VARCHAR;
+BEGIN
+	SELECT
+		'P' || product_id
+		|| '-' || COALESCE(NULLIF(UPPER(LEFT(regexp_replace(c.color, '[^a-zA-Z0-9]', '', 'g'), 3)), ''), 'C')
+		|| '-' || COALESCE(NULLIF(UPPER(LEFT(regexp_replace(s.size || s.label, '[^a-zA-Z0-9]', '', 'g'), 8)), ''), 'S')
+	INTO sku
+	FROM colors c, sizes s
+	WHERE c.id = color_id AND s.id = size_id;
+
+	IF EXISTS (SELECT 1 FROM product_variants pv WHERE pv.sku = variant_sku.sku) THEN
+		sku := sku || '-' || color_id || '-' || size_id;
+	END IF;
+
+	RETURN sku;
+END;
+$$ LANGUAGE plpgsql;
+
+-- one variant at a time, so each sku sees the ones generated before it.
+DO $$
+DECLARE
+	v RECORD;
+BEGIN
+	FOR v IN SELECT id, product_id, color_id, size_id FROM product_variants WHERE sku IS NULL ORDER BY id LOOP
+		UPDATE product_variants
+		SET sku = variant_sku(v.product_id, v.color_id, v.size_id)
+		WHERE id = v.id;
+	END LOOP;
+END $$;
+
+ALTER TABLE product_variants
+ALTER COLUMN sku SET NOT NULL,
+ADD CONSTRAINT product_variants_sku_key UNIQUE (sku),
+ADD CONSTRAINT product_variants_barcode_key UNIQUE (barcode),
+-- EAN-8 or EAN-13, a UPC-A is kept as the EAN-13 with a leading 0 the code scanners read it as.
+-- The check digit is validated by the server.
+ADD CONSTRAINT product_variants_barcode_check CHECK (barcode ~ '^([0-9]{8}|[0-9]{13})$');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE product_variants
+DROP CONSTRAINT IF EXISTS product_variants_barcode_check,
+DROP CONSTRAINT IF EXISTS product_variants_barcode_key,
+DROP CONSTRAINT IF EXISTS product_variants_sku_key,
+DROP COLUMN IF EXISTS barcode,
+DROP COLUMN IF EXISTS sku;
+
+DROP FUNCTION IF EXISTS variant_sku(INT, INT, INT);
+-- +goose StatementEnd
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+)
+
+type NotificationRepository interface {
+	// This method will create a notification.
+	//
+	// Columns required: type, message, data, user_id, product_id.
+	// Returns: id and created_at set on the notification.
+	Create(ctx context.Context, db Querier, n *models.Notification) error
+
+	// This method will create the notifications at once.
+	//
+	// Columns required: type, message, data, user_id, product_id.
+	CreateMany(ctx context.Context, db Querier, notifications []models.Notification) error
+
+	// This method will get a page of the user notifications, sortable by created_at.
+	GetAllOfUser(
+		c *gin.Context,
+		db Querier,
+		userID int32,
+		f filters.Filters,
+	) ([]models.Notification, filters.Metadata, error)
+
+	// This method will count the user notifications that weren't read.
+	CountUnread(c *gin.Context, db Querier, userID int32) (int, error)
+
+	// This method will mark a notification of the user read, by id.
+	MarkRead(c *gin.Context, db Querier, id, userID int32) error
+
+	// This method will mark every notification of the user read.
+	MarkAllRead(c *gin.Context, db Querier, userID int32) error
+}
+
+type notificationRepo struct{}
+
+func NewNotificationRepository() NotificationRepository {
+	return &notificationRepo{}
+}
+
+func (repo *notificationRepo) Create(ctx context.Context, db Querier, n *models.Notification) error {
+	if n.Data == nil {
+		n.Data = map[string]any{}
+	}
+
+	query := `
+		INSERT INTO notifications (type, message, data, user_id, product_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := db.QueryRow(ctx, query, n.Type, n.Message, n.Data, n.UserID, n.ProductID).
+		Scan(&n.ID, &n.CreatedAt)
+	if err != nil {
+		return Parse(err, "Notification", "Create", Constraints{
+			ForeignKeyViolationCode: "user or product",
+		})
+	}
+
+	return nil
+}
+
+func (repo *notificationRepo) CreateMany(
+	ctx context.Context,
+	db Querier,
+	notifications []models.Notification,
+) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	var (
+		types      = make([]string, len(notifications))
+		messages   = make([]string, len(notifications))
+		data       = make([]string, len(notifications))
+		userIDs    = make([]int32, len(notifications))
+		productIDs = make([]pgtype.Int4, len(notifications))
+	)
+	for i, n := range notifications {
+		if n.Data == nil {
+			n.Data = map[string]any{}
+		}
+		encoded, err := json.Marshal(n.Data)
+		if err != nil {
+			return Parse(err, "Notification", "CreateMany", make(Constraints))
+		}
+
+		types[i] = string(n.Type)
+		messages[i] = n.Message
+		data[i] = string(encoded)
+		userIDs[i] = n.UserID
+		productIDs[i] = n.ProductID
+	}
+
+	query := `
+		INSERT INTO notifications (type, message, data, user_id, product_id)
+		SELECT t::notification_type, m, d::jsonb, u, p
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::int[], $5::int[]) AS n(t, m, d, u, p)
+	`
+
+	_, err := db.Exec(ctx, query, types, messages, data, userIDs, productIDs)
+	if err != nil {
+		return Parse(err, "Notification", "CreateMany", Constraints{
+			ForeignKeyViolationCode: "user or product",
+		})
+	}
+
+	return nil
+}
+
+func (repo *notificationRepo) GetAllOfUser(
+	c *gin.Context,
+	db Querier,
+	userID int32,
+	f filters.Filters,
+) ([]models.Notification, filters.Metadata, error) {
+	cursorCondition, cursorArgs := f.CursorCondition("id", 4)
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS total_records,
+			id, type, message, data, read_at, created_at, product_id,
+			%s::text AS cursor_value
+		FROM notifications
+		WHERE user_id = $3 AND %s
+		ORDER BY %s
+		LIMIT $1 OFFSET $2
+	`, f.CountSQL(), f.SortColumn(), cursorCondition, f.OrderBy("id"))
+
+	args := append([]any{f.Limit(), f.Offset(), userID}, cursorArgs...)
+	rows, err := db.Query(c, query, args...)
+	if err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Notification", "GetAllOfUser", make(Constraints))
+	}
+	defer rows.Close()
+
+	var (
+		totalRecords  int
+		notifications []models.Notification
+		keys          []filters.Keyset
+	)
+	for rows.Next() {
+		var (
+			n   models.Notification
+			key filters.Keyset
+		)
+		err = rows.Scan(
+			&totalRecords,
+			&n.ID,
+			&n.Type,
+			&n.Message,
+			&n.Data,
+			&n.ReadAt,
+			&n.CreatedAt,
+			&n.ProductID,
+			&key.Value,
+		)
+		if err != nil {
+			return nil, filters.Metadata{}, Parse(err, "Notification", "GetAllOfUser", make(Constraints))
+		}
+		n.UserID = userID
+		key.ID = int64(n.ID)
+		notifications = append(notifications, n)
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Notification", "GetAllOfUser", make(Constraints))
+	}
+
+	notifications, metadata := filters.Paginate(f, notifications, keys, totalRecords)
+	return notifications, metadata, nil
+}
+
+func (repo *notificationRepo) CountUnread(c *gin.Context, db Querier, userID int32) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM notifications
+		WHERE user_id = $1 AND read_at IS NULL
+	`
+
+	var count int
+	err := db.QueryRow(c, query, userID).Scan(&count)
+	if err != nil {
+		return 0, Parse(err, "Notification", "CountUnread", make(Constraints))
+	}
+
+	return count, nil
+}
+
+func (repo *notificationRepo) MarkRead(c *gin.Context, db Querier, id, userID int32) error {
+	query := `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := db.Exec(c, query, id, userID)
+	if err != nil {
+		return Parse(err, "Notification", "MarkRead", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Notification", "MarkRead", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *notificationRepo) MarkAllRead(c *gin.Context, db Querier, userID int32) error {
+	query := `
+		UPDATE notifications
+		SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL
+	`
+
+	_, err := db.Exec(c, query, userID)
+	if err != nil {
+		return Parse(err, "Notification", "MarkAllRead", make(Constraints))
+	}
+
+	return nil
+}
@@ -6,10 +6,22 @@ import (
 )
 
 type OrderDetailsRepository interface {
-	// Create order details
+	// This method will turn the cart items into the order lines.
+	// Each line takes a snapshot of the product name, brand, color, size, thumbnail,
+	// unit price and the discount running on the variant at the time of the checkout.
+	// The items of deleted variants or products are left out.
 	//
-	// required columns: product_id, quantity, total_price, order_id
-	Create(ctx *gin.Context, db Querier, orderDetails *models.OrderDetails) error
+	// By: cart_id.
+	// Returns: the number of created lines.
+	CreateFromCart(ctx *gin.Context, db Querier, orderID, cartID int32) (int64, error)
+
+	// This method will get the lines of an order.
+	//
+	// By: order_id.
+	GetAllOfOrder(ctx *gin.Context, db Querier, orderID int32) ([]models.OrderDetails, error)
+
+	// This method will check whether an order line shows the given thumbnail.
+	ShowsThumbnail(ctx *gin.Context, db Querier, thumbnail string) (bool, error)
 }
 
 type orderDetailsRepo struct{}
@@ -18,29 +30,53 @@ func NewOrderDetailsRepository() OrderDetailsRepository {
 	return &orderDetailsRepo{}
 }
 
-func (r *orderDetailsRepo) Create(
+func (r *orderDetailsRepo) CreateFromCart(
 	ctx *gin.Context,
 	db Querier,
-	orderDetails *models.OrderDetails,
-) error {
+	orderID, cartID int32,
+) (int64, error) {
 	query := `
-		INSERT INTO order_details(product_id, quantity, total_price, order_id)
-		VALUES($1, $2, $3, $4)
+		INSERT INTO order_details(
+			product_id, quantity, total_price, order_id,
+			product_name, brand, color, size, thumbnail, unit_price,
+			discount_type, discount_value
+		)
+		SELECT
+			pv.id,
+			ci.quantity,
+			ci.total_price,
+			$1,
+			p.name,
+			b.brand,
+			c.color,
+			s.size || ' (' || s.label || ')',
+			p.thumbnail,
+			pv.price,
+			d.discount_type,
+			d.discount_value
+		FROM cart_items ci
+		JOIN product_variants pv ON pv.id = ci.product_id
+		JOIN products p ON p.id = pv.product_id
+		JOIN brands b ON b.id = p.brand_id
+		JOIN colors c ON c.id = pv.color_id
+		JOIN sizes s ON s.id = pv.size_id
+		LEFT JOIN LATERAL (
+			SELECT discounts.discount_type, discounts.discount_value
+			FROM variant_discount vd
+			JOIN discounts ON discounts.id = vd.discount_id
+			WHERE vd.variant_id = pv.id AND CURRENT_DATE BETWEEN discounts.start_date AND discounts.end_date
+			ORDER BY discounts.start_date DESC, discounts.id DESC
+			LIMIT 1
+		) d ON TRUE
+		WHERE ci.cart_id = $2 AND pv.deleted_at IS NULL AND p.deleted_at IS NULL
 	`
 
-	_, err := db.Exec(
-		ctx,
-		query,
-		orderDetails.ProductID,
-		orderDetails.Quantity,
-		orderDetails.TotalPrice,
-		orderDetails.OrderID,
-	)
+	result, err := db.Exec(ctx, query, orderID, cartID)
 	if err != nil {
-		return Parse(
+		return 0, Parse(
 			err,
 			"orderDetails",
-			"Create",
+			"CreateFromCart",
 			Constraints{
 				NotNullViolationCode:    "all columns",
 				UniqueViolationCode:     "order_id & product_id",
@@ -48,5 +84,76 @@ func (r *orderDetailsRepo) Create(
 			},
 		)
 	}
-	return nil
+
+	return result.RowsAffected(), nil
+}
+
+func (r *orderDetailsRepo) GetAllOfOrder(
+	ctx *gin.Context,
+	db Querier,
+	orderID int32,
+) ([]models.OrderDetails, error) {
+	query := `
+		SELECT
+			id, quantity, total_price, product_id, order_id,
+			product_name, brand, color, size, thumbnail, unit_price,
+			discount_type, discount_value
+		FROM order_details
+		WHERE order_id = $1
+		ORDER BY id
+	`
+
+	rows, err := db.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, Parse(err, "orderDetails", "GetAllOfOrder", make(Constraints))
+	}
+	defer rows.Close()
+
+	var lines []models.OrderDetails
+	for rows.Next() {
+		var od models.OrderDetails
+		err = rows.Scan(
+			&od.ID,
+			&od.Quantity,
+			&od.TotalPrice,
+			&od.ProductID,
+			&od.OrderID,
+			&od.ProductName,
+			&od.Brand,
+			&od.Color,
+			&od.Size,
+			&od.Thumbnail,
+			&od.UnitPrice,
+			&od.DiscountType,
+			&od.DiscountValue,
+		)
+		if err != nil {
+			return nil, Parse(err, "orderDetails", "GetAllOfOrder", make(Constraints))
+		}
+		lines = append(lines, od)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "orderDetails", "GetAllOfOrder", make(Constraints))
+	}
+
+	return lines, nil
+}
+
+func (r *orderDetailsRepo) ShowsThumbnail(
+	ctx *gin.Context,
+	db Querier,
+	thumbnail string,
+) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM order_details WHERE thumbnail = $1)
+	`
+
+	var shown bool
+	err := db.QueryRow(ctx, query, thumbnail).Scan(&shown)
+	if err != nil {
+		return false, Parse(err, "orderDetails", "ShowsThumbnail", make(Constraints))
+	}
+
+	return shown, nil
 }
@@ -1,12 +1,47 @@
 package database
 
 import (
+	"fmt"
+
 	"github.com/gin-gonic/gin"
 	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
 )
 
 type OrderRepository interface {
 	Create(ctx *gin.Context, db Querier, order *models.Order) (int32, error)
+
+	// This method will get an order.
+	//
+	// By: id.
+	Get(ctx *gin.Context, db Querier, id int32) (*models.Order, error)
+
+	// This method will update the order_status column,
+	// cancelled_at is set when the new status is cancelled.
+	// By: id.
+	// Returns: the status the order had before.
+	UpdateStatus(
+		ctx *gin.Context,
+		db Querier,
+		id int32,
+		status models.OrderStatus,
+	) (models.OrderStatus, error)
+
+	// This method will get a page of all the orders,
+	// sortable by created_at and total_price.
+	GetAll(ctx *gin.Context, db Querier, f filters.Filters) ([]models.Order, filters.Metadata, error)
+
+	// This method will get a page of the user orders,
+	// sortable by created_at and total_price.
+	GetAllOfUser(
+		ctx *gin.Context,
+		db Querier,
+		userID int32,
+		f filters.Filters,
+	) ([]models.Order, filters.Metadata, error)
+
+	// This method will check whether the user has a delivered order of a variant of the product.
+	HasReceivedProduct(ctx *gin.Context, db Querier, userID, productID int32) (bool, error)
 }
 
 type orderRepo struct{}
@@ -50,8 +85,180 @@ func (r *orderRepo) Create(ctx *gin.Context, db Querier, order *models.Order) (i
 	return orderID, nil
 }
 
-func (r *orderRepo) GetAll(ctx *gin.Context, db Querier) {
+func (r *orderRepo) Get(ctx *gin.Context, db Querier, id int32) (*models.Order, error) {
+	query := `
+		SELECT
+			id, town, street, address, name, phone_number, total_price, city_id, user_id,
+			order_status, created_at, updated_at, cancelled_at
+		FROM orders
+		WHERE id = $1
+	`
+
+	var o models.Order
+	err := db.QueryRow(ctx, query, id).Scan(
+		&o.ID,
+		&o.Town,
+		&o.Street,
+		&o.Address,
+		&o.Name,
+		&o.PhoneNumber,
+		&o.TotalPrice,
+		&o.CityID,
+		&o.UserID,
+		&o.OrderStatus,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.CancelledAt,
+	)
+	if err != nil {
+		return nil, Parse(err, "Order", "Get", make(Constraints))
+	}
+
+	return &o, nil
+}
+
+func (r *orderRepo) UpdateStatus(
+	ctx *gin.Context,
+	db Querier,
+	id int32,
+	status models.OrderStatus,
+) (models.OrderStatus, error) {
+	query := `
+		UPDATE orders o
+		SET
+			order_status = $2,
+			cancelled_at = CASE WHEN $2 = 'cancelled' THEN NOW() ELSE o.cancelled_at END
+		FROM (
+			SELECT id, order_status FROM orders
+			WHERE id = $1
+			FOR UPDATE
+		) previous
+		WHERE o.id = previous.id
+		RETURNING COALESCE(previous.order_status::text, '')
+	`
+
+	var previous models.OrderStatus
+	err := db.QueryRow(ctx, query, id, status).Scan(&previous)
+	if err != nil {
+		return "", Parse(err, "Order", "UpdateStatus", Constraints{
+			InvalidTextRepresentationCode: "status",
+		})
+	}
+
+	return previous, nil
+}
+
+func (r *orderRepo) GetAll(
+	ctx *gin.Context,
+	db Querier,
+	f filters.Filters,
+) ([]models.Order, filters.Metadata, error) {
+	return r.getPage(ctx, db, "GetAll", "TRUE", nil, f)
 }
 
-func (r *orderRepo) GetByUserID(ctx *gin.Context, db Querier) {
+func (r *orderRepo) GetAllOfUser(
+	ctx *gin.Context,
+	db Querier,
+	userID int32,
+	f filters.Filters,
+) ([]models.Order, filters.Metadata, error) {
+	return r.getPage(ctx, db, "GetAllOfUser", "user_id = $3", []any{userID}, f)
+}
+
+// getPage reads a page of the orders matching the condition,
+// its placeholders have to start at $3 since $1 and $2 are the limit and offset.
+func (r *orderRepo) getPage(
+	ctx *gin.Context,
+	db Querier,
+	method string,
+	condition string,
+	conditionArgs []any,
+	f filters.Filters,
+) ([]models.Order, filters.Metadata, error) {
+	cursorCondition, cursorArgs := f.CursorCondition("id", len(conditionArgs)+3)
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS total_records,
+			id, town, street, address, name, phone_number, total_price, city_id, user_id,
+			order_status, created_at, updated_at, cancelled_at,
+			%s::text AS cursor_value
+		FROM orders
+		WHERE %s AND %s
+		ORDER BY %s
+		LIMIT $1 OFFSET $2
+	`, f.CountSQL(), f.SortColumn(), condition, cursorCondition, f.OrderBy("id"))
+
+	args := append([]any{f.Limit(), f.Offset()}, conditionArgs...)
+	args = append(args, cursorArgs...)
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Order", method, make(Constraints))
+	}
+	defer rows.Close()
+
+	var (
+		totalRecords int
+		orders       []models.Order
+		keys         []filters.Keyset
+	)
+	for rows.Next() {
+		var (
+			o   models.Order
+			key filters.Keyset
+		)
+		err = rows.Scan(
+			&totalRecords,
+			&o.ID,
+			&o.Town,
+			&o.Street,
+			&o.Address,
+			&o.Name,
+			&o.PhoneNumber,
+			&o.TotalPrice,
+			&o.CityID,
+			&o.UserID,
+			&o.OrderStatus,
+			&o.CreatedAt,
+			&o.UpdatedAt,
+			&o.CancelledAt,
+			&key.Value,
+		)
+		if err != nil {
+			return nil, filters.Metadata{}, Parse(err, "Order", method, make(Constraints))
+		}
+		key.ID = int64(o.ID)
+		orders = append(orders, o)
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Order", method, make(Constraints))
+	}
+
+	orders, metadata := filters.Paginate(f, orders, keys, totalRecords)
+	return orders, metadata, nil
+}
+
+func (r *orderRepo) HasReceivedProduct(
+	ctx *gin.Context,
+	db Querier,
+	userID, productID int32,
+) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM orders o
+			JOIN order_details od ON od.order_id = o.id
+			JOIN product_variants pv ON pv.id = od.product_id
+			WHERE o.user_id = $1 AND pv.product_id = $2 AND o.order_status = 'delivered'
+		)
+	`
+
+	var received bool
+	err := db.QueryRow(ctx, query, userID, productID).Scan(&received)
+	if err != nil {
+		return false, Parse(err, "Order", "HasReceivedProduct", make(Constraints))
+	}
+
+	return received, nil
 }
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type ProductEventRepository interface {
+	// This method will create the events in a single statement.
+	//
+	// Columns required: event, product_id, user_id or anonymous_id, created_at.
+	CreateMany(ctx context.Context, db Querier, events []models.ProductEvent) error
+
+	// This method will get the products the user or the guest with the anonymous id viewed,
+	// the last viewed first. Only the published products are listed, an anonymousID other
+	// than "" adds the views the user made as a guest before logging in.
+	GetRecentlyViewed(
+		c *gin.Context,
+		db Querier,
+		userID int32,
+		anonymousID string,
+		limit int,
+	) ([]ViewedProduct, error)
+
+	// This method will recompute the daily trending scores of the products since the time.
+	// Every customer who viewed a product on a day scores 1, added it to the cart 3 and ordered it 5,
+	// so the same customer repeating the same action doesn't make a product trend.
+	// Returns: the number of scores.
+	RefreshTrending(ctx context.Context, db Querier, since time.Time) (int64, error)
+
+	// This method will get the published products in stock with the highest daily
+	// trending scores since the day of the time, summed up.
+	GetTrending(c *gin.Context, db Querier, since time.Time, limit int) ([]TrendingProduct, error)
+
+	// This method will delete the events made before the time.
+	// Returns: the number of deleted events.
+	PurgeBefore(ctx context.Context, db Querier, before time.Time) (int64, error)
+}
+
+type productEventRepo struct{}
+
+func NewProductEventRepository() ProductEventRepository {
+	return &productEventRepo{}
+}
+
+// ViewedProduct is a product a customer viewed, with the last time they viewed it.
+type ViewedProduct struct {
+	Product
+	ViewedAt time.Time `json:"viewedAt"`
+}
+
+// TrendingProduct is a popular product, with the score it's ranked by.
+type TrendingProduct struct {
+	Product
+	Score float64 `json:"score"`
+}
+
+func (repo *productEventRepo) CreateMany(
+	ctx context.Context,
+	db Querier,
+	events []models.ProductEvent,
+) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	types := make([]string, len(events))
+	productIDs := make([]int32, len(events))
+	userIDs := make([]pgtype.Int4, len(events))
+	anonymousIDs := make([]pgtype.Text, len(events))
+	createdAts := make([]time.Time, len(events))
+	for i, e := range events {
+		types[i] = string(e.Event)
+		productIDs[i] = e.ProductID
+		userIDs[i] = e.UserID
+		anonymousIDs[i] = e.AnonymousID
+		createdAts[i] = e.CreatedAt
+	}
+
+	// the events of the products deleted in the meantime are left out,
+	// so a single one of them doesn't fail the whole batch.
+	query := `
+		INSERT INTO product_events (event, product_id, user_id, anonymous_id, created_at)
+		SELECT e.event::product_event_type, e.product_id, e.user_id, e.anonymous_id, e.created_at
+		FROM unnest($1::text[], $2::int[], $3::int[], $4::text[], $5::timestamptz[])
+			AS e(event, product_id, user_id, anonymous_id, created_at)
+		JOIN products p ON p.id = e.product_id
+		LEFT JOIN users u ON u.id = e.user_id
+		WHERE e.user_id IS NULL OR u.id IS NOT NULL
+	`
+
+	_, err := db.Exec(ctx, query, types, productIDs, userIDs, anonymousIDs, createdAts)
+	if err != nil {
+		return Parse(err, "ProductEvent", "CreateMany", Constraints{
+			ForeignKeyViolationCode: "product or user",
+			CheckViolationCode:      "user or anonymous id",
+		})
+	}
+
+	return nil
+}
+
+func (repo *productEventRepo) GetRecentlyViewed(
+	c *gin.Context,
+	db Querier,
+	userID int32,
+	anonymousID string,
+	limit int,
+) ([]ViewedProduct, error) {
+	query := `
+		WITH viewed AS (
+			SELECT product_id, MAX(created_at) AS viewed_at
+			FROM product_events
+			WHERE event = 'view' AND (user_id = $1 OR ($2 <> '' AND anonymous_id = $2))
+			GROUP BY product_id
+		)
+		SELECT ` + recommendedProductSQL + `, v.viewed_at
+		FROM viewed v
+		JOIN products p ON p.id = v.product_id
+		JOIN brands b ON b.id = p.brand_id
+		JOIN categories c ON c.id = p.product_category
+		WHERE p.deleted_at IS NULL AND p.status = 'published'
+		ORDER BY v.viewed_at DESC
+		LIMIT $3
+	`
+
+	rows, err := db.Query(c, query, userID, anonymousID, limit)
+	if err != nil {
+		return nil, Parse(err, "ProductEvent", "GetRecentlyViewed", make(Constraints))
+	}
+	defer rows.Close()
+
+	var products []ViewedProduct
+	for rows.Next() {
+		var p ViewedProduct
+		err = rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Slug,
+			&p.Thumbnail,
+			&p.Brand,
+			&p.Category,
+			&p.Price,
+			&p.Rating,
+			&p.Status,
+			&p.PublishedAt,
+			&p.ViewedAt,
+		)
+		if err != nil {
+			return nil, Parse(err, "ProductEvent", "GetRecentlyViewed", make(Constraints))
+		}
+		products = append(products, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "ProductEvent", "GetRecentlyViewed", make(Constraints))
+	}
+
+	return products, nil
+}
+
+func (repo *productEventRepo) RefreshTrending(
+	ctx context.Context,
+	db Querier,
+	since time.Time,
+) (int64, error) {
+	_, err := db.Exec(ctx, "DELETE FROM product_trend_scores")
+	if err != nil {
+		return 0, Parse(err, "ProductEvent", "RefreshTrending", make(Constraints))
+	}
+
+	query := `
+		WITH signals AS (
+			SELECT
+				product_id,
+				created_at::date AS day,
+				COUNT(DISTINCT COALESCE('u' || user_id, 'a' || anonymous_id))
+					* CASE event WHEN 'view' THEN 1 ELSE 3 END AS score
+			FROM product_events
+			WHERE created_at >= $1::date
+			GROUP BY product_id, day, event
+
+			UNION ALL
+
+			SELECT pv.product_id, o.created_at::date, COUNT(DISTINCT o.user_id) * 5
+			FROM order_details od
+			JOIN orders o ON o.id = od.order_id
+			JOIN product_variants pv ON pv.id = od.product_id
+			WHERE o.created_at >= $1::date AND o.order_status IS DISTINCT FROM 'cancelled'
+			GROUP BY pv.product_id, o.created_at::date
+		)
+		INSERT INTO product_trend_scores (product_id, day, score)
+		SELECT product_id, day, SUM(score)
+		FROM signals
+		GROUP BY product_id, day
+	`
+
+	result, err := db.Exec(ctx, query, since)
+	if err != nil {
+		return 0, Parse(err, "ProductEvent", "RefreshTrending", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (repo *productEventRepo) GetTrending(
+	c *gin.Context,
+	db Querier,
+	since time.Time,
+	limit int,
+) ([]TrendingProduct, error) {
+	query := `
+		WITH scored AS (
+			SELECT product_id, SUM(score) AS score
+			FROM product_trend_scores
+			WHERE day >= $1::date
+			GROUP BY product_id
+		)
+		SELECT ` + recommendedProductSQL + `, s.score
+		FROM scored s
+		JOIN products p ON p.id = s.product_id
+		JOIN brands b ON b.id = p.brand_id
+		JOIN categories c ON c.id = p.product_category
+		WHERE ` + listableProductSQL + `
+		ORDER BY s.score DESC, p.id
+		LIMIT $2
+	`
+
+	rows, err := db.Query(c, query, since, limit)
+	if err != nil {
+		return nil, Parse(err, "ProductEvent", "GetTrending", make(Constraints))
+	}
+	defer rows.Close()
+
+	var products []TrendingProduct
+	for rows.Next() {
+		var p TrendingProduct
+		err = rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Slug,
+			&p.Thumbnail,
+			&p.Brand,
+			&p.Category,
+			&p.Price,
+			&p.Rating,
+			&p.Status,
+			&p.PublishedAt,
+			&p.Score,
+		)
+		if err != nil {
+			return nil, Parse(err, "ProductEvent", "GetTrending", make(Constraints))
+		}
+		products = append(products, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "ProductEvent", "GetTrending", make(Constraints))
+	}
+
+	return products, nil
+}
+
+func (repo *productEventRepo) PurgeBefore(
+	ctx context.Context,
+	db Querier,
+	before time.Time,
+) (int64, error) {
+	query := `
+		DELETE FROM product_events
+		WHERE created_at < $1
+	`
+
+	result, err := db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, Parse(err, "ProductEvent", "PurgeBefore", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -18,24 +20,90 @@ type ProductRepository interface {
 		prodFilter *filters.ProductFilterOptions,
 	) ([]Product, filters.Metadata, error)
 
-	GetDetails(ctx *gin.Context, db Querier, productID int) (*ProductDetails, error)
+	// This method will count the products matching the filters along every facet,
+	// each facet ignores its own filter so the other options stay selectable.
+	// The given attributes get a facet each, unless none of the products has them.
+	GetFacets(
+		ctx *gin.Context,
+		db Querier,
+		prodFilter *filters.ProductFilterOptions,
+		attributes []models.Attribute,
+	) (*ProductFacets, error)
+
+	// Get the product details, when publishedOnly is set a product
+	// that isn't published is treated as not found.
+	GetDetails(
+		ctx *gin.Context,
+		db Querier,
+		productID int,
+		publishedOnly bool,
+	) (*ProductDetails, error)
+
+	// Get the product id by its current slug.
+	GetIDBySlug(ctx *gin.Context, db Querier, slug string) (int, error)
 
 	Get(ctx *gin.Context, db Querier, productID int) (*models.Product, error)
 
 	// This function will create a product.
 	//
-	// Columns required: name, details, thumbnail, brand_id, product_category.
+	// Columns required: name, details, thumbnail, brand_id, product_category, status, published_at.
+	// The slug is generated out of the name by the database and set on the product.
 	// Returns: id.
 	Create(*gin.Context, Querier, *models.Product) (productID int32, err error)
 
 	// This Method will update the product.
+	// Renaming the product gives it a new slug, the old one keeps redirecting to it.
 	//
 	// Columns required: name, details, brand_id, product_category.
 	// By: id.
 	Update(*gin.Context, Querier, *models.Product) error
 
-	// This method will delete a product and all its variants
+	// This method will move the product through its publication lifecycle.
+	// Publishing sets published_at to now unless the product is already published,
+	// scheduling sets it to the given time and going back to draft clears it.
+	//
+	// Columns required: status, published_at.
+	// By: id.
+	UpdateStatus(
+		c *gin.Context,
+		db Querier,
+		id int32,
+		status models.ProductStatus,
+		publishAt pgtype.Timestamptz,
+	) error
+
+	// This method will set one of the product's ready gallery images as its thumbnail.
+	// Returns: the url of the replaced thumbnail when it isn't a gallery image nor shown
+	// by an order line, so its object can be deleted, an empty string otherwise.
+	SetThumbnail(c *gin.Context, db Querier, productID, imageID int32) (replaced string, err error)
+
+	// This method will publish every scheduled product whose publish time has come.
+	// Returns: the number of published products.
+	PublishScheduled(ctx context.Context, db Querier) (int64, error)
+
+	// This method will soft delete a product, its variants and images are kept
+	// until the product is purged, by id.
 	Delete(c *gin.Context, db Querier, id int32) error
+
+	// This method will restore a soft deleted product, by id.
+	// It fails with a unique violation if its name was taken meanwhile,
+	// and with a foreign key violation while its category is deleted.
+	Restore(c *gin.Context, db Querier, id int32) error
+
+	// This method will permanently delete the products soft deleted before the given time,
+	// along with their variants and images.
+	// Returns: the urls of the purged products thumbnails and images, except the ones
+	// the order lines still show.
+	PurgeDeleted(ctx context.Context, db Querier, before time.Time) ([]string, error)
+
+	// This method will get the names out of the given ones that products which aren't deleted
+	// already have, compared case insensitively.
+	GetExistingNames(c *gin.Context, db Querier, names []string) ([]string, error)
+
+	// This method will get a row per variant of every product that isn't deleted,
+	// a product without variants has a single row with the variant columns empty.
+	// Ordered by product id then variant id.
+	GetExportRows(c *gin.Context, db Querier) ([]ProductExportRow, error)
 }
 
 type productRepo struct{}
@@ -45,13 +113,16 @@ func NewProductRepository() ProductRepository {
 }
 
 type Product struct {
-	ID        int32   `json:"id"`
-	Name      string  `json:"name"`
-	Thumbnail string  `json:"thumbnail"`
-	Brand     string  `json:"brand"`
-	Category  string  `json:"category"`
-	Price     int     `json:"price"`
-	Rating    float32 `json:"rating"`
+	ID          int32                `json:"id"`
+	Name        string               `json:"name"`
+	Slug        string               `json:"slug"`
+	Thumbnail   string               `json:"thumbnail"`
+	Brand       string               `json:"brand"`
+	Category    string               `json:"category"`
+	Price       int                  `json:"price"`
+	Rating      float32              `json:"rating"`
+	Status      models.ProductStatus `json:"status"`
+	PublishedAt pgtype.Timestamptz   `json:"publishedAt"`
 }
 
 func (pr *productRepo) GetAll(
@@ -61,34 +132,48 @@ func (pr *productRepo) GetAll(
 	productFilters *filters.ProductFilterOptions,
 ) ([]Product, filters.Metadata, error) {
 	whereClause, args := productFilters.GetWhereClause()
+	cursorCondition, cursorArgs := f.CursorCondition("id", len(args)+3)
 	query := fmt.Sprintf(`
-	SELECT 
-  	COUNT(*) OVER() AS total_records,
-  	products.id,
-  	products.name,
-  	products.thumbnail,
-  	brands.brand,
-  	categories.name AS category,
-  	MIN(product_variants.price) AS min_price,
-  	COALESCE(ROUND(AVG(DISTINCT rating_review.rating)::numeric, 2), 0.00) AS avg_rating
-	FROM products
-	JOIN categories ON categories.id = products.product_category
-	JOIN brands ON brands.id = products.brand_id
-	JOIN product_variants ON product_variants.product_id = products.id
-	LEFT JOIN rating_review ON rating_review.product_id = products.id
-	%s
-	GROUP BY 
-  	products.id,
-  	products.name,
-  	products.thumbnail,
-  	brands.brand,
-  	categories.name
-	ORDER BY %s %s, products.id ASC
+	SELECT
+		%s AS total_records,
+		id, name, slug, thumbnail, brand, category, price, rating, status, published_at,
+		%s::text AS cursor_value
+	FROM (
+		SELECT
+			products.id,
+			products.name,
+			products.slug,
+			products.thumbnail,
+			brands.brand,
+			categories.name AS category,
+			MIN(product_variants.price) AS price,
+			COALESCE(ROUND(AVG(DISTINCT rating_review.rating)::numeric, 2), 0.00) AS rating,
+			products.status,
+			products.published_at,
+			%s AS relevance
+		FROM products
+		JOIN categories ON categories.id = products.product_category
+		JOIN brands ON brands.id = products.brand_id
+		JOIN product_variants ON product_variants.product_id = products.id AND product_variants.deleted_at IS NULL
+		LEFT JOIN rating_review ON rating_review.product_id = products.id
+		%s
+		GROUP BY
+			products.id,
+			products.name,
+			products.thumbnail,
+			brands.brand,
+			categories.name,
+			products.status,
+			products.published_at
+	) AS listing
+	WHERE %s
+	ORDER BY %s
 	LIMIT $1 OFFSET $2
-	`, whereClause, f.SortColumn(), f.SortDirection())
+	`, f.CountSQL(), f.SortColumn(), productFilters.RelevanceSQL(), whereClause, cursorCondition, f.OrderBy("id"))
 
 	fullArgs := []any{f.Limit(), f.Offset()}
 	fullArgs = append(fullArgs, args...)
+	fullArgs = append(fullArgs, cursorArgs...)
 	rows, err := db.Query(ctx, query, fullArgs...)
 	if err != nil {
 		return nil, filters.Metadata{}, Parse(err, "Product", "GetAll", make(Constraints))
@@ -98,58 +183,360 @@ func (pr *productRepo) GetAll(
 	var (
 		totalRecords int
 		products     []Product
+		keys         []filters.Keyset
 	)
 	for rows.Next() {
-		var p Product
-		if err = rows.Scan(&totalRecords, &p.ID, &p.Name, &p.Thumbnail, &p.Brand, &p.Category, &p.Price, &p.Rating); err != nil {
+		var (
+			p   Product
+			key filters.Keyset
+		)
+		if err = rows.Scan(&totalRecords, &p.ID, &p.Name, &p.Slug, &p.Thumbnail, &p.Brand, &p.Category, &p.Price, &p.Rating, &p.Status, &p.PublishedAt, &key.Value); err != nil {
 			return nil, filters.Metadata{}, Parse(err, "Product", "GetAll", make(Constraints))
 		}
+		key.ID = int64(p.ID)
 		products = append(products, p)
+		keys = append(keys, key)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, filters.Metadata{}, Parse(err, "Product", "GetAll", make(Constraints))
 	}
 
-	metadata := filters.CalculateMetadata(totalRecords, f.Page, f.PageSize)
+	products, metadata := filters.Paginate(f, products, keys, totalRecords)
 
 	return products, metadata, nil
 }
 
+type FacetCount struct {
+	ID    int32  `json:"id"`
+	Name  string `json:"name"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+type RatingFacetCount struct {
+	MinRating int `json:"minRating"`
+	Count     int `json:"count"`
+}
+
+type PriceRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type NumberRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// AttributeFacet counts the products by their values of a text or enum attribute,
+// a number attribute has the range of its values instead.
+type AttributeFacet struct {
+	ID     int32                `json:"id"`
+	Code   string               `json:"code"`
+	Name   string               `json:"name"`
+	Type   models.AttributeType `json:"type"`
+	Unit   pgtype.Text          `json:"unit"`
+	Values []FacetCount         `json:"values,omitempty"`
+	Range  *NumberRange         `json:"range,omitempty"`
+}
+
+type ProductFacets struct {
+	Brands     []FacetCount       `json:"brands"`
+	Colors     []FacetCount       `json:"colors"`
+	Sizes      []FacetCount       `json:"sizes"`
+	Ratings    []RatingFacetCount `json:"ratings"`
+	Price      PriceRange         `json:"price"`
+	InStock    int                `json:"inStock"`
+	OnSale     int                `json:"onSale"`
+	Attributes []AttributeFacet   `json:"attributes"`
+}
+
+func (pr *productRepo) GetFacets(
+	ctx *gin.Context,
+	db Querier,
+	productFilters *filters.ProductFilterOptions,
+	attributes []models.Attribute,
+) (*ProductFacets, error) {
+	var (
+		facets ProductFacets
+		err    error
+	)
+
+	whereClause, args := productFilters.GetFacetWhereClause(filters.FacetBrand, "pv")
+	facets.Brands, err = getFacetCounts(ctx, db, fmt.Sprintf(`
+		SELECT brands.id, brands.brand, '', COUNT(DISTINCT products.id)
+		FROM products
+		JOIN brands ON brands.id = products.brand_id
+		JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+		%s
+		GROUP BY brands.id, brands.brand
+		ORDER BY brands.brand
+	`, whereClause), args)
+	if err != nil {
+		return nil, err
+	}
+
+	whereClause, args = productFilters.GetFacetWhereClause(filters.FacetColor, "pv")
+	facets.Colors, err = getFacetCounts(ctx, db, fmt.Sprintf(`
+		SELECT colors.id, colors.color, '', COUNT(DISTINCT products.id)
+		FROM products
+		JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+		JOIN colors ON colors.id = pv.color_id
+		%s
+		GROUP BY colors.id, colors.color
+		ORDER BY colors.color
+	`, whereClause), args)
+	if err != nil {
+		return nil, err
+	}
+
+	whereClause, args = productFilters.GetFacetWhereClause(filters.FacetSize, "pv")
+	facets.Sizes, err = getFacetCounts(ctx, db, fmt.Sprintf(`
+		SELECT sizes.id, sizes.size, sizes.label, COUNT(DISTINCT products.id)
+		FROM products
+		JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+		JOIN sizes ON sizes.id = pv.size_id
+		%s
+		GROUP BY sizes.id, sizes.size, sizes.label
+		ORDER BY sizes.label, sizes.size
+	`, whereClause), args)
+	if err != nil {
+		return nil, err
+	}
+
+	whereClause, args = productFilters.GetFacetWhereClause(filters.FacetPrice, "pv")
+	err = db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT COALESCE(MIN(pv.price), 0), COALESCE(MAX(pv.price), 0)
+		FROM products
+		JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+		%s
+	`, whereClause), args...).Scan(&facets.Price.Min, &facets.Price.Max)
+	if err != nil {
+		return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+	}
+
+	whereClause, args = productFilters.GetFacetWhereClause(filters.FacetStock, "pv")
+	err = db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT COUNT(DISTINCT products.id) FILTER (WHERE pv.quantity > 0)
+		FROM products
+		JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+		%s
+	`, whereClause), args...).Scan(&facets.InStock)
+	if err != nil {
+		return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+	}
+
+	whereClause, args = productFilters.GetFacetWhereClause(filters.FacetSale, "pv")
+	err = db.QueryRow(ctx, fmt.Sprintf(`
+		SELECT COUNT(DISTINCT products.id) FILTER (WHERE %s)
+		FROM products
+		JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+		%s
+	`, filters.OnSaleCondition("pv"), whereClause), args...).Scan(&facets.OnSale)
+	if err != nil {
+		return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+	}
+
+	// every bucket counts the products rated at least that much, like "4 stars & up".
+	whereClause, args = productFilters.GetFacetWhereClause(filters.FacetRating, "pv")
+	rows, err := db.Query(ctx, fmt.Sprintf(`
+		WITH matched AS (
+			SELECT DISTINCT products.id
+			FROM products
+			JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+			%s
+		), ratings AS (
+			SELECT matched.id, AVG(rating_review.rating) AS avg_rating
+			FROM matched
+			JOIN rating_review ON rating_review.product_id = matched.id
+			GROUP BY matched.id
+		)
+		SELECT buckets.min_rating, COUNT(ratings.id)
+		FROM generate_series(1, 4) AS buckets(min_rating)
+		LEFT JOIN ratings ON ratings.avg_rating >= buckets.min_rating
+		GROUP BY buckets.min_rating
+		ORDER BY buckets.min_rating DESC
+	`, whereClause), args...)
+	if err != nil {
+		return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r RatingFacetCount
+		if err = rows.Scan(&r.MinRating, &r.Count); err != nil {
+			return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+		}
+		facets.Ratings = append(facets.Ratings, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+	}
+
+	facets.Attributes = []AttributeFacet{}
+	for _, attr := range attributes {
+		facet, err := getAttributeFacet(ctx, db, productFilters, attr)
+		if err != nil {
+			return nil, err
+		}
+		if facet != nil {
+			facets.Attributes = append(facets.Attributes, *facet)
+		}
+	}
+
+	return &facets, nil
+}
+
+// getAttributeFacet counts the products by their values of the attribute,
+// it returns nil when none of the matching products has the attribute.
+func getAttributeFacet(
+	ctx *gin.Context,
+	db Querier,
+	productFilters *filters.ProductFilterOptions,
+	attr models.Attribute,
+) (*AttributeFacet, error) {
+	facet := AttributeFacet{
+		ID:   attr.ID,
+		Code: attr.Code,
+		Name: attr.Name,
+		Type: attr.Type,
+		Unit: attr.Unit,
+	}
+
+	whereClause, args := productFilters.GetFacetWhereClause(filters.AttributeFacet(attr.ID), "pv")
+	attributeArg := fmt.Sprintf("$%d", len(args)+1)
+	args = append(args, attr.ID)
+
+	if attr.Type == models.AttributeNumber {
+		var (
+			r     NumberRange
+			count int
+		)
+		err := db.QueryRow(ctx, fmt.Sprintf(`
+			SELECT COALESCE(MIN(pa.number_value), 0), COALESCE(MAX(pa.number_value), 0), COUNT(pa.product_id)
+			FROM product_attributes pa
+			WHERE pa.attribute_id = %s AND pa.product_id IN (
+				SELECT products.id
+				FROM products
+				JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+				%s
+			)
+		`, attributeArg, whereClause), args...).Scan(&r.Min, &r.Max, &count)
+		if err != nil {
+			return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+		}
+		if count == 0 {
+			return nil, nil
+		}
+
+		facet.Range = &r
+		return &facet, nil
+	}
+
+	values, err := getFacetCounts(ctx, db, fmt.Sprintf(`
+		SELECT COALESCE(ao.id, 0), COALESCE(ao.value, pa.text_value), '', COUNT(DISTINCT products.id)
+		FROM products
+		JOIN product_variants pv ON pv.product_id = products.id AND pv.deleted_at IS NULL
+		JOIN product_attributes pa ON pa.product_id = products.id AND pa.attribute_id = %s
+		LEFT JOIN attribute_options ao ON ao.id = pa.option_id
+		%s
+		GROUP BY 1, 2
+		ORDER BY MIN(ao.position), 2
+	`, attributeArg, whereClause), args)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	facet.Values = values
+	return &facet, nil
+}
+
+func getFacetCounts(ctx *gin.Context, db Querier, query string, args []any) ([]FacetCount, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+	}
+	defer rows.Close()
+
+	var counts []FacetCount
+	for rows.Next() {
+		var fc FacetCount
+		if err = rows.Scan(&fc.ID, &fc.Name, &fc.Label, &fc.Count); err != nil {
+			return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+		}
+		counts = append(counts, fc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Product", "GetFacets", make(Constraints))
+	}
+
+	return counts, nil
+}
+
 type ProductDetails struct {
-	ID         int32       `json:"id"`
-	Name       string      `json:"name"`
-	Details    pgtype.Text `json:"details"`
-	Thumbnail  string      `json:"thumbnail"`
-	BrandID    int         `json:"brandId"`
-	Brand      string      `json:"brand"`
-	CategoryID int         `json:"categoryId"`
-	Category   string      `json:"category"`
+	ID          int32                `json:"id"`
+	Name        string               `json:"name"`
+	Slug        string               `json:"slug"`
+	Details     pgtype.Text          `json:"details"`
+	Thumbnail   string               `json:"thumbnail"`
+	BrandID     int                  `json:"brandId"`
+	Brand       string               `json:"brand"`
+	CategoryID  int                  `json:"categoryId"`
+	Category    string               `json:"category"`
+	Status      models.ProductStatus `json:"status"`
+	PublishedAt pgtype.Timestamptz   `json:"publishedAt"`
+	// LowestPrice30Days is the lowest price any of the variants had in the last 30 days.
+	LowestPrice30Days pgtype.Int4 `json:"lowestPrice30Days"`
 }
 
 func (pr *productRepo) GetDetails(
 	ctx *gin.Context,
 	db Querier,
 	productID int,
+	publishedOnly bool,
 ) (*ProductDetails, error) {
 	query := `
 		SELECT 
 			p.id,
 			p.name,
+			p.slug,
 			p.details,
 			p.thumbnail,
 			p.brand_id,
 			b.brand,
 			p.product_category,
-			c.name as category
+			c.name as category,
+			p.status,
+			p.published_at,
+			(
+				-- the prices set within the window, and the one each variant had when it started.
+				SELECT MIN(vp.price)
+				FROM product_variants pv
+				CROSS JOIN LATERAL (
+					SELECT price FROM variant_prices
+					WHERE variant_id = pv.id AND created_at >= NOW() - INTERVAL '30 days'
+					UNION ALL
+					(
+						SELECT price FROM variant_prices
+						WHERE variant_id = pv.id AND created_at < NOW() - INTERVAL '30 days'
+						ORDER BY created_at DESC
+						LIMIT 1
+					)
+				) vp
+				WHERE pv.product_id = p.id AND pv.deleted_at IS NULL
+			) AS lowest_price_30_days
 		FROM products p
 		JOIN brands b ON p.brand_id = b.id
 		JOIN categories c ON p.product_category = c.id
-		WHERE p.id = $1
+		WHERE p.id = $1 AND p.deleted_at IS NULL AND (NOT $2 OR p.status = 'published')
 	`
 
 	var p ProductDetails
-	err := db.QueryRow(ctx, query, productID).
-		Scan(&p.ID, &p.Name, &p.Details, &p.Thumbnail, &p.BrandID, &p.Brand, &p.CategoryID, &p.Category)
+	err := db.QueryRow(ctx, query, productID, publishedOnly).
+		Scan(&p.ID, &p.Name, &p.Slug, &p.Details, &p.Thumbnail, &p.BrandID, &p.Brand, &p.CategoryID, &p.Category, &p.Status, &p.PublishedAt, &p.LowestPrice30Days)
 	if err != nil {
 		return nil, Parse(err, "Product", "Get", make(Constraints))
 	}
@@ -157,6 +544,57 @@ func (pr *productRepo) GetDetails(
 	return &p, nil
 }
 
+func (pr *productRepo) GetIDBySlug(ctx *gin.Context, db Querier, slug string) (int, error) {
+	query := `
+		SELECT id
+		FROM products
+		WHERE slug = $1 AND deleted_at IS NULL
+	`
+
+	var id int
+	err := db.QueryRow(ctx, query, slug).Scan(&id)
+	if err != nil {
+		return 0, Parse(err, "Product", "GetIDBySlug", make(Constraints))
+	}
+
+	return id, nil
+}
+
+func (pr *productRepo) SetThumbnail(
+	c *gin.Context,
+	db Querier,
+	productID, imageID int32,
+) (replaced string, err error) {
+	// the thumbnail uploaded with the product isn't part of the gallery,
+	// nothing references it anymore once it's replaced unless an order line shows it.
+	query := `
+		WITH old AS (
+			SELECT
+				p.thumbnail,
+				EXISTS (SELECT 1 FROM images WHERE images.image = p.thumbnail) AS in_gallery,
+				EXISTS (SELECT 1 FROM order_details od WHERE od.thumbnail = p.thumbnail) AS ordered
+			FROM products p
+			WHERE p.id = $1 AND p.deleted_at IS NULL
+		), updated AS (
+			UPDATE products
+			SET thumbnail = i.image
+			FROM images i
+			WHERE products.id = $1 AND products.deleted_at IS NULL
+				AND i.id = $2 AND i.product_id = products.id AND i.status = 'ready'
+			RETURNING products.id
+		)
+		SELECT CASE WHEN old.in_gallery OR old.ordered THEN '' ELSE old.thumbnail END
+		FROM old, updated
+	`
+
+	err = db.QueryRow(c, query, productID, imageID).Scan(&replaced)
+	if err != nil {
+		return "", Parse(err, "Product", "SetThumbnail", make(Constraints))
+	}
+
+	return replaced, nil
+}
+
 func (pr *productRepo) Get(
 	ctx *gin.Context,
 	db Querier,
@@ -166,19 +604,22 @@ func (pr *productRepo) Get(
 		SELECT 
 			id,
 			name,
+			slug,
 			details,
 			thumbnail,
+			status,
+			published_at,
 			created_at,
 			updated_at,
 			brand_id,
 			product_category
 		FROM products
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	var p models.Product
 	err := db.QueryRow(ctx, query, productID).
-		Scan(&p.ID, &p.Name, &p.Details, &p.Thumbnail, &p.CreatedAt, &p.UpdatedAt, &p.BrandID, &p.ProductCategory)
+		Scan(&p.ID, &p.Name, &p.Slug, &p.Details, &p.Thumbnail, &p.Status, &p.PublishedAt, &p.CreatedAt, &p.UpdatedAt, &p.BrandID, &p.ProductCategory)
 	if err != nil {
 		return nil, Parse(err, "Product", "Get", make(Constraints))
 	}
@@ -192,16 +633,17 @@ func (pr *productRepo) Create(
 	p *models.Product,
 ) (productID int32, err error) {
 	query := `
-		INSERT INTO products (name, details, thumbnail, brand_id, product_category)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
+		INSERT INTO products (name, details, thumbnail, brand_id, product_category, status, published_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, slug
 	`
 
-	err = db.QueryRow(c, query, p.Name, p.Details, p.Thumbnail, p.BrandID, p.ProductCategory).
-		Scan(&productID)
+	err = db.QueryRow(c, query, p.Name, p.Details, p.Thumbnail, p.BrandID, p.ProductCategory, p.Status, p.PublishedAt).
+		Scan(&productID, &p.Slug)
 	if err != nil {
 		return 0, Parse(err, "Product", "Create", Constraints{
 			UniqueViolationCode:           "name",
+			CheckViolationCode:            "published_at",
 			ForeignKeyViolationCode:       "brand_id or product_category", // can’t distinguish which without inspecting error detail
 			NotNullViolationCode:          "name or thumbnail or brand_id or product_category",
 			StringDataRightTruncationCode: "name or thumbnail",
@@ -219,10 +661,10 @@ func (pr *productRepo) Update(
 	query := `
 		UPDATE products
 		SET name = $2, details = $3, brand_id = $4, product_category = $5
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	_, err := db.Exec(c, query, p.ID, p.Name, p.Details, p.BrandID, p.ProductCategory)
+	result, err := db.Exec(c, query, p.ID, p.Name, p.Details, p.BrandID, p.ProductCategory)
 	if err != nil {
 		return Parse(err, "Product", "Update", Constraints{
 			UniqueViolationCode:     "name",
@@ -231,17 +673,72 @@ func (pr *productRepo) Update(
 		})
 	}
 
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Product", "Update", make(Constraints))
+	}
+
+	return nil
+}
+
+func (pr *productRepo) UpdateStatus(
+	c *gin.Context,
+	db Querier,
+	id int32,
+	status models.ProductStatus,
+	publishAt pgtype.Timestamptz,
+) error {
+	query := `
+		UPDATE products
+		SET
+			status = $2,
+			published_at = CASE
+				WHEN $2::product_status = 'scheduled' THEN $3
+				WHEN $2::product_status = 'published' AND status = 'published' THEN published_at
+				WHEN $2::product_status = 'published' THEN NOW()
+				WHEN $2::product_status = 'draft' THEN NULL
+				ELSE published_at
+			END
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := db.Exec(c, query, id, status, publishAt)
+	if err != nil {
+		return Parse(err, "Product", "UpdateStatus", Constraints{
+			CheckViolationCode: "published_at",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Product", "UpdateStatus", make(Constraints))
+	}
+
 	return nil
 }
 
+func (pr *productRepo) PublishScheduled(ctx context.Context, db Querier) (int64, error) {
+	query := `
+		UPDATE products
+		SET status = 'published'
+		WHERE status = 'scheduled' AND published_at <= NOW() AND deleted_at IS NULL
+	`
+
+	result, err := db.Exec(ctx, query)
+	if err != nil {
+		return 0, Parse(err, "Product", "PublishScheduled", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
 func (pr *productRepo) Delete(
 	c *gin.Context,
 	db Querier,
 	id int32,
 ) error {
 	query := `
-		DELETE FROM products
-		WHERE id = $1
+		UPDATE products
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	result, err := db.Exec(c, query, id)
@@ -255,3 +752,218 @@ func (pr *productRepo) Delete(
 
 	return nil
 }
+
+func (pr *productRepo) Restore(
+	c *gin.Context,
+	db Querier,
+	id int32,
+) error {
+	query := `
+		UPDATE products
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	result, err := db.Exec(c, query, id)
+	if err != nil {
+		return Parse(err, "Product", "Restore", Constraints{
+			UniqueViolationCode:     "name",
+			ForeignKeyViolationCode: "product_category, the category is deleted",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Product", "Restore", make(Constraints))
+	}
+
+	return nil
+}
+
+func (pr *productRepo) PurgeDeleted(
+	ctx context.Context,
+	db Querier,
+	before time.Time,
+) ([]string, error) {
+	// the discounts only reference the variants, they're detached before the variants go.
+	query := `
+		WITH purged AS (
+			SELECT id FROM products
+			WHERE deleted_at < $1
+		), detached_discounts AS (
+			DELETE FROM variant_discount
+			WHERE variant_id IN (
+				SELECT pv.id FROM product_variants pv WHERE pv.product_id IN (SELECT id FROM purged)
+			)
+		), deleted_products AS (
+			DELETE FROM products
+			WHERE id IN (SELECT id FROM purged)
+			RETURNING id, thumbnail
+		)
+		SELECT url
+		FROM (
+			SELECT thumbnail AS url FROM deleted_products
+			UNION
+			SELECT url
+			FROM images, LATERAL (VALUES (images.image), (images.low_res_image), (images.original)) AS urls(url)
+			WHERE images.product_id IN (SELECT id FROM purged) AND url IS NOT NULL
+			UNION
+			SELECT r.url
+			FROM image_renditions r
+			JOIN images ON images.id = r.image_id
+			WHERE images.product_id IN (SELECT id FROM purged)
+		) AS purged_urls
+		WHERE NOT EXISTS (SELECT 1 FROM order_details od WHERE od.thumbnail = purged_urls.url)
+	`
+
+	rows, err := db.Query(ctx, query, before)
+	if err != nil {
+		return nil, Parse(err, "Product", "PurgeDeleted", make(Constraints))
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err = rows.Scan(&url); err != nil {
+			return nil, Parse(err, "Product", "PurgeDeleted", make(Constraints))
+		}
+		urls = append(urls, url)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Product", "PurgeDeleted", make(Constraints))
+	}
+
+	return urls, nil
+}
+
+func (pr *productRepo) GetExistingNames(
+	c *gin.Context,
+	db Querier,
+	names []string,
+) ([]string, error) {
+	query := `
+		SELECT name
+		FROM products
+		WHERE deleted_at IS NULL AND lower(name) = ANY(
+			SELECT lower(n) FROM unnest($1::text[]) AS n
+		)
+	`
+
+	rows, err := db.Query(c, query, names)
+	if err != nil {
+		return nil, Parse(err, "Product", "GetExistingNames", make(Constraints))
+	}
+
+	defer rows.Close()
+
+	var existing []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, Parse(err, "Product", "GetExistingNames", make(Constraints))
+		}
+		existing = append(existing, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Product", "GetExistingNames", make(Constraints))
+	}
+
+	return existing, nil
+}
+
+// ProductExportRow is a variant of a product along with the product columns.
+type ProductExportRow struct {
+	ProductID   int32
+	Name        string
+	Details     string
+	Brand       string
+	CategoryID  int32
+	Status      models.ProductStatus
+	PublishedAt pgtype.Timestamptz
+	Thumbnail   string
+	// Images are the largest renditions of the ready gallery images in order.
+	Images    []string
+	Color     pgtype.Text
+	Size      pgtype.Text
+	SizeLabel pgtype.Text
+	Price     pgtype.Int4
+	Quantity  pgtype.Int4
+	SKU       pgtype.Text
+	Barcode   pgtype.Text
+}
+
+func (pr *productRepo) GetExportRows(c *gin.Context, db Querier) ([]ProductExportRow, error) {
+	query := `
+		SELECT
+			p.id,
+			p.name,
+			COALESCE(p.details, ''),
+			b.brand,
+			p.product_category,
+			p.status,
+			p.published_at,
+			p.thumbnail,
+			COALESCE(
+				(
+					SELECT array_agg(i.image ORDER BY i.position, i.id)
+					FROM images i
+					WHERE i.product_id = p.id AND i.status = 'ready'
+				),
+				'{}'
+			),
+			c.color,
+			s.size,
+			s.label,
+			pv.price,
+			pv.quantity,
+			pv.sku,
+			pv.barcode
+		FROM products p
+		JOIN brands b ON b.id = p.brand_id
+		LEFT JOIN product_variants pv ON pv.product_id = p.id AND pv.deleted_at IS NULL
+		LEFT JOIN colors c ON c.id = pv.color_id
+		LEFT JOIN sizes s ON s.id = pv.size_id
+		WHERE p.deleted_at IS NULL
+		ORDER BY p.id, pv.id
+	`
+
+	rows, err := db.Query(c, query)
+	if err != nil {
+		return nil, Parse(err, "Product", "GetExportRows", make(Constraints))
+	}
+	defer rows.Close()
+
+	var exportRows []ProductExportRow
+	for rows.Next() {
+		var r ProductExportRow
+		err = rows.Scan(
+			&r.ProductID,
+			&r.Name,
+			&r.Details,
+			&r.Brand,
+			&r.CategoryID,
+			&r.Status,
+			&r.PublishedAt,
+			&r.Thumbnail,
+			&r.Images,
+			&r.Color,
+			&r.Size,
+			&r.SizeLabel,
+			&r.Price,
+			&r.Quantity,
+			&r.SKU,
+			&r.Barcode,
+		)
+		if err != nil {
+			return nil, Parse(err, "Product", "GetExportRows", make(Constraints))
+		}
+		exportRows = append(exportRows, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Product", "GetExportRows", make(Constraints))
+	}
+
+	return exportRows, nil
+}
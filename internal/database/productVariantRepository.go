@@ -1,31 +1,65 @@
 package database
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/models"
 )
 
 type ProductVariantRepository interface {
 	GetAllOfProduct(c *gin.Context, db Querier, productID int32) ([]ProductVariantDetails, error)
+
+	// Get the variant price, only the variants of published products can be bought.
 	GetPriceByID(c *gin.Context, db Querier, id int32) (int, error)
 
-	// This method will create a product variant.
+	// Get the id of the product the variant belongs to, by the variant id.
+	GetProductID(c *gin.Context, db Querier, variantID int32) (int32, error)
+
+	// This method will create a product variant out of stock and record its price,
+	// its quantity is added with a stock movement. The sku is generated when it's null.
 	//
-	// Columns required: quantity, price, product_id, color_id, size_id.
+	// Columns required: price, product_id, color_id, size_id, sku, barcode.
+	// Returns: id and sku set on the variant.
 	Create(*gin.Context, Querier, *models.ProductVariant) error
 
 	// This method will get a variant by id.
 	Get(c *gin.Context, db Querier, variantID int32) (ProductVariantDetails, error)
 
-	// This method will delete a variant by id.
+	// This method will soft delete a variant by id.
 	Delete(c *gin.Context, db Querier, variantID int) error
 
-	// This method will update the product variant.
+	// This method will restore a soft deleted variant by id.
+	// It fails with a unique violation if the same color and size were added meanwhile,
+	// and with a foreign key violation while its product, color or size is deleted.
+	Restore(c *gin.Context, db Querier, variantID int) error
+
+	// This method will permanently delete the variants soft deleted before the given time.
+	// Returns: the number of purged variants.
+	PurgeDeleted(ctx context.Context, db Querier, before time.Time) (int64, error)
+
+	// This method will update the product variant and record its price when it changes,
+	// its quantity only changes with stock movements. A null sku or barcode is kept as is,
+	// an empty barcode removes it.
 	//
-	// Columns required: quantity, price, color_id, size_id,
+	// Columns required: price, color_id, size_id, sku, barcode,
 	// By: id.
 	Update(*gin.Context, Querier, *models.ProductVariant) error
+
+	// This method will get the prices the variant had, the latest first.
+	GetPriceHistory(c *gin.Context, db Querier, variantID int32) ([]models.VariantPrice, error)
+
+	// This method will lock the product row until the transaction ends and get the lowest price
+	// of its variants, 0 when it has none. Its price changes are serialized this way, so the
+	// price a change started from is the one the previous change left, db has to be a transaction.
+	GetLowestPrice(c *gin.Context, db Querier, productID int32) (int, error)
+
+	// This method will get a variant along with its product by its sku or barcode,
+	// the one with the barcode first when a legacy sku is the same as another's barcode.
+	GetByCode(c *gin.Context, db Querier, code string) (VariantLookup, error)
 }
 
 type productVariantRepo struct{}
@@ -35,11 +69,20 @@ func NewProductVariantRepository() ProductVariantRepository {
 }
 
 type ProductVariantDetails struct {
-	ID       int32  `json:"id"`
-	Quantity int    `json:"quantity"`
-	Price    int    `json:"price"`
-	Color    string `json:"color"`
-	Size     string `json:"size"`
+	ID       int32       `json:"id"`
+	Quantity int         `json:"quantity"`
+	Price    int         `json:"price"`
+	Color    string      `json:"color"`
+	Size     string      `json:"size"`
+	SKU      string      `json:"sku"`
+	Barcode  pgtype.Text `json:"barcode"`
+}
+
+// VariantLookup is a variant found by its sku or barcode, along with its product.
+type VariantLookup struct {
+	ProductVariantDetails
+	ProductID   int32  `json:"productId"`
+	ProductName string `json:"productName"`
 }
 
 func (pvr *productVariantRepo) Get(
@@ -53,16 +96,18 @@ func (pvr *productVariantRepo) Get(
 			pv.quantity, 
 			pv.price, 
 			c.color, 
-			s.size || ' (' || s.label || ')' as size
+			s.size || ' (' || s.label || ')' as size,
+			pv.sku,
+			pv.barcode
 		FROM product_variants pv
 		JOIN colors c ON pv.color_id = c.id
 		JOIN sizes s ON pv.size_id = s.id
-		WHERE pv.id = $1
+		WHERE pv.id = $1 AND pv.deleted_at IS NULL
 	`
 
 	var pv ProductVariantDetails
 	err := db.QueryRow(c, query, variantID).
-		Scan(&pv.ID, &pv.Quantity, &pv.Price, &pv.Color, &pv.Size)
+		Scan(&pv.ID, &pv.Quantity, &pv.Price, &pv.Color, &pv.Size, &pv.SKU, &pv.Barcode)
 	if err != nil {
 		return ProductVariantDetails{}, Parse(err, "Product Variant", "Get", make(Constraints))
 	}
@@ -81,11 +126,13 @@ func (pvr *productVariantRepo) GetAllOfProduct(
 			pv.quantity, 
 			pv.price, 
 			c.color, 
-			s.size || ' (' || s.label || ')' as size
+			s.size || ' (' || s.label || ')' as size,
+			pv.sku,
+			pv.barcode
 		FROM product_variants pv
 		JOIN colors c ON pv.color_id = c.id
 		JOIN sizes s ON pv.size_id = s.id
-		WHERE pv.product_id = $1
+		WHERE pv.product_id = $1 AND pv.deleted_at IS NULL
 	`
 
 	rows, err := db.Query(c, query, productID)
@@ -103,6 +150,8 @@ func (pvr *productVariantRepo) GetAllOfProduct(
 			&pv.Price,
 			&pv.Color,
 			&pv.Size,
+			&pv.SKU,
+			&pv.Barcode,
 		)
 		if err != nil {
 			return nil, Parse(err, "Product Variant", "GetAllOfProduct", make(Constraints))
@@ -123,14 +172,23 @@ func (pvr *productVariantRepo) Create(
 	pv *models.ProductVariant,
 ) error {
 	query := `
-		INSERT INTO product_variants (quantity, price, product_id, color_id, size_id)
-		VALUES ($1, $2, $3, $4, $5)
+		WITH created AS (
+			INSERT INTO product_variants (quantity, price, product_id, color_id, size_id, sku, barcode)
+			VALUES (0, $1, $2, $3, $4, COALESCE($5, variant_sku($2, $3, $4)), NULLIF($6, ''))
+			RETURNING id, price, sku
+		), history AS (
+			INSERT INTO variant_prices (price, variant_id)
+			SELECT price, id FROM created
+		)
+		SELECT id, sku FROM created
 	`
 
-	_, err := db.Exec(c, query, pv.Quantity, pv.Price, pv.ProductID, pv.ColorID, pv.SizeID)
+	err := db.QueryRow(c, query, pv.Price, pv.ProductID, pv.ColorID, pv.SizeID, pv.SKU, pv.Barcode).
+		Scan(&pv.ID, &pv.SKU)
 	if err != nil {
 		return Parse(err, "Product Variant", "Create", Constraints{
-			UniqueViolationCode:     "variant",
+			UniqueViolationCode:     "variant, sku or barcode",
+			CheckViolationCode:      "barcode",
 			ForeignKeyViolationCode: "product_id or color_id or size_id",
 		})
 	}
@@ -140,9 +198,10 @@ func (pvr *productVariantRepo) Create(
 
 func (pvr *productVariantRepo) GetPriceByID(c *gin.Context, db Querier, id int32) (int, error) {
 	query := `
-		SELECT price
-		FROM product_variants
-		WHERE id = $1
+		SELECT pv.price
+		FROM product_variants pv
+		JOIN products p ON p.id = pv.product_id
+		WHERE pv.id = $1 AND pv.deleted_at IS NULL AND p.deleted_at IS NULL AND p.status = 'published'
 	`
 	var price int
 
@@ -153,14 +212,34 @@ func (pvr *productVariantRepo) GetPriceByID(c *gin.Context, db Querier, id int32
 	return price, nil
 }
 
+func (pvr *productVariantRepo) GetProductID(
+	c *gin.Context,
+	db Querier,
+	variantID int32,
+) (int32, error) {
+	query := `
+		SELECT product_id
+		FROM product_variants
+		WHERE id = $1
+	`
+	var productID int32
+
+	err := db.QueryRow(c, query, variantID).Scan(&productID)
+	if err != nil {
+		return 0, Parse(err, "Product Variant", "GetProductID", make(Constraints))
+	}
+	return productID, nil
+}
+
 func (pvr *productVariantRepo) Delete(
 	c *gin.Context,
 	db Querier,
 	variantID int,
 ) error {
 	query := `
-		DELETE FROM product_variants
-		WHERE id = $1
+		UPDATE product_variants
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	result, err := db.Exec(c, query, variantID)
@@ -175,29 +254,201 @@ func (pvr *productVariantRepo) Delete(
 	return nil
 }
 
-func (pvr *productVariantRepo) Update(
+func (pvr *productVariantRepo) Restore(
 	c *gin.Context,
 	db Querier,
-	pv *models.ProductVariant,
+	variantID int,
 ) error {
 	query := `
 		UPDATE product_variants
-		SET quantity = $2, price = $3, color_id = $4, size_id = $5
-		WHERE id = $1
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
 	`
 
-	result, err := db.Exec(c, query, pv.ID, pv.Quantity, pv.Price, pv.ColorID, pv.SizeID)
+	result, err := db.Exec(c, query, variantID)
 	if err != nil {
-		return Parse(err, "Product Variant", "Update", Constraints{
+		return Parse(err, "Product Variant", "Restore", Constraints{
 			UniqueViolationCode:     "product_id, color_id, size_id",
-			ForeignKeyViolationCode: "color_id or size_id",
-			NotNullViolationCode:    "quantity or price or color_id or size_id",
+			ForeignKeyViolationCode: "product_id or color_id or size_id, it is deleted",
 		})
 	}
 
 	if result.RowsAffected() == 0 {
-		return Parse(pgx.ErrNoRows, "Product Variant", "Update", make(Constraints))
+		return Parse(pgx.ErrNoRows, "Product Variant", "Restore", make(Constraints))
+	}
+
+	return nil
+}
+
+func (pvr *productVariantRepo) PurgeDeleted(
+	ctx context.Context,
+	db Querier,
+	before time.Time,
+) (int64, error) {
+	query := `
+		WITH purged AS (
+			SELECT id FROM product_variants
+			WHERE deleted_at < $1
+		), detached_discounts AS (
+			DELETE FROM variant_discount
+			WHERE variant_id IN (SELECT id FROM purged)
+		)
+		DELETE FROM product_variants
+		WHERE id IN (SELECT id FROM purged)
+	`
+
+	result, err := db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, Parse(err, "Product Variant", "PurgeDeleted", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (pvr *productVariantRepo) Update(
+	c *gin.Context,
+	db Querier,
+	pv *models.ProductVariant,
+) error {
+	query := `
+		WITH previous AS (
+			SELECT id, price FROM product_variants
+			WHERE id = $1 AND deleted_at IS NULL
+			FOR UPDATE
+		), updated AS (
+			UPDATE product_variants pv
+			SET
+				price = $2,
+				color_id = $3,
+				size_id = $4,
+				sku = COALESCE($5, pv.sku),
+				barcode = CASE WHEN $6::varchar IS NULL THEN pv.barcode ELSE NULLIF($6, '') END
+			FROM previous
+			WHERE pv.id = previous.id
+			RETURNING pv.id, pv.price, previous.price AS previous_price
+		), history AS (
+			INSERT INTO variant_prices (price, variant_id)
+			SELECT price, id FROM updated
+			WHERE price <> previous_price
+		)
+		SELECT id FROM updated
+	`
+
+	err := db.QueryRow(c, query, pv.ID, pv.Price, pv.ColorID, pv.SizeID, pv.SKU, pv.Barcode).
+		Scan(&pv.ID)
+	if err != nil {
+		return Parse(err, "Product Variant", "Update", Constraints{
+			UniqueViolationCode:     "product_id, color_id, size_id or sku or barcode",
+			CheckViolationCode:      "barcode",
+			ForeignKeyViolationCode: "color_id or size_id",
+			NotNullViolationCode:    "price or color_id or size_id",
+		})
 	}
 
 	return nil
 }
+
+func (pvr *productVariantRepo) GetPriceHistory(
+	c *gin.Context,
+	db Querier,
+	variantID int32,
+) ([]models.VariantPrice, error) {
+	query := `
+		SELECT id, price, created_at, variant_id
+		FROM variant_prices
+		WHERE variant_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := db.Query(c, query, variantID)
+	if err != nil {
+		return nil, Parse(err, "Product Variant", "GetPriceHistory", make(Constraints))
+	}
+	defer rows.Close()
+
+	var prices []models.VariantPrice
+	for rows.Next() {
+		var p models.VariantPrice
+		err = rows.Scan(&p.ID, &p.Price, &p.CreatedAt, &p.VariantID)
+		if err != nil {
+			return nil, Parse(err, "Product Variant", "GetPriceHistory", make(Constraints))
+		}
+		prices = append(prices, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Product Variant", "GetPriceHistory", make(Constraints))
+	}
+
+	return prices, nil
+}
+
+func (pvr *productVariantRepo) GetLowestPrice(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+) (int, error) {
+	// the lowest price is read by a statement of its own, so it sees the changes
+	// committed while waiting for the lock.
+	_, err := db.Exec(c, "SELECT id FROM products WHERE id = $1 FOR UPDATE", productID)
+	if err != nil {
+		return 0, Parse(err, "Product Variant", "GetLowestPrice", make(Constraints))
+	}
+
+	query := `
+		SELECT COALESCE(MIN(price), 0)
+		FROM product_variants
+		WHERE product_id = $1 AND deleted_at IS NULL
+	`
+	var price int
+
+	err = db.QueryRow(c, query, productID).Scan(&price)
+	if err != nil {
+		return 0, Parse(err, "Product Variant", "GetLowestPrice", make(Constraints))
+	}
+	return price, nil
+}
+
+func (pvr *productVariantRepo) GetByCode(
+	c *gin.Context,
+	db Querier,
+	code string,
+) (VariantLookup, error) {
+	query := `
+		SELECT
+			pv.id,
+			pv.quantity,
+			pv.price,
+			c.color,
+			s.size || ' (' || s.label || ')' as size,
+			pv.sku,
+			pv.barcode,
+			p.id,
+			p.name
+		FROM product_variants pv
+		JOIN products p ON p.id = pv.product_id
+		JOIN colors c ON pv.color_id = c.id
+		JOIN sizes s ON pv.size_id = s.id
+		WHERE (pv.sku = UPPER($1) OR pv.barcode = $1) AND pv.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY pv.barcode = $1 DESC NULLS LAST
+		LIMIT 1
+	`
+
+	var v VariantLookup
+	err := db.QueryRow(c, query, code).Scan(
+		&v.ID,
+		&v.Quantity,
+		&v.Price,
+		&v.Color,
+		&v.Size,
+		&v.SKU,
+		&v.Barcode,
+		&v.ProductID,
+		&v.ProductName,
+	)
+	if err != nil {
+		return VariantLookup{}, Parse(err, "Product Variant", "GetByCode", make(Constraints))
+	}
+
+	return v, nil
+}
@@ -0,0 +1,509 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+)
+
+type QuestionRepository interface {
+	// This method will create a pending question about a published product.
+	//
+	// Columns required: question, product_id, user_id.
+	// Returns: id, status, created_at and updated_at set on the question.
+	Create(c *gin.Context, db Querier, q *models.ProductQuestion) error
+
+	// This method will get a question, by id.
+	Get(c *gin.Context, db Querier, id int32) (*models.ProductQuestion, error)
+
+	// This method will get a page of the approved questions of the product along with
+	// their approved answers, the most upvoted first. Sortable by created_at and upvotes.
+	GetPageOfProduct(
+		c *gin.Context,
+		db Querier,
+		productID int32,
+		f filters.Filters,
+	) ([]QuestionDetails, filters.Metadata, error)
+
+	// This method will get a page of the questions in the status or having answers in the status,
+	// along with all their answers. Sortable by created_at and upvotes.
+	GetPageForModeration(
+		c *gin.Context,
+		db Querier,
+		status models.ModerationStatus,
+		f filters.Filters,
+	) ([]QuestionDetails, filters.Metadata, error)
+
+	// This method will update the status column.
+	//
+	// By: id.
+	SetStatus(c *gin.Context, db Querier, id int32, status models.ModerationStatus) error
+
+	// This method will delete a question of the user along with its answers, by id.
+	Delete(c *gin.Context, db Querier, id, userID int32) error
+
+	// This method will upvote an approved question for the user, upvoting it again does nothing.
+	Upvote(c *gin.Context, db Querier, id, userID int32) error
+
+	// This method will take back the upvote of the user on a question.
+	RemoveUpvote(c *gin.Context, db Querier, id, userID int32) error
+
+	// This method will create an answer to an approved question.
+	//
+	// Columns required: answer, author, status, question_id, user_id.
+	// Returns: id, created_at and updated_at set on the answer.
+	CreateAnswer(c *gin.Context, db Querier, a *models.ProductAnswer) error
+
+	// This method will update the status column of an answer.
+	//
+	// By: id.
+	// Returns: the answer and the status it had before.
+	SetAnswerStatus(
+		c *gin.Context,
+		db Querier,
+		id int32,
+		status models.ModerationStatus,
+	) (*models.ProductAnswer, models.ModerationStatus, error)
+
+	// This method will upvote an approved answer for the user, upvoting it again does nothing.
+	UpvoteAnswer(c *gin.Context, db Querier, id, userID int32) error
+
+	// This method will take back the upvote of the user on an answer.
+	RemoveAnswerUpvote(c *gin.Context, db Querier, id, userID int32) error
+}
+
+type questionRepo struct{}
+
+func NewQuestionRepository() QuestionRepository {
+	return &questionRepo{}
+}
+
+type QuestionDetails struct {
+	ID        int32                   `json:"id"`
+	Question  string                  `json:"question"`
+	Status    models.ModerationStatus `json:"status"`
+	Upvotes   int                     `json:"upvotes"`
+	CreatedAt time.Time               `json:"createdAt"`
+	ProductID int32                   `json:"productId"`
+	UserID    int32                   `json:"userId"`
+	FirstName string                  `json:"firstName"`
+	Answers   []AnswerDetails         `json:"answers"`
+}
+
+type AnswerDetails struct {
+	ID        int32                   `json:"id"`
+	Answer    string                  `json:"answer"`
+	Author    models.AnswerAuthor     `json:"author"`
+	Status    models.ModerationStatus `json:"status"`
+	Upvotes   int                     `json:"upvotes"`
+	CreatedAt time.Time               `json:"createdAt"`
+	UserID    int32                   `json:"userId"`
+	FirstName string                  `json:"firstName"`
+}
+
+func (repo *questionRepo) Create(c *gin.Context, db Querier, q *models.ProductQuestion) error {
+	query := `
+		INSERT INTO product_questions (question, product_id, user_id)
+		SELECT $1, p.id, $3
+		FROM products p
+		WHERE p.id = $2 AND p.deleted_at IS NULL AND p.status = 'published'
+		RETURNING id, status, created_at, updated_at
+	`
+
+	err := db.QueryRow(c, query, q.Question, q.ProductID, q.UserID).
+		Scan(&q.ID, &q.Status, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		return Parse(err, "Question", "Create", Constraints{
+			ForeignKeyViolationCode: "user",
+		})
+	}
+
+	return nil
+}
+
+func (repo *questionRepo) Get(c *gin.Context, db Querier, id int32) (*models.ProductQuestion, error) {
+	query := `
+		SELECT id, question, status, created_at, updated_at, product_id, user_id
+		FROM product_questions
+		WHERE id = $1
+	`
+
+	var q models.ProductQuestion
+	err := db.QueryRow(c, query, id).
+		Scan(&q.ID, &q.Question, &q.Status, &q.CreatedAt, &q.UpdatedAt, &q.ProductID, &q.UserID)
+	if err != nil {
+		return nil, Parse(err, "Question", "Get", make(Constraints))
+	}
+
+	return &q, nil
+}
+
+// getQuestionsPage gets a page of the questions matching the condition, whose placeholders
+// are numbered from $3, then their answers matching answersCondition.
+func getQuestionsPage(
+	c *gin.Context,
+	db Querier,
+	method, condition, answersCondition string,
+	f filters.Filters,
+	args ...any,
+) ([]QuestionDetails, filters.Metadata, error) {
+	cursorCondition, cursorArgs := f.CursorCondition("id", 3+len(args))
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS total_records,
+			id, question, status, upvotes, created_at, product_id, user_id, first_name,
+			%s::text AS cursor_value
+		FROM (
+			SELECT
+				q.id,
+				q.question,
+				q.status,
+				(SELECT COUNT(*) FROM question_votes v WHERE v.question_id = q.id) AS upvotes,
+				q.created_at,
+				q.product_id,
+				q.user_id,
+				u.first_name
+			FROM product_questions q
+			JOIN users u ON u.id = q.user_id
+			WHERE %s
+		) AS listing
+		WHERE %s
+		ORDER BY %s
+		LIMIT $1 OFFSET $2
+	`, f.CountSQL(), f.SortColumn(), condition, cursorCondition, f.OrderBy("id"))
+
+	args = append([]any{f.Limit(), f.Offset()}, args...)
+	args = append(args, cursorArgs...)
+	rows, err := db.Query(c, query, args...)
+	if err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Question", method, make(Constraints))
+	}
+	defer rows.Close()
+
+	var (
+		totalRecords int
+		questions    []QuestionDetails
+		keys         []filters.Keyset
+	)
+	for rows.Next() {
+		var (
+			q   QuestionDetails
+			key filters.Keyset
+		)
+		err = rows.Scan(
+			&totalRecords,
+			&q.ID,
+			&q.Question,
+			&q.Status,
+			&q.Upvotes,
+			&q.CreatedAt,
+			&q.ProductID,
+			&q.UserID,
+			&q.FirstName,
+			&key.Value,
+		)
+		if err != nil {
+			return nil, filters.Metadata{}, Parse(err, "Question", method, make(Constraints))
+		}
+		q.Answers = []AnswerDetails{}
+		key.ID = int64(q.ID)
+		questions = append(questions, q)
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Question", method, make(Constraints))
+	}
+
+	questions, metadata := filters.Paginate(f, questions, keys, totalRecords)
+	if len(questions) == 0 {
+		return questions, metadata, nil
+	}
+
+	ids := make([]int32, len(questions))
+	byID := make(map[int32]*QuestionDetails, len(questions))
+	for i := range questions {
+		ids[i] = questions[i].ID
+		byID[questions[i].ID] = &questions[i]
+	}
+
+	query = fmt.Sprintf(`
+		SELECT
+			a.question_id,
+			a.id,
+			a.answer,
+			a.author,
+			a.status,
+			(SELECT COUNT(*) FROM answer_votes v WHERE v.answer_id = a.id) AS upvotes,
+			a.created_at,
+			a.user_id,
+			u.first_name
+		FROM product_answers a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.question_id = ANY($1) AND %s
+		ORDER BY a.author, upvotes DESC, a.created_at, a.id
+	`, answersCondition)
+
+	answerRows, err := db.Query(c, query, ids)
+	if err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Question", method, make(Constraints))
+	}
+	defer answerRows.Close()
+
+	for answerRows.Next() {
+		var (
+			questionID int32
+			a          AnswerDetails
+		)
+		err = answerRows.Scan(
+			&questionID,
+			&a.ID,
+			&a.Answer,
+			&a.Author,
+			&a.Status,
+			&a.Upvotes,
+			&a.CreatedAt,
+			&a.UserID,
+			&a.FirstName,
+		)
+		if err != nil {
+			return nil, filters.Metadata{}, Parse(err, "Question", method, make(Constraints))
+		}
+		q := byID[questionID]
+		q.Answers = append(q.Answers, a)
+	}
+	if err = answerRows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Question", method, make(Constraints))
+	}
+
+	return questions, metadata, nil
+}
+
+func (repo *questionRepo) GetPageOfProduct(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+	f filters.Filters,
+) ([]QuestionDetails, filters.Metadata, error) {
+	return getQuestionsPage(
+		c,
+		db,
+		"GetPageOfProduct",
+		"q.product_id = $3 AND q.status = 'approved'",
+		"a.status = 'approved'",
+		f,
+		productID,
+	)
+}
+
+func (repo *questionRepo) GetPageForModeration(
+	c *gin.Context,
+	db Querier,
+	status models.ModerationStatus,
+	f filters.Filters,
+) ([]QuestionDetails, filters.Metadata, error) {
+	return getQuestionsPage(
+		c,
+		db,
+		"GetPageForModeration",
+		`q.status = $3 OR EXISTS (
+			SELECT 1 FROM product_answers a WHERE a.question_id = q.id AND a.status = $3
+		)`,
+		"TRUE",
+		f,
+		status,
+	)
+}
+
+func (repo *questionRepo) SetStatus(
+	c *gin.Context,
+	db Querier,
+	id int32,
+	status models.ModerationStatus,
+) error {
+	query := `
+		UPDATE product_questions
+		SET status = $2
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(c, query, id, status)
+	if err != nil {
+		return Parse(err, "Question", "SetStatus", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Question", "SetStatus", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *questionRepo) Delete(c *gin.Context, db Querier, id, userID int32) error {
+	query := `
+		DELETE FROM product_questions
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := db.Exec(c, query, id, userID)
+	if err != nil {
+		return Parse(err, "Question", "Delete", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Question", "Delete", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *questionRepo) Upvote(c *gin.Context, db Querier, id, userID int32) error {
+	query := `
+		WITH question AS (
+			SELECT id FROM product_questions
+			WHERE id = $1 AND status = 'approved'
+		), vote AS (
+			INSERT INTO question_votes (question_id, user_id)
+			SELECT id, $2 FROM question
+			ON CONFLICT DO NOTHING
+		)
+		SELECT COUNT(*) FROM question
+	`
+
+	return upvote(c, db, "Upvote", query, id, userID)
+}
+
+func (repo *questionRepo) RemoveUpvote(c *gin.Context, db Querier, id, userID int32) error {
+	query := `
+		DELETE FROM question_votes
+		WHERE question_id = $1 AND user_id = $2
+	`
+
+	result, err := db.Exec(c, query, id, userID)
+	if err != nil {
+		return Parse(err, "Question", "RemoveUpvote", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Question", "RemoveUpvote", make(Constraints))
+	}
+
+	return nil
+}
+
+// upvote runs an upvote query, which returns how many approved rows it found to upvote.
+func upvote(c *gin.Context, db Querier, method, query string, id, userID int32) error {
+	var found int
+	err := db.QueryRow(c, query, id, userID).Scan(&found)
+	if err != nil {
+		return Parse(err, "Question", method, Constraints{
+			ForeignKeyViolationCode: "user",
+		})
+	}
+
+	if found == 0 {
+		return Parse(pgx.ErrNoRows, "Question", method, make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *questionRepo) CreateAnswer(c *gin.Context, db Querier, a *models.ProductAnswer) error {
+	query := `
+		INSERT INTO product_answers (answer, author, status, question_id, user_id)
+		SELECT $1, $2, $3, q.id, $5
+		FROM product_questions q
+		WHERE q.id = $4 AND q.status = 'approved'
+		RETURNING id, created_at, updated_at
+	`
+
+	err := db.QueryRow(c, query, a.Answer, a.Author, a.Status, a.QuestionID, a.UserID).
+		Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return Parse(err, "Question", "CreateAnswer", Constraints{
+			ForeignKeyViolationCode: "user",
+		})
+	}
+
+	return nil
+}
+
+func (repo *questionRepo) SetAnswerStatus(
+	c *gin.Context,
+	db Querier,
+	id int32,
+	status models.ModerationStatus,
+) (*models.ProductAnswer, models.ModerationStatus, error) {
+	query := `
+		UPDATE product_answers a
+		SET status = $2
+		FROM (
+			SELECT id, status FROM product_answers
+			WHERE id = $1
+			FOR UPDATE
+		) previous
+		WHERE a.id = previous.id
+		RETURNING
+			a.id, a.answer, a.author, a.status, a.created_at, a.updated_at, a.question_id, a.user_id,
+			previous.status
+	`
+
+	var (
+		a        models.ProductAnswer
+		previous models.ModerationStatus
+	)
+	err := db.QueryRow(c, query, id, status).Scan(
+		&a.ID,
+		&a.Answer,
+		&a.Author,
+		&a.Status,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+		&a.QuestionID,
+		&a.UserID,
+		&previous,
+	)
+	if err != nil {
+		return nil, "", Parse(err, "Question", "SetAnswerStatus", make(Constraints))
+	}
+
+	return &a, previous, nil
+}
+
+func (repo *questionRepo) UpvoteAnswer(c *gin.Context, db Querier, id, userID int32) error {
+	query := `
+		WITH answer AS (
+			SELECT a.id FROM product_answers a
+			JOIN product_questions q ON q.id = a.question_id
+			WHERE a.id = $1 AND a.status = 'approved' AND q.status = 'approved'
+		), vote AS (
+			INSERT INTO answer_votes (answer_id, user_id)
+			SELECT id, $2 FROM answer
+			ON CONFLICT DO NOTHING
+		)
+		SELECT COUNT(*) FROM answer
+	`
+
+	return upvote(c, db, "UpvoteAnswer", query, id, userID)
+}
+
+func (repo *questionRepo) RemoveAnswerUpvote(c *gin.Context, db Querier, id, userID int32) error {
+	query := `
+		DELETE FROM answer_votes
+		WHERE answer_id = $1 AND user_id = $2
+	`
+
+	result, err := db.Exec(c, query, id, userID)
+	if err != nil {
+		return Parse(err, "Question", "RemoveAnswerUpvote", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Question", "RemoveAnswerUpvote", make(Constraints))
+	}
+
+	return nil
+}
@@ -1,12 +1,14 @@
 package database
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
 )
 
 type RatingReviewRepository interface {
@@ -26,7 +28,23 @@ type RatingReviewRepository interface {
 		productID int32,
 	) ([]RatingsAndReviewDetails, error)
 
-	GetAllOfUser(c *gin.Context, db Querier, userID int32) ([]models.RatingReview, error)
+	// This method will get a page of the product reviews,
+	// sortable by created_at and rating.
+	GetPageOfProduct(
+		c *gin.Context,
+		db Querier,
+		productID int32,
+		f filters.Filters,
+	) ([]RatingsAndReviewDetails, filters.Metadata, error)
+
+	// This method will get a page of the user reviews,
+	// sortable by created_at and rating.
+	GetAllOfUser(
+		c *gin.Context,
+		db Querier,
+		userID int32,
+		f filters.Filters,
+	) ([]models.RatingReview, filters.Metadata, error)
 
 	// This method updates the RatingReview model,
 	// Required columns: rating, review.
@@ -36,6 +54,9 @@ type RatingReviewRepository interface {
 	// This method will delete a review from the database
 	// by the review id.
 	Delete(c *gin.Context, db Querier, reviewID int32) error
+
+	// This method will add an image after the other images of the review.
+	AddImage(c *gin.Context, db Querier, reviewID int32, imageURL string) error
 }
 
 type ratingReviewRepo struct{}
@@ -98,14 +119,25 @@ type RatingsAndReviewDetails struct {
 	LastName  string      `json:"lastName"`
 	UserImage pgtype.Text `json:"userImage"`
 	UserID    int32       `json:"userId"`
+	Images    []string    `json:"images"`
 }
 
+// reviewImagesSQL selects the images of the review rr in order.
+const reviewImagesSQL = `COALESCE(
+	(
+		SELECT array_agg(ri.image ORDER BY ri.position, ri.id)
+		FROM review_images ri
+		WHERE ri.review_id = rr.id
+	),
+	'{}'
+)`
+
 func (repo *ratingReviewRepo) GetAllOfProduct(
 	c *gin.Context,
 	db Querier,
 	productID int32,
 ) ([]RatingsAndReviewDetails, error) {
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
 			rr.id,
 			rr.rating,
@@ -115,12 +147,13 @@ func (repo *ratingReviewRepo) GetAllOfProduct(
 			u.first_name,
 			u.last_name,
 			u.image,
-			u.id
+			u.id,
+			%s
 		FROM rating_review rr
 		JOIN users u ON rr.user_id = u.id
 		WHERE rr.product_id = $1
 		ORDER BY rr.created_at DESC
-	`
+	`, reviewImagesSQL)
 
 	rows, err := db.Query(c, query, productID)
 	if err != nil {
@@ -141,6 +174,7 @@ func (repo *ratingReviewRepo) GetAllOfProduct(
 			&rr.LastName,
 			&rr.UserImage,
 			&rr.UserID,
+			&rr.Images,
 		)
 		if err != nil {
 			return nil, Parse(err, "Rating Review", "GetAllOfProduct", make(Constraints))
@@ -155,28 +189,123 @@ func (repo *ratingReviewRepo) GetAllOfProduct(
 	return rrs, nil
 }
 
+func (repo *ratingReviewRepo) GetPageOfProduct(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+	f filters.Filters,
+) ([]RatingsAndReviewDetails, filters.Metadata, error) {
+	cursorCondition, cursorArgs := f.CursorCondition("id", 4)
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS total_records,
+			id, rating, review, created_at, updated_at, first_name, last_name, image, user_id,
+			images, %s::text AS cursor_value
+		FROM (
+			SELECT
+				rr.id,
+				rr.rating,
+				rr.review,
+				rr.created_at,
+				rr.updated_at,
+				u.first_name,
+				u.last_name,
+				u.image,
+				u.id AS user_id,
+				%s AS images
+			FROM rating_review rr
+			JOIN users u ON rr.user_id = u.id
+			WHERE rr.product_id = $3
+		) AS listing
+		WHERE %s
+		ORDER BY %s
+		LIMIT $1 OFFSET $2
+	`, f.CountSQL(), f.SortColumn(), reviewImagesSQL, cursorCondition, f.OrderBy("id"))
+
+	args := append([]any{f.Limit(), f.Offset(), productID}, cursorArgs...)
+	rows, err := db.Query(c, query, args...)
+	if err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Rating Review", "GetPageOfProduct", make(Constraints))
+	}
+	defer rows.Close()
+
+	var (
+		totalRecords int
+		rrs          []RatingsAndReviewDetails
+		keys         []filters.Keyset
+	)
+	for rows.Next() {
+		var (
+			rr  RatingsAndReviewDetails
+			key filters.Keyset
+		)
+		err = rows.Scan(
+			&totalRecords,
+			&rr.ID,
+			&rr.Rating,
+			&rr.Review,
+			&rr.CreatedAt,
+			&rr.UpdatedAt,
+			&rr.FirstName,
+			&rr.LastName,
+			&rr.UserImage,
+			&rr.UserID,
+			&rr.Images,
+			&key.Value,
+		)
+		if err != nil {
+			return nil, filters.Metadata{}, Parse(err, "Rating Review", "GetPageOfProduct", make(Constraints))
+		}
+		key.ID = int64(rr.ID)
+		rrs = append(rrs, rr)
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Rating Review", "GetPageOfProduct", make(Constraints))
+	}
+
+	rrs, metadata := filters.Paginate(f, rrs, keys, totalRecords)
+	return rrs, metadata, nil
+}
+
 func (repo *ratingReviewRepo) GetAllOfUser(
 	c *gin.Context,
 	db Querier,
 	userID int32,
-) ([]models.RatingReview, error) {
-	query := `
-		SELECT id, rating, review, created_at, updated_at, user_id, product_id
+	f filters.Filters,
+) ([]models.RatingReview, filters.Metadata, error) {
+	cursorCondition, cursorArgs := f.CursorCondition("id", 4)
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS total_records,
+			id, rating, review, created_at, updated_at, user_id, product_id,
+			%s::text AS cursor_value
 		FROM rating_review
-		WHERE user_id = $1
-	`
+		WHERE user_id = $3 AND %s
+		ORDER BY %s
+		LIMIT $1 OFFSET $2
+	`, f.CountSQL(), f.SortColumn(), cursorCondition, f.OrderBy("id"))
 
-	var rrs []models.RatingReview
-
-	rows, err := db.Query(c, query, userID)
+	args := append([]any{f.Limit(), f.Offset(), userID}, cursorArgs...)
+	rows, err := db.Query(c, query, args...)
 	if err != nil {
-		return nil, Parse(err, "Rating Review", "GetAllOfUser", make(Constraints))
+		return nil, filters.Metadata{}, Parse(err, "Rating Review", "GetAllOfUser", make(Constraints))
 	}
 	defer rows.Close()
 
+	var (
+		totalRecords int
+		rrs          []models.RatingReview
+		keys         []filters.Keyset
+	)
 	for rows.Next() {
-		var rr models.RatingReview
+		var (
+			rr  models.RatingReview
+			key filters.Keyset
+		)
 		err = rows.Scan(
+			&totalRecords,
 			&rr.ID,
 			&rr.Rating,
 			&rr.Review,
@@ -184,15 +313,22 @@ func (repo *ratingReviewRepo) GetAllOfUser(
 			&rr.UpdatedAt,
 			&rr.UserID,
 			&rr.ProductID,
+			&key.Value,
 		)
 		if err != nil {
-			return nil, Parse(err, "Rating Review", "GetAllOfUser", make(Constraints))
+			return nil, filters.Metadata{}, Parse(err, "Rating Review", "GetAllOfUser", make(Constraints))
 		}
-
+		key.ID = int64(rr.ID)
 		rrs = append(rrs, rr)
+		keys = append(keys, key)
 	}
 
-	return rrs, nil
+	if err = rows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Rating Review", "GetAllOfUser", make(Constraints))
+	}
+
+	rrs, metadata := filters.Paginate(f, rrs, keys, totalRecords)
+	return rrs, metadata, nil
 }
 
 func (repo *ratingReviewRepo) Update(c *gin.Context, db Querier, rr *models.RatingReview) error {
@@ -231,3 +367,28 @@ func (repo *ratingReviewRepo) Delete(c *gin.Context, db Querier, reviewID int32)
 
 	return nil
 }
+
+func (repo *ratingReviewRepo) AddImage(
+	c *gin.Context,
+	db Querier,
+	reviewID int32,
+	imageURL string,
+) error {
+	query := `
+		INSERT INTO review_images (image, review_id, position)
+		VALUES (
+			$1, $2,
+			(SELECT COALESCE(MAX(position) + 1, 0) FROM review_images WHERE review_id = $2)
+		)
+	`
+
+	_, err := db.Exec(c, query, imageURL, reviewID)
+	if err != nil {
+		return Parse(err, "Rating Review", "AddImage", Constraints{
+			UniqueViolationCode:     "image",
+			ForeignKeyViolationCode: "review",
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type RecommendationRepository interface {
+	// This method will recompute the recommendations of every product, keeping the
+	// perProduct best scored ones. A pair of products scores 1 per order they were bought
+	// together in and 0.5 per customer who wishlisted both, the products of the same category
+	// get a small score, more of the same brand, so there is a fallback without any signal.
+	// Returns: the number of recommendations.
+	Refresh(ctx context.Context, db Querier, perProduct int) (int64, error)
+
+	// This method will get the precomputed recommendations of the product, the best scored first.
+	// The products that aren't published or are out of stock are left out.
+	GetOfProduct(c *gin.Context, db Querier, productID int32, limit int) ([]RecommendedProduct, error)
+
+	// This method will get the published products in stock of the same category or brand as the product,
+	// the ones of the same category first, leaving out the excluded ones. It tops up the product's
+	// recommendations, all of them until they're computed.
+	GetSimilar(
+		c *gin.Context,
+		db Querier,
+		productID int32,
+		exclude []int32,
+		limit int,
+	) ([]RecommendedProduct, error)
+}
+
+type recommendationRepo struct{}
+
+func NewRecommendationRepository() RecommendationRepository {
+	return &recommendationRepo{}
+}
+
+// RecommendedProduct is a product listed along another one, with the reason it's listed for.
+type RecommendedProduct struct {
+	Product
+	Reason models.RecommendationReason `json:"reason"`
+}
+
+func (repo *recommendationRepo) Refresh(
+	ctx context.Context,
+	db Querier,
+	perProduct int,
+) (int64, error) {
+	_, err := db.Exec(ctx, "DELETE FROM product_recommendations")
+	if err != nil {
+		return 0, Parse(err, "Recommendation", "Refresh", make(Constraints))
+	}
+
+	query := `
+		WITH listed AS (
+			SELECT id, brand_id, product_category
+			FROM products
+			WHERE deleted_at IS NULL AND status = 'published'
+		), baskets AS (
+			SELECT DISTINCT od.order_id, pv.product_id
+			FROM order_details od
+			JOIN orders o ON o.id = od.order_id
+			JOIN product_variants pv ON pv.id = od.product_id
+			WHERE o.order_status IS DISTINCT FROM 'cancelled'
+		), signals AS (
+			SELECT a.product_id, b.product_id AS recommended_id,
+				COUNT(*)::float8 AS score, 'bought_together'::recommendation_reason AS reason
+			FROM baskets a
+			JOIN baskets b ON b.order_id = a.order_id AND b.product_id <> a.product_id
+			GROUP BY a.product_id, b.product_id
+
+			UNION ALL
+
+			SELECT a.product_id, b.product_id,
+				COUNT(*) * 0.5, 'wishlisted_together'
+			FROM wishlists a
+			JOIN wishlists b ON b.user_id = a.user_id AND b.product_id <> a.product_id
+			GROUP BY a.product_id, b.product_id
+
+			UNION ALL
+
+			-- only the products of the same category are paired, pairing every product
+			-- of a brand too grows with the square of the catalog.
+			SELECT p.id, q.id,
+				0.2 + CASE WHEN q.brand_id = p.brand_id THEN 0.1 ELSE 0 END,
+				'similar'
+			FROM listed p
+			JOIN listed q ON q.product_category = p.product_category AND q.id <> p.id
+		), scored AS (
+			SELECT
+				s.product_id,
+				s.recommended_id,
+				SUM(s.score) AS score,
+				(array_agg(s.reason ORDER BY s.score DESC))[1] AS reason
+			FROM signals s
+			JOIN listed ON listed.id = s.recommended_id
+			GROUP BY s.product_id, s.recommended_id
+		), ranked AS (
+			SELECT *, ROW_NUMBER() OVER (
+				PARTITION BY product_id ORDER BY score DESC, recommended_id
+			) AS rank
+			FROM scored
+		)
+		INSERT INTO product_recommendations (product_id, recommended_id, score, reason)
+		SELECT product_id, recommended_id, score, reason
+		FROM ranked
+		WHERE rank <= $1
+	`
+
+	result, err := db.Exec(ctx, query, perProduct)
+	if err != nil {
+		return 0, Parse(err, "Recommendation", "Refresh", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// recommendedProductSQL selects a recommended product aliased as p, along with its
+// lowest price in stock and its rating. The query joins the brand as b and the category as c.
+const recommendedProductSQL = `
+	p.id,
+	p.name,
+	p.slug,
+	p.thumbnail,
+	b.brand,
+	c.name,
+	(
+		SELECT MIN(pv.price) FROM product_variants pv
+		WHERE pv.product_id = p.id AND pv.deleted_at IS NULL AND pv.quantity > 0
+	),
+	COALESCE((
+		SELECT ROUND(AVG(rr.rating)::numeric, 2) FROM rating_review rr WHERE rr.product_id = p.id
+	), 0.00),
+	p.status,
+	p.published_at
+`
+
+// listableProductSQL keeps the products aliased as p that customers can buy.
+const listableProductSQL = `
+	p.deleted_at IS NULL AND p.status = 'published' AND EXISTS (
+		SELECT 1 FROM product_variants pv
+		WHERE pv.product_id = p.id AND pv.deleted_at IS NULL AND pv.quantity > 0
+	)
+`
+
+func (repo *recommendationRepo) GetOfProduct(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+	limit int,
+) ([]RecommendedProduct, error) {
+	query := `
+		SELECT ` + recommendedProductSQL + `, r.reason
+		FROM product_recommendations r
+		JOIN products p ON p.id = r.recommended_id
+		JOIN brands b ON b.id = p.brand_id
+		JOIN categories c ON c.id = p.product_category
+		WHERE r.product_id = $1 AND ` + listableProductSQL + `
+		ORDER BY r.score DESC, r.recommended_id
+		LIMIT $2
+	`
+
+	return getRecommendedProducts(c, db, "GetOfProduct", query, productID, limit)
+}
+
+func (repo *recommendationRepo) GetSimilar(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+	exclude []int32,
+	limit int,
+) ([]RecommendedProduct, error) {
+	query := `
+		SELECT ` + recommendedProductSQL + `, 'similar'::recommendation_reason
+		FROM products origin
+		JOIN products p ON p.id <> origin.id
+			AND (p.product_category = origin.product_category OR p.brand_id = origin.brand_id)
+		JOIN brands b ON b.id = p.brand_id
+		JOIN categories c ON c.id = p.product_category
+		WHERE origin.id = $1 AND p.id <> ALL($2::int[]) AND ` + listableProductSQL + `
+		ORDER BY p.product_category = origin.product_category DESC, p.published_at DESC, p.id
+		LIMIT $3
+	`
+
+	return getRecommendedProducts(c, db, "GetSimilar", query, productID, exclude, limit)
+}
+
+func getRecommendedProducts(
+	c *gin.Context,
+	db Querier,
+	method, query string,
+	args ...any,
+) ([]RecommendedProduct, error) {
+	rows, err := db.Query(c, query, args...)
+	if err != nil {
+		return nil, Parse(err, "Recommendation", method, make(Constraints))
+	}
+	defer rows.Close()
+
+	var products []RecommendedProduct
+	for rows.Next() {
+		var p RecommendedProduct
+		err = rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Slug,
+			&p.Thumbnail,
+			&p.Brand,
+			&p.Category,
+			&p.Price,
+			&p.Rating,
+			&p.Status,
+			&p.PublishedAt,
+			&p.Reason,
+		)
+		if err != nil {
+			return nil, Parse(err, "Recommendation", method, make(Constraints))
+		}
+		products = append(products, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Recommendation", method, make(Constraints))
+	}
+
+	return products, nil
+}
@@ -0,0 +1,304 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type SizeChartRepository interface {
+	// This method will get the size charts along with their rows,
+	// a brandID or categoryID other than 0 keeps the charts of that brand or category.
+	GetAll(c *gin.Context, db Querier, brandID, categoryID int32) ([]models.SizeChart, error)
+
+	// This method will get a size chart along with its rows, by id.
+	Get(c *gin.Context, db Querier, id int32) (*models.SizeChart, error)
+
+	// This method will get the size chart a product of the brand and category uses,
+	// the chart of the category or else of its closest ancestor that has one.
+	GetForProduct(c *gin.Context, db Querier, brandID, categoryID int32) (*models.SizeChart, error)
+
+	// This method will create a size chart, its rows are set separately.
+	//
+	// Columns required: brand_id, category_id, note.
+	// Returns: id set on the chart.
+	Create(c *gin.Context, db Querier, chart *models.SizeChart) error
+
+	// This method will update the size chart.
+	//
+	// Columns required: brand_id, category_id, note.
+	// By: id.
+	Update(c *gin.Context, db Querier, chart *models.SizeChart) error
+
+	// This method will delete a size chart along with its rows, by id.
+	Delete(c *gin.Context, db Querier, id int32) error
+
+	// This method will replace the rows of the size chart.
+	//
+	// Columns required: size_id, chest_cm, waist_cm, length_cm, position.
+	SetRows(c *gin.Context, db Querier, chartID int32, rows []models.SizeChartRow) error
+}
+
+type sizeChartRepo struct{}
+
+func NewSizeChartRepository() SizeChartRepository {
+	return &sizeChartRepo{}
+}
+
+// getSizeCharts gets the size charts matching the condition in its order, then their rows.
+func getSizeCharts(
+	c *gin.Context,
+	db Querier,
+	method, condition string,
+	args ...any,
+) ([]models.SizeChart, error) {
+	query := fmt.Sprintf(`
+		SELECT sc.id, sc.brand_id, sc.category_id, sc.note
+		FROM size_charts sc
+		%s
+	`, condition)
+
+	rows, err := db.Query(c, query, args...)
+	if err != nil {
+		return nil, Parse(err, "SizeChart", method, make(Constraints))
+	}
+	defer rows.Close()
+
+	var (
+		charts []models.SizeChart
+		ids    []int32
+	)
+	for rows.Next() {
+		var chart models.SizeChart
+		if err = rows.Scan(&chart.ID, &chart.BrandID, &chart.CategoryID, &chart.Note); err != nil {
+			return nil, Parse(err, "SizeChart", method, make(Constraints))
+		}
+		chart.Rows = []models.SizeChartRow{}
+		charts = append(charts, chart)
+		ids = append(ids, chart.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "SizeChart", method, make(Constraints))
+	}
+
+	if len(charts) == 0 {
+		return charts, nil
+	}
+
+	query = `
+		SELECT
+			r.size_chart_id, r.size_id, s.size, s.label,
+			r.chest_cm::float8, r.waist_cm::float8, r.length_cm::float8, r.position
+		FROM size_chart_rows r
+		JOIN sizes s ON s.id = r.size_id
+		WHERE r.size_chart_id = ANY($1) AND s.deleted_at IS NULL
+		ORDER BY r.position, r.size_id
+	`
+
+	chartRows, err := db.Query(c, query, ids)
+	if err != nil {
+		return nil, Parse(err, "SizeChart", method, make(Constraints))
+	}
+	defer chartRows.Close()
+
+	byID := make(map[int32]*models.SizeChart, len(charts))
+	for i := range charts {
+		byID[charts[i].ID] = &charts[i]
+	}
+	for chartRows.Next() {
+		var (
+			chartID int32
+			r       models.SizeChartRow
+		)
+		err = chartRows.Scan(
+			&chartID,
+			&r.SizeID,
+			&r.Size,
+			&r.Label,
+			&r.ChestCm,
+			&r.WaistCm,
+			&r.LengthCm,
+			&r.Position,
+		)
+		if err != nil {
+			return nil, Parse(err, "SizeChart", method, make(Constraints))
+		}
+		chart := byID[chartID]
+		chart.Rows = append(chart.Rows, r)
+	}
+	if err = chartRows.Err(); err != nil {
+		return nil, Parse(err, "SizeChart", method, make(Constraints))
+	}
+
+	return charts, nil
+}
+
+func (repo *sizeChartRepo) GetAll(
+	c *gin.Context,
+	db Querier,
+	brandID, categoryID int32,
+) ([]models.SizeChart, error) {
+	return getSizeCharts(c, db, "GetAll", `
+		WHERE ($1 = 0 OR sc.brand_id = $1) AND ($2 = 0 OR sc.category_id = $2)
+		ORDER BY sc.brand_id, sc.category_id
+	`, brandID, categoryID)
+}
+
+func (repo *sizeChartRepo) Get(c *gin.Context, db Querier, id int32) (*models.SizeChart, error) {
+	charts, err := getSizeCharts(c, db, "Get", "WHERE sc.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(charts) == 0 {
+		return nil, Parse(pgx.ErrNoRows, "SizeChart", "Get", make(Constraints))
+	}
+
+	return &charts[0], nil
+}
+
+func (repo *sizeChartRepo) GetForProduct(
+	c *gin.Context,
+	db Querier,
+	brandID, categoryID int32,
+) (*models.SizeChart, error) {
+	charts, err := getSizeCharts(c, db, "GetForProduct", `
+		JOIN (
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id, 0 AS depth
+				FROM categories
+				WHERE id = $2
+				UNION ALL
+				SELECT c.id, c.parent_id, a.depth + 1
+				FROM categories c
+				JOIN ancestors a ON c.id = a.parent_id
+			)
+			SELECT id, depth FROM ancestors
+		) a ON a.id = sc.category_id
+		WHERE sc.brand_id = $1
+		ORDER BY a.depth
+		LIMIT 1
+	`, brandID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(charts) == 0 {
+		return nil, Parse(pgx.ErrNoRows, "SizeChart", "GetForProduct", make(Constraints))
+	}
+
+	return &charts[0], nil
+}
+
+func (repo *sizeChartRepo) Create(c *gin.Context, db Querier, chart *models.SizeChart) error {
+	query := `
+		INSERT INTO size_charts (brand_id, category_id, note)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	err := db.QueryRow(c, query, chart.BrandID, chart.CategoryID, chart.Note).Scan(&chart.ID)
+	if err != nil {
+		return Parse(err, "SizeChart", "Create", Constraints{
+			UniqueViolationCode:     "size chart of the brand and category",
+			ForeignKeyViolationCode: "brand or category",
+		})
+	}
+
+	return nil
+}
+
+func (repo *sizeChartRepo) Update(c *gin.Context, db Querier, chart *models.SizeChart) error {
+	query := `
+		UPDATE size_charts
+		SET brand_id = $2, category_id = $3, note = $4
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(c, query, chart.ID, chart.BrandID, chart.CategoryID, chart.Note)
+	if err != nil {
+		return Parse(err, "SizeChart", "Update", Constraints{
+			UniqueViolationCode:     "size chart of the brand and category",
+			ForeignKeyViolationCode: "brand or category",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "SizeChart", "Update", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *sizeChartRepo) Delete(c *gin.Context, db Querier, id int32) error {
+	query := `
+		DELETE FROM size_charts
+		WHERE id = $1
+	`
+
+	result, err := db.Exec(c, query, id)
+	if err != nil {
+		return Parse(err, "SizeChart", "Delete", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "SizeChart", "Delete", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *sizeChartRepo) SetRows(
+	c *gin.Context,
+	db Querier,
+	chartID int32,
+	rows []models.SizeChartRow,
+) error {
+	query := `
+		DELETE FROM size_chart_rows
+		WHERE size_chart_id = $1
+	`
+
+	_, err := db.Exec(c, query, chartID)
+	if err != nil {
+		return Parse(err, "SizeChart", "SetRows", make(Constraints))
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	sizeIDs := make([]int32, len(rows))
+	chests := make([]pgtype.Float8, len(rows))
+	waists := make([]pgtype.Float8, len(rows))
+	lengths := make([]pgtype.Float8, len(rows))
+	positions := make([]int32, len(rows))
+	for i, r := range rows {
+		sizeIDs[i] = r.SizeID
+		chests[i] = r.ChestCm
+		waists[i] = r.WaistCm
+		lengths[i] = r.LengthCm
+		positions[i] = r.Position
+	}
+
+	query = `
+		INSERT INTO size_chart_rows (size_chart_id, size_id, chest_cm, waist_cm, length_cm, position)
+		SELECT $1, r.size_id, r.chest_cm, r.waist_cm, r.length_cm, r.position
+		FROM unnest($2::int[], $3::float8[], $4::float8[], $5::float8[], $6::int[])
+			AS r(size_id, chest_cm, waist_cm, length_cm, position)
+	`
+
+	_, err = db.Exec(c, query, chartID, sizeIDs, chests, waists, lengths, positions)
+	if err != nil {
+		return Parse(err, "SizeChart", "SetRows", Constraints{
+			UniqueViolationCode:     "size",
+			ForeignKeyViolationCode: "size",
+			CheckViolationCode:      "measurements",
+		})
+	}
+
+	return nil
+}
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -24,8 +26,33 @@ type SizeRepository interface {
 	// required columns:size, label.
 	Update(ctx *gin.Context, db Querier, size *models.Size) error
 
-	// Delete size by id
+	// Soft delete size by id,
+	// it fails with a foreign key violation while variants still use the size.
 	Delete(ctx *gin.Context, db Querier, id int32) error
+
+	// Restore a soft deleted size by id
+	Restore(ctx *gin.Context, db Querier, id int32) error
+
+	// This method will permanently delete the sizes soft deleted before the given time,
+	// a size is kept as long as any variant, even a deleted one, still uses it.
+	// Returns: the number of purged sizes.
+	PurgeDeleted(ctx context.Context, db Querier, before time.Time) (int64, error)
+
+	// This method will get the US and UK equivalents of the EU shoe sizes ordered by foot length,
+	// a productID other than 0 keeps the sizes of the product's variants.
+	GetConversions(
+		c *gin.Context,
+		db Querier,
+		productID int32,
+	) ([]models.ShoeSizeConversion, error)
+
+	// This method will set the US and UK equivalents of a shoe size.
+	//
+	// Columns required: size_id, uk, us_men, us_women, foot_length_cm.
+	SetConversion(c *gin.Context, db Querier, conversion *models.ShoeSizeConversion) error
+
+	// This method will delete the conversion of a shoe size, by size_id.
+	DeleteConversion(c *gin.Context, db Querier, sizeID int32) error
 }
 
 type sizeRepo struct{}
@@ -56,6 +83,7 @@ func (r *sizeRepo) GetAll(ctx *gin.Context, db Querier) (*[]models.Size, error)
 	query := `
 		SELECT id, size, label 
 		FROM sizes
+		WHERE deleted_at IS NULL
 	`
 
 	rows, err := db.Query(ctx, query)
@@ -100,7 +128,7 @@ func (r *sizeRepo) GetByLabel(
 ) (*[]models.Size, error) {
 	query := fmt.Sprintf(`SELECT id, size, label 
 		FROM sizes
-		WHERE %s`, getWhereClauses(labels))
+		WHERE deleted_at IS NULL AND (%s)`, getWhereClauses(labels))
 
 	args := make([]any, len(labels))
 	for i, v := range labels {
@@ -138,7 +166,7 @@ func (r *sizeRepo) Update(ctx *gin.Context, db Querier, size *models.Size) error
 		SET
  			size = COALESCE(NULLIF($2, ''), size),
     	label = COALESCE(NULLIF($3, ''), label)
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	result, err := db.Exec(ctx, query, size.ID, size.Size, size.Label)
@@ -153,13 +181,14 @@ func (r *sizeRepo) Update(ctx *gin.Context, db Querier, size *models.Size) error
 
 func (r *sizeRepo) Delete(ctx *gin.Context, db Querier, id int32) error {
 	query := `
-		DELETE FROM sizes
-		WHERE id = $1
+		UPDATE sizes
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	result, err := db.Exec(ctx, query, id)
 	if err != nil {
-		return Parse(err, "Size", "Delete", Constraints{ErrForeignKey: "id"})
+		return Parse(err, "Size", "Delete", Constraints{ForeignKeyViolationCode: "size"})
 	}
 	if result.RowsAffected() == 0 {
 		return Parse(pgx.ErrNoRows, "Size", "Delete", make(Constraints))
@@ -167,3 +196,128 @@ func (r *sizeRepo) Delete(ctx *gin.Context, db Querier, id int32) error {
 
 	return nil
 }
+
+func (r *sizeRepo) Restore(ctx *gin.Context, db Querier, id int32) error {
+	query := `
+		UPDATE sizes
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	result, err := db.Exec(ctx, query, id)
+	if err != nil {
+		return Parse(err, "Size", "Restore", Constraints{UniqueViolationCode: "size, label"})
+	}
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Size", "Restore", make(Constraints))
+	}
+
+	return nil
+}
+
+func (r *sizeRepo) PurgeDeleted(ctx context.Context, db Querier, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM sizes s
+		WHERE s.deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM product_variants pv WHERE pv.size_id = s.id)
+	`
+
+	result, err := db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, Parse(err, "Size", "PurgeDeleted", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *sizeRepo) GetConversions(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+) ([]models.ShoeSizeConversion, error) {
+	query := `
+		SELECT
+			sc.size_id, s.size, sc.uk::float8, sc.us_men::float8, sc.us_women::float8,
+			sc.foot_length_cm::float8
+		FROM shoe_size_conversions sc
+		JOIN sizes s ON s.id = sc.size_id
+		WHERE s.deleted_at IS NULL AND ($1 = 0 OR sc.size_id IN (
+			SELECT pv.size_id FROM product_variants pv
+			WHERE pv.product_id = $1 AND pv.deleted_at IS NULL
+		))
+		ORDER BY sc.foot_length_cm, sc.size_id
+	`
+
+	rows, err := db.Query(c, query, productID)
+	if err != nil {
+		return nil, Parse(err, "Size", "GetConversions", make(Constraints))
+	}
+	defer rows.Close()
+
+	conversions := []models.ShoeSizeConversion{}
+	for rows.Next() {
+		var sc models.ShoeSizeConversion
+		err = rows.Scan(&sc.SizeID, &sc.EU, &sc.UK, &sc.USMen, &sc.USWomen, &sc.FootLengthCm)
+		if err != nil {
+			return nil, Parse(err, "Size", "GetConversions", make(Constraints))
+		}
+		conversions = append(conversions, sc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Size", "GetConversions", make(Constraints))
+	}
+
+	return conversions, nil
+}
+
+func (r *sizeRepo) SetConversion(
+	c *gin.Context,
+	db Querier,
+	conversion *models.ShoeSizeConversion,
+) error {
+	query := `
+		INSERT INTO shoe_size_conversions (size_id, uk, us_men, us_women, foot_length_cm)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (size_id) DO UPDATE
+		SET
+			uk = EXCLUDED.uk,
+			us_men = EXCLUDED.us_men,
+			us_women = EXCLUDED.us_women,
+			foot_length_cm = EXCLUDED.foot_length_cm
+	`
+
+	_, err := db.Exec(
+		c,
+		query,
+		conversion.SizeID,
+		conversion.UK,
+		conversion.USMen,
+		conversion.USWomen,
+		conversion.FootLengthCm,
+	)
+	if err != nil {
+		return Parse(err, "Size", "SetConversion", Constraints{
+			ForeignKeyViolationCode: "size",
+			CheckViolationCode:      "uk or us_men or us_women or foot_length_cm",
+		})
+	}
+
+	return nil
+}
+
+func (r *sizeRepo) DeleteConversion(c *gin.Context, db Querier, sizeID int32) error {
+	query := `
+		DELETE FROM shoe_size_conversions
+		WHERE size_id = $1
+	`
+
+	result, err := db.Exec(c, query, sizeID)
+	if err != nil {
+		return Parse(err, "Size", "DeleteConversion", make(Constraints))
+	}
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Size", "DeleteConversion", make(Constraints))
+	}
+
+	return nil
+}
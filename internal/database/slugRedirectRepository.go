@@ -0,0 +1,45 @@
+package database
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type SlugRedirectRepository interface {
+	// This method will resolve an old slug to the current slug of the
+	// product or category it used to name.
+	// By: entity_type, slug.
+	Resolve(ctx *gin.Context, db Querier, entity models.SlugEntity, slug string) (string, error)
+}
+
+type slugRedirectRepo struct{}
+
+func NewSlugRedirectRepository() SlugRedirectRepository {
+	return &slugRedirectRepo{}
+}
+
+func (r *slugRedirectRepo) Resolve(
+	ctx *gin.Context,
+	db Querier,
+	entity models.SlugEntity,
+	slug string,
+) (string, error) {
+	query := `
+		SELECT COALESCE(p.slug, c.slug)
+		FROM slug_redirects sr
+		LEFT JOIN products p
+			ON sr.entity_type = 'products' AND p.id = sr.entity_id AND p.deleted_at IS NULL
+		LEFT JOIN categories c
+			ON sr.entity_type = 'categories' AND c.id = sr.entity_id AND c.deleted_at IS NULL
+		WHERE sr.entity_type = $1 AND sr.slug = $2
+		AND COALESCE(p.slug, c.slug) IS NOT NULL
+	`
+
+	var currentSlug string
+	err := db.QueryRow(ctx, query, entity, slug).Scan(&currentSlug)
+	if err != nil {
+		return "", Parse(err, "Slug Redirect", "Resolve", make(Constraints))
+	}
+
+	return currentSlug, nil
+}
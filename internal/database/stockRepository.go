@@ -0,0 +1,322 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+)
+
+type StockRepository interface {
+	// This method will change the quantity of the variant by the movement change
+	// and record the movement in the ledger, in a single statement.
+	// The quantity can't go below 0, it fails with a violation of VariantQuantityCheck instead.
+	//
+	// Columns required: change, reason, note, variant_id, actor_id, api_key_id, order_id.
+	// Returns: id, quantity_after and created_at set on the movement.
+	Record(c *gin.Context, db Querier, m *models.StockMovement) error
+
+	// This method will get how much of every variant the order took out of the stock,
+	// its sales minus what was returned already.
+	// Returns: the quantities by variant id.
+	GetSoldOfOrder(c *gin.Context, db Querier, orderID int32) (map[int32]int, error)
+
+	// This method will get a page of the movements of the variant, sortable by created_at.
+	GetHistory(
+		c *gin.Context,
+		db Querier,
+		variantID int32,
+		f filters.Filters,
+	) ([]models.StockMovement, filters.Metadata, error)
+
+	// This method will set the low stock threshold of the variant, null uses defaultThreshold.
+	SetThreshold(c *gin.Context, db Querier, variantID int32, threshold pgtype.Int4) error
+
+	// This method will open an alert when the variant quantity is at or below its threshold,
+	// or else resolve its open alert. The threshold is defaultThreshold unless the variant has one.
+	SyncAlert(c *gin.Context, db Querier, variantID int32, defaultThreshold int) error
+
+	// This method will get the open alerts of the variants that aren't deleted, the lowest stock first.
+	GetOpenAlerts(c *gin.Context, db Querier, defaultThreshold int) ([]StockAlert, error)
+}
+
+// VariantQuantityCheck is the constraint keeping the quantity of the variants at or above 0.
+const VariantQuantityCheck = "product_variants_quantity_check"
+
+type stockRepo struct{}
+
+func NewStockRepository() StockRepository {
+	return &stockRepo{}
+}
+
+// StockAlert is an open low stock alert, with the current quantity of the variant.
+type StockAlert struct {
+	ID          int32     `json:"id"`
+	VariantID   int32     `json:"variantId"`
+	ProductID   int32     `json:"productId"`
+	ProductName string    `json:"productName"`
+	Color       string    `json:"color"`
+	Size        string    `json:"size"`
+	Quantity    int       `json:"quantity"`
+	Threshold   int       `json:"threshold"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (repo *stockRepo) Record(c *gin.Context, db Querier, m *models.StockMovement) error {
+	query := `
+		WITH variant AS (
+			UPDATE product_variants
+			SET quantity = quantity + $2
+			WHERE id = $1
+			RETURNING id, quantity
+		)
+		INSERT INTO stock_movements (
+			change, quantity_after, reason, note, variant_id, actor_id, api_key_id, order_id
+		)
+		SELECT $2, quantity, $3, $4, id, $5, $6, $7
+		FROM variant
+		RETURNING id, quantity_after, created_at
+	`
+
+	err := db.QueryRow(
+		c,
+		query,
+		m.VariantID,
+		m.Change,
+		m.Reason,
+		m.Note,
+		m.ActorID,
+		m.APIKeyID,
+		m.OrderID,
+	).Scan(&m.ID, &m.QuantityAfter, &m.CreatedAt)
+	if err != nil {
+		return Parse(err, "Stock", "Record", Constraints{
+			CheckViolationCode:      "quantity",
+			ForeignKeyViolationCode: "actor or order",
+		})
+	}
+
+	return nil
+}
+
+func (repo *stockRepo) GetSoldOfOrder(
+	c *gin.Context,
+	db Querier,
+	orderID int32,
+) (map[int32]int, error) {
+	query := `
+		SELECT variant_id, -SUM(change)
+		FROM stock_movements
+		WHERE order_id = $1 AND reason IN ('sale', 'cancellation_return')
+		GROUP BY variant_id
+		HAVING SUM(change) < 0
+	`
+
+	rows, err := db.Query(c, query, orderID)
+	if err != nil {
+		return nil, Parse(err, "Stock", "GetSoldOfOrder", make(Constraints))
+	}
+	defer rows.Close()
+
+	sold := make(map[int32]int)
+	for rows.Next() {
+		var (
+			variantID int32
+			quantity  int
+		)
+		if err = rows.Scan(&variantID, &quantity); err != nil {
+			return nil, Parse(err, "Stock", "GetSoldOfOrder", make(Constraints))
+		}
+		sold[variantID] = quantity
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Stock", "GetSoldOfOrder", make(Constraints))
+	}
+
+	return sold, nil
+}
+
+func (repo *stockRepo) GetHistory(
+	c *gin.Context,
+	db Querier,
+	variantID int32,
+	f filters.Filters,
+) ([]models.StockMovement, filters.Metadata, error) {
+	cursorCondition, cursorArgs := f.CursorCondition("id", 4)
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS total_records,
+			id, change, quantity_after, reason, note, created_at,
+			variant_id, actor_id, api_key_id, order_id,
+			%s::text AS cursor_value
+		FROM stock_movements
+		WHERE variant_id = $3 AND %s
+		ORDER BY %s
+		LIMIT $1 OFFSET $2
+	`, f.CountSQL(), f.SortColumn(), cursorCondition, f.OrderBy("id"))
+
+	args := append([]any{f.Limit(), f.Offset(), variantID}, cursorArgs...)
+	rows, err := db.Query(c, query, args...)
+	if err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Stock", "GetHistory", make(Constraints))
+	}
+	defer rows.Close()
+
+	var (
+		totalRecords int
+		movements    []models.StockMovement
+		keys         []filters.Keyset
+	)
+	for rows.Next() {
+		var (
+			m   models.StockMovement
+			key filters.Keyset
+		)
+		err = rows.Scan(
+			&totalRecords,
+			&m.ID,
+			&m.Change,
+			&m.QuantityAfter,
+			&m.Reason,
+			&m.Note,
+			&m.CreatedAt,
+			&m.VariantID,
+			&m.ActorID,
+			&m.APIKeyID,
+			&m.OrderID,
+			&key.Value,
+		)
+		if err != nil {
+			return nil, filters.Metadata{}, Parse(err, "Stock", "GetHistory", make(Constraints))
+		}
+		key.ID = m.ID
+		movements = append(movements, m)
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Stock", "GetHistory", make(Constraints))
+	}
+
+	movements, metadata := filters.Paginate(f, movements, keys, totalRecords)
+	return movements, metadata, nil
+}
+
+func (repo *stockRepo) SetThreshold(
+	c *gin.Context,
+	db Querier,
+	variantID int32,
+	threshold pgtype.Int4,
+) error {
+	query := `
+		UPDATE product_variants
+		SET low_stock_threshold = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := db.Exec(c, query, variantID, threshold)
+	if err != nil {
+		return Parse(err, "Stock", "SetThreshold", Constraints{
+			CheckViolationCode: "threshold",
+		})
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Stock", "SetThreshold", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *stockRepo) SyncAlert(
+	c *gin.Context,
+	db Querier,
+	variantID int32,
+	defaultThreshold int,
+) error {
+	query := `
+		WITH variant AS (
+			SELECT id, quantity, COALESCE(low_stock_threshold, $2) AS threshold
+			FROM product_variants
+			WHERE id = $1
+		), resolved AS (
+			UPDATE stock_alerts a
+			SET resolved_at = NOW()
+			FROM variant v
+			WHERE a.variant_id = v.id AND a.resolved_at IS NULL AND v.quantity > v.threshold
+		)
+		INSERT INTO stock_alerts (variant_id, quantity, threshold)
+		SELECT id, quantity, threshold
+		FROM variant
+		WHERE quantity <= threshold
+		ON CONFLICT (variant_id) WHERE resolved_at IS NULL
+		DO UPDATE SET quantity = EXCLUDED.quantity, threshold = EXCLUDED.threshold
+	`
+
+	_, err := db.Exec(c, query, variantID, defaultThreshold)
+	if err != nil {
+		return Parse(err, "Stock", "SyncAlert", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *stockRepo) GetOpenAlerts(
+	c *gin.Context,
+	db Querier,
+	defaultThreshold int,
+) ([]StockAlert, error) {
+	query := `
+		SELECT
+			a.id,
+			a.variant_id,
+			p.id,
+			p.name,
+			co.color,
+			sz.size,
+			pv.quantity,
+			COALESCE(pv.low_stock_threshold, $1),
+			a.created_at
+		FROM stock_alerts a
+		JOIN product_variants pv ON pv.id = a.variant_id
+		JOIN products p ON p.id = pv.product_id
+		JOIN colors co ON co.id = pv.color_id
+		JOIN sizes sz ON sz.id = pv.size_id
+		WHERE a.resolved_at IS NULL AND pv.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY pv.quantity, a.created_at, a.id
+	`
+
+	rows, err := db.Query(c, query, defaultThreshold)
+	if err != nil {
+		return nil, Parse(err, "Stock", "GetOpenAlerts", make(Constraints))
+	}
+	defer rows.Close()
+
+	var alerts []StockAlert
+	for rows.Next() {
+		var a StockAlert
+		err = rows.Scan(
+			&a.ID,
+			&a.VariantID,
+			&a.ProductID,
+			&a.ProductName,
+			&a.Color,
+			&a.Size,
+			&a.Quantity,
+			&a.Threshold,
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, Parse(err, "Stock", "GetOpenAlerts", make(Constraints))
+		}
+		alerts = append(alerts, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Stock", "GetOpenAlerts", make(Constraints))
+	}
+
+	return alerts, nil
+}
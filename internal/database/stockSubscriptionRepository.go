@@ -0,0 +1,287 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type StockSubscriptionRepository interface {
+	// This method will subscribe the user to an out of stock variant of a published product,
+	// subscribing again renews the subscription.
+	//
+	// Columns required: expires_at, variant_id, user_id.
+	// Returns: id, created_at and product_id set on the subscription.
+	Subscribe(c *gin.Context, db Querier, sub *models.StockSubscription) error
+
+	// This method will get the subscriptions of the user that weren't notified nor expired,
+	// the latest first.
+	GetAllOfUser(c *gin.Context, db Querier, userID int32) ([]StockSubscriptionDetails, error)
+
+	// This method will delete a subscription of the user, by id.
+	Delete(c *gin.Context, db Querier, id, userID int32) error
+
+	// This method will queue the oldest waiting subscriptions of the variant to be notified,
+	// as many as its quantity minus the ones already queued.
+	// Returns: the number of queued subscriptions.
+	Queue(ctx context.Context, db Querier, variantID int32) (int64, error)
+
+	// This method will mark up to limit queued subscriptions of variants in stock notified,
+	// the first queued first. The rows are locked, so concurrent runs don't claim the same ones.
+	// Returns: the claimed subscriptions.
+	ClaimQueued(ctx context.Context, db Querier, limit int) ([]models.StockSubscription, error)
+
+	// This method will put the queued subscriptions of the variants out of stock again
+	// back to waiting, so the next restock queues them again.
+	// Returns: the number of subscriptions put back.
+	ReleaseSoldOut(ctx context.Context, db Querier) (int64, error)
+
+	// This method will delete the expired subscriptions and the ones notified before the time.
+	// Returns: the number of deleted subscriptions.
+	PurgeEnded(ctx context.Context, db Querier, notifiedBefore time.Time) (int64, error)
+}
+
+type stockSubscriptionRepo struct{}
+
+func NewStockSubscriptionRepository() StockSubscriptionRepository {
+	return &stockSubscriptionRepo{}
+}
+
+// StockSubscriptionDetails is a subscription along with the variant it's waiting for.
+type StockSubscriptionDetails struct {
+	models.StockSubscription
+	ProductName string `json:"productName"`
+	Thumbnail   string `json:"thumbnail"`
+	Color       string `json:"color"`
+	Size        string `json:"size"`
+}
+
+func (repo *stockSubscriptionRepo) Subscribe(
+	c *gin.Context,
+	db Querier,
+	sub *models.StockSubscription,
+) error {
+	query := `
+		INSERT INTO stock_subscriptions (expires_at, variant_id, user_id)
+		SELECT $1, pv.id, $3
+		FROM product_variants pv
+		JOIN products p ON p.id = pv.product_id
+		WHERE pv.id = $2 AND pv.deleted_at IS NULL AND pv.quantity = 0
+			AND p.deleted_at IS NULL AND p.status = 'published'
+		ON CONFLICT (variant_id, user_id) DO UPDATE
+		SET created_at = NOW(), expires_at = EXCLUDED.expires_at, queued_at = NULL, notified_at = NULL
+		RETURNING id, created_at, (SELECT product_id FROM product_variants WHERE id = $2)
+	`
+
+	err := db.QueryRow(c, query, sub.ExpiresAt, sub.VariantID, sub.UserID).
+		Scan(&sub.ID, &sub.CreatedAt, &sub.ProductID)
+	if err != nil {
+		return Parse(err, "StockSubscription", "Subscribe", Constraints{
+			ForeignKeyViolationCode: "user",
+		})
+	}
+
+	return nil
+}
+
+func (repo *stockSubscriptionRepo) GetAllOfUser(
+	c *gin.Context,
+	db Querier,
+	userID int32,
+) ([]StockSubscriptionDetails, error) {
+	query := `
+		SELECT
+			s.id, s.created_at, s.expires_at, s.variant_id, pv.product_id,
+			p.name, p.thumbnail, co.color, sz.size
+		FROM stock_subscriptions s
+		JOIN product_variants pv ON pv.id = s.variant_id
+		JOIN products p ON p.id = pv.product_id
+		JOIN colors co ON co.id = pv.color_id
+		JOIN sizes sz ON sz.id = pv.size_id
+		WHERE s.user_id = $1 AND s.notified_at IS NULL AND s.expires_at > NOW()
+			AND pv.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY s.created_at DESC, s.id DESC
+	`
+
+	rows, err := db.Query(c, query, userID)
+	if err != nil {
+		return nil, Parse(err, "StockSubscription", "GetAllOfUser", make(Constraints))
+	}
+	defer rows.Close()
+
+	var subs []StockSubscriptionDetails
+	for rows.Next() {
+		var s StockSubscriptionDetails
+		err = rows.Scan(
+			&s.ID,
+			&s.CreatedAt,
+			&s.ExpiresAt,
+			&s.VariantID,
+			&s.ProductID,
+			&s.ProductName,
+			&s.Thumbnail,
+			&s.Color,
+			&s.Size,
+		)
+		if err != nil {
+			return nil, Parse(err, "StockSubscription", "GetAllOfUser", make(Constraints))
+		}
+		s.UserID = userID
+		subs = append(subs, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "StockSubscription", "GetAllOfUser", make(Constraints))
+	}
+
+	return subs, nil
+}
+
+func (repo *stockSubscriptionRepo) Delete(c *gin.Context, db Querier, id, userID int32) error {
+	query := `
+		DELETE FROM stock_subscriptions
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := db.Exec(c, query, id, userID)
+	if err != nil {
+		return Parse(err, "StockSubscription", "Delete", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "StockSubscription", "Delete", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *stockSubscriptionRepo) Queue(
+	ctx context.Context,
+	db Querier,
+	variantID int32,
+) (int64, error) {
+	query := `
+		WITH available AS (
+			SELECT GREATEST(
+				COALESCE((
+					SELECT quantity FROM product_variants
+					WHERE id = $1 AND deleted_at IS NULL
+				), 0) - (
+					SELECT COUNT(*) FROM stock_subscriptions
+					WHERE variant_id = $1 AND queued_at IS NOT NULL AND notified_at IS NULL
+				),
+				0
+			) AS slots
+		), next AS (
+			SELECT id
+			FROM stock_subscriptions
+			WHERE variant_id = $1 AND queued_at IS NULL AND notified_at IS NULL AND expires_at > NOW()
+			ORDER BY created_at, id
+			LIMIT (SELECT slots FROM available)
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE stock_subscriptions s
+		SET queued_at = NOW()
+		FROM next
+		WHERE s.id = next.id
+	`
+
+	result, err := db.Exec(ctx, query, variantID)
+	if err != nil {
+		return 0, Parse(err, "StockSubscription", "Queue", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (repo *stockSubscriptionRepo) ClaimQueued(
+	ctx context.Context,
+	db Querier,
+	limit int,
+) ([]models.StockSubscription, error) {
+	query := `
+		WITH due AS (
+			SELECT s.id
+			FROM stock_subscriptions s
+			JOIN product_variants pv ON pv.id = s.variant_id
+			WHERE s.queued_at IS NOT NULL AND s.notified_at IS NULL AND s.expires_at > NOW()
+				AND pv.quantity > 0 AND pv.deleted_at IS NULL
+			ORDER BY s.queued_at, s.id
+			LIMIT $1
+			FOR UPDATE OF s SKIP LOCKED
+		)
+		UPDATE stock_subscriptions s
+		SET notified_at = NOW()
+		FROM due, product_variants pv
+		WHERE s.id = due.id AND pv.id = s.variant_id
+		RETURNING s.id, s.created_at, s.expires_at, s.queued_at, s.notified_at,
+			s.variant_id, s.user_id, pv.product_id
+	`
+
+	rows, err := db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, Parse(err, "StockSubscription", "ClaimQueued", make(Constraints))
+	}
+	defer rows.Close()
+
+	var subs []models.StockSubscription
+	for rows.Next() {
+		var s models.StockSubscription
+		err = rows.Scan(
+			&s.ID,
+			&s.CreatedAt,
+			&s.ExpiresAt,
+			&s.QueuedAt,
+			&s.NotifiedAt,
+			&s.VariantID,
+			&s.UserID,
+			&s.ProductID,
+		)
+		if err != nil {
+			return nil, Parse(err, "StockSubscription", "ClaimQueued", make(Constraints))
+		}
+		subs = append(subs, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "StockSubscription", "ClaimQueued", make(Constraints))
+	}
+
+	return subs, nil
+}
+
+func (repo *stockSubscriptionRepo) ReleaseSoldOut(ctx context.Context, db Querier) (int64, error) {
+	query := `
+		UPDATE stock_subscriptions s
+		SET queued_at = NULL
+		FROM product_variants pv
+		WHERE pv.id = s.variant_id AND s.queued_at IS NOT NULL AND s.notified_at IS NULL
+			AND pv.quantity = 0
+	`
+
+	result, err := db.Exec(ctx, query)
+	if err != nil {
+		return 0, Parse(err, "StockSubscription", "ReleaseSoldOut", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (repo *stockSubscriptionRepo) PurgeEnded(
+	ctx context.Context,
+	db Querier,
+	notifiedBefore time.Time,
+) (int64, error) {
+	query := `
+		DELETE FROM stock_subscriptions
+		WHERE (notified_at IS NULL AND expires_at <= NOW()) OR notified_at < $1
+	`
+
+	result, err := db.Exec(ctx, query, notifiedBefore)
+	if err != nil {
+		return 0, Parse(err, "StockSubscription", "PurgeEnded", make(Constraints))
+	}
+
+	return result.RowsAffected(), nil
+}
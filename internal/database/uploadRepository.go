@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/refine-software/afrad-api/internal/models"
+)
+
+type UploadRepository interface {
+	// This method will record an uploaded object in the ledger,
+	// it should be called right after the upload and before the rows referencing it are committed.
+	Create(ctx context.Context, db Querier, url string, owner models.UploadOwner) error
+
+	// This method will get the uploads that no row references
+	// and that were uploaded before createdBefore, the oldest first.
+	GetUnreferenced(
+		ctx context.Context,
+		db Querier,
+		createdBefore time.Time,
+		limit int,
+	) ([]models.Upload, error)
+
+	// This method will delete the ledger entries of deleted objects, by id.
+	DeleteMany(ctx context.Context, db Querier, ids []int32) error
+
+	// This method will get the referenced uploads that weren't checked since checkedBefore,
+	// the ones never checked first.
+	// Returns: the uploads with the owner of the row referencing them.
+	GetDueForCheck(
+		ctx context.Context,
+		db Querier,
+		checkedBefore time.Time,
+		limit int,
+	) ([]models.Upload, error)
+
+	// This method will record whether the object of the upload was found in the storage,
+	// along with its owner.
+	// Columns required: id, owner_type, owner_id.
+	MarkChecked(ctx context.Context, db Querier, upload *models.Upload, found bool) error
+
+	// This method will get the uploads whose objects are missing from the storage,
+	// the longest missing first.
+	GetMissing(c *gin.Context, db Querier) ([]models.Upload, error)
+
+	// This method will record a presigned upload, before the client uploads it.
+	// Columns required: url, owner_type, expected_size, expected_content_type.
+	CreatePresigned(c *gin.Context, db Querier, upload *models.Upload) error
+
+	// This method will get a presigned upload that isn't confirmed yet, by id.
+	GetPending(c *gin.Context, db Querier, id int32) (*models.Upload, error)
+
+	// This method will mark a presigned upload confirmed, by id.
+	// It fails with not found when it was confirmed already, so an upload is only attached once.
+	Confirm(c *gin.Context, db Querier, id int32) error
+}
+
+type uploadRepo struct{}
+
+func NewUploadRepository() UploadRepository {
+	return &uploadRepo{}
+}
+
+func (repo *uploadRepo) Create(
+	ctx context.Context,
+	db Querier,
+	url string,
+	owner models.UploadOwner,
+) error {
+	query := `
+		INSERT INTO uploads (url, owner_type)
+		VALUES ($1, $2)
+	`
+
+	_, err := db.Exec(ctx, query, url, owner)
+	if err != nil {
+		return Parse(err, "Upload", "Create", Constraints{
+			UniqueViolationCode: "url",
+		})
+	}
+
+	return nil
+}
+
+func scanUploads(rows pgx.Rows) ([]models.Upload, error) {
+	defer rows.Close()
+
+	var uploads []models.Upload
+	for rows.Next() {
+		var u models.Upload
+		err := rows.Scan(
+			&u.ID,
+			&u.URL,
+			&u.OwnerType,
+			&u.OwnerID,
+			&u.CheckedAt,
+			&u.MissingSince,
+			&u.CreatedAt,
+			&u.ExpectedSize,
+			&u.ExpectedContentType,
+			&u.ConfirmedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+
+	return uploads, rows.Err()
+}
+
+func (repo *uploadRepo) GetUnreferenced(
+	ctx context.Context,
+	db Querier,
+	createdBefore time.Time,
+	limit int,
+) ([]models.Upload, error) {
+	query := `
+		SELECT
+			id, url, owner_type, owner_id, checked_at, missing_since, created_at,
+			expected_size, expected_content_type, confirmed_at
+		FROM uploads
+		WHERE created_at < $1 AND NOT EXISTS (
+			SELECT 1 FROM upload_references r WHERE r.url = uploads.url
+		)
+		ORDER BY created_at
+		LIMIT $2
+	`
+
+	rows, err := db.Query(ctx, query, createdBefore, limit)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetUnreferenced", make(Constraints))
+	}
+
+	uploads, err := scanUploads(rows)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetUnreferenced", make(Constraints))
+	}
+
+	return uploads, nil
+}
+
+func (repo *uploadRepo) DeleteMany(ctx context.Context, db Querier, ids []int32) error {
+	query := `
+		DELETE FROM uploads
+		WHERE id = ANY($1)
+	`
+
+	_, err := db.Exec(ctx, query, ids)
+	if err != nil {
+		return Parse(err, "Upload", "DeleteMany", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *uploadRepo) GetDueForCheck(
+	ctx context.Context,
+	db Querier,
+	checkedBefore time.Time,
+	limit int,
+) ([]models.Upload, error) {
+	query := `
+		SELECT
+			u.id, u.url, r.owner_type, r.owner_id, u.checked_at, u.missing_since, u.created_at,
+			u.expected_size, u.expected_content_type, u.confirmed_at
+		FROM uploads u
+		JOIN LATERAL (
+			SELECT owner_type, owner_id FROM upload_references
+			WHERE url = u.url
+			LIMIT 1
+		) r ON true
+		WHERE u.checked_at IS NULL OR u.checked_at < $1
+		ORDER BY u.checked_at NULLS FIRST
+		LIMIT $2
+	`
+
+	rows, err := db.Query(ctx, query, checkedBefore, limit)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetDueForCheck", make(Constraints))
+	}
+
+	uploads, err := scanUploads(rows)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetDueForCheck", make(Constraints))
+	}
+
+	return uploads, nil
+}
+
+func (repo *uploadRepo) MarkChecked(
+	ctx context.Context,
+	db Querier,
+	upload *models.Upload,
+	found bool,
+) error {
+	query := `
+		UPDATE uploads
+		SET
+			owner_type = $2,
+			owner_id = $3,
+			checked_at = NOW(),
+			missing_since = CASE WHEN $4 THEN NULL ELSE COALESCE(missing_since, NOW()) END
+		WHERE id = $1
+		RETURNING checked_at, missing_since
+	`
+
+	err := db.QueryRow(ctx, query, upload.ID, upload.OwnerType, upload.OwnerID, found).
+		Scan(&upload.CheckedAt, &upload.MissingSince)
+	if err != nil {
+		return Parse(err, "Upload", "MarkChecked", make(Constraints))
+	}
+
+	return nil
+}
+
+func (repo *uploadRepo) GetMissing(c *gin.Context, db Querier) ([]models.Upload, error) {
+	query := `
+		SELECT
+			id, url, owner_type, owner_id, checked_at, missing_since, created_at,
+			expected_size, expected_content_type, confirmed_at
+		FROM uploads
+		WHERE missing_since IS NOT NULL
+		ORDER BY missing_since
+	`
+
+	rows, err := db.Query(c, query)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetMissing", make(Constraints))
+	}
+
+	uploads, err := scanUploads(rows)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetMissing", make(Constraints))
+	}
+
+	return uploads, nil
+}
+
+func (repo *uploadRepo) CreatePresigned(c *gin.Context, db Querier, upload *models.Upload) error {
+	query := `
+		INSERT INTO uploads (url, owner_type, expected_size, expected_content_type)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := db.QueryRow(
+		c,
+		query,
+		upload.URL,
+		upload.OwnerType,
+		upload.ExpectedSize,
+		upload.ExpectedContentType,
+	).Scan(&upload.ID, &upload.CreatedAt)
+	if err != nil {
+		return Parse(err, "Upload", "CreatePresigned", Constraints{
+			UniqueViolationCode: "url",
+		})
+	}
+
+	return nil
+}
+
+func (repo *uploadRepo) GetPending(c *gin.Context, db Querier, id int32) (*models.Upload, error) {
+	query := `
+		SELECT
+			id, url, owner_type, owner_id, checked_at, missing_since, created_at,
+			expected_size, expected_content_type, confirmed_at
+		FROM uploads
+		WHERE id = $1 AND expected_size IS NOT NULL AND confirmed_at IS NULL
+	`
+
+	rows, err := db.Query(c, query, id)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetPending", make(Constraints))
+	}
+
+	uploads, err := scanUploads(rows)
+	if err != nil {
+		return nil, Parse(err, "Upload", "GetPending", make(Constraints))
+	}
+
+	if len(uploads) == 0 {
+		return nil, Parse(pgx.ErrNoRows, "Upload", "GetPending", make(Constraints))
+	}
+
+	return &uploads[0], nil
+}
+
+func (repo *uploadRepo) Confirm(c *gin.Context, db Querier, id int32) error {
+	query := `
+		UPDATE uploads
+		SET confirmed_at = NOW()
+		WHERE id = $1 AND expected_size IS NOT NULL AND confirmed_at IS NULL
+	`
+
+	result, err := db.Exec(c, query, id)
+	if err != nil {
+		return Parse(err, "Upload", "Confirm", make(Constraints))
+	}
+
+	if result.RowsAffected() == 0 {
+		return Parse(pgx.ErrNoRows, "Upload", "Confirm", make(Constraints))
+	}
+
+	return nil
+}
@@ -1,21 +1,41 @@
 package database
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
 )
 
 type WishlistRepository interface {
-	GetAllOfUser(c *gin.Context, db Querier, userID int32) ([]wishlist, error)
-
-	// This method creates a wishlist record,
+	// This method will get a page of the user wishlist, sortable by created_at.
+	GetAllOfUser(
+		c *gin.Context,
+		db Querier,
+		userID int32,
+		f filters.Filters,
+	) ([]WishlistItem, filters.Metadata, error)
+
+	// This method creates a wishlist record along with the product lowest price at the time,
 	// columns required: product_id, user_id
 	Create(*gin.Context, Querier, *models.Wishlist) error
 
 	Delete(c *gin.Context, db Querier, wishlistID int32) error
+
+	// This method will find the wishlists of the published product whose lowest price dropped
+	// from previousPrice below the price it had when wishlisted or the one last notified of,
+	// or by dropPercent or more at once, and mark them notified of the new price.
+	// The wishlists added while the product had no price start from the first one it's given.
+	// Returns: the price drops of the wishlisters to notify.
+	ClaimPriceDrops(
+		c *gin.Context,
+		db Querier,
+		productID int32,
+		previousPrice, dropPercent int,
+	) ([]PriceDrop, error)
 }
 
 type wishlistRepo struct{}
@@ -24,7 +44,15 @@ func NewWishlistRepository() WishlistRepository {
 	return &wishlistRepo{}
 }
 
-type wishlist struct {
+// PriceDrop is a drop of the lowest price of a product on the user wishlist.
+type PriceDrop struct {
+	UserID        int32
+	ProductID     int32
+	Price         int
+	PreviousPrice int
+}
+
+type WishlistItem struct {
 	ID               int32     `json:"id"`
 	CreatedAt        time.Time `json:"createdAt"`
 	UserID           int32     `json:"userId"`
@@ -37,49 +65,76 @@ func (repo *wishlistRepo) GetAllOfUser(
 	c *gin.Context,
 	db Querier,
 	userID int32,
-) ([]wishlist, error) {
-	query := `
-		SELECT w.id, w.created_at, w.user_id, w.product_id, p.name, p.thumbnail
-		FROM wishlists w
-		JOIN products p ON w.product_id = p.id
-		WHERE user_id = $1
-	`
-
-	rows, err := db.Query(c, query, userID)
+	f filters.Filters,
+) ([]WishlistItem, filters.Metadata, error) {
+	cursorCondition, cursorArgs := f.CursorCondition("id", 4)
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS total_records,
+			id, created_at, user_id, product_id, name, thumbnail,
+			%s::text AS cursor_value
+		FROM (
+			SELECT w.id, w.created_at, w.user_id, w.product_id, p.name, p.thumbnail
+			FROM wishlists w
+			JOIN products p ON w.product_id = p.id
+			WHERE user_id = $3 AND p.deleted_at IS NULL
+		) AS listing
+		WHERE %s
+		ORDER BY %s
+		LIMIT $1 OFFSET $2
+	`, f.CountSQL(), f.SortColumn(), cursorCondition, f.OrderBy("id"))
+
+	args := append([]any{f.Limit(), f.Offset(), userID}, cursorArgs...)
+	rows, err := db.Query(c, query, args...)
 	if err != nil {
-		return nil, Parse(err, "Wishlist", "GetAllOfUser", make(Constraints))
+		return nil, filters.Metadata{}, Parse(err, "Wishlist", "GetAllOfUser", make(Constraints))
 	}
 	defer rows.Close()
 
-	var ws []wishlist
+	var (
+		totalRecords int
+		ws           []WishlistItem
+		keys         []filters.Keyset
+	)
 	for rows.Next() {
-		var w wishlist
+		var (
+			w   WishlistItem
+			key filters.Keyset
+		)
 		err = rows.Scan(
+			&totalRecords,
 			&w.ID,
 			&w.CreatedAt,
 			&w.UserID,
 			&w.ProductID,
 			&w.ProductName,
 			&w.ProductThumbnail,
+			&key.Value,
 		)
 		if err != nil {
-			return nil, Parse(err, "Wishlist", "GetAllOfUser", make(Constraints))
+			return nil, filters.Metadata{}, Parse(err, "Wishlist", "GetAllOfUser", make(Constraints))
 		}
 
+		key.ID = int64(w.ID)
 		ws = append(ws, w)
+		keys = append(keys, key)
 	}
 
-	if rows.Err() != nil {
-		return nil, Parse(err, "Wishlist", "GetAllOfUser", make(Constraints))
+	if err = rows.Err(); err != nil {
+		return nil, filters.Metadata{}, Parse(err, "Wishlist", "GetAllOfUser", make(Constraints))
 	}
 
-	return ws, nil
+	ws, metadata := filters.Paginate(f, ws, keys, totalRecords)
+	return ws, metadata, nil
 }
 
 func (repo *wishlistRepo) Create(c *gin.Context, db Querier, r *models.Wishlist) error {
 	query := `
-		INSERT INTO wishlists(user_id, product_id)
-		VALUES($1, $2)
+		INSERT INTO wishlists(user_id, product_id, added_price)
+		VALUES($1, $2, (
+			SELECT MIN(price) FROM product_variants
+			WHERE product_id = $2 AND deleted_at IS NULL
+		))
 	`
 
 	_, err := db.Exec(c, query, r.UserID, r.ProductID)
@@ -111,3 +166,63 @@ func (repo *wishlistRepo) Delete(c *gin.Context, db Querier, wishlistID int32) e
 
 	return nil
 }
+
+func (repo *wishlistRepo) ClaimPriceDrops(
+	c *gin.Context,
+	db Querier,
+	productID int32,
+	previousPrice, dropPercent int,
+) ([]PriceDrop, error) {
+	priceQuery := `
+		UPDATE wishlists
+		SET added_price = COALESCE(NULLIF($2, 0), (
+			SELECT MIN(price) FROM product_variants
+			WHERE product_id = $1 AND deleted_at IS NULL
+		))
+		WHERE product_id = $1 AND added_price IS NULL
+	`
+
+	_, err := db.Exec(c, priceQuery, productID, previousPrice)
+	if err != nil {
+		return nil, Parse(err, "Wishlist", "ClaimPriceDrops", make(Constraints))
+	}
+
+	query := `
+		WITH current AS (
+			SELECT MIN(pv.price) AS price
+			FROM product_variants pv
+			JOIN products p ON p.id = pv.product_id
+			WHERE pv.product_id = $1 AND pv.deleted_at IS NULL
+				AND p.deleted_at IS NULL AND p.status = 'published'
+		)
+		UPDATE wishlists w
+		SET notified_price = current.price
+		FROM current
+		WHERE w.product_id = $1 AND current.price < $2 AND (
+			current.price < COALESCE(w.notified_price, w.added_price)
+			OR current.price * 100 <= $2 * (100 - $3)
+		)
+		RETURNING w.user_id, current.price
+	`
+
+	rows, err := db.Query(c, query, productID, previousPrice, dropPercent)
+	if err != nil {
+		return nil, Parse(err, "Wishlist", "ClaimPriceDrops", make(Constraints))
+	}
+	defer rows.Close()
+
+	var drops []PriceDrop
+	for rows.Next() {
+		d := PriceDrop{ProductID: productID, PreviousPrice: previousPrice}
+		if err = rows.Scan(&d.UserID, &d.Price); err != nil {
+			return nil, Parse(err, "Wishlist", "ClaimPriceDrops", make(Constraints))
+		}
+		drops = append(drops, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Parse(err, "Wishlist", "ClaimPriceDrops", make(Constraints))
+	}
+
+	return drops, nil
+}
@@ -11,7 +11,8 @@ func SetupCors() gin.HandlerFunc {
 	corsMiddleware := cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // Add your frontend URL
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-Anonymous-ID"},
+		ExposeHeaders:    []string{"X-Anonymous-ID"},
 		AllowCredentials: true, // Enable cookies/auth
 		MaxAge:           12 * time.Hour,
 	})
@@ -3,6 +3,8 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
 )
 
@@ -40,3 +42,63 @@ func AuthRequired(accessTokenSecret string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminOrAPIKey works like AdminOnly, but also lets server-to-server clients
+// authenticate with an X-API-Key header instead of a bearer token.
+// Either way the same claims end up in the context.
+func AdminOrAPIKey(accessTokenSecret, hashSecret string, db database.Service) gin.HandlerFunc {
+	adminOnly := AdminOnly(accessTokenSecret)
+
+	return func(c *gin.Context) {
+		if c.GetHeader(auth.APIKeyHeader) == "" {
+			adminOnly(c)
+			return
+		}
+
+		claims := auth.GetAccessClaimsFromAPIKey(c, db, hashSecret)
+		if claims == nil {
+			return
+		}
+
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// RequireScope rejects api keys that weren't granted the given scope,
+// user access tokens aren't affected by it.
+func RequireScope(scope models.APIKeyScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := auth.GetAccessClaims(c)
+		if claims == nil {
+			c.Abort()
+			return
+		}
+
+		if !claims.HasScope(string(scope)) {
+			utils.FailAndAbort(c, utils.ErrMissingScope(string(scope)), nil)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UserTokenOnly rejects requests authenticated with an api key,
+// it's used for endpoints that only a logged in admin should reach.
+func UserTokenOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := auth.GetAccessClaims(c)
+		if claims == nil {
+			c.Abort()
+			return
+		}
+
+		if claims.IsAPIKey() {
+			utils.FailAndAbort(c, utils.ErrAPIKeyNotAllowed, nil)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Attribute is a product specification like the material or the season. Its values
+// are set per product, a filterable attribute is also a filter of the product listing.
+type Attribute struct {
+	ID         int32         `json:"id"`
+	Code       string        `json:"code"`
+	Name       string        `json:"name"`
+	Type       AttributeType `json:"type"`
+	Unit       pgtype.Text   `json:"unit"`
+	Filterable bool          `json:"filterable"`
+	Position   int32         `json:"position"`
+
+	// CategoryIDs are the categories the attribute is scoped to, the products
+	// under them have it. Without any, every product has it.
+	CategoryIDs []int32 `json:"categoryIds"`
+
+	// Options are the values an enum attribute can take.
+	Options []AttributeOption `json:"options"`
+}
+
+type AttributeOption struct {
+	ID          int32  `json:"id"`
+	Value       string `json:"value"`
+	Position    int32  `json:"position"`
+	AttributeID int32  `json:"-"`
+}
+
+// ProductAttribute is the value a product has for an attribute, only the
+// column of the attribute type is set.
+type ProductAttribute struct {
+	ProductID   int32         `json:"-"`
+	AttributeID int32         `json:"attributeId"`
+	TextValue   pgtype.Text   `json:"-"`
+	OptionID    pgtype.Int4   `json:"-"`
+	NumberValue pgtype.Float8 `json:"-"`
+}
@@ -32,3 +32,155 @@ func (o OrderStatus) IsValid() bool {
 	}
 	return false
 }
+
+type APIKeyScope string
+
+const (
+	ScopeProductsWrite APIKeyScope = "products:write"
+	ScopeCatalogWrite  APIKeyScope = "catalog:write"
+	ScopeOrdersWrite   APIKeyScope = "orders:write"
+)
+
+func (s APIKeyScope) IsValid() bool {
+	switch s {
+	case ScopeProductsWrite, ScopeCatalogWrite, ScopeOrdersWrite:
+		return true
+	}
+	return false
+}
+
+// ProductStatus is where a product is in its publication lifecycle,
+// only published products are shown to the customers.
+type ProductStatus string
+
+const (
+	ProductDraft     ProductStatus = "draft"
+	ProductScheduled ProductStatus = "scheduled"
+	ProductPublished ProductStatus = "published"
+	ProductArchived  ProductStatus = "archived"
+)
+
+func (p ProductStatus) IsValid() bool {
+	switch p {
+	case ProductDraft, ProductScheduled, ProductPublished, ProductArchived:
+		return true
+	}
+	return false
+}
+
+// SlugEntity names the table a slug belongs to.
+type SlugEntity string
+
+const (
+	SlugEntityProduct  SlugEntity = "products"
+	SlugEntityCategory SlugEntity = "categories"
+)
+
+// ImageStatus tells whether the renditions of an uploaded image are made yet.
+type ImageStatus string
+
+const (
+	ImageProcessing ImageStatus = "processing"
+	ImageReady      ImageStatus = "ready"
+	ImageFailed     ImageStatus = "failed"
+)
+
+// UploadOwner is the kind of row an uploaded object belongs to.
+type UploadOwner string
+
+const (
+	UploadOwnerProduct     UploadOwner = "product"
+	UploadOwnerImage       UploadOwner = "image"
+	UploadOwnerUser        UploadOwner = "user"
+	UploadOwnerReview      UploadOwner = "review"
+	UploadOwnerOrderDetail UploadOwner = "order_detail"
+)
+
+// AttributeType is the kind of value a product attribute holds.
+type AttributeType string
+
+const (
+	AttributeText   AttributeType = "text"
+	AttributeEnum   AttributeType = "enum"
+	AttributeNumber AttributeType = "number"
+)
+
+func (a AttributeType) IsValid() bool {
+	switch a {
+	case AttributeText, AttributeEnum, AttributeNumber:
+		return true
+	}
+	return false
+}
+
+// RecommendationReason is the signal a product is recommended along another for.
+type RecommendationReason string
+
+const (
+	RecommendationBoughtTogether     RecommendationReason = "bought_together"
+	RecommendationWishlistedTogether RecommendationReason = "wishlisted_together"
+	RecommendationSimilar            RecommendationReason = "similar"
+)
+
+// ProductEventType is what a customer did with a product.
+type ProductEventType string
+
+const (
+	ProductEventView      ProductEventType = "view"
+	ProductEventAddToCart ProductEventType = "add_to_cart"
+)
+
+// ModerationStatus is where user content stands in moderation, only the approved content is public.
+type ModerationStatus string
+
+const (
+	ModerationPending  ModerationStatus = "pending"
+	ModerationApproved ModerationStatus = "approved"
+	ModerationRejected ModerationStatus = "rejected"
+)
+
+func (m ModerationStatus) IsValid() bool {
+	switch m {
+	case ModerationPending, ModerationApproved, ModerationRejected:
+		return true
+	}
+	return false
+}
+
+// AnswerAuthor is who answered a product question.
+type AnswerAuthor string
+
+const (
+	AnswerByAdmin AnswerAuthor = "admin"
+	AnswerByBuyer AnswerAuthor = "buyer"
+)
+
+// NotificationType is the event a user is notified of.
+type NotificationType string
+
+const (
+	NotificationQuestionAnswered NotificationType = "question_answered"
+	NotificationBackInStock      NotificationType = "back_in_stock"
+	NotificationPriceDrop        NotificationType = "price_drop"
+)
+
+// StockMovementReason is why the stock of a variant changed.
+type StockMovementReason string
+
+const (
+	StockRestock            StockMovementReason = "restock"
+	StockSale               StockMovementReason = "sale"
+	StockCancellationReturn StockMovementReason = "cancellation_return"
+	StockAdjustment         StockMovementReason = "adjustment"
+	StockDamage             StockMovementReason = "damage"
+)
+
+// IsManual reports whether the admins can record movements for the reason,
+// the sales and their returns are only recorded along the orders.
+func (r StockMovementReason) IsManual() bool {
+	switch r {
+	case StockRestock, StockAdjustment, StockDamage:
+		return true
+	}
+	return false
+}
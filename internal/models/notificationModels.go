@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Notification is an in-app notification of a user, Data holds the ids
+// the client needs to link it, like the questionId of an answered question.
+type Notification struct {
+	ID        int32              `json:"id"`
+	Type      NotificationType   `json:"type"`
+	Message   string             `json:"message"`
+	Data      map[string]any     `json:"data"`
+	ReadAt    pgtype.Timestamptz `json:"readAt"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UserID    int32              `json:"-"`
+	ProductID pgtype.Int4        `json:"productId"`
+}
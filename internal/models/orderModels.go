@@ -7,27 +7,39 @@ import (
 )
 
 type Order struct {
-	ID          int32
-	Town        string
-	Street      string
-	Address     string
-	Name        string
-	PhoneNumber string
-	TotalPrice  int
-	CityID      int32
-	UserID      int32
-	OrderStatus OrderStatus
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	CancelledAt pgtype.Time
+	ID          int32            `json:"id"`
+	Town        string           `json:"town"`
+	Street      string           `json:"street"`
+	Address     string           `json:"address"`
+	Name        string           `json:"name"`
+	PhoneNumber string           `json:"phoneNumber"`
+	TotalPrice  int              `json:"totalPrice"`
+	CityID      int32            `json:"cityId"`
+	UserID      int32            `json:"userId"`
+	OrderStatus OrderStatus      `json:"orderStatus"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+	CancelledAt pgtype.Timestamp `json:"cancelledAt"`
 }
 
+// OrderDetails is a line of an order, the product it was is copied into the line at
+// checkout so editing or deleting the product later doesn't change the order.
 type OrderDetails struct {
-	ID         int32
-	Quantity   int
-	TotalPrice int
-	ProductID  int32
-	OrderID    int32
+	ID         int32 `json:"id"`
+	Quantity   int   `json:"quantity"`
+	TotalPrice int   `json:"totalPrice"`
+	// the variant that was bought, it's null once the variant is deleted.
+	ProductID pgtype.Int4 `json:"variantId"`
+	OrderID   int32       `json:"orderId"`
+
+	ProductName   string         `json:"productName"`
+	Brand         string         `json:"brand"`
+	Color         string         `json:"color"`
+	Size          string         `json:"size"`
+	Thumbnail     string         `json:"thumbnail"`
+	UnitPrice     int            `json:"unitPrice"`
+	DiscountType  pgtype.Text    `json:"discountType"`
+	DiscountValue pgtype.Numeric `json:"discountValue"`
 }
 
 type City struct {
@@ -7,30 +7,45 @@ import (
 )
 
 type Product struct {
-	ID              int32       `json:"id"`
-	Name            string      `json:"name"`
-	Details         pgtype.Text `json:"details"`
-	Thumbnail       string      `json:"thumbnail"`
-	CreatedAt       time.Time   `json:"-"`
-	UpdatedAt       time.Time   `json:"-"`
-	BrandID         int32       `json:"-"`
-	ProductCategory int32       `json:"-"`
+	ID              int32              `json:"id"`
+	Name            string             `json:"name"`
+	Slug            string             `json:"slug"`
+	Details         pgtype.Text        `json:"details"`
+	Thumbnail       string             `json:"thumbnail"`
+	Status          ProductStatus      `json:"status"`
+	PublishedAt     pgtype.Timestamptz `json:"publishedAt"`
+	CreatedAt       time.Time          `json:"-"`
+	UpdatedAt       time.Time          `json:"-"`
+	BrandID         int32              `json:"-"`
+	ProductCategory int32              `json:"-"`
 }
 
 type ProductVariant struct {
-	ID        int32     `json:"id"`
-	Quantity  int       `json:"quantity"`
+	ID       int32 `json:"id"`
+	Quantity int   `json:"quantity"`
+	Price    int   `json:"price"`
+	// SKU is generated from the product, color and size codes when it's created without one.
+	SKU       pgtype.Text `json:"sku"`
+	Barcode   pgtype.Text `json:"barcode"`
+	CreatedAt time.Time   `json:"-"`
+	UpdatedAt time.Time   `json:"-"`
+	ProductID int32       `json:"-"`
+	ColorID   int32       `json:"-"`
+	SizeID    int32       `json:"-"`
+}
+
+// VariantPrice is a price the variant had from CreatedAt until the next one.
+type VariantPrice struct {
+	ID        int64     `json:"id"`
 	Price     int       `json:"price"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
-	ProductID int32     `json:"-"`
-	ColorID   int32     `json:"-"`
-	SizeID    int32     `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	VariantID int32     `json:"variantId"`
 }
 
 type Category struct {
 	ID       int32       `json:"id"`
 	Name     string      `json:"name"`
+	Slug     string      `json:"slug"`
 	ParentID pgtype.Int4 `json:"parentId"`
 }
 
@@ -44,11 +59,49 @@ type RatingReview struct {
 	ProductID int32       `json:"productId"`
 }
 
+// Image is a gallery image, Image and LowResImage are its largest and smallest renditions.
+// They're empty until the image is ready, Original is the upload they're made out of.
 type Image struct {
-	ID          int32  `json:"id"`
-	Image       string `json:"image"`
-	LowResImage string `json:"lowResImage"`
-	ProductID   int32  `json:"-"`
+	ID          int32       `json:"id"`
+	Status      ImageStatus `json:"status"`
+	Image       string      `json:"image"`
+	LowResImage string      `json:"lowResImage"`
+	Original    pgtype.Text `json:"-"`
+	// SourceURL is where an imported image is fetched from.
+	SourceURL     pgtype.Text      `json:"-"`
+	Srcset        []ImageRendition `json:"srcset"`
+	Blurhash      pgtype.Text      `json:"blurhash"`
+	DominantColor pgtype.Text      `json:"dominantColor"`
+	Position      int32            `json:"position"`
+	AltText       pgtype.Text      `json:"altText"`
+	ColorID       pgtype.Int4      `json:"colorId"`
+	ProductID     int32            `json:"-"`
+}
+
+// URLs returns the urls of all the image objects.
+func (i *Image) URLs() []string {
+	var urls []string
+	if i.Original.Valid {
+		urls = append(urls, i.Original.String)
+	}
+	if i.Image != "" {
+		urls = append(urls, i.Image)
+	}
+	if i.LowResImage != "" && i.LowResImage != i.Image {
+		urls = append(urls, i.LowResImage)
+	}
+	for _, r := range i.Srcset {
+		if r.URL != i.Image && r.URL != i.LowResImage {
+			urls = append(urls, r.URL)
+		}
+	}
+	return urls
+}
+
+type ImageRendition struct {
+	URL    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
 }
 
 type Brand struct {
@@ -66,3 +119,42 @@ type Color struct {
 	ID    int32  `json:"id"`
 	Color string `json:"color"`
 }
+
+// SizeChart holds a brand's measurements of its sizes for a category.
+type SizeChart struct {
+	ID         int32          `json:"id"`
+	BrandID    int32          `json:"brandId"`
+	CategoryID int32          `json:"categoryId"`
+	Note       pgtype.Text    `json:"note"`
+	Rows       []SizeChartRow `json:"rows"`
+}
+
+// SizeChartRow is the measurements of a size in cm, the ones not measured are null.
+type SizeChartRow struct {
+	SizeID   int32         `json:"sizeId"`
+	Size     string        `json:"size"`
+	Label    string        `json:"label"`
+	ChestCm  pgtype.Float8 `json:"chestCm"`
+	WaistCm  pgtype.Float8 `json:"waistCm"`
+	LengthCm pgtype.Float8 `json:"lengthCm"`
+	Position int32         `json:"position"`
+}
+
+// ShoeSizeConversion is the US and UK equivalent of an EU shoe size.
+type ShoeSizeConversion struct {
+	SizeID       int32         `json:"sizeId"`
+	EU           string        `json:"eu"`
+	UK           float64       `json:"uk"`
+	USMen        float64       `json:"usMen"`
+	USWomen      float64       `json:"usWomen"`
+	FootLengthCm pgtype.Float8 `json:"footLengthCm"`
+}
+
+// ProductEvent is a product view or add to cart, made by a user or else by a guest.
+type ProductEvent struct {
+	Event       ProductEventType
+	ProductID   int32
+	UserID      pgtype.Int4
+	AnonymousID pgtype.Text
+	CreatedAt   time.Time
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductQuestion is a question a user asked about a product.
+type ProductQuestion struct {
+	ID        int32            `json:"id"`
+	Question  string           `json:"question"`
+	Status    ModerationStatus `json:"status"`
+	CreatedAt time.Time        `json:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	ProductID int32            `json:"productId"`
+	UserID    int32            `json:"userId"`
+}
+
+// ProductAnswer is the answer of an admin or a buyer of the product to a question.
+type ProductAnswer struct {
+	ID         int32            `json:"id"`
+	Answer     string           `json:"answer"`
+	Author     AnswerAuthor     `json:"author"`
+	Status     ModerationStatus `json:"status"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+	QuestionID int32            `json:"questionId"`
+	UserID     int32            `json:"userId"`
+}
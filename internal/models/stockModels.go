@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// StockMovement is an entry of the stock ledger, a change of the quantity of a variant.
+// ActorID or APIKeyID is who made it, OrderID the order of a sale or its return.
+type StockMovement struct {
+	ID            int64               `json:"id"`
+	Change        int                 `json:"change"`
+	QuantityAfter int                 `json:"quantityAfter"`
+	Reason        StockMovementReason `json:"reason"`
+	Note          pgtype.Text         `json:"note"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	VariantID     int32               `json:"variantId"`
+	ActorID       pgtype.Int4         `json:"actorId"`
+	APIKeyID      pgtype.Int4         `json:"apiKeyId"`
+	OrderID       pgtype.Int4         `json:"orderId"`
+}
+
+// StockSubscription is a user waiting for a variant to be back in stock. It's queued
+// by a restock, notified shortly after and it's over once notified or expired.
+type StockSubscription struct {
+	ID         int32              `json:"id"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	ExpiresAt  time.Time          `json:"expiresAt"`
+	QueuedAt   pgtype.Timestamptz `json:"-"`
+	NotifiedAt pgtype.Timestamptz `json:"-"`
+	VariantID  int32              `json:"variantId"`
+	UserID     int32              `json:"-"`
+	ProductID  int32              `json:"productId"`
+}
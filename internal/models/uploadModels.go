@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Upload is an entry of the uploads ledger, every object put in the storage has one.
+type Upload struct {
+	ID           int32              `json:"id"`
+	URL          string             `json:"url"`
+	OwnerType    UploadOwner        `json:"ownerType"`
+	OwnerID      pgtype.Int4        `json:"ownerId"`
+	CheckedAt    pgtype.Timestamptz `json:"checkedAt"`
+	MissingSince pgtype.Timestamptz `json:"missingSince"`
+	CreatedAt    time.Time          `json:"createdAt"`
+
+	// the size and content type of a presigned upload, it's attached once it's confirmed.
+	ExpectedSize        pgtype.Int8        `json:"expectedSize"`
+	ExpectedContentType pgtype.Text        `json:"expectedContentType"`
+	ConfirmedAt         pgtype.Timestamptz `json:"confirmedAt"`
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+)
+
+type createAPIKeyReq struct {
+	Name          string   `json:"name"          binding:"required"`
+	Scopes        []string `json:"scopes"        binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"min=0"`
+}
+
+type createAPIKeyRes struct {
+	// Key is only returned once, it can't be recovered afterwards.
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"apiKey"`
+}
+
+func (s *Server) createAPIKey(c *gin.Context) {
+	var req createAPIKeyReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !models.APIKeyScope(scope).IsValid() {
+			utils.Fail(
+				c,
+				utils.NewAPIError(http.StatusBadRequest, "there's no such scope: "+scope),
+				nil,
+			)
+			return
+		}
+	}
+
+	claims := auth.GetAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	rawKey, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	keyHash, err := utils.HashToken(rawKey, s.Env.HashSecret)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	var expiresAt pgtype.Timestamptz
+	if req.ExpiresInDays > 0 {
+		expiresAt = pgtype.Timestamptz{
+			Time:  utils.GetExpTimeAfterDays(req.ExpiresInDays),
+			Valid: true,
+		}
+	}
+
+	key := models.APIKey{
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+		CreatedBy: int32(userID),
+	}
+
+	db := s.DB.Pool()
+	apiKeyRepo := s.DB.APIKey()
+
+	_, err = apiKeyRepo.Create(c, db, &key)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, createAPIKeyRes{
+		Key:    rawKey,
+		APIKey: key,
+	})
+}
+
+func (s *Server) getAPIKeys(c *gin.Context) {
+	db := s.DB.Pool()
+	apiKeyRepo := s.DB.APIKey()
+
+	keys, err := apiKeyRepo.GetAll(c, db)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(keys) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, keys)
+}
+
+func (s *Server) revokeAPIKey(c *gin.Context) {
+	keyID := convStrToInt(c, c.Param("id"), "api key id")
+	if keyID == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	apiKeyRepo := s.DB.APIKey()
+
+	err := apiKeyRepo.Revoke(c, db, int32(keyID))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "api key revoked successfully")
+}
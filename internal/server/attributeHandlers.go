@@ -0,0 +1,500 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+)
+
+// attributeQueryPrefix starts the queries filtering by an attribute, ?attr_<code>=a,b
+// for text and enum attributes and ?attr_<code>_min=&attr_<code>_max= for number ones.
+const attributeQueryPrefix = "attr_"
+
+// getAttributeFilters reads the filters of the given attributes out of the query.
+// It fails the request and returns false as the second value if a filter doesn't
+// name one of the attributes or a number filter isn't a number.
+func getAttributeFilters(
+	c *gin.Context,
+	attributes []models.Attribute,
+) ([]filters.AttributeFilter, bool) {
+	query := c.Request.URL.Query()
+	known := make(map[string]bool)
+
+	var attributeFilters []filters.AttributeFilter
+	for _, attr := range attributes {
+		key := attributeQueryPrefix + attr.Code
+		f := filters.AttributeFilter{AttributeID: attr.ID}
+
+		if attr.Type == models.AttributeNumber {
+			for _, bound := range []struct {
+				key   string
+				value **float64
+			}{{key + "_min", &f.Min}, {key + "_max", &f.Max}} {
+				known[bound.key] = true
+				raw := strings.TrimSpace(query.Get(bound.key))
+				if raw == "" {
+					continue
+				}
+
+				val, err := strconv.ParseFloat(raw, 64)
+				if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+					utils.Fail(
+						c,
+						utils.NewAPIError(http.StatusBadRequest, "Invalid "+bound.key),
+						err,
+					)
+					return nil, false
+				}
+				*bound.value = &val
+			}
+
+			if f.Min != nil || f.Max != nil {
+				attributeFilters = append(attributeFilters, f)
+			}
+			continue
+		}
+
+		known[key] = true
+		for _, values := range query[key] {
+			for _, value := range strings.Split(values, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					f.Values = append(f.Values, value)
+				}
+			}
+		}
+		if len(f.Values) > 0 {
+			attributeFilters = append(attributeFilters, f)
+		}
+	}
+
+	for key := range query {
+		if strings.HasPrefix(key, attributeQueryPrefix) && !known[key] {
+			utils.Fail(
+				c,
+				utils.NewAPIError(http.StatusBadRequest, "Unknown attribute filter "+key),
+				errors.New("unknown attribute filter"),
+			)
+			return nil, false
+		}
+	}
+
+	return attributeFilters, true
+}
+
+// getAttributes lists the attributes along with their options,
+// ?category_id= keeps the ones the products of that category have.
+func (s *Server) getAttributes(c *gin.Context) {
+	categoryID, ok := getOptionalQueryInt(c, "category_id")
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	attributeRepo := s.DB.Attribute()
+
+	attributes, err := attributeRepo.GetAll(c, db, int32(categoryID))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, attributes)
+}
+
+type createAttributeReq struct {
+	Code        string               `json:"code"        binding:"required"`
+	Name        string               `json:"name"        binding:"required"`
+	Type        models.AttributeType `json:"type"        binding:"required"`
+	Unit        string               `json:"unit"`
+	Filterable  bool                 `json:"filterable"`
+	Position    int32                `json:"position"`
+	CategoryIDs []int32              `json:"categoryIds"`
+	Options     []string             `json:"options"`
+}
+
+func (s *Server) createAttribute(c *gin.Context) {
+	var req createAttributeReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	if !req.Type.IsValid() {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "type must be text, enum or number"),
+			errors.New("invalid attribute type"),
+		)
+		return
+	}
+	if req.Unit != "" && req.Type != models.AttributeNumber {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "only number attributes have a unit"),
+			errors.New("unit of a non number attribute"),
+		)
+		return
+	}
+	if len(req.Options) > 0 && req.Type != models.AttributeEnum {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "only enum attributes have options"),
+			errors.New("options of a non enum attribute"),
+		)
+		return
+	}
+
+	attribute := models.Attribute{
+		Code:       strings.ToLower(strings.TrimSpace(req.Code)),
+		Name:       req.Name,
+		Type:       req.Type,
+		Unit:       pgtype.Text{String: req.Unit, Valid: req.Unit != ""},
+		Filterable: req.Filterable,
+		Position:   req.Position,
+	}
+
+	attributeRepo := s.DB.Attribute()
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := attributeRepo.Create(c, tx, &attribute); err != nil {
+			return err
+		}
+
+		if err := attributeRepo.SetCategories(c, tx, attribute.ID, req.CategoryIDs); err != nil {
+			return err
+		}
+
+		for _, value := range req.Options {
+			option := models.AttributeOption{
+				Value:       strings.TrimSpace(value),
+				AttributeID: attribute.ID,
+			}
+			if err := attributeRepo.CreateOption(c, tx, &option); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, gin.H{"id": attribute.ID})
+}
+
+type updateAttributeReq struct {
+	Name       string  `json:"name"`
+	Unit       *string `json:"unit"`
+	Filterable *bool   `json:"filterable"`
+	Position   *int32  `json:"position"`
+
+	// CategoryIDs replace the categories of the attribute when they're given,
+	// an empty list makes it apply to every product.
+	CategoryIDs *[]int32 `json:"categoryIds"`
+}
+
+// updateAttribute updates the given fields of the attribute, its code and type can't change.
+func (s *Server) updateAttribute(c *gin.Context) {
+	id := convStrToInt(c, c.Param("id"), "attribute id")
+	if id == 0 {
+		return
+	}
+
+	var req updateAttributeReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	attributeRepo := s.DB.Attribute()
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		attribute, err := attributeRepo.Get(c, tx, int32(id))
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(req.Name) != "" {
+			attribute.Name = req.Name
+		}
+		if req.Unit != nil {
+			attribute.Unit = pgtype.Text{String: *req.Unit, Valid: *req.Unit != ""}
+		}
+		if req.Filterable != nil {
+			attribute.Filterable = *req.Filterable
+		}
+		if req.Position != nil {
+			attribute.Position = *req.Position
+		}
+
+		if err = attributeRepo.Update(c, tx, attribute); err != nil {
+			return err
+		}
+
+		if req.CategoryIDs != nil {
+			return attributeRepo.SetCategories(c, tx, attribute.ID, *req.CategoryIDs)
+		}
+
+		return nil
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "attribute updated successfully")
+}
+
+// deleteAttribute deletes the attribute along with the values the products have for it.
+func (s *Server) deleteAttribute(c *gin.Context) {
+	id := convStrToInt(c, c.Param("id"), "attribute id")
+	if id == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	attributeRepo := s.DB.Attribute()
+
+	err := attributeRepo.Delete(c, db, int32(id))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "attribute deleted successfully")
+}
+
+type attributeOptionReq struct {
+	Value string `json:"value" binding:"required"`
+}
+
+func (s *Server) addAttributeOption(c *gin.Context) {
+	id := convStrToInt(c, c.Param("id"), "attribute id")
+	if id == 0 {
+		return
+	}
+
+	var req attributeOptionReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	db := s.DB.Pool()
+	attributeRepo := s.DB.Attribute()
+
+	attribute, err := attributeRepo.Get(c, db, int32(id))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if attribute.Type != models.AttributeEnum {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "only enum attributes have options"),
+			errors.New("option of a non enum attribute"),
+		)
+		return
+	}
+
+	option := models.AttributeOption{
+		Value:       strings.TrimSpace(req.Value),
+		AttributeID: attribute.ID,
+	}
+	err = attributeRepo.CreateOption(c, db, &option)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, option)
+}
+
+func (s *Server) deleteAttributeOption(c *gin.Context) {
+	id := convStrToInt(c, c.Param("id"), "attribute id")
+	if id == 0 {
+		return
+	}
+
+	optionID := convStrToInt(c, c.Param("optionId"), "option id")
+	if optionID == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	attributeRepo := s.DB.Attribute()
+
+	err := attributeRepo.DeleteOption(c, db, int32(id), int32(optionID))
+	if err != nil && database.IsDBForeignKeyErr(err) {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusConflict, "can not delete this option while products have it"),
+			err,
+		)
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "option deleted successfully")
+}
+
+type productAttributeReq struct {
+	AttributeID int32 `json:"attributeId" binding:"required"`
+
+	// Value is the value of a text attribute or one of the options of an enum
+	// attribute, Number is the value of a number attribute.
+	Value  *string  `json:"value"`
+	Number *float64 `json:"number"`
+}
+
+type productAttributesReq struct {
+	Attributes []productAttributeReq `json:"attributes" binding:"dive"`
+}
+
+// setProductAttributes replaces the attribute values of the product,
+// the attributes have to apply to the product's category.
+func (s *Server) setProductAttributes(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	var req productAttributesReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+	attributeRepo := s.DB.Attribute()
+
+	p, err := productRepo.Get(c, db, productID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	attributes, err := attributeRepo.GetAll(c, db, p.ProductCategory)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	values, apiErr := productAttributeValues(attributes, req.Attributes)
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New(apiErr.Message))
+		return
+	}
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		return attributeRepo.SetValuesOfProduct(c, tx, p.ID, values)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "product attributes updated successfully")
+}
+
+// productAttributeValues checks the requested values against the attributes the product
+// can have, and puts each one in the column of its attribute type.
+func productAttributeValues(
+	attributes []models.Attribute,
+	reqs []productAttributeReq,
+) ([]models.ProductAttribute, *utils.APIError) {
+	byID := make(map[int32]models.Attribute, len(attributes))
+	for _, attr := range attributes {
+		byID[attr.ID] = attr
+	}
+
+	seen := make(map[int32]bool, len(reqs))
+	values := make([]models.ProductAttribute, 0, len(reqs))
+	for _, req := range reqs {
+		attr, ok := byID[req.AttributeID]
+		if !ok {
+			return nil, utils.NewAPIError(
+				http.StatusBadRequest,
+				fmt.Sprintf("attribute %d doesn't apply to the product's category", req.AttributeID),
+			)
+		}
+		if seen[attr.ID] {
+			return nil, utils.NewAPIError(
+				http.StatusBadRequest,
+				fmt.Sprintf("attribute %s is given more than once", attr.Code),
+			)
+		}
+		seen[attr.ID] = true
+
+		v := models.ProductAttribute{AttributeID: attr.ID}
+		switch attr.Type {
+		case models.AttributeNumber:
+			if req.Number == nil || math.IsNaN(*req.Number) || math.IsInf(*req.Number, 0) {
+				return nil, utils.NewAPIError(
+					http.StatusBadRequest,
+					fmt.Sprintf("attribute %s needs a number", attr.Code),
+				)
+			}
+			v.NumberValue = pgtype.Float8{Float64: *req.Number, Valid: true}
+		case models.AttributeEnum:
+			if req.Value == nil {
+				return nil, utils.NewAPIError(
+					http.StatusBadRequest,
+					fmt.Sprintf("attribute %s needs one of its options", attr.Code),
+				)
+			}
+			for _, option := range attr.Options {
+				if strings.EqualFold(option.Value, strings.TrimSpace(*req.Value)) {
+					v.OptionID = pgtype.Int4{Int32: option.ID, Valid: true}
+				}
+			}
+			if !v.OptionID.Valid {
+				return nil, utils.NewAPIError(
+					http.StatusBadRequest,
+					fmt.Sprintf("%q isn't an option of attribute %s", *req.Value, attr.Code),
+				)
+			}
+		default:
+			if req.Value == nil || strings.TrimSpace(*req.Value) == "" {
+				return nil, utils.NewAPIError(
+					http.StatusBadRequest,
+					fmt.Sprintf("attribute %s needs a value", attr.Code),
+				)
+			}
+			v.TextValue = pgtype.Text{String: strings.TrimSpace(*req.Value), Valid: true}
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
@@ -39,6 +39,7 @@ func (s *Server) addToCart(ctx *gin.Context) {
 		return
 	}
 
+	var productID int32
 	err = s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
 		var (
 			cartID        int32
@@ -64,6 +65,11 @@ func (s *Server) addToCart(ctx *gin.Context) {
 		}
 		totalPricePerProduct := price * req.Quantity
 
+		productID, err = productVariantRepo.GetProductID(ctx, tx, req.ProductID)
+		if err != nil {
+			return err
+		}
+
 		// add product to cart item
 		err = cartItemRepo.Create(ctx, tx, &models.CartItem{
 			CartID:     cartID,
@@ -99,6 +105,8 @@ func (s *Server) addToCart(ctx *gin.Context) {
 		return
 	}
 
+	s.recordProductEvent(ctx, models.ProductEventAddToCart, productID)
+
 	utils.Created(ctx, nil)
 }
 
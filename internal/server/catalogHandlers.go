@@ -0,0 +1,748 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/catalogsheet"
+	"github.com/refine-software/afrad-api/internal/utils/imageproc"
+	"github.com/refine-software/afrad-api/internal/utils/validator"
+)
+
+const (
+	// maxImportFileSize is the max size of an imported sheet.
+	maxImportFileSize = 10 << 20 // 10MB
+
+	// importFetchTimeout bounds the fetch of a single image of an import.
+	importFetchTimeout = 30 * time.Second
+)
+
+// importClient fetches the images of the sheets for the image worker, the urls come
+// from the sheet so it only connects to public addresses.
+var importClient = &http.Client{
+	Timeout: importFetchTimeout,
+	Transport: &http.Transport{
+		// a proxy would connect on our behalf, out of reach of the address check.
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: rejectPrivateAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: importFetchTimeout,
+	},
+}
+
+var errPrivateAddress = errors.New("the image url points to a private address")
+
+// rejectPrivateAddress refuses the connections to loopback, private, link-local and
+// unspecified addresses. It runs on the resolved address of every connection,
+// the redirects included, so a host name can't resolve around it.
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	addr = addr.Unmap()
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return errPrivateAddress
+	}
+
+	return nil
+}
+
+type importRowError struct {
+	Line    int    `json:"line"`
+	Column  string `json:"column"`
+	Message string `json:"message"`
+}
+
+type importReport struct {
+	DryRun   bool `json:"dryRun"`
+	Applied  bool `json:"applied"`
+	Products int  `json:"products"`
+	Variants int  `json:"variants"`
+	// Images are queued for the image worker, which fetches them once the import is applied.
+	Images int `json:"images"`
+	// NewBrands are created by the import, the other names have to exist already.
+	NewBrands []string         `json:"newBrands"`
+	Errors    []importRowError `json:"errors"`
+}
+
+func (r *importReport) addError(line int, column, format string, args ...any) {
+	r.Errors = append(r.Errors, importRowError{
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// importProduct is a product of the sheet along with its variants.
+type importProduct struct {
+	// first is the first row of the product, the product columns are read from it.
+	first    catalogsheet.Row
+	product  models.Product
+	brand    string
+	variants []models.ProductVariant
+
+	// thumbnail and images are the urls in the sheet, they're fetched by the image worker.
+	thumbnail string
+	images    []string
+}
+
+// sources are the urls of the product gallery, the thumbnail comes first when
+// it isn't one of the images.
+func (p *importProduct) sources() []string {
+	if slices.Contains(p.images, p.thumbnail) {
+		return p.images
+	}
+	return append([]string{p.thumbnail}, p.images...)
+}
+
+// catalogLookup resolves the names used in the sheet to ids, the keys are lower cased.
+type catalogLookup struct {
+	brands     map[string]int32
+	categories map[string]int32
+	colors     map[string]int32
+	sizes      map[string][]models.Size
+
+	// categoryPaths are the category paths by id as they're written in the sheet.
+	categoryPaths map[int32]string
+}
+
+// lookupKey normalizes a name of the sheet, the names are matched case insensitively.
+func lookupKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// categoryPathKey normalizes a category path, the spaces around the slashes don't matter.
+func categoryPathKey(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = lookupKey(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// categoryPaths builds the path of every category, e.g. Men/Shoes/Sneakers.
+func categoryPaths(categories []models.Category) map[int32]string {
+	byID := make(map[int32]models.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	paths := make(map[int32]string, len(categories))
+	for _, category := range categories {
+		names := []string{category.Name}
+		parent := category.ParentID
+
+		// the depth guards against a cycle, moving a category under its descendant is rejected anyway.
+		for depth := 0; parent.Valid && depth < len(categories); depth++ {
+			p, ok := byID[parent.Int32]
+			if !ok {
+				break
+			}
+			names = append(names, p.Name)
+			parent = p.ParentID
+		}
+
+		slices.Reverse(names)
+		paths[category.ID] = strings.Join(names, "/")
+	}
+
+	return paths
+}
+
+func (s *Server) loadCatalogLookup(c *gin.Context, db database.Querier) (*catalogLookup, error) {
+	brands, err := s.DB.Brand().GetAll(c, db)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := s.DB.Category().GetAll(c, db)
+	if err != nil {
+		return nil, err
+	}
+
+	colors, err := s.DB.Color().GetAll(c, db)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, err := s.DB.Size().GetAll(c, db)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := &catalogLookup{
+		brands:        make(map[string]int32, len(brands)),
+		categories:    make(map[string]int32, len(*categories)),
+		colors:        make(map[string]int32, len(*colors)),
+		sizes:         make(map[string][]models.Size, len(*sizes)),
+		categoryPaths: categoryPaths(*categories),
+	}
+	for _, b := range brands {
+		lookup.brands[lookupKey(b.Brand)] = b.ID
+	}
+	for id, path := range lookup.categoryPaths {
+		lookup.categories[categoryPathKey(path)] = id
+	}
+	for _, color := range *colors {
+		lookup.colors[lookupKey(color.Color)] = color.ID
+	}
+	for _, size := range *sizes {
+		key := lookupKey(size.Size)
+		lookup.sizes[key] = append(lookup.sizes[key], size)
+	}
+
+	return lookup, nil
+}
+
+// sizeID resolves the size of a row, the label is only needed when several sizes share the value.
+func (l *catalogLookup) sizeID(size, label string) (int32, string) {
+	var matches []models.Size
+	for _, s := range l.sizes[lookupKey(size)] {
+		if label == "" || lookupKey(s.Label) == lookupKey(label) {
+			matches = append(matches, s)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return 0, "there's no such size"
+	case 1:
+		return matches[0].ID, ""
+	default:
+		return 0, "there are several sizes with this value, set its size_label"
+	}
+}
+
+// isImportURL tells whether the image url of the sheet can be fetched.
+func isImportURL(imageURL string) bool {
+	u, err := url.Parse(imageURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// buildImport validates the rows of the sheet and groups them into products by name.
+// Every problem is reported with its line, so the whole sheet can be fixed at once.
+func buildImport(
+	rows []catalogsheet.Row,
+	lookup *catalogLookup,
+	existingNames []string,
+) ([]*importProduct, *importReport) {
+	report := &importReport{
+		NewBrands: []string{},
+		Errors:    []importRowError{},
+	}
+
+	existing := make(map[string]bool, len(existingNames))
+	for _, name := range existingNames {
+		existing[lookupKey(name)] = true
+	}
+
+	var (
+		products  []*importProduct
+		byName    = make(map[string]*importProduct)
+		newBrands = make(map[string]bool)
+		// variantLines are the lines of the variants of every product by color and size.
+		variantLines = make(map[*importProduct]map[[2]int32]int)
+		// skuLines and barcodeLines are the lines of the variants by their codes.
+		skuLines     = make(map[string]int)
+		barcodeLines = make(map[string]int)
+	)
+	for _, row := range rows {
+		if row.Name == "" {
+			report.addError(row.Line, "name", "the product name is required")
+			continue
+		}
+
+		p, ok := byName[lookupKey(row.Name)]
+		if !ok {
+			p = newImportProduct(row, lookup, existing, report)
+			byName[lookupKey(row.Name)] = p
+			products = append(products, p)
+			variantLines[p] = make(map[[2]int32]int)
+
+			if p.product.BrandID == 0 && p.brand != "" && !newBrands[lookupKey(p.brand)] {
+				newBrands[lookupKey(p.brand)] = true
+				report.NewBrands = append(report.NewBrands, p.brand)
+			}
+		} else {
+			checkSameProduct(p.first, row, report)
+		}
+
+		// a row without the variant columns only adds the product.
+		if row.Color == "" && row.Size == "" && row.Price == "" && row.Quantity == "" {
+			continue
+		}
+
+		v, ok := importVariant(row, lookup, report)
+		if !ok {
+			continue
+		}
+
+		key := [2]int32{v.ColorID, v.SizeID}
+		if line, ok := variantLines[p][key]; ok {
+			report.addError(row.Line, "size", "the product has this color and size on line %d already", line)
+			continue
+		}
+		if line, ok := skuLines[v.SKU.String]; ok && v.SKU.Valid {
+			report.addError(row.Line, "sku", "the sku is on line %d already", line)
+			continue
+		}
+		if line, ok := barcodeLines[v.Barcode.String]; ok && v.Barcode.Valid {
+			report.addError(row.Line, "barcode", "the barcode is on line %d already", line)
+			continue
+		}
+
+		variantLines[p][key] = row.Line
+		if v.SKU.Valid {
+			skuLines[v.SKU.String] = row.Line
+		}
+		if v.Barcode.Valid {
+			barcodeLines[v.Barcode.String] = row.Line
+		}
+		p.variants = append(p.variants, v)
+	}
+
+	report.Products = len(products)
+	for _, p := range products {
+		report.Variants += len(p.variants)
+	}
+
+	return products, report
+}
+
+func newImportProduct(
+	row catalogsheet.Row,
+	lookup *catalogLookup,
+	existing map[string]bool,
+	report *importReport,
+) *importProduct {
+	p := &importProduct{
+		first: row,
+		brand: row.Brand,
+		product: models.Product{
+			Name:    row.Name,
+			Details: pgtype.Text{String: row.Details, Valid: row.Details != ""},
+		},
+	}
+
+	if existing[lookupKey(row.Name)] {
+		report.addError(row.Line, "name", "a product with this name already exists")
+	}
+
+	if row.Brand == "" {
+		report.addError(row.Line, "brand", "the brand is required")
+	} else {
+		// a missing brand is created, p.product.BrandID stays 0 until then.
+		p.product.BrandID = lookup.brands[lookupKey(row.Brand)]
+	}
+
+	if row.Category == "" {
+		report.addError(row.Line, "category", "the category is required")
+	} else if id, ok := lookup.categories[categoryPathKey(row.Category)]; ok {
+		p.product.ProductCategory = id
+	} else {
+		report.addError(row.Line, "category", "there's no such category path")
+	}
+
+	p.product.Status = models.ProductDraft
+	if row.Status != "" {
+		p.product.Status = models.ProductStatus(lookupKey(row.Status))
+	}
+
+	var publishAt *time.Time
+	if row.PublishAt != "" {
+		t, err := time.Parse(time.RFC3339, row.PublishAt)
+		if err != nil {
+			report.addError(row.Line, "publish_at", "the publish time has to be like 2026-01-02T15:04:05Z")
+		} else {
+			publishAt = &t
+		}
+	}
+	publishedAt, apiErr := getPublishedAt(p.product.Status, publishAt)
+	if apiErr != nil {
+		report.addError(row.Line, "status", "%s", apiErr.Message)
+	}
+	p.product.PublishedAt = publishedAt
+
+	p.images = catalogsheet.SplitImages(row.Images)
+	for _, imageURL := range p.images {
+		if !isImportURL(imageURL) {
+			report.addError(row.Line, "images", "%q isn't an http url", imageURL)
+		}
+	}
+
+	// the first image is the thumbnail when there is none.
+	p.thumbnail = row.Thumbnail
+	if p.thumbnail == "" && len(p.images) > 0 {
+		p.thumbnail = p.images[0]
+	}
+	if p.thumbnail == "" {
+		report.addError(row.Line, "thumbnail", "a thumbnail or images are required")
+	} else if !isImportURL(p.thumbnail) {
+		report.addError(row.Line, "thumbnail", "%q isn't an http url", p.thumbnail)
+	}
+
+	return p
+}
+
+// checkSameProduct reports the product columns of a later row of a product that
+// differ from its first row, they can be left empty instead.
+func checkSameProduct(first, row catalogsheet.Row, report *importReport) {
+	columns := []struct {
+		name          string
+		first, values string
+	}{
+		{"details", first.Details, row.Details},
+		{"brand", lookupKey(first.Brand), lookupKey(row.Brand)},
+		{"category", categoryPathKey(first.Category), categoryPathKey(row.Category)},
+		{"status", lookupKey(first.Status), lookupKey(row.Status)},
+		{"publish_at", first.PublishAt, row.PublishAt},
+		{"thumbnail", first.Thumbnail, row.Thumbnail},
+		{"images", first.Images, row.Images},
+	}
+	for _, column := range columns {
+		if column.values != "" && column.values != column.first {
+			report.addError(
+				row.Line,
+				column.name,
+				"it differs from the first row of the product on line %d, leave it empty or repeat it",
+				first.Line,
+			)
+		}
+	}
+}
+
+func importVariant(
+	row catalogsheet.Row,
+	lookup *catalogLookup,
+	report *importReport,
+) (models.ProductVariant, bool) {
+	var v models.ProductVariant
+	errorsBefore := len(report.Errors)
+
+	if row.Color == "" {
+		report.addError(row.Line, "color", "the color is required")
+	} else if id, ok := lookup.colors[lookupKey(row.Color)]; ok {
+		v.ColorID = id
+	} else {
+		report.addError(row.Line, "color", "there's no such color")
+	}
+
+	if row.Size == "" {
+		report.addError(row.Line, "size", "the size is required")
+	} else if id, msg := lookup.sizeID(row.Size, row.SizeLabel); msg != "" {
+		report.addError(row.Line, "size", "%s", msg)
+	} else {
+		v.SizeID = id
+	}
+
+	price, err := strconv.Atoi(row.Price)
+	if err != nil || price <= 0 {
+		report.addError(row.Line, "price", "the price has to be a whole number above 0")
+	}
+	v.Price = price
+
+	quantity, err := strconv.Atoi(row.Quantity)
+	if err != nil || quantity < 0 {
+		report.addError(row.Line, "quantity", "the quantity has to be a whole number of 0 or more")
+	}
+	v.Quantity = quantity
+
+	if sku := strings.ToUpper(row.SKU); sku != "" {
+		if !validator.Matches(sku, validator.SKURX) {
+			report.addError(row.Line, "sku", "the sku has to be up to 64 letters, digits, dots, dashes or underscores")
+		}
+		if validator.Matches(sku, validator.BarcodeRX) {
+			report.addError(row.Line, "sku", "the sku can't be shaped like a barcode")
+		}
+		v.SKU = pgtype.Text{String: sku, Valid: true}
+	}
+
+	if row.Barcode != "" {
+		if !validator.Barcode(row.Barcode) {
+			report.addError(row.Line, "barcode", "the barcode has to be an EAN-8, UPC-A or EAN-13 with a valid check digit")
+		}
+		v.Barcode = pgtype.Text{String: validator.NormalizeBarcode(row.Barcode), Valid: true}
+	}
+
+	return v, len(report.Errors) == errorsBefore
+}
+
+// fetchImportImage downloads an image of the sheet, it has to be one of the allowed image types.
+func fetchImportImage(ctx context.Context, imageURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, importFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := importClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the image url responded with %s", res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxPresignedUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPresignedUploadSize {
+		return nil, errors.New("the image exceeds the max size of 20MB")
+	}
+
+	if _, ok := imageproc.Sniff(data); !ok {
+		return nil, errors.New("the url isn't a png, jpeg or webp image")
+	}
+
+	return data, nil
+}
+
+// importProducts validates a CSV or XLSX sheet of products and variants and creates them
+// in a single transaction, their images are fetched and processed by the image worker
+// afterwards. With ?dry_run=true only the validation report is returned.
+func (s *Server) importProducts(c *gin.Context) {
+	dryRun, ok := getOptionalQueryBool(c, "dry_run")
+	if !ok {
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, "the sheet file is required"), err)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxImportFileSize {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "the sheet exceeds the max size of 10MB"),
+			nil,
+		)
+		return
+	}
+
+	format, err := catalogsheet.FormatOf(header.Filename)
+	if err != nil {
+		utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, err.Error()), err)
+		return
+	}
+
+	rows, err := catalogsheet.Read(file, format)
+	if err != nil {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "couldn't read the sheet: "+err.Error()),
+			err,
+		)
+		return
+	}
+
+	db := s.DB.Pool()
+
+	lookup, err := s.loadCatalogLookup(c, db)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		names = append(names, row.Name)
+	}
+	existingNames, err := s.DB.Product().GetExistingNames(c, db, names)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	products, report := buildImport(rows, lookup, existingNames)
+	report.DryRun = dryRun
+
+	if dryRun {
+		utils.Success(c, report)
+		return
+	}
+	if len(report.Errors) > 0 {
+		utils.Unprocessable(c, report)
+		return
+	}
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		return s.applyImport(c, tx, products)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	for _, p := range products {
+		report.Images += len(p.sources())
+	}
+	report.Applied = true
+	utils.Accepted(c, report)
+}
+
+// applyImport creates the new brands, then the products with their variants and images.
+// The images are queued for the image worker, a product shows the thumbnail of the sheet
+// until the worker replaces it with its own.
+func (s *Server) applyImport(c *gin.Context, tx pgx.Tx, products []*importProduct) error {
+	brandRepo := s.DB.Brand()
+	productRepo := s.DB.Product()
+	imageRepo := s.DB.Image()
+	imageJobRepo := s.DB.ImageJob()
+
+	brandIDs := make(map[string]int32)
+	for _, p := range products {
+		if p.product.BrandID != 0 {
+			continue
+		}
+
+		key := lookupKey(p.brand)
+		if _, ok := brandIDs[key]; !ok {
+			id, err := brandRepo.Create(c, tx, p.brand)
+			if err != nil {
+				return err
+			}
+			brandIDs[key] = id
+		}
+		p.product.BrandID = brandIDs[key]
+	}
+
+	for _, p := range products {
+		p.product.Thumbnail = p.thumbnail
+		productID, err := productRepo.Create(c, tx, &p.product)
+		if err != nil {
+			return err
+		}
+
+		for i := range p.variants {
+			p.variants[i].ProductID = productID
+			if err = s.createVariant(c, tx, &p.variants[i]); err != nil {
+				return err
+			}
+		}
+
+		for _, sourceURL := range p.sources() {
+			img := models.Image{
+				SourceURL: pgtype.Text{String: sourceURL, Valid: true},
+				ProductID: productID,
+			}
+			if err = imageRepo.Create(c, tx, &img); err != nil {
+				return err
+			}
+			if err = imageJobRepo.Create(c, tx, img.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportProducts writes every product that isn't deleted to a sheet of the import format,
+// ?format=xlsx for an XLSX file, CSV otherwise.
+func (s *Server) exportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", catalogsheet.FormatCSV)
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case catalogsheet.FormatCSV:
+	case catalogsheet.FormatXLSX:
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, "the format has to be csv or xlsx"), nil)
+		return
+	}
+
+	db := s.DB.Pool()
+
+	categories, err := s.DB.Category().GetAll(c, db)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+	paths := categoryPaths(*categories)
+
+	exportRows, err := s.DB.Product().GetExportRows(c, db)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	rows := make([]catalogsheet.Row, 0, len(exportRows))
+	for _, r := range exportRows {
+		row := catalogsheet.Row{
+			Name:      r.Name,
+			Details:   r.Details,
+			Brand:     r.Brand,
+			Category:  paths[r.CategoryID],
+			Status:    string(r.Status),
+			Color:     r.Color.String,
+			Size:      r.Size.String,
+			SizeLabel: r.SizeLabel.String,
+			Thumbnail: r.Thumbnail,
+			Images:    catalogsheet.JoinImages(r.Images),
+		}
+		// only a scheduled product needs its publish time to be imported again.
+		if r.Status == models.ProductScheduled && r.PublishedAt.Valid {
+			row.PublishAt = r.PublishedAt.Time.UTC().Format(time.RFC3339)
+		}
+		if r.Price.Valid {
+			row.Price = strconv.Itoa(int(r.Price.Int32))
+			row.Quantity = strconv.Itoa(int(r.Quantity.Int32))
+			row.SKU = r.SKU.String
+			row.Barcode = r.Barcode.String
+		}
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	if err = catalogsheet.Write(&buf, format, rows); err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	filename := fmt.Sprintf("products-%s.%s", time.Now().Format(time.DateOnly), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/models"
@@ -61,21 +62,108 @@ func (s *Server) getCategories(ctx *gin.Context) {
 	})
 }
 
-func (s *Server) deleteCategory(ctx *gin.Context) {
+type getCategoryBySlugRes struct {
+	Category   models.Category   `json:"category"`
+	Breadcrumb []models.Category `json:"breadcrumb"`
+}
+
+// getCategoryBySlug returns the category along with its path from the root category,
+// an old slug is permanently redirected to the category's current slug.
+func (s *Server) getCategoryBySlug(ctx *gin.Context) {
+	slug := ctx.Param("slug")
+
 	categoryRepo := s.DB.Category()
 	db := s.DB.Pool()
-	id := convStrToInt(ctx, ctx.Param("id"), "category_id")
 
-	err := categoryRepo.Delete(ctx, db, int32(id))
+	category, err := categoryRepo.GetBySlug(ctx, db, slug)
 	if err != nil && database.IsDBNotFoundErr(err) {
+		s.redirectOldSlug(ctx, models.SlugEntityCategory, slug, "/categories/by-slug/")
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	breadcrumb, err := categoryRepo.GetPath(ctx, db, category.ID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, getCategoryBySlugRes{
+		Category:   *category,
+		Breadcrumb: breadcrumb,
+	})
+}
+
+type getCategoryTreeRes struct {
+	Categories []*database.CategoryNode `json:"categories"`
+}
+
+func (s *Server) getCategoryTree(ctx *gin.Context) {
+	categoryRepo := s.DB.Category()
+	db := s.DB.Pool()
+
+	tree, err := categoryRepo.GetTree(ctx, db)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, getCategoryTreeRes{
+		Categories: tree,
+	})
+}
+
+const (
+	// deleteStrategyBlock refuses to delete a category that still has subcategories or products.
+	deleteStrategyBlock = "block"
+	// deleteStrategyReparent moves the subcategories and products to the deleted category's parent.
+	deleteStrategyReparent = "reparent"
+)
+
+func (s *Server) deleteCategory(ctx *gin.Context) {
+	id := convStrToInt(ctx, ctx.Param("id"), "category_id")
+	if id == 0 {
+		return
+	}
+
+	strategy := ctx.DefaultQuery("strategy", deleteStrategyBlock)
+	if strategy != deleteStrategyBlock && strategy != deleteStrategyReparent {
+		utils.Fail(
+			ctx,
+			utils.NewAPIError(http.StatusBadRequest, "strategy must be either block or reparent"),
+			nil,
+		)
+		return
+	}
+
+	categoryRepo := s.DB.Category()
+
+	err := s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
+		if strategy == deleteStrategyReparent {
+			err := categoryRepo.Reparent(ctx, tx, int32(id))
+			if err != nil {
+				return err
+			}
+		}
+
+		return categoryRepo.Delete(ctx, tx, int32(id))
+	})
+	if err != nil && database.IsDBForeignKeyErr(err) {
 		utils.Fail(
 			ctx,
 			&utils.APIError{
-				Code:    http.StatusBadRequest,
-				Message: "can not delete this category, you should delete its childs first",
+				Code:    http.StatusConflict,
+				Message: "can not delete this category while it has subcategories or products, move them first or use strategy=reparent",
 			},
 			err,
 		)
+		return
 	}
 
 	if err != nil {
@@ -87,6 +175,68 @@ func (s *Server) deleteCategory(ctx *gin.Context) {
 	utils.Success(ctx, nil)
 }
 
+func (s *Server) restoreCategory(ctx *gin.Context) {
+	id := convStrToInt(ctx, ctx.Param("id"), "category_id")
+	if id == 0 {
+		return
+	}
+
+	categoryRepo := s.DB.Category()
+	db := s.DB.Pool()
+
+	err := categoryRepo.Restore(ctx, db, int32(id))
+	if err != nil && database.IsDBForeignKeyErr(err) {
+		utils.Fail(
+			ctx,
+			utils.NewAPIError(
+				http.StatusConflict,
+				"can not restore this category while its parent is deleted, restore the parent first",
+			),
+			err,
+		)
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, "category restored successfully")
+}
+
+type moveCategoryReq struct {
+	// ParentID is the new parent, 0 makes the category a root category.
+	ParentID int32 `json:"parentId"`
+}
+
+func (s *Server) moveCategory(ctx *gin.Context) {
+	var req moveCategoryReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(ctx, utils.ErrBadRequest, err)
+		return
+	}
+
+	id := convStrToInt(ctx, ctx.Param("id"), "category_id")
+	if id == 0 {
+		return
+	}
+
+	categoryRepo := s.DB.Category()
+	db := s.DB.Pool()
+
+	parentID := pgtype.Int4{Int32: req.ParentID, Valid: req.ParentID != 0}
+	err = categoryRepo.Move(ctx, db, int32(id), parentID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, "category moved successfully")
+}
+
 type updateCategoryReq struct {
 	Name string `json:"name" binding:"required"`
 }
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/utils"
 )
 
@@ -70,6 +73,14 @@ func (s *Server) deleteColor(ctx *gin.Context) {
 	colorRepo := s.DB.Color()
 
 	err := colorRepo.Delete(ctx, db, int32(id))
+	if err != nil && database.IsDBForeignKeyErr(err) {
+		utils.Fail(
+			ctx,
+			utils.NewAPIError(http.StatusConflict, "can not delete this color while variants use it"),
+			err,
+		)
+		return
+	}
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(ctx, apiErr, err)
@@ -77,3 +88,21 @@ func (s *Server) deleteColor(ctx *gin.Context) {
 	}
 	utils.Success(ctx, nil)
 }
+
+func (s *Server) restoreColor(ctx *gin.Context) {
+	id := convStrToInt(ctx, ctx.Param("id"), "color_id")
+	if id == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	colorRepo := s.DB.Color()
+
+	err := colorRepo.Restore(ctx, db, int32(id))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+	utils.Success(ctx, "color restored successfully")
+}
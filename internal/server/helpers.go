@@ -1,23 +1,24 @@
 package server
 
 import (
-	"bytes"
+	"context"
 	"errors"
-	"image"
-	"image/jpeg"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
 	"github.com/refine-software/afrad-api/internal/auth"
 	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+	"github.com/refine-software/afrad-api/internal/utils/imageproc"
+	"github.com/refine-software/afrad-api/internal/utils/validator"
 )
 
 func getUserRole(email string) models.Role {
@@ -112,7 +113,7 @@ type ImageUpload struct {
 //
 // Notes:
 //   - This function does not close the returned file. The caller is responsible for closing it.
-//   - Only "image/png", "image/jpeg", and "image/webp" content types are accepted.
+//   - Only png, jpeg and webp images are accepted, the type is sniffed out of the file content.
 func getImageFile(
 	ctx *gin.Context,
 	formImgName string,
@@ -140,14 +141,8 @@ func getImageFile(
 		}
 	}
 
-	allowedTypes := map[string]bool{
-		"image/png":  true,
-		"image/jpeg": true,
-		"image/webp": true,
-	}
-
-	contentType := header.Header.Get("Content-Type")
-	if !allowedTypes[contentType] {
+	if !isAllowedImage(file) {
+		file.Close()
 		return nil, &utils.APIError{
 			Code:    http.StatusBadRequest,
 			Message: "this type of file is not allowed",
@@ -170,7 +165,7 @@ func getImageFile(
 // Behavior:
 //   - Parses the multipart form data from the request.
 //   - Returns nil, nil if no files are provided under the specified form field (i.e., optional).
-//   - Validates each file for size and MIME type ("image/png", "image/jpeg", "image/webp").
+//   - Validates each file for size and type (png, jpeg or webp, sniffed out of the file content).
 //   - Opens each valid image file and returns a slice of ImageUpload structs.
 //   - Returns an APIError if any file fails validation or opening.
 //
@@ -201,12 +196,6 @@ func getImageFiles(
 		return nil, nil
 	}
 
-	allowedTypes := map[string]bool{
-		"image/png":  true,
-		"image/jpeg": true,
-		"image/webp": true,
-	}
-
 	var uploads []ImageUpload
 	for _, header := range files {
 		if header.Size > maxUploadSize {
@@ -216,14 +205,6 @@ func getImageFiles(
 			}
 		}
 
-		contentType := header.Header.Get("Content-Type")
-		if !allowedTypes[contentType] {
-			return nil, &utils.APIError{
-				Code:    http.StatusBadRequest,
-				Message: "one of the files is not an allowed image type",
-			}
-		}
-
 		file, err := header.Open()
 		if err != nil {
 			return nil, &utils.APIError{
@@ -232,6 +213,17 @@ func getImageFiles(
 			}
 		}
 
+		if !isAllowedImage(file) {
+			file.Close()
+			for _, u := range uploads {
+				u.File.Close()
+			}
+			return nil, &utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "one of the files is not an allowed image type",
+			}
+		}
+
 		uploads = append(uploads, ImageUpload{
 			File:   file,
 			Header: header,
@@ -241,6 +233,23 @@ func getImageFiles(
 	return uploads, nil
 }
 
+// isAllowedImage sniffs the image type out of the file's first bytes, the client
+// Content-Type header can claim anything. The file is rewound afterwards.
+func isAllowedImage(file multipart.File) bool {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+
+	_, ok := imageproc.Sniff(head[:n])
+	return ok
+}
+
 func convStrToInt(c *gin.Context, numAsStr string, fieldName string) int {
 	val, err := strconv.Atoi(numAsStr)
 	if err != nil {
@@ -283,80 +292,274 @@ func getRequiredQueryInt(c *gin.Context, queryName string) int {
 	return query
 }
 
-type readSeekCloser struct {
-	*bytes.Reader // embeds Reader, ReaderAt, and Seeker
-}
+// getUserID returns the id of the user the access claims of the request belong to,
+// it fails the request and returns false when there are none.
+func getUserID(c *gin.Context) (int32, bool) {
+	claims := auth.GetAccessClaims(c)
+	if claims == nil {
+		return 0, false
+	}
 
-func newReadSeekCloser(b []byte) multipart.File {
-	return &readSeekCloser{Reader: bytes.NewReader(b)}
-}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return 0, false
+	}
 
-func (r *readSeekCloser) Close() error {
-	return nil // no-op close
+	return int32(userID), true
 }
 
-type ImagePair struct {
-	OriginalURL string
-	LowResURL   string
+// redirectOldSlug permanently redirects an old slug to basePath followed by
+// the current slug, it fails with not found if the slug was never used.
+func (s *Server) redirectOldSlug(
+	c *gin.Context,
+	entity models.SlugEntity,
+	slug string,
+	basePath string,
+) {
+	currentSlug, err := s.DB.SlugRedirect().Resolve(c, s.DB.Pool(), entity, slug)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	c.Redirect(http.StatusMovedPermanently, basePath+url.PathEscape(currentSlug))
 }
 
-func (s *Server) UploadImageWithLowRes(
+// getPaginationFilters reads the pagination queries shared by the listing endpoints:
+// page_size, sort (defaultSort when missing) and either page or cursor, a cursor
+// continues the listing by keyset so page is only required without one.
+// It fails the request and returns false on bad input.
+func (s *Server) getPaginationFilters(
 	c *gin.Context,
-	file multipart.File,
-	header *multipart.FileHeader,
-	targetWidth int,
-) (*ImagePair, *utils.APIError) {
-	// 1. Copy file into buffer
-	var copyBuf bytes.Buffer
-	if _, err := io.Copy(&copyBuf, file); err != nil {
-		return nil, &utils.APIError{
-			Message: "failed to read image",
-			Code:    http.StatusInternalServerError,
+	defaultSort string,
+	sortSafeList []string,
+) (filters.Filters, bool) {
+	f := filters.Filters{
+		Sort:         c.DefaultQuery("sort", defaultSort),
+		SortSafeList: sortSafeList,
+		CursorSecret: s.Env.HashSecret,
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := filters.DecodeCursor(cursor, s.Env.HashSecret)
+		if err != nil {
+			utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, "Invalid cursor"), err)
+			return f, false
+		}
+		f.Cursor = decoded
+	} else {
+		f.Page = getRequiredQueryInt(c, "page")
+		if f.Page == 0 {
+			return f, false
 		}
 	}
 
-	// 2. Decode from copied buffer for resizing
-	img, _, err := image.Decode(bytes.NewReader(copyBuf.Bytes()))
+	f.PageSize = getRequiredQueryInt(c, "page_size")
+	if f.PageSize == 0 {
+		return f, false
+	}
+
+	// Execute the validation checks on the Filters struct and send a response
+	// containing the errors if necessary.
+	v := validator.New()
+	if filters.ValidateFilters(v, f); !v.Valid() {
+		utils.Fail(c, &utils.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "bad filter options",
+			Errors:  v.Errors,
+		}, errors.New("bad filter options"))
+		return f, false
+	}
+
+	return f, true
+}
+
+// getQueryIntList reads a multi-select query, given either as a comma separated list
+// (color_id=1,3) or repeated (color_id=1&color_id=3). It fails the request and
+// returns false if one of the values isn't a positive number.
+func getQueryIntList(c *gin.Context, queryName string) ([]int, bool) {
+	var list []int
+	for _, query := range c.QueryArray(queryName) {
+		for _, part := range strings.Split(query, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			val, err := strconv.Atoi(part)
+			if err != nil || val <= 0 {
+				utils.Fail(
+					c,
+					&utils.APIError{Code: http.StatusBadRequest, Message: "Invalid " + queryName},
+					err,
+				)
+				return nil, false
+			}
+			list = append(list, val)
+		}
+	}
+
+	return list, true
+}
+
+// getOptionalQueryInt reads a numeric query, a missing query is 0.
+// It fails the request and returns false as the second value if the query isn't a number.
+func getOptionalQueryInt(c *gin.Context, queryName string) (int, bool) {
+	query := c.Query(queryName)
+	if query == "" {
+		return 0, true
+	}
+
+	val, err := strconv.Atoi(query)
+	if err != nil {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "Invalid " + queryName},
+			err,
+		)
+		return 0, false
+	}
+
+	return val, true
+}
+
+// getOptionalQueryBool reads a boolean query, a missing query is false.
+// It fails the request and returns false as the second value if the query isn't a boolean.
+func getOptionalQueryBool(c *gin.Context, queryName string) (bool, bool) {
+	query := c.Query(queryName)
+	if query == "" {
+		return false, true
+	}
+
+	val, err := strconv.ParseBool(query)
+	if err != nil {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "Invalid " + queryName},
+			err,
+		)
+		return false, false
+	}
+
+	return val, true
+}
+
+// processImage encodes the image into WebP renditions of the given widths.
+func (s *Server) processImage(data []byte, widths []int) (*imageproc.Result, *utils.APIError) {
+	res, err := imageproc.Process(data, widths, s.Env.ImageWebPQuality)
+	if errors.Is(err, imageproc.ErrImageTooLarge) {
+		return nil, utils.NewAPIError(http.StatusBadRequest, "image dimensions are too large")
+	}
 	if err != nil {
 		return nil, &utils.APIError{
 			Message: "invalid image format",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	return res, nil
+}
+
+// uploadOriginalImage uploads the image as it was sent, its renditions are made out of it
+// by the image worker.
+func (s *Server) uploadOriginalImage(c *gin.Context, file multipart.File) (string, *utils.APIError) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", &utils.APIError{
+			Message: "failed to read image",
 			Code:    http.StatusInternalServerError,
 		}
 	}
 
-	// 3. Resize
-	lowRes := imaging.Resize(img, targetWidth, 0, imaging.Lanczos)
-	var lowBuf bytes.Buffer
-	err = jpeg.Encode(&lowBuf, lowRes, &jpeg.Options{Quality: 80})
+	contentType, ok := imageproc.Sniff(data)
+	if !ok {
+		return "", utils.NewAPIError(http.StatusBadRequest, "this type of file is not allowed")
+	}
+
+	url, err := s.upload(c, data, imageproc.ExtensionOf(contentType), contentType, models.UploadOwnerImage)
 	if err != nil {
-		return nil, &utils.APIError{
-			Message: "failed to encode low-res image",
+		return "", &utils.APIError{
+			Message: "failed to upload image",
 			Code:    http.StatusInternalServerError,
 		}
 	}
 
-	// 4. Upload original
-	originalReader := newReadSeekCloser(copyBuf.Bytes())
-	originalURL, err := s.S3.UploadImage(c, originalReader, header)
+	return url, nil
+}
+
+// avatarWidth is the width the user images are stored at.
+const avatarWidth = 480
+
+// uploadSingleImage encodes the uploaded image as a single WebP no wider than width,
+// it's used for the images that aren't served in several sizes like avatars.
+func (s *Server) uploadSingleImage(
+	c *gin.Context,
+	file multipart.File,
+	width int,
+	owner models.UploadOwner,
+) (string, *utils.APIError) {
+	data, err := io.ReadAll(file)
 	if err != nil {
-		return nil, &utils.APIError{
-			Message: "failed to upload original image",
+		return "", &utils.APIError{
+			Message: "failed to read image",
 			Code:    http.StatusInternalServerError,
 		}
 	}
 
-	// 5. Upload low-res
-	lowHeader := *header
-	lowHeader.Size = int64(lowBuf.Len())
-	lowHeader.Header.Set("Content-Type", "image/jpeg")
-	lowReader := newReadSeekCloser(lowBuf.Bytes())
-	lowResURL, err := s.S3.UploadImage(c, lowReader, &lowHeader)
+	return s.storeSingleImage(c, data, width, owner)
+}
+
+// storeSingleImage encodes the image as a single WebP no wider than width and uploads it.
+func (s *Server) storeSingleImage(
+	c *gin.Context,
+	data []byte,
+	width int,
+	owner models.UploadOwner,
+) (string, *utils.APIError) {
+	res, apiErr := s.processImage(data, []int{width})
+	if apiErr != nil {
+		return "", apiErr
+	}
+
+	url, err := s.upload(
+		c,
+		res.Renditions[0].Data,
+		imageproc.Extension,
+		imageproc.ContentType,
+		owner,
+	)
 	if err != nil {
-		return nil, &utils.APIError{
-			Message: "failed to encode low-res image",
+		return "", &utils.APIError{
+			Message: "failed to upload image",
 			Code:    http.StatusInternalServerError,
 		}
 	}
 
-	return &ImagePair{OriginalURL: originalURL, LowResURL: lowResURL}, nil
+	return url, nil
+}
+
+// upload records the file in the uploads ledger then stores it, so the object is garbage
+// collected if the rows meant to reference it are never committed. A row whose upload
+// failed is deleted by the reconciler like any other unreferenced one.
+func (s *Server) upload(
+	ctx context.Context,
+	data []byte,
+	ext, contentType string,
+	owner models.UploadOwner,
+) (string, error) {
+	url := s.Storage.NewURL(ext)
+
+	err := s.DB.Upload().Create(ctx, s.DB.Pool(), url, owner)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.Storage.Upload(ctx, url, data, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	return url, nil
 }
@@ -0,0 +1,388 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+)
+
+// getProductImageIDs reads the product and image ids from the path,
+// it fails the request and returns false if one of them is invalid.
+func getProductImageIDs(c *gin.Context) (productID, imageID int32, ok bool) {
+	productID = int32(convStrToInt(c, c.Param("id"), "product id"))
+	if productID == 0 {
+		return 0, 0, false
+	}
+
+	imageID = int32(convStrToInt(c, c.Param("imageId"), "image id"))
+	if imageID == 0 {
+		return 0, 0, false
+	}
+
+	return productID, imageID, true
+}
+
+func (s *Server) getProductImages(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+	imageRepo := s.DB.Image()
+
+	_, err := productRepo.Get(c, db, productID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	imgs, err := imageRepo.GetAllOfProduct(c, db, int32(productID), false)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, imgs)
+}
+
+// addProductImages appends the uploaded images to the end of the product gallery,
+// the optional colorId and altText form values apply to all of them.
+// The images are processing until the image worker makes their renditions.
+func (s *Server) addProductImages(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	uploads, apiErr := getImageFiles(c, "images", 4<<20)
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New("failed to get image uploads"))
+		return
+	}
+
+	if len(uploads) == 0 {
+		utils.Fail(c, &utils.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "images are required",
+		}, errors.New("no images found"))
+		return
+	}
+
+	defer func() {
+		for _, u := range uploads {
+			u.File.Close()
+		}
+	}()
+
+	var colorID pgtype.Int4
+	if colorIDStr := c.PostForm("colorId"); colorIDStr != "" {
+		id, err := strconv.Atoi(colorIDStr)
+		if err != nil || id <= 0 {
+			utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, "Invalid color id"), err)
+			return
+		}
+		colorID = pgtype.Int4{Int32: int32(id), Valid: true}
+	}
+
+	altText := strings.TrimSpace(c.PostForm("altText"))
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+	imageRepo := s.DB.Image()
+	imageJobRepo := s.DB.ImageJob()
+
+	_, err := productRepo.Get(c, db, productID)
+	if err != nil {
+		apiErr = utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	var imgs []*models.Image
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for _, img := range imgs {
+			for _, url := range img.URLs() {
+				_ = s.Storage.Delete(c, url)
+			}
+		}
+	}()
+
+	for _, upload := range uploads {
+		originalURL, apiErr := s.uploadOriginalImage(c, upload.File)
+		if apiErr != nil {
+			utils.Fail(c, apiErr, errors.New("couldn't upload image"))
+			return
+		}
+
+		imgs = append(imgs, &models.Image{
+			Original:  pgtype.Text{String: originalURL, Valid: true},
+			AltText:   pgtype.Text{String: altText, Valid: altText != ""},
+			ColorID:   colorID,
+			ProductID: int32(productID),
+		})
+	}
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		for _, img := range imgs {
+			if err := imageRepo.Create(c, tx, img); err != nil {
+				return err
+			}
+			if err := imageJobRepo.Create(c, tx, img.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apiErr = utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	committed = true
+	utils.Created(c, imgs)
+}
+
+type productImageReq struct {
+	AltText *string `json:"altText"`
+
+	// ColorID links the image to the color it shows, 0 unlinks it.
+	ColorID *int32 `json:"colorId"`
+}
+
+func (s *Server) updateProductImage(c *gin.Context) {
+	productID, imageID, ok := getProductImageIDs(c)
+	if !ok {
+		return
+	}
+
+	var req productImageReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	db := s.DB.Pool()
+	imageRepo := s.DB.Image()
+
+	img, err := imageRepo.Get(c, db, productID, imageID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if req.AltText != nil {
+		altText := strings.TrimSpace(*req.AltText)
+		img.AltText = pgtype.Text{String: altText, Valid: altText != ""}
+	}
+
+	if req.ColorID != nil {
+		img.ColorID = pgtype.Int4{Int32: *req.ColorID, Valid: *req.ColorID != 0}
+	}
+
+	err = imageRepo.Update(c, db, &img)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, img)
+}
+
+type reorderImagesReq struct {
+	ImageIDs []int32 `json:"imageIds" binding:"required"`
+}
+
+// reorderProductImages orders the gallery as given, every image of the product
+// has to be listed exactly once.
+func (s *Server) reorderProductImages(c *gin.Context) {
+	productID := int32(convStrToInt(c, c.Param("id"), "product id"))
+	if productID == 0 {
+		return
+	}
+
+	var req reorderImagesReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	imageRepo := s.DB.Image()
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		imgs, err := imageRepo.GetAllOfProduct(c, tx, productID, false)
+		if err != nil {
+			return err
+		}
+
+		if !sameImageIDs(imgs, req.ImageIDs) {
+			return errImagesOrderMismatch
+		}
+
+		return imageRepo.Reorder(c, tx, productID, req.ImageIDs)
+	})
+	if errors.Is(err, errImagesOrderMismatch) {
+		utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, err.Error()), err)
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "images reordered successfully")
+}
+
+var errImagesOrderMismatch = errors.New("imageIds must list every image of the product once")
+
+// sameImageIDs reports whether ids holds exactly the ids of the given images.
+func sameImageIDs(imgs []models.Image, ids []int32) bool {
+	if len(imgs) != len(ids) {
+		return false
+	}
+
+	remaining := make(map[int32]bool, len(imgs))
+	for _, img := range imgs {
+		remaining[img.ID] = true
+	}
+
+	for _, id := range ids {
+		if !remaining[id] {
+			return false
+		}
+		delete(remaining, id)
+	}
+
+	return true
+}
+
+// retryProductImage queues a failed image for processing again.
+func (s *Server) retryProductImage(c *gin.Context) {
+	productID, imageID, ok := getProductImageIDs(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	imageJobRepo := s.DB.ImageJob()
+
+	err := imageJobRepo.Retry(c, db, productID, imageID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "image queued for processing")
+}
+
+func (s *Server) setProductThumbnail(c *gin.Context) {
+	productID, imageID, ok := getProductImageIDs(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+
+	replaced, err := productRepo.SetThumbnail(c, db, productID, imageID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if replaced != "" {
+		_ = s.Storage.Delete(context.WithoutCancel(c), replaced)
+	}
+
+	utils.Success(c, "thumbnail updated successfully")
+}
+
+// deleteProductImage removes the image from the gallery along with all its renditions,
+// the image used as the thumbnail can't be deleted.
+// The objects are deleted once the image is, but the original an order line still shows.
+func (s *Server) deleteProductImage(c *gin.Context) {
+	productID, imageID, ok := getProductImageIDs(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+	imageRepo := s.DB.Image()
+
+	p, err := productRepo.Get(c, db, int(productID))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	img, err := imageRepo.Get(c, db, productID, imageID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if img.Image == p.Thumbnail {
+		utils.Fail(
+			c,
+			utils.NewAPIError(
+				http.StatusConflict,
+				"can not delete the product thumbnail, set another image as the thumbnail first",
+			),
+			nil,
+		)
+		return
+	}
+
+	var ordered bool
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		err = imageRepo.Delete(c, tx, productID, imageID)
+		if err != nil {
+			return err
+		}
+
+		ordered, err = s.DB.OrderDetails().ShowsThumbnail(c, tx, img.Image)
+		return err
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	// the row is gone, the objects are deleted even if the client goes away meanwhile.
+	ctx := context.WithoutCancel(c)
+	for _, url := range img.URLs() {
+		if ordered && url == img.Image {
+			continue
+		}
+		_ = s.Storage.Delete(ctx, url)
+	}
+
+	utils.NoContent(c)
+}
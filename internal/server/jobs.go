@@ -0,0 +1,617 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/imageproc"
+)
+
+// publishScheduledInterval is how often the scheduled products are checked,
+// a product goes live at most this long after its publish time.
+const publishScheduledInterval = time.Minute
+
+// startJobs runs the background jobs of the server until the context is cancelled.
+func (s *Server) startJobs(ctx context.Context) {
+	go runPeriodically(ctx, publishScheduledInterval, "publish scheduled products", s.publishScheduledProducts)
+	go runPeriodically(ctx, purgeDeletedInterval, "purge deleted rows", s.purgeDeleted)
+	go runPeriodically(ctx, reconcileUploadsInterval, "reconcile uploads", s.reconcileUploads)
+	go runPeriodically(ctx, refreshRecommendationsInterval, "refresh recommendations", s.refreshRecommendations)
+	go runPeriodically(ctx, refreshTrendingInterval, "refresh trending products", s.refreshTrending)
+	go runPeriodically(ctx, purgeProductEventsInterval, "purge product events", s.purgeProductEvents)
+	go runPeriodically(ctx, backInStockInterval, "send back in stock notifications", s.sendBackInStockNotifications)
+	go s.writeProductEvents(ctx)
+
+	for range imageWorkers {
+		go runPeriodically(ctx, imageWorkerInterval, "process images", s.processImageJobs)
+	}
+}
+
+// runPeriodically runs the job right away and then every interval, until the context is cancelled.
+// A failed run is logged and the job is retried on the next tick.
+func runPeriodically(
+	ctx context.Context,
+	interval time.Duration,
+	name string,
+	job func(ctx context.Context) error,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Job Error: %s | Error: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) publishScheduledProducts(ctx context.Context) error {
+	published, err := s.DB.Product().PublishScheduled(ctx, s.DB.Pool())
+	if err != nil {
+		return err
+	}
+
+	if published > 0 {
+		log.Printf("published %d scheduled products", published)
+	}
+
+	return nil
+}
+
+// purgeDeletedInterval is how often the soft deleted rows past their retention are purged.
+const purgeDeletedInterval = time.Hour
+
+// purgeDeleted permanently deletes the rows soft deleted longer than the retention period ago,
+// then deletes the images of the purged products from the storage, but the thumbnails the order
+// lines still show. An image that fails to be deleted is only logged, the rows referencing it
+// are already gone.
+func (s *Server) purgeDeleted(ctx context.Context) error {
+	before := time.Now().AddDate(0, 0, -s.Env.SoftDeleteRetentionDays)
+
+	var (
+		imageURLs []string
+		purged    int64
+	)
+	err := s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
+		var (
+			count int64
+			err   error
+		)
+
+		// the variants and products go first, since the categories,
+		// colors and sizes they use can't be purged before them.
+		count, err = s.DB.ProductVariant().PurgeDeleted(ctx, tx, before)
+		if err != nil {
+			return err
+		}
+		purged += count
+
+		imageURLs, err = s.DB.Product().PurgeDeleted(ctx, tx, before)
+		if err != nil {
+			return err
+		}
+
+		count, err = s.DB.Category().PurgeDeleted(ctx, tx, before)
+		if err != nil {
+			return err
+		}
+		purged += count
+
+		count, err = s.DB.Color().PurgeDeleted(ctx, tx, before)
+		if err != nil {
+			return err
+		}
+		purged += count
+
+		count, err = s.DB.Size().PurgeDeleted(ctx, tx, before)
+		if err != nil {
+			return err
+		}
+		purged += count
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, url := range imageURLs {
+		if err = s.Storage.Delete(ctx, url); err != nil {
+			log.Printf("couldn't delete purged image %s: %v", url, err)
+		}
+	}
+
+	if purged > 0 || len(imageURLs) > 0 {
+		log.Printf(
+			"purged the deleted products, %d other deleted rows and %d product images",
+			purged,
+			len(imageURLs),
+		)
+	}
+
+	return nil
+}
+
+const (
+	// imageWorkers is how many image jobs are processed at the same time by a server.
+	imageWorkers = 2
+
+	// imageWorkerInterval is how often an idle worker checks for new image jobs.
+	imageWorkerInterval = 5 * time.Second
+
+	// imageJobLock is how long a claimed job is left to its worker before it's claimed again.
+	imageJobLock = 5 * time.Minute
+
+	// imageJobMaxAttempts is how many times an image is processed before it's marked failed.
+	imageJobMaxAttempts = 5
+)
+
+// processImageJobs processes the due image jobs one after the other until there are none left.
+func (s *Server) processImageJobs(ctx context.Context) error {
+	jobRepo := s.DB.ImageJob()
+
+	// a worker that crashed on the last attempt never reports back.
+	exhausted, err := jobRepo.FailExhausted(ctx, s.DB.Pool(), imageJobMaxAttempts)
+	if err != nil {
+		return err
+	}
+	if exhausted > 0 {
+		log.Printf("%d images failed after %d attempts", exhausted, imageJobMaxAttempts)
+	}
+
+	for ctx.Err() == nil {
+		job, err := jobRepo.Claim(ctx, s.DB.Pool(), imageJobLock, imageJobMaxAttempts)
+		if database.IsDBNotFoundErr(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		processErr := s.processImageJob(ctx, job)
+		if processErr == nil {
+			continue
+		}
+
+		failed, err := jobRepo.Fail(ctx, s.DB.Pool(), job.ID, processErr.Error(), imageJobMaxAttempts)
+		if err != nil {
+			return err
+		}
+		if failed {
+			log.Printf("image %d failed after %d attempts: %v", job.ImageID, job.Attempts, processErr)
+		}
+	}
+
+	return nil
+}
+
+// processImageJob makes the renditions of the job's image out of its original,
+// then marks the image ready and deletes the original. An imported image is fetched
+// from its source url and stored as its original first.
+func (s *Server) processImageJob(ctx context.Context, job database.ImageJob) error {
+	var (
+		data []byte
+		err  error
+	)
+	if job.Original == "" {
+		data, err = s.storeImageSource(ctx, &job)
+		// the image was deleted while it was fetched, its job went with it.
+		if database.IsDBNotFoundErr(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err = s.Storage.Download(ctx, job.Original)
+		if err != nil {
+			return fmt.Errorf("download original: %w", err)
+		}
+	}
+
+	res, err := imageproc.Process(data, s.Env.ImageWidths, s.Env.ImageWebPQuality)
+	if err != nil {
+		return fmt.Errorf("process original: %w", err)
+	}
+
+	img := models.Image{
+		ID:            job.ImageID,
+		Blurhash:      pgtype.Text{String: res.Placeholder.Blurhash, Valid: true},
+		DominantColor: pgtype.Text{String: res.Placeholder.DominantColor, Valid: true},
+	}
+	deleteRenditions := func() {
+		for _, r := range img.Srcset {
+			_ = s.Storage.Delete(ctx, r.URL)
+		}
+	}
+
+	for _, r := range res.Renditions {
+		url, err := s.upload(ctx, r.Data, imageproc.Extension, imageproc.ContentType, models.UploadOwnerImage)
+		if err != nil {
+			deleteRenditions()
+			return fmt.Errorf("upload %dpx rendition: %w", r.Width, err)
+		}
+
+		img.Srcset = append(img.Srcset, models.ImageRendition{
+			URL:    url,
+			Width:  int32(r.Width),
+			Height: int32(r.Height),
+		})
+	}
+	img.LowResImage = img.Srcset[0].URL
+	img.Image = img.Srcset[len(img.Srcset)-1].URL
+
+	err = s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
+		if err := s.DB.Image().CompleteProcessing(ctx, tx, &img); err != nil {
+			return err
+		}
+		return s.DB.ImageJob().Delete(ctx, tx, job.ID)
+	})
+	// the image was deleted while it was processed, its job went with it.
+	if database.IsDBNotFoundErr(err) {
+		deleteRenditions()
+		_ = s.Storage.Delete(ctx, job.Original)
+		return nil
+	}
+	if err != nil {
+		deleteRenditions()
+		return fmt.Errorf("complete processing: %w", err)
+	}
+
+	if err = s.Storage.Delete(ctx, job.Original); err != nil {
+		log.Printf("couldn't delete the original of image %d: %v", job.ImageID, err)
+	}
+
+	return nil
+}
+
+// storeImageSource fetches an imported image from its source url and stores it as the
+// image original, so the later attempts don't fetch it again.
+// Returns: the fetched image.
+func (s *Server) storeImageSource(ctx context.Context, job *database.ImageJob) ([]byte, error) {
+	data, err := fetchImportImage(ctx, job.SourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch source: %w", err)
+	}
+
+	contentType, _ := imageproc.Sniff(data)
+	originalURL, err := s.upload(
+		ctx,
+		data,
+		imageproc.ExtensionOf(contentType),
+		contentType,
+		models.UploadOwnerImage,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("upload original: %w", err)
+	}
+
+	err = s.DB.Image().SetOriginal(ctx, s.DB.Pool(), job.ImageID, originalURL)
+	if err != nil {
+		_ = s.Storage.Delete(ctx, originalURL)
+		return nil, fmt.Errorf("set original: %w", err)
+	}
+
+	job.Original = originalURL
+	return data, nil
+}
+
+const (
+	// reconcileUploadsInterval is how often the uploads ledger is reconciled with the storage.
+	reconcileUploadsInterval = time.Hour
+
+	// uploadsBatchSize is how many uploads are handled at a time.
+	uploadsBatchSize = 500
+
+	// uploadCheckInterval is how long a referenced object isn't checked again after it's found.
+	uploadCheckInterval = 24 * time.Hour
+
+	// maxUploadChecksPerRun bounds the storage requests made by a single run.
+	maxUploadChecksPerRun = 2000
+)
+
+// reconcileUploads deletes the objects no row references past the grace period,
+// then checks that the referenced objects still exist in the storage.
+func (s *Server) reconcileUploads(ctx context.Context) error {
+	if err := s.deleteOrphanUploads(ctx); err != nil {
+		return err
+	}
+
+	return s.checkReferencedUploads(ctx)
+}
+
+// deleteOrphanUploads deletes the unreferenced objects along with their ledger entries.
+// An object that fails to be deleted keeps its entry, so it's tried again on the next run.
+func (s *Server) deleteOrphanUploads(ctx context.Context) error {
+	uploadRepo := s.DB.Upload()
+	grace := time.Duration(s.Env.OrphanUploadGraceHours) * time.Hour
+
+	var deleted int
+	for ctx.Err() == nil {
+		// the grace period is taken from the start of every batch, so the uploads of
+		// the rows being committed right now are never picked.
+		orphans, err := uploadRepo.GetUnreferenced(
+			ctx,
+			s.DB.Pool(),
+			time.Now().Add(-grace),
+			uploadsBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int32, 0, len(orphans))
+		for _, u := range orphans {
+			if err = s.Storage.Delete(ctx, u.URL); err != nil {
+				log.Printf("couldn't delete orphan upload %s: %v", u.URL, err)
+				continue
+			}
+			ids = append(ids, u.ID)
+		}
+
+		if len(ids) > 0 {
+			if err = uploadRepo.DeleteMany(ctx, s.DB.Pool(), ids); err != nil {
+				return err
+			}
+			deleted += len(ids)
+		}
+
+		// the failed ones would be picked again, they wait for the next run.
+		if len(orphans) < uploadsBatchSize || len(ids) < len(orphans) {
+			break
+		}
+	}
+
+	if deleted > 0 {
+		log.Printf("deleted %d orphan uploads", deleted)
+	}
+
+	return nil
+}
+
+// checkReferencedUploads checks the referenced objects that weren't checked for a while
+// and reports the rows whose objects are missing.
+func (s *Server) checkReferencedUploads(ctx context.Context) error {
+	uploadRepo := s.DB.Upload()
+
+	for checked := 0; checked < maxUploadChecksPerRun && ctx.Err() == nil; {
+		uploads, err := uploadRepo.GetDueForCheck(
+			ctx,
+			s.DB.Pool(),
+			time.Now().Add(-uploadCheckInterval),
+			uploadsBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		for i := range uploads {
+			u := &uploads[i]
+			found, err := s.Storage.Exists(ctx, u.URL)
+			if err != nil {
+				// the row keeps its state and waits for the next check,
+				// so an object that can't be checked doesn't hold the others back.
+				log.Printf("couldn't check upload %s: %v", u.URL, err)
+				found = !u.MissingSince.Valid
+			} else if !found && !u.MissingSince.Valid {
+				log.Printf("the object of %s %d is missing: %s", u.OwnerType, u.OwnerID.Int32, u.URL)
+			}
+
+			if err = uploadRepo.MarkChecked(ctx, s.DB.Pool(), u, found); err != nil {
+				return err
+			}
+		}
+
+		checked += len(uploads)
+		if len(uploads) < uploadsBatchSize {
+			break
+		}
+	}
+
+	return nil
+}
+
+const (
+	// refreshRecommendationsInterval is how often the product recommendations are recomputed,
+	// the orders and wishlists of the meantime only show up after the next refresh.
+	refreshRecommendationsInterval = time.Hour
+
+	// recommendationsPerProduct is how many recommendations are kept per product.
+	recommendationsPerProduct = 30
+)
+
+// refreshRecommendations recomputes the recommendations of every product in a transaction,
+// so the endpoint keeps serving the previous ones until the new ones are ready.
+func (s *Server) refreshRecommendations(ctx context.Context) error {
+	var count int64
+	err := s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
+		var err error
+		count, err = s.DB.Recommendation().Refresh(ctx, tx, recommendationsPerProduct)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		log.Printf("refreshed %d product recommendations", count)
+	}
+	return nil
+}
+
+// refreshTrendingInterval is how often the trending scores are recomputed,
+// the events and orders of the meantime only show up after the next refresh.
+const refreshTrendingInterval = 15 * time.Minute
+
+// refreshTrending recomputes the daily trending scores of the longest trending window
+// in a transaction, so the endpoint keeps serving the previous ones until the new ones are ready.
+func (s *Server) refreshTrending(ctx context.Context) error {
+	since := time.Now().AddDate(0, 0, -maxTrendingDays)
+
+	var count int64
+	err := s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
+		var err error
+		count, err = s.DB.ProductEvent().RefreshTrending(ctx, tx, since)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		log.Printf("refreshed %d trending scores", count)
+	}
+	return nil
+}
+
+const (
+	// productEventsBuffer is how many product events wait to be written at most,
+	// the ones made while it's full are dropped rather than slowing the requests down.
+	productEventsBuffer = 10000
+
+	// productEventsBatchSize is how many product events are written at a time.
+	productEventsBatchSize = 500
+
+	// productEventsFlushInterval is how long a product event waits at most before it's written.
+	productEventsFlushInterval = 5 * time.Second
+
+	// purgeProductEventsInterval is how often the product events past their retention are purged.
+	purgeProductEventsInterval = 24 * time.Hour
+
+	// productEventsRetention is how long the product events are kept,
+	// it has to outlast the trending window and the recently viewed history.
+	productEventsRetention = 90 * 24 * time.Hour
+)
+
+// writeProductEvents writes the buffered product events in batches, whenever a batch is full
+// or the flush interval passed. The events still buffered are written before it returns.
+func (s *Server) writeProductEvents(ctx context.Context) {
+	ticker := time.NewTicker(productEventsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.ProductEvent, 0, productEventsBatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.DB.ProductEvent().CreateMany(ctx, s.DB.Pool(), batch); err != nil {
+			log.Printf("Job Error: write product events | dropped %d events | Error: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e := <-s.events:
+			batch = append(batch, e)
+			if len(batch) == productEventsBatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			// the jobs context is already cancelled, the last batch gets a little time of its own.
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for {
+				select {
+				case e := <-s.events:
+					batch = append(batch, e)
+					if len(batch) == productEventsBatchSize {
+						flush(shutdownCtx)
+					}
+				default:
+					flush(shutdownCtx)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) purgeProductEvents(ctx context.Context) error {
+	purged, err := s.DB.ProductEvent().PurgeBefore(ctx, s.DB.Pool(), time.Now().Add(-productEventsRetention))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		log.Printf("purged %d product events", purged)
+	}
+
+	return nil
+}
+
+const (
+	// backInStockInterval is how often the queued back in stock notifications are sent.
+	backInStockInterval = time.Minute
+
+	// backInStockBatchSize is how many notifications are sent in one transaction.
+	backInStockBatchSize = 500
+
+	// notifiedSubscriptionsRetention is how long the notified subscriptions are kept.
+	notifiedSubscriptionsRetention = 30 * 24 * time.Hour
+)
+
+// sendBackInStockNotifications notifies the subscribers queued by a restock, in batches.
+// The subscriptions of the variants sold out again before being notified go back to waiting
+// for the next restock, and the ended subscriptions are purged.
+func (s *Server) sendBackInStockNotifications(ctx context.Context) error {
+	subRepo := s.DB.StockSubscription()
+
+	if _, err := subRepo.ReleaseSoldOut(ctx, s.DB.Pool()); err != nil {
+		return err
+	}
+
+	var sent int
+	for {
+		var claimed int
+		err := s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
+			subs, err := subRepo.ClaimQueued(ctx, tx, backInStockBatchSize)
+			if err != nil {
+				return err
+			}
+			claimed = len(subs)
+
+			notifications := make([]models.Notification, 0, len(subs))
+			for _, sub := range subs {
+				notifications = append(notifications, models.Notification{
+					Type:      models.NotificationBackInStock,
+					Message:   "A product you asked about is back in stock",
+					Data:      map[string]any{"variantId": sub.VariantID},
+					UserID:    sub.UserID,
+					ProductID: pgtype.Int4{Int32: sub.ProductID, Valid: true},
+				})
+			}
+
+			return s.DB.Notification().CreateMany(ctx, tx, notifications)
+		})
+		if err != nil {
+			return err
+		}
+
+		sent += claimed
+		if claimed < backInStockBatchSize {
+			break
+		}
+	}
+
+	if sent > 0 {
+		log.Printf("sent %d back in stock notifications", sent)
+	}
+
+	_, err := subRepo.PurgeEnded(ctx, s.DB.Pool(), time.Now().Add(-notifiedSubscriptionsRetention))
+	return err
+}
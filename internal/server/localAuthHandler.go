@@ -63,9 +63,9 @@ func (s *Server) register(ctx *gin.Context) {
 		defer imgUpload.File.Close()
 
 		var uploadedURL string
-		uploadedURL, err = s.S3.UploadImage(ctx, imgUpload.File, imgUpload.Header)
-		if err != nil {
-			utils.Fail(ctx, utils.ErrInternal, err)
+		uploadedURL, apiErr = s.uploadSingleImage(ctx, imgUpload.File, avatarWidth, models.UploadOwnerUser)
+		if apiErr != nil {
+			utils.Fail(ctx, apiErr, errors.New(apiErr.Message))
 			return
 		}
 		imgURL.String = uploadedURL
@@ -130,7 +130,7 @@ func (s *Server) register(ctx *gin.Context) {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(ctx, apiErr, err)
 		if imgURL.String != "" {
-			_ = s.S3.DeleteImageByURL(ctx, imgURL.String)
+			_ = s.Storage.Delete(ctx, imgURL.String)
 		}
 		return
 	}
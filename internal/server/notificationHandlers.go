@@ -0,0 +1,94 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+)
+
+type notificationsRes struct {
+	Metadata      filters.Metadata      `json:"metadata"`
+	Unread        int                   `json:"unread"`
+	Notifications []models.Notification `json:"notifications"`
+}
+
+// getNotifications serves a page of the user notifications, the latest first,
+// along with how many of them weren't read.
+func (s *Server) getNotifications(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	f, ok := s.getPaginationFilters(c, "-created_at", []string{"created_at", "-created_at"})
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	notificationRepo := s.DB.Notification()
+
+	notifications, metadata, err := notificationRepo.GetAllOfUser(c, db, userID, f)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	unread, err := notificationRepo.CountUnread(c, db, userID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, notificationsRes{
+		Metadata:      metadata,
+		Unread:        unread,
+		Notifications: notifications,
+	})
+}
+
+func (s *Server) readNotification(c *gin.Context) {
+	notificationID := convStrToInt(c, c.Param("id"), "notification id")
+	if notificationID == 0 {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	notificationRepo := s.DB.Notification()
+
+	err := notificationRepo.MarkRead(c, db, int32(notificationID), userID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "notification marked read")
+}
+
+func (s *Server) readAllNotifications(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	notificationRepo := s.DB.Notification()
+
+	err := notificationRepo.MarkAllRead(c, db, userID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "notifications marked read")
+}
@@ -1,15 +1,18 @@
 package server
 
 import (
-	"fmt"
+	"errors"
+	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/auth"
 	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
 )
 
 type orderReq struct {
@@ -44,13 +47,12 @@ func (s *Server) createOrder(ctx *gin.Context) {
 	orderRepo := s.DB.Order()
 	orderDetailsRepo := s.DB.OrderDetails()
 	cartRepo := s.DB.Cart()
-	cartItemRepo := s.DB.CartItem()
 
 	err = s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
 		var (
-			orderID   int32
-			cartID    int32
-			cartItems []database.GetCartItems
+			orderID int32
+			cartID  int32
+			lines   int64
 		)
 		// create the order
 		orderID, err = orderRepo.Create(
@@ -71,31 +73,21 @@ func (s *Server) createOrder(ctx *gin.Context) {
 			return err
 		}
 
-		// get cart items
+		// turn the cart items into the order lines
 		cartID, err = cartRepo.GetIDByUserID(ctx, tx, int32(userID))
 		if err != nil {
 			return err
 		}
-		cartItems, err = cartItemRepo.GetAll(ctx, tx, cartID)
+		lines, err = orderDetailsRepo.CreateFromCart(ctx, tx, orderID, cartID)
 		if err != nil {
 			return err
 		}
+		if lines == 0 {
+			return errEmptyCart
+		}
 
-		for _, item := range cartItems {
-			fmt.Println(item.VariantID)
-			err = orderDetailsRepo.Create(
-				ctx,
-				tx,
-				&models.OrderDetails{
-					Quantity:   item.Quantity,
-					ProductID:  item.VariantID,
-					TotalPrice: item.TotalPrice,
-					OrderID:    orderID,
-				},
-			)
-			if err != nil {
-				return err
-			}
+		if err = s.takeOrderStock(ctx, tx, orderID); err != nil {
+			return err
 		}
 
 		err = cartRepo.Delete(ctx, tx, cartID)
@@ -105,6 +97,14 @@ func (s *Server) createOrder(ctx *gin.Context) {
 
 		return nil
 	})
+	if errors.Is(err, errEmptyCart) {
+		utils.Fail(ctx, utils.NewAPIError(http.StatusBadRequest, "the cart is empty"), err)
+		return
+	}
+	if database.IsDBConstraintErr(err, database.VariantQuantityCheck) {
+		utils.Fail(ctx, errOutOfStock, err)
+		return
+	}
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(ctx, apiErr, err)
@@ -112,3 +112,244 @@ func (s *Server) createOrder(ctx *gin.Context) {
 	}
 	utils.Success(ctx, nil)
 }
+
+var errEmptyCart = errors.New("can't place an order out of an empty cart")
+
+// takeOrderStock records the sales of the order lines, the order fails
+// when a variant doesn't have enough stock left.
+func (s *Server) takeOrderStock(ctx *gin.Context, db database.Querier, orderID int32) error {
+	lines, err := s.DB.OrderDetails().GetAllOfOrder(ctx, db, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if !line.ProductID.Valid {
+			continue
+		}
+
+		err = s.moveStock(ctx, db, &models.StockMovement{
+			Change:    -line.Quantity,
+			Reason:    models.StockSale,
+			VariantID: line.ProductID.Int32,
+			OrderID:   pgtype.Int4{Int32: orderID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returnOrderStock puts back the stock the cancelled order took.
+func (s *Server) returnOrderStock(ctx *gin.Context, db database.Querier, orderID int32) error {
+	sold, err := s.DB.Stock().GetSoldOfOrder(ctx, db, orderID)
+	if err != nil {
+		return err
+	}
+
+	for variantID, quantity := range sold {
+		err = s.moveStock(ctx, db, &models.StockMovement{
+			Change:    quantity,
+			Reason:    models.StockCancellationReturn,
+			VariantID: variantID,
+			OrderID:   pgtype.Int4{Int32: orderID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type orderRes struct {
+	Order models.Order          `json:"order"`
+	Lines []models.OrderDetails `json:"lines"`
+}
+
+// getOrder serves an order of the user along with its lines,
+// the lines are the products as they were when the order was placed.
+func (s *Server) getOrder(ctx *gin.Context) {
+	orderID := convStrToInt(ctx, ctx.Param("id"), "order id")
+	if orderID == 0 {
+		return
+	}
+
+	claims := auth.GetAccessClaims(ctx)
+	if claims == nil {
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(ctx, utils.ErrInternal, err)
+		return
+	}
+
+	db := s.DB.Pool()
+	orderRepo := s.DB.Order()
+	orderDetailsRepo := s.DB.OrderDetails()
+
+	order, err := orderRepo.Get(ctx, db, int32(orderID))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	// other users orders are reported as not found, so their ids can't be probed.
+	if order.UserID != int32(userID) {
+		utils.Fail(ctx, utils.ErrNotFound, errors.New("order of another user"))
+		return
+	}
+
+	lines, err := orderDetailsRepo.GetAllOfOrder(ctx, db, order.ID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, orderRes{
+		Order: *order,
+		Lines: lines,
+	})
+}
+
+var errCancelledOrder = errors.New("can't change the status of a cancelled order")
+
+type updateOrderStatusReq struct {
+	Status models.OrderStatus `json:"status" binding:"required"`
+}
+
+func (s *Server) updateOrderStatus(ctx *gin.Context) {
+	orderID := convStrToInt(ctx, ctx.Param("id"), "order id")
+	if orderID == 0 {
+		return
+	}
+
+	var req updateOrderStatusReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(ctx, utils.ErrBadRequest, err)
+		return
+	}
+
+	if !req.Status.IsValid() {
+		utils.Fail(
+			ctx,
+			utils.NewAPIError(http.StatusBadRequest, "there's no such order status"),
+			nil,
+		)
+		return
+	}
+
+	orderRepo := s.DB.Order()
+
+	err = s.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
+		previous, err := orderRepo.UpdateStatus(ctx, tx, int32(orderID), req.Status)
+		if err != nil {
+			return err
+		}
+
+		// the stock of a cancelled order is already back, the order can't take it again.
+		if previous == models.Cancelled && req.Status != models.Cancelled {
+			return errCancelledOrder
+		}
+		if previous != models.Cancelled && req.Status == models.Cancelled {
+			return s.returnOrderStock(ctx, tx, int32(orderID))
+		}
+
+		return nil
+	})
+	if errors.Is(err, errCancelledOrder) {
+		utils.Fail(
+			ctx,
+			utils.NewAPIError(http.StatusConflict, "a cancelled order can't change status"),
+			err,
+		)
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, "order status updated successfully")
+}
+
+// ordersSortSafeList holds the sort values supported by the orders listings.
+var ordersSortSafeList = []string{"created_at", "total_price", "-created_at", "-total_price"}
+
+type ordersRes struct {
+	Metadata filters.Metadata `json:"metadata"`
+	Orders   []models.Order   `json:"orders"`
+}
+
+func (s *Server) getUserOrders(ctx *gin.Context) {
+	claims := auth.GetAccessClaims(ctx)
+	if claims == nil {
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(ctx, utils.ErrInternal, err)
+		return
+	}
+
+	f, ok := s.getPaginationFilters(ctx, "-created_at", ordersSortSafeList)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	orderRepo := s.DB.Order()
+
+	orders, metadata, err := orderRepo.GetAllOfUser(ctx, db, int32(userID), f)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	if len(orders) == 0 {
+		utils.NoContent(ctx)
+		return
+	}
+
+	utils.Success(ctx, ordersRes{
+		Metadata: metadata,
+		Orders:   orders,
+	})
+}
+
+func (s *Server) getAllOrders(ctx *gin.Context) {
+	f, ok := s.getPaginationFilters(ctx, "-created_at", ordersSortSafeList)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	orderRepo := s.DB.Order()
+
+	orders, metadata, err := orderRepo.GetAll(ctx, db, f)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	if len(orders) == 0 {
+		utils.NoContent(ctx)
+		return
+	}
+
+	utils.Success(ctx, ordersRes{
+		Metadata: metadata,
+		Orders:   orders,
+	})
+}
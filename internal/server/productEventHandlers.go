@@ -0,0 +1,200 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+)
+
+// anonymousIDHeader carries the id the server gave a guest, signed so a client can't
+// pass itself off as another guest. It tells its views apart until it logs in.
+const anonymousIDHeader = "X-Anonymous-ID"
+
+const (
+	// defaultRecentlyViewed is how many products are returned without ?limit=.
+	defaultRecentlyViewed = 20
+
+	// defaultTrending is how many products are returned without ?limit=.
+	defaultTrending = 12
+
+	// maxProductsLimit is the largest ?limit= of the recently viewed and trending products.
+	maxProductsLimit = 50
+
+	// defaultTrendingDays is the window the popularity is measured over without ?days=.
+	defaultTrendingDays = 7
+
+	// maxTrendingDays is the largest ?days= of the trending products.
+	maxTrendingDays = 30
+)
+
+// anonymousID returns the anonymous id of the request's header, or "" when it has none
+// or its signature doesn't match.
+func (s *Server) anonymousID(c *gin.Context) string {
+	id, signature, found := strings.Cut(c.GetHeader(anonymousIDHeader), ".")
+	if !found || id == "" || !utils.VerifyToken(signature, id, s.Env.HashSecret) {
+		return ""
+	}
+	return id
+}
+
+// ensureAnonymousID returns the anonymous id of the request, a guest without one is
+// given a new one in the response header, which its client sends along from then on.
+func (s *Server) ensureAnonymousID(c *gin.Context) (string, error) {
+	if id := s.anonymousID(c); id != "" {
+		return id, nil
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(idBytes)
+
+	signature, err := utils.HashToken(id, s.Env.HashSecret)
+	if err != nil {
+		return "", err
+	}
+
+	c.Header(anonymousIDHeader, id+"."+signature)
+	return id, nil
+}
+
+// recordProductEvent queues the event of the user of the request, or else of its guest,
+// to be written by writeProductEvents. It never blocks, the event is dropped when the
+// queue is full or the guest couldn't be given an anonymous id.
+func (s *Server) recordProductEvent(c *gin.Context, event models.ProductEventType, productID int32) {
+	e := models.ProductEvent{
+		Event:     event,
+		ProductID: productID,
+		CreatedAt: time.Now(),
+	}
+
+	claims, _ := c.Get("claims")
+	accessClaims, _ := claims.(*auth.AccessClaims)
+	if accessClaims == nil {
+		accessClaims = auth.GetOptionalAccessClaims(c, s.Env.AccessTokenSecret)
+	}
+
+	if accessClaims != nil && !accessClaims.IsAPIKey() {
+		userID, err := strconv.Atoi(accessClaims.Subject)
+		if err == nil {
+			e.UserID = pgtype.Int4{Int32: int32(userID), Valid: true}
+		}
+	}
+	if !e.UserID.Valid {
+		id, err := s.ensureAnonymousID(c)
+		if err != nil {
+			return
+		}
+		e.AnonymousID = pgtype.Text{String: id, Valid: true}
+	}
+
+	select {
+	case s.events <- e:
+	default:
+	}
+}
+
+// getProductsLimit returns the ?limit= of a products list, between 1 and maxProductsLimit.
+func getProductsLimit(c *gin.Context, defaultLimit int) (int, bool) {
+	limit, ok := getOptionalQueryInt(c, "limit")
+	if !ok {
+		return 0, false
+	}
+	if limit == 0 {
+		return defaultLimit, true
+	}
+	if limit < 0 || limit > maxProductsLimit {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "limit must be between 1 and 50"),
+			errors.New("invalid products limit"),
+		)
+		return 0, false
+	}
+	return limit, true
+}
+
+// getRecentlyViewed lists the published products the user viewed, the last viewed first.
+// The views made as a guest are included when its anonymous id header is sent along.
+func (s *Server) getRecentlyViewed(c *gin.Context) {
+	limit, ok := getProductsLimit(c, defaultRecentlyViewed)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	productEventRepo := s.DB.ProductEvent()
+
+	products, err := productEventRepo.GetRecentlyViewed(c, db, int32(userID), s.anonymousID(c), limit)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(products) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, products)
+}
+
+// getTrendingProducts lists the published products in stock that were the most
+// viewed, added to carts and ordered over the last ?days=.
+func (s *Server) getTrendingProducts(c *gin.Context) {
+	limit, ok := getProductsLimit(c, defaultTrending)
+	if !ok {
+		return
+	}
+
+	days, ok := getOptionalQueryInt(c, "days")
+	if !ok {
+		return
+	}
+	if days == 0 {
+		days = defaultTrendingDays
+	}
+	if days < 0 || days > maxTrendingDays {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "days must be between 1 and 30"),
+			errors.New("invalid trending days"),
+		)
+		return
+	}
+
+	db := s.DB.Pool()
+	productEventRepo := s.DB.ProductEvent()
+
+	since := time.Now().AddDate(0, 0, -days)
+	products, err := productEventRepo.GetTrending(c, db, since, limit)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(products) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, products)
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -17,65 +19,139 @@ import (
 )
 
 type productsRes struct {
-	Metadata filters.Metadata   `json:"metadata"`
-	Products []database.Product `json:"products"`
+	Metadata filters.Metadata        `json:"metadata"`
+	Facets   *database.ProductFacets `json:"facets,omitempty"`
+	Products []database.Product      `json:"products"`
 }
 
 func (s *Server) getAllProducts(c *gin.Context) {
-	// Initialize a new Validator instance.
-	v := validator.New()
+	s.listProducts(c, nil, false)
+}
 
-	page := getRequiredQueryInt(c, "page")
-	if page == 0 {
+// getAdminProducts lists the products in any state, the status query narrows
+// them down to some of the states, e.g. ?status=draft,scheduled.
+// With ?deleted=true the soft deleted products are listed instead, so they can be restored.
+func (s *Server) getAdminProducts(c *gin.Context) {
+	deleted, ok := getOptionalQueryBool(c, "deleted")
+	if !ok {
 		return
 	}
 
-	pageSize := getRequiredQueryInt(c, "page_size")
-	if pageSize == 0 {
-		return
+	var statuses []string
+	for _, query := range c.QueryArray("status") {
+		for _, status := range strings.Split(query, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" {
+				continue
+			}
+
+			if !models.ProductStatus(status).IsValid() {
+				utils.Fail(
+					c,
+					utils.NewAPIError(http.StatusBadRequest, "Invalid status"),
+					errors.New("invalid product status"),
+				)
+				return
+			}
+			statuses = append(statuses, status)
+		}
 	}
 
-	sort := c.Query("sort")
-	if sort == "" {
-		sort = "id"
+	if len(statuses) == 0 {
+		statuses = []string{
+			string(models.ProductDraft),
+			string(models.ProductScheduled),
+			string(models.ProductPublished),
+			string(models.ProductArchived),
+		}
 	}
 
-	var f filters.Filters
-	f.Page = int(page)
-	f.PageSize = int(pageSize)
-	f.Sort = sort
+	s.listProducts(c, statuses, deleted)
+}
 
+// listProducts serves a page of the products in the given statuses,
+// no statuses means only the published products.
+func (s *Server) listProducts(c *gin.Context, statuses []string, deleted bool) {
 	// Add the supported sort value for this endpoint to the sort safelist.
-	f.SortSafeList = []string{
+	f, ok := s.getPaginationFilters(c, "id", []string{
 		// ascending sort values
 		"id", "price", "name", "rating",
 		// descending sort values
 		"-id", "-price", "-name", "-rating",
-	}
-
-	// Execute the validation checks on the Filters struct and send a response
-	// containing the errors if necessary.
-	if filters.ValidateFilters(v, f); !v.Valid() {
-		utils.Fail(c, utils.ErrBadRequest, errors.New("bad filter options"))
+		// best search matches first, only meaningful together with search
+		"relevance",
+	})
+	if !ok {
 		return
 	}
 
 	categoryIDStr := c.Query("category_id")
 	categoryID, _ := strconv.Atoi(categoryIDStr)
 
-	brandIDStr := c.Query("brand_id")
-	brandID, _ := strconv.Atoi(brandIDStr)
-
 	search := c.Query("search")
 
 	productsFilterOptions := filters.ProductFilterOptions{
 		CategoryID: categoryID,
-		BrandID:    brandID,
 		Search:     search,
+		Statuses:   statuses,
+		Deleted:    deleted,
+	}
+
+	if productsFilterOptions.BrandIDs, ok = getQueryIntList(c, "brand_id"); !ok {
+		return
+	}
+	if productsFilterOptions.ColorIDs, ok = getQueryIntList(c, "color_id"); !ok {
+		return
+	}
+	if productsFilterOptions.SizeIDs, ok = getQueryIntList(c, "size_id"); !ok {
+		return
+	}
+	if productsFilterOptions.InStock, ok = getOptionalQueryBool(c, "in_stock"); !ok {
+		return
+	}
+	if productsFilterOptions.OnSale, ok = getOptionalQueryBool(c, "on_sale"); !ok {
+		return
+	}
+
+	if productsFilterOptions.MinPrice, ok = getOptionalQueryInt(c, "min_price"); !ok {
+		return
+	}
+	if productsFilterOptions.MaxPrice, ok = getOptionalQueryInt(c, "max_price"); !ok {
+		return
+	}
+	if minRating := c.Query("min_rating"); minRating != "" {
+		rating, err := strconv.ParseFloat(minRating, 64)
+		if err != nil {
+			utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, "Invalid min_rating"), err)
+			return
+		}
+		productsFilterOptions.MinRating = rating
 	}
 
 	db := s.DB.Pool()
 	product := s.DB.Product()
+
+	// the filterable attributes are filters of their own, e.g. ?attr_material=cotton.
+	attributes, err := s.DB.Attribute().GetFilterable(c, db)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+	if productsFilterOptions.Attributes, ok = getAttributeFilters(c, attributes); !ok {
+		return
+	}
+
+	v := validator.New()
+	if filters.ValidateProductFilters(v, productsFilterOptions); !v.Valid() {
+		utils.Fail(c, &utils.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "bad filter options",
+			Errors:  v.Errors,
+		}, errors.New("bad filter options"))
+		return
+	}
+
 	products, metadata, err := product.GetAll(c, db, f, &productsFilterOptions)
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
@@ -88,17 +164,33 @@ func (s *Server) getAllProducts(c *gin.Context) {
 		return
 	}
 
+	// the facets don't change while scrolling, they're only sent with the first page.
+	var facets *database.ProductFacets
+	if f.Cursor == nil {
+		facets, err = product.GetFacets(c, db, &productsFilterOptions, attributes)
+		if err != nil {
+			apiErr := utils.MapDBErrorToAPIError(err)
+			utils.Fail(c, apiErr, err)
+			return
+		}
+	}
+
 	utils.Success(c, productsRes{
 		Metadata: metadata,
+		Facets:   facets,
 		Products: products,
 	})
 }
 
 type productDetailsRes struct {
 	Product           database.ProductDetails            `json:"product"`
+	Breadcrumb        []models.Category                  `json:"breadcrumb"`      // the product category path, from the root category down
 	ProductVariants   []database.ProductVariantDetails   `json:"productVariants"` // will contain the color and size of each variant
 	RatingsAndReviews []database.RatingsAndReviewDetails `json:"ratingsAndReviews"`
 	Images            []models.Image                     `json:"images"`
+	Attributes        []database.ProductAttributeValue   `json:"attributes"`
+	SizeChart         *models.SizeChart                  `json:"sizeChart"`       // the measurements of the brand's sizes, null when there is no chart
+	SizeConversions   []models.ShoeSizeConversion        `json:"sizeConversions"` // the US and UK equivalents of the variants shoe sizes
 	Discount          []models.Discount                  `json:"discount"`
 }
 
@@ -108,13 +200,61 @@ func (s *Server) getProduct(c *gin.Context) {
 		return
 	}
 
+	s.respondWithProductDetails(c, productID, true)
+}
+
+// getAdminProduct serves the product details whatever state the product is in,
+// so drafts can be previewed before they're published.
+func (s *Server) getAdminProduct(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	s.respondWithProductDetails(c, productID, false)
+}
+
+// getProductBySlug serves the same details as getProduct, an old slug
+// is permanently redirected to the product's current slug.
+func (s *Server) getProductBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+
+	productID, err := productRepo.GetIDBySlug(c, db, slug)
+	if err != nil && database.IsDBNotFoundErr(err) {
+		s.redirectOldSlug(c, models.SlugEntityProduct, slug, "/products/by-slug/")
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	s.respondWithProductDetails(c, productID, true)
+}
+
+func (s *Server) respondWithProductDetails(c *gin.Context, productID int, publishedOnly bool) {
 	db := s.DB.Pool()
 	productRepo := s.DB.Product()
 	productVariantRepo := s.DB.ProductVariant()
 	ratingsAndReviewsRepo := s.DB.RatingReview()
 	imageRepo := s.DB.Image()
+	categoryRepo := s.DB.Category()
+	attributeRepo := s.DB.Attribute()
+	sizeChartRepo := s.DB.SizeChart()
+	sizeRepo := s.DB.Size()
+
+	p, err := productRepo.GetDetails(c, db, productID, publishedOnly)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
 
-	p, err := productRepo.GetDetails(c, db, productID)
+	breadcrumb, err := categoryRepo.GetPath(c, db, int32(p.CategoryID))
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(c, apiErr, err)
@@ -135,18 +275,50 @@ func (s *Server) getProduct(c *gin.Context) {
 		return
 	}
 
-	imgs, err := imageRepo.GetAllOfProduct(c, db, p.ID)
+	imgs, err := imageRepo.GetAllOfProduct(c, db, p.ID, publishedOnly)
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(c, apiErr, err)
 		return
 	}
 
+	attributes, err := attributeRepo.GetValuesOfProduct(c, db, p.ID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	// a brand without a chart for the category isn't an error, the chart is left out.
+	sizeChart, err := sizeChartRepo.GetForProduct(c, db, int32(p.BrandID), int32(p.CategoryID))
+	if err != nil && !database.IsDBNotFoundErr(err) {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	sizeConversions, err := sizeRepo.GetConversions(c, db, p.ID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	// the admins previewing the product aren't counted as views. It's recorded before
+	// the response is written, a guest may be given its anonymous id header.
+	if publishedOnly {
+		s.recordProductEvent(c, models.ProductEventView, p.ID)
+	}
+
 	utils.Success(c, productDetailsRes{
 		Product:           *p,
+		Breadcrumb:        breadcrumb,
 		ProductVariants:   pvs,
 		RatingsAndReviews: rrs,
 		Images:            imgs,
+		Attributes:        attributes,
+		SizeChart:         sizeChart,
+		SizeConversions:   sizeConversions,
 	})
 }
 
@@ -155,6 +327,9 @@ type productVariant struct {
 	Price    int   `json:"price"    binding:"required"`
 	ColorID  int32 `json:"colorId"  binding:"required"`
 	SizeID   int32 `json:"sizeId"   binding:"required"`
+	// SKU is generated when it's left empty.
+	SKU     string  `json:"sku"`
+	Barcode *string `json:"barcode"`
 }
 
 type addProductReq struct {
@@ -163,6 +338,11 @@ type addProductReq struct {
 	BrandID    int32            `json:"brandId"    binding:"required"`
 	CategoryID int32            `json:"categoryId" binding:"required"`
 	Variants   []productVariant `json:"variants"   binding:"required"`
+
+	// the product is a draft unless it's published right away or scheduled,
+	// publishAt is required when it's scheduled.
+	Status    models.ProductStatus `json:"status"`
+	PublishAt *time.Time           `json:"publishAt"`
 }
 
 func (s *Server) addProduct(c *gin.Context) {
@@ -176,6 +356,28 @@ func (s *Server) addProduct(c *gin.Context) {
 		return
 	}
 
+	if req.Status == "" {
+		req.Status = models.ProductDraft
+	}
+	publishedAt, apiErr := getPublishedAt(req.Status, req.PublishAt)
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New(apiErr.Message))
+		return
+	}
+
+	variants := make([]models.ProductVariant, len(req.Variants))
+	for i, v := range req.Variants {
+		variants[i] = models.ProductVariant{
+			Quantity: v.Quantity,
+			Price:    v.Price,
+			ColorID:  v.ColorID,
+			SizeID:   v.SizeID,
+		}
+		if !bindVariantCodes(c, &variants[i], v.SKU, v.Barcode) {
+			return
+		}
+	}
+
 	// get thumbnail image
 	imageUpload, apiErr := getImageFile(c, "thumbnail", 1000<<10) // 1000 << 10 this equals 1MB
 	if apiErr != nil {
@@ -190,29 +392,29 @@ func (s *Server) addProduct(c *gin.Context) {
 		}, err)
 		return
 	}
-	thumbnailURL, err := s.S3.UploadImage(c, imageUpload.File, imageUpload.Header)
-	if err != nil {
-		utils.Fail(
-			c,
-			&utils.APIError{
-				Code:    http.StatusInternalServerError,
-				Message: "couldn't upload product thumbnail image",
-			},
-			err,
-		)
+	// the thumbnail is stored at the largest rendition width.
+	thumbnailURL, apiErr := s.uploadSingleImage(
+		c,
+		imageUpload.File,
+		slices.Max(s.Env.ImageWidths),
+		models.UploadOwnerProduct,
+	)
+	imageUpload.File.Close()
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New("couldn't upload product thumbnail image"))
 		return
 	}
-	imageUpload.File.Close()
 
 	productRepo := s.DB.Product()
-	variantRepo := s.DB.ProductVariant()
 	imageRepo := s.DB.Image()
+	imageJobRepo := s.DB.ImageJob()
 	db, err := s.DB.BeginTx(c)
 	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
+	var images []*models.Image
 	committed := false
 	defer func() {
 		if p := recover(); p != nil {
@@ -220,7 +422,12 @@ func (s *Server) addProduct(c *gin.Context) {
 			panic(p)
 		} else if !committed {
 			_ = db.Rollback(c)
-			_ = s.S3.DeleteImageByURL(c, thumbnailURL)
+			_ = s.Storage.Delete(c, thumbnailURL)
+			for _, img := range images {
+				for _, url := range img.URLs() {
+					_ = s.Storage.Delete(c, url)
+				}
+			}
 		}
 	}()
 
@@ -230,6 +437,8 @@ func (s *Server) addProduct(c *gin.Context) {
 		BrandID:         req.BrandID,
 		ProductCategory: req.CategoryID,
 		Thumbnail:       thumbnailURL,
+		Status:          req.Status,
+		PublishedAt:     publishedAt,
 	}
 	productID, err := productRepo.Create(c, db, &p)
 	if err != nil {
@@ -239,16 +448,9 @@ func (s *Server) addProduct(c *gin.Context) {
 	}
 
 	// create product variants
-	var pv models.ProductVariant
-	for _, v := range req.Variants {
-		pv = models.ProductVariant{
-			Quantity:  v.Quantity,
-			Price:     v.Price,
-			ColorID:   v.ColorID,
-			SizeID:    v.SizeID,
-			ProductID: productID,
-		}
-		err = variantRepo.Create(c, db, &pv)
+	for i := range variants {
+		variants[i].ProductID = productID
+		err = s.createVariant(c, db, &variants[i])
 		if err != nil {
 			apiErr = utils.MapDBErrorToAPIError(err)
 			utils.Fail(c, apiErr, err)
@@ -277,28 +479,29 @@ func (s *Server) addProduct(c *gin.Context) {
 		}
 	}()
 
-	var (
-		imagePair  *ImagePair
-		imagePairs []ImagePair
-	)
 	for _, upload := range uploads {
-		imagePair, apiErr = s.UploadImageWithLowRes(c, upload.File, upload.Header, 500)
+		originalURL, apiErr := s.uploadOriginalImage(c, upload.File)
 		if apiErr != nil {
 			utils.Fail(c, apiErr, errors.New("couldn't upload image"))
 			return
 		}
 
-		imagePairs = append(imagePairs, *imagePair)
+		images = append(images, &models.Image{
+			Original:  pgtype.Text{String: originalURL, Valid: true},
+			ProductID: productID,
+		})
 	}
 
-	var image models.Image
-	for _, imgPair := range imagePairs {
-		image = models.Image{
-			Image:       imgPair.OriginalURL,
-			LowResImage: imgPair.LowResURL,
-			ProductID:   productID,
+	// the renditions are made by the image worker once the product is committed.
+	for _, img := range images {
+		err = imageRepo.Create(c, db, img)
+		if err != nil {
+			apiErr = utils.MapDBErrorToAPIError(err)
+			utils.Fail(c, apiErr, err)
+			return
 		}
-		err = imageRepo.Create(c, db, &image)
+
+		err = imageJobRepo.Create(c, db, img.ID)
 		if err != nil {
 			apiErr = utils.MapDBErrorToAPIError(err)
 			utils.Fail(c, apiErr, err)
@@ -372,6 +575,71 @@ func (s *Server) updateProduct(c *gin.Context) {
 	utils.Success(c, "product updated successfully")
 }
 
+type productStatusReq struct {
+	Status    models.ProductStatus `json:"status"    binding:"required"`
+	PublishAt *time.Time           `json:"publishAt"`
+}
+
+// updateProductStatus publishes, schedules, archives or puts back to draft a product.
+func (s *Server) updateProductStatus(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	var req productStatusReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	publishAt, apiErr := getPublishedAt(req.Status, req.PublishAt)
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New(apiErr.Message))
+		return
+	}
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+
+	err = productRepo.UpdateStatus(c, db, int32(productID), req.Status, publishAt)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "product status updated successfully")
+}
+
+// getPublishedAt validates the status a product is moved to, and returns the time
+// it goes live at. A scheduled product needs a publish time in the future, publishing
+// right away goes live now and the other statuses don't take a publish time.
+func getPublishedAt(
+	status models.ProductStatus,
+	publishAt *time.Time,
+) (pgtype.Timestamptz, *utils.APIError) {
+	if !status.IsValid() {
+		return pgtype.Timestamptz{}, utils.NewAPIError(http.StatusBadRequest, "Invalid status")
+	}
+
+	switch status {
+	case models.ProductScheduled:
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return pgtype.Timestamptz{}, utils.NewAPIError(
+				http.StatusBadRequest,
+				"publishAt must be a time in the future to schedule the product",
+			)
+		}
+		return pgtype.Timestamptz{Time: *publishAt, Valid: true}, nil
+	case models.ProductPublished:
+		return pgtype.Timestamptz{Time: time.Now(), Valid: true}, nil
+	}
+
+	return pgtype.Timestamptz{}, nil
+}
+
 func (s *Server) deleteProduct(c *gin.Context) {
 	productID := convStrToInt(c, c.Param("id"), "product id")
 	if productID == 0 {
@@ -390,3 +658,33 @@ func (s *Server) deleteProduct(c *gin.Context) {
 
 	utils.Success(c, "product deleted successfully")
 }
+
+func (s *Server) restoreProduct(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+
+	err := productRepo.Restore(c, db, int32(productID))
+	if err != nil && database.IsDBForeignKeyErr(err) {
+		utils.Fail(
+			c,
+			utils.NewAPIError(
+				http.StatusConflict,
+				"can not restore this product while its category is deleted, restore the category first",
+			),
+			err,
+		)
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "product restored successfully")
+}
@@ -0,0 +1,406 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+)
+
+// questionsSortSafeList holds the sort values supported by the questions listings.
+var questionsSortSafeList = []string{"created_at", "upvotes", "-created_at", "-upvotes"}
+
+type questionsRes struct {
+	Metadata  filters.Metadata           `json:"metadata"`
+	Questions []database.QuestionDetails `json:"questions"`
+}
+
+// getProductQuestions serves a page of the approved questions of the product
+// along with their approved answers, the answers of the admins first.
+func (s *Server) getProductQuestions(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	f, ok := s.getPaginationFilters(c, "-upvotes", questionsSortSafeList)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	questionRepo := s.DB.Question()
+
+	questions, metadata, err := questionRepo.GetPageOfProduct(c, db, int32(productID), f)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(questions) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, questionsRes{
+		Metadata:  metadata,
+		Questions: questions,
+	})
+}
+
+type questionReq struct {
+	ProductID int32  `json:"productId" binding:"required"`
+	Question  string `json:"question"  binding:"required,min=8,max=1000"`
+}
+
+// postQuestion asks a question about a published product,
+// it's only public once an admin approves it.
+func (s *Server) postQuestion(c *gin.Context) {
+	var req questionReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	questionRepo := s.DB.Question()
+
+	q := models.ProductQuestion{
+		Question:  req.Question,
+		ProductID: req.ProductID,
+		UserID:    userID,
+	}
+
+	err = questionRepo.Create(c, db, &q)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, q)
+}
+
+func (s *Server) deleteQuestion(c *gin.Context) {
+	questionID := convStrToInt(c, c.Param("id"), "question id")
+	if questionID == 0 {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	questionRepo := s.DB.Question()
+
+	err := questionRepo.Delete(c, db, int32(questionID), userID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "question deleted successfully")
+}
+
+type answerReq struct {
+	Answer string `json:"answer" binding:"required,min=2,max=2000"`
+}
+
+// answerQuestion answers an approved question. The answers of the admins are approved right away
+// and the asker is notified, the other users have to have received the product and their answers
+// wait for moderation.
+func (s *Server) answerQuestion(c *gin.Context) {
+	questionID := convStrToInt(c, c.Param("id"), "question id")
+	if questionID == 0 {
+		return
+	}
+
+	var req answerReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	claims := auth.GetAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	questionRepo := s.DB.Question()
+	orderRepo := s.DB.Order()
+
+	q, err := questionRepo.Get(c, db, int32(questionID))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	// a question that isn't public can't be answered, like it can't be seen.
+	if q.Status != models.ModerationApproved {
+		utils.Fail(c, utils.ErrNotFound, errors.New("answering a question that isn't approved"))
+		return
+	}
+
+	a := models.ProductAnswer{
+		Answer:     req.Answer,
+		Author:     models.AnswerByAdmin,
+		Status:     models.ModerationApproved,
+		QuestionID: q.ID,
+		UserID:     userID,
+	}
+
+	if claims.Role != string(models.RoleAdmin) {
+		received, err := orderRepo.HasReceivedProduct(c, db, userID, q.ProductID)
+		if err != nil {
+			apiErr := utils.MapDBErrorToAPIError(err)
+			utils.Fail(c, apiErr, err)
+			return
+		}
+		if !received {
+			utils.Fail(
+				c,
+				utils.NewAPIError(http.StatusForbidden, "only the buyers of the product can answer"),
+				errors.New("answer by a user who didn't receive the product"),
+			)
+			return
+		}
+
+		a.Author = models.AnswerByBuyer
+		a.Status = models.ModerationPending
+	}
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := questionRepo.CreateAnswer(c, tx, &a); err != nil {
+			return err
+		}
+
+		if a.Status != models.ModerationApproved {
+			return nil
+		}
+		return s.notifyQuestionAnswered(c, tx, q, &a)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, a)
+}
+
+// notifyQuestionAnswered notifies the asker of the question of the approved answer,
+// unless they answered it themselves.
+func (s *Server) notifyQuestionAnswered(
+	c *gin.Context,
+	db database.Querier,
+	q *models.ProductQuestion,
+	a *models.ProductAnswer,
+) error {
+	if q.UserID == a.UserID {
+		return nil
+	}
+
+	return s.DB.Notification().Create(c, db, &models.Notification{
+		Type:      models.NotificationQuestionAnswered,
+		Message:   "Your question got an answer",
+		Data:      map[string]any{"questionId": q.ID, "answerId": a.ID},
+		UserID:    q.UserID,
+		ProductID: pgtype.Int4{Int32: q.ProductID, Valid: true},
+	})
+}
+
+func (s *Server) upvoteQuestion(c *gin.Context) {
+	s.vote(c, "question id", s.DB.Question().Upvote, "question upvoted successfully")
+}
+
+func (s *Server) removeQuestionUpvote(c *gin.Context) {
+	s.vote(c, "question id", s.DB.Question().RemoveUpvote, "question upvote removed successfully")
+}
+
+func (s *Server) upvoteAnswer(c *gin.Context) {
+	s.vote(c, "answer id", s.DB.Question().UpvoteAnswer, "answer upvoted successfully")
+}
+
+func (s *Server) removeAnswerUpvote(c *gin.Context) {
+	s.vote(c, "answer id", s.DB.Question().RemoveAnswerUpvote, "answer upvote removed successfully")
+}
+
+// vote runs the upvote or its removal for the user on the question or answer of the :id param.
+func (s *Server) vote(
+	c *gin.Context,
+	fieldName string,
+	vote func(c *gin.Context, db database.Querier, id, userID int32) error,
+	message string,
+) {
+	id := convStrToInt(c, c.Param("id"), fieldName)
+	if id == 0 {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	err := vote(c, s.DB.Pool(), int32(id), userID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, message)
+}
+
+// getQuestionsForModeration serves a page of the questions in the ?status= (pending by default)
+// or having answers in it, along with all their answers.
+func (s *Server) getQuestionsForModeration(c *gin.Context) {
+	status := models.ModerationStatus(c.DefaultQuery("status", string(models.ModerationPending)))
+	if !status.IsValid() {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "there's no such moderation status"),
+			errors.New("invalid moderation status"),
+		)
+		return
+	}
+
+	f, ok := s.getPaginationFilters(c, "created_at", questionsSortSafeList)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	questionRepo := s.DB.Question()
+
+	questions, metadata, err := questionRepo.GetPageForModeration(c, db, status, f)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(questions) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, questionsRes{
+		Metadata:  metadata,
+		Questions: questions,
+	})
+}
+
+type moderationReq struct {
+	Status models.ModerationStatus `json:"status" binding:"required"`
+}
+
+// bindModerationReq binds the moderation status of the request,
+// it fails the request and returns false when it isn't valid.
+func bindModerationReq(c *gin.Context) (models.ModerationStatus, bool) {
+	var req moderationReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return "", false
+	}
+
+	if !req.Status.IsValid() {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "there's no such moderation status"),
+			errors.New("invalid moderation status"),
+		)
+		return "", false
+	}
+
+	return req.Status, true
+}
+
+func (s *Server) moderateQuestion(c *gin.Context) {
+	questionID := convStrToInt(c, c.Param("id"), "question id")
+	if questionID == 0 {
+		return
+	}
+
+	status, ok := bindModerationReq(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	questionRepo := s.DB.Question()
+
+	err := questionRepo.SetStatus(c, db, int32(questionID), status)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "question status updated successfully")
+}
+
+// moderateAnswer sets the status of an answer,
+// the asker is notified whenever the answer becomes approved.
+func (s *Server) moderateAnswer(c *gin.Context) {
+	answerID := convStrToInt(c, c.Param("id"), "answer id")
+	if answerID == 0 {
+		return
+	}
+
+	status, ok := bindModerationReq(c)
+	if !ok {
+		return
+	}
+
+	questionRepo := s.DB.Question()
+
+	err := s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		a, previous, err := questionRepo.SetAnswerStatus(c, tx, int32(answerID), status)
+		if err != nil {
+			return err
+		}
+
+		if status != models.ModerationApproved || previous == models.ModerationApproved {
+			return nil
+		}
+
+		q, err := questionRepo.Get(c, tx, a.QuestionID)
+		if err != nil {
+			return err
+		}
+		return s.notifyQuestionAnswered(c, tx, q, a)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "answer status updated successfully")
+}
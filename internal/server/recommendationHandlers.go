@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/internal/utils"
+)
+
+// defaultRecommendations is how many recommendations are returned without ?limit=.
+const defaultRecommendations = 12
+
+// getProductRecommendations lists the products bought or wishlisted along the product,
+// topped up with products of the same category or brand. Only published products
+// in stock are listed, up to ?limit= of them.
+func (s *Server) getProductRecommendations(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	limit, ok := getOptionalQueryInt(c, "limit")
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultRecommendations
+	}
+	if limit < 0 || limit > recommendationsPerProduct {
+		utils.Fail(
+			c,
+			utils.NewAPIError(http.StatusBadRequest, "limit must be between 1 and 30"),
+			errors.New("invalid recommendations limit"),
+		)
+		return
+	}
+
+	db := s.DB.Pool()
+	productRepo := s.DB.Product()
+	recommendationRepo := s.DB.Recommendation()
+
+	// the product has to be published, like its details.
+	_, err := productRepo.GetDetails(c, db, productID, true)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	products, err := recommendationRepo.GetOfProduct(c, db, int32(productID), limit)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	// a product published since the last refresh has no recommendations yet,
+	// and the listed ones may have sold out since.
+	if len(products) < limit {
+		exclude := make([]int32, 0, len(products))
+		for _, p := range products {
+			exclude = append(exclude, p.ID)
+		}
+
+		similar, err := recommendationRepo.GetSimilar(
+			c,
+			db,
+			int32(productID),
+			exclude,
+			limit-len(products),
+		)
+		if err != nil {
+			apiErr := utils.MapDBErrorToAPIError(err)
+			utils.Fail(c, apiErr, err)
+			return
+		}
+		products = append(products, similar...)
+	}
+
+	if len(products) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, products)
+}
@@ -8,11 +8,21 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
 	"github.com/refine-software/afrad-api/internal/utils/validator"
 )
 
+// reviewsSortSafeList holds the sort values supported by the reviews listings.
+var reviewsSortSafeList = []string{"created_at", "rating", "-created_at", "-rating"}
+
+type userReviewsRes struct {
+	Metadata filters.Metadata      `json:"metadata"`
+	Reviews  []models.RatingReview `json:"reviews"`
+}
+
 func (s *Server) getUserReviews(c *gin.Context) {
 	claims := auth.GetAccessClaims(c)
 	if claims == nil {
@@ -25,17 +35,62 @@ func (s *Server) getUserReviews(c *gin.Context) {
 		return
 	}
 
+	f, ok := s.getPaginationFilters(c, "-created_at", reviewsSortSafeList)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	reviewRepo := s.DB.RatingReview()
+
+	rrs, metadata, err := reviewRepo.GetAllOfUser(c, db, int32(userID), f)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, userReviewsRes{
+		Metadata: metadata,
+		Reviews:  rrs,
+	})
+}
+
+type productReviewsRes struct {
+	Metadata filters.Metadata                   `json:"metadata"`
+	Reviews  []database.RatingsAndReviewDetails `json:"reviews"`
+}
+
+func (s *Server) getProductReviews(c *gin.Context) {
+	productID := convStrToInt(c, c.Param("id"), "product id")
+	if productID == 0 {
+		return
+	}
+
+	f, ok := s.getPaginationFilters(c, "-created_at", reviewsSortSafeList)
+	if !ok {
+		return
+	}
+
 	db := s.DB.Pool()
 	reviewRepo := s.DB.RatingReview()
 
-	rrs, err := reviewRepo.GetAllOfUser(c, db, int32(userID))
+	rrs, metadata, err := reviewRepo.GetPageOfProduct(c, db, int32(productID), f)
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(c, apiErr, err)
 		return
 	}
 
-	utils.Success(c, rrs)
+	if len(rrs) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, productReviewsRes{
+		Metadata: metadata,
+		Reviews:  rrs,
+	})
 }
 
 type ReviewReq struct {
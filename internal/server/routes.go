@@ -8,6 +8,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/refine-software/afrad-api/internal/middleware"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/storage"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"github.com/coder/websocket"
@@ -20,6 +22,15 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	engine.GET("/websocket", s.websocketHandler)
 
+	// the local storage files are served and received by the API itself.
+	if local, ok := s.Storage.(*storage.LocalStorage); ok {
+		engine.Static(storage.LocalRoute, s.Env.LocalStorageDir)
+		engine.PUT(
+			storage.LocalRoute+"/*filepath",
+			gin.WrapH(http.StripPrefix(storage.LocalRoute, local.UploadHandler())),
+		)
+	}
+
 	s.registerPublicRoutes(engine)
 	s.registerUserRoutes(engine)
 	s.registerAdminRoutes(engine)
@@ -48,12 +59,19 @@ func (s *Server) registerPublicRoutes(e *gin.Engine) {
 	products := e.Group("/products")
 	{
 		products.GET("", s.getAllProducts)
+		products.GET("/trending", s.getTrendingProducts)
 		products.GET("/:id", s.getProduct)
+		products.GET("/by-slug/:slug", s.getProductBySlug)
+		products.GET("/:id/reviews", s.getProductReviews)
+		products.GET("/:id/recommendations", s.getProductRecommendations)
+		products.GET("/:id/questions", s.getProductQuestions)
 	}
 
 	categories := e.Group("/categories")
 	{
 		categories.GET("", s.getCategories)
+		categories.GET("/tree", s.getCategoryTree)
+		categories.GET("/by-slug/:slug", s.getCategoryBySlug)
 	}
 
 	variant := products.Group("/variants")
@@ -64,6 +82,7 @@ func (s *Server) registerPublicRoutes(e *gin.Engine) {
 	sizes := e.Group("/sizes")
 	{
 		sizes.GET("", s.GetSizes)
+		sizes.GET("/conversions", s.getSizeConversions)
 	}
 
 	colors := e.Group("/colors")
@@ -71,6 +90,11 @@ func (s *Server) registerPublicRoutes(e *gin.Engine) {
 		colors.GET("", s.getColors)
 	}
 
+	attributes := e.Group("/attributes")
+	{
+		attributes.GET("", s.getAttributes)
+	}
+
 	e.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
 
@@ -87,12 +111,27 @@ func (s *Server) registerUserRoutes(e *gin.Engine) {
 		user.POST("/reviews", s.postReview)
 		user.PUT("/reviews/:id", s.updateReview)
 		user.DELETE("/reviews/:id", s.deleteReview)
+		user.GET("/recently-viewed", s.getRecentlyViewed)
+		user.POST("/questions", s.postQuestion)
+		user.DELETE("/questions/:id", s.deleteQuestion)
+		user.POST("/questions/:id/answers", s.answerQuestion)
+		user.POST("/questions/:id/upvote", s.upvoteQuestion)
+		user.DELETE("/questions/:id/upvote", s.removeQuestionUpvote)
+		user.POST("/answers/:id/upvote", s.upvoteAnswer)
+		user.DELETE("/answers/:id/upvote", s.removeAnswerUpvote)
+		user.GET("/notifications", s.getNotifications)
+		user.PATCH("/notifications/read", s.readAllNotifications)
+		user.PATCH("/notifications/:id/read", s.readNotification)
+		user.GET("/stock-subscriptions", s.getStockSubscriptions)
+		user.DELETE("/stock-subscriptions/:id", s.deleteStockSubscription)
 		user.GET("/user/notificatoin-preferences")
 		user.PATCH("/user/notificatoin-preferences")
 		user.POST("/logout", s.logout)
 		user.POST("/logout/all", s.logoutFromAllSessions)
 	}
 
+	protected.POST("/products/variants/:id/notify-me", s.subscribeToVariant)
+
 	cart := protected.Group("/cart")
 	{
 		cart.GET("", s.getCart)
@@ -111,35 +150,58 @@ func (s *Server) registerUserRoutes(e *gin.Engine) {
 
 	orders := protected.Group("/orders")
 	{
-		orders.GET("")
+		orders.GET("", s.getUserOrders)
 		orders.POST("", s.createOrder)
-		orders.GET("/:id")
+		orders.GET("/:id", s.getOrder)
 		orders.PATCH("/:id/cancel")
 	}
 }
 
 func (s *Server) registerAdminRoutes(e *gin.Engine) {
 	admin := e.Group("/admin")
+	admin.Use(middleware.AdminOrAPIKey(s.Env.AccessTokenSecret, s.Env.HashSecret, s.DB))
 
-	product := admin.Group("/products")
+	product := admin.Group("/products", middleware.RequireScope(models.ScopeProductsWrite))
 	{
+		product.GET("", s.getAdminProducts)
+		product.GET("/export", s.exportProducts)
+		product.GET("/:id", s.getAdminProduct)
 		product.POST("", s.addProduct)
+		product.POST("/import", s.importProducts)
 		product.PUT("/:id", s.updateProduct)
+		product.PATCH("/:id/status", s.updateProductStatus)
 		product.DELETE("/:id", s.deleteProduct)
+		product.PATCH("/:id/restore", s.restoreProduct)
+		product.PUT("/:id/attributes", s.setProductAttributes)
+
+		images := product.Group("/:id/images")
+		{
+			images.GET("", s.getProductImages)
+			images.POST("", s.addProductImages)
+			images.PUT("/order", s.reorderProductImages)
+			images.PATCH("/:imageId", s.updateProductImage)
+			images.PATCH("/:imageId/thumbnail", s.setProductThumbnail)
+			images.POST("/:imageId/retry", s.retryProductImage)
+			images.DELETE("/:imageId", s.deleteProductImage)
+		}
 
 		variant := product.Group("/variants")
 		{
 			variant.POST("", s.addVariant)
 			variant.PUT("/:id", s.updateVariant)
+			variant.GET("/:id/prices", s.getVariantPriceHistory)
 			variant.DELETE("/:id", s.deleteVariant)
+			variant.PATCH("/:id/restore", s.restoreVariant)
 		}
 	}
 
-	category := admin.Group("/category")
+	category := admin.Group("/category", middleware.RequireScope(models.ScopeCatalogWrite))
 	{
 		category.POST("", s.createCategory)
 		category.PATCH("/:id", s.updateCategory)
+		category.PATCH("/:id/parent", s.moveCategory)
 		category.DELETE("/:id", s.deleteCategory)
+		category.PATCH("/:id/restore", s.restoreCategory)
 	}
 
 	discount := admin.Group("/discounts")
@@ -151,23 +213,81 @@ func (s *Server) registerAdminRoutes(e *gin.Engine) {
 		discount.DELETE("/:id")
 	}
 
-	orders := admin.Group("/orders")
+	orders := admin.Group("/orders", middleware.RequireScope(models.ScopeOrdersWrite))
 	{
-		orders.GET("")
+		orders.GET("", s.getAllOrders)
+		orders.PATCH("/:id/status", s.updateOrderStatus)
 	}
 
-	sizes := admin.Group("/sizes")
+	sizes := admin.Group("/sizes", middleware.RequireScope(models.ScopeCatalogWrite))
 	{
 		sizes.POST("", s.createSize)
 		sizes.PUT("/:id", s.updateSize)
 		sizes.DELETE("/:id", s.deleteSize)
+		sizes.PATCH("/:id/restore", s.restoreSize)
+		sizes.PUT("/:id/conversion", s.setSizeConversion)
+		sizes.DELETE("/:id/conversion", s.deleteSizeConversion)
+	}
+
+	sizeCharts := admin.Group("/size-charts", middleware.RequireScope(models.ScopeCatalogWrite))
+	{
+		sizeCharts.GET("", s.getSizeCharts)
+		sizeCharts.GET("/:id", s.getSizeChart)
+		sizeCharts.POST("", s.createSizeChart)
+		sizeCharts.PUT("/:id", s.updateSizeChart)
+		sizeCharts.DELETE("/:id", s.deleteSizeChart)
 	}
 
-	colors := admin.Group("/colors")
+	colors := admin.Group("/colors", middleware.RequireScope(models.ScopeCatalogWrite))
 	{
 		colors.POST("", s.createColor)
 		colors.PUT("/:id", s.updateColor)
 		colors.DELETE("/:id", s.deleteColor)
+		colors.PATCH("/:id/restore", s.restoreColor)
+	}
+
+	attributes := admin.Group("/attributes", middleware.RequireScope(models.ScopeCatalogWrite))
+	{
+		attributes.POST("", s.createAttribute)
+		attributes.PUT("/:id", s.updateAttribute)
+		attributes.DELETE("/:id", s.deleteAttribute)
+		attributes.POST("/:id/options", s.addAttributeOption)
+		attributes.DELETE("/:id/options/:optionId", s.deleteAttributeOption)
+	}
+
+	inventory := admin.Group("/inventory", middleware.RequireScope(models.ScopeProductsWrite))
+	{
+		inventory.GET("/alerts", s.getStockAlerts)
+		inventory.GET("/variants/:id/movements", s.getStockHistory)
+		inventory.POST("/variants/:id/adjustments", s.adjustStock)
+		inventory.PUT("/variants/:id/threshold", s.setLowStockThreshold)
+	}
+
+	admin.GET("/variants/lookup", middleware.RequireScope(models.ScopeProductsWrite), s.lookupVariant)
+
+	questions := admin.Group("/questions", middleware.RequireScope(models.ScopeProductsWrite))
+	{
+		questions.GET("", s.getQuestionsForModeration)
+		questions.PATCH("/:id/status", s.moderateQuestion)
+	}
+
+	answers := admin.Group("/answers", middleware.RequireScope(models.ScopeProductsWrite))
+	{
+		answers.PATCH("/:id/status", s.moderateAnswer)
+	}
+
+	apiKeys := admin.Group("/api-keys", middleware.UserTokenOnly())
+	{
+		apiKeys.POST("", s.createAPIKey)
+		apiKeys.GET("", s.getAPIKeys)
+		apiKeys.DELETE("/:id", s.revokeAPIKey)
+	}
+
+	uploads := admin.Group("/uploads", middleware.UserTokenOnly())
+	{
+		uploads.POST("", s.presignUploads)
+		uploads.POST("/:id/confirm", s.confirmUpload)
+		uploads.GET("/missing", s.getMissingUploads)
 	}
 }
 
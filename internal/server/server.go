@@ -2,6 +2,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,17 +15,21 @@ import (
 	"github.com/refine-software/afrad-api/config"
 	"github.com/refine-software/afrad-api/internal/auth"
 	"github.com/refine-software/afrad-api/internal/database"
-	"github.com/refine-software/afrad-api/internal/s3"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/storage"
 	myvalidator "github.com/refine-software/afrad-api/internal/utils/validator"
 )
 
 type Server struct {
 	port int
 
-	DB    database.Service
-	Env   *config.Env
-	S3    s3.S3
-	Email auth.EmailSender
+	DB      database.Service
+	Env     *config.Env
+	Storage storage.Storage
+	Email   auth.EmailSender
+
+	// events buffers the product views and adds to cart until they're written in a batch.
+	events chan models.ProductEvent
 }
 
 func NewServer() *http.Server {
@@ -34,10 +39,9 @@ func NewServer() *http.Server {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	s3Storage, err := s3.NewS3Storage(env)
+	fileStorage, err := storage.New(env)
 	if err != nil {
-		log.Println("couldn't create s3 storage")
-		log.Fatalln(err)
+		log.Fatalf("couldn't create the file storage: %v", err)
 	}
 
 	auth.InitOauth(env)
@@ -49,10 +53,12 @@ func NewServer() *http.Server {
 	NewServer := &Server{
 		port: env.Port,
 
-		DB:    database.New(env),
-		Env:   env,
-		S3:    s3Storage,
-		Email: auth.NewEmailService(env.Email, env.Password),
+		DB:      database.New(env),
+		Env:     env,
+		Storage: fileStorage,
+		Email:   auth.NewEmailService(env.Email, env.Password),
+
+		events: make(chan models.ProductEvent, productEventsBuffer),
 	}
 
 	// Declare Server config
@@ -64,5 +70,10 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// the background jobs stop along with the server.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	server.RegisterOnShutdown(stopJobs)
+	NewServer.startJobs(jobsCtx)
+
 	return server
 }
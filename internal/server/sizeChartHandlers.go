@@ -0,0 +1,201 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+)
+
+type sizeChartRowReq struct {
+	SizeID   int32    `json:"sizeId"   binding:"required"`
+	ChestCm  *float64 `json:"chestCm"  binding:"omitempty,gt=0"`
+	WaistCm  *float64 `json:"waistCm"  binding:"omitempty,gt=0"`
+	LengthCm *float64 `json:"lengthCm" binding:"omitempty,gt=0"`
+}
+
+type sizeChartReq struct {
+	BrandID    int32  `json:"brandId"    binding:"required"`
+	CategoryID int32  `json:"categoryId" binding:"required"`
+	Note       string `json:"note"`
+
+	// Rows are the measurements of the sizes in cm, in the order they're shown.
+	Rows []sizeChartRowReq `json:"rows" binding:"required,min=1,dive"`
+}
+
+// toSizeChart returns the chart of the request, every row needs at least one measurement.
+func (req *sizeChartReq) toSizeChart() (*models.SizeChart, *utils.APIError) {
+	chart := models.SizeChart{
+		BrandID:    req.BrandID,
+		CategoryID: req.CategoryID,
+		Note:       pgtype.Text{String: req.Note, Valid: req.Note != ""},
+		Rows:       make([]models.SizeChartRow, 0, len(req.Rows)),
+	}
+
+	seen := make(map[int32]bool, len(req.Rows))
+	for i, r := range req.Rows {
+		if r.ChestCm == nil && r.WaistCm == nil && r.LengthCm == nil {
+			return nil, utils.NewAPIError(
+				http.StatusBadRequest,
+				"every row needs the chest, waist or length",
+			)
+		}
+		if seen[r.SizeID] {
+			return nil, utils.NewAPIError(http.StatusBadRequest, "a size can only have one row")
+		}
+		seen[r.SizeID] = true
+
+		chart.Rows = append(chart.Rows, models.SizeChartRow{
+			SizeID:   r.SizeID,
+			ChestCm:  optionalFloat(r.ChestCm),
+			WaistCm:  optionalFloat(r.WaistCm),
+			LengthCm: optionalFloat(r.LengthCm),
+			Position: int32(i),
+		})
+	}
+
+	return &chart, nil
+}
+
+func optionalFloat(f *float64) pgtype.Float8 {
+	if f == nil {
+		return pgtype.Float8{}
+	}
+	return pgtype.Float8{Float64: *f, Valid: true}
+}
+
+// getSizeCharts lists the size charts, ?brand_id= and ?category_id= narrow them down.
+func (s *Server) getSizeCharts(c *gin.Context) {
+	brandID, ok := getOptionalQueryInt(c, "brand_id")
+	if !ok {
+		return
+	}
+
+	categoryID, ok := getOptionalQueryInt(c, "category_id")
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	sizeChartRepo := s.DB.SizeChart()
+
+	charts, err := sizeChartRepo.GetAll(c, db, int32(brandID), int32(categoryID))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, charts)
+}
+
+func (s *Server) getSizeChart(c *gin.Context) {
+	id := convStrToInt(c, c.Param("id"), "size chart id")
+	if id == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	sizeChartRepo := s.DB.SizeChart()
+
+	chart, err := sizeChartRepo.Get(c, db, int32(id))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, chart)
+}
+
+func (s *Server) createSizeChart(c *gin.Context) {
+	var req sizeChartReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	chart, apiErr := req.toSizeChart()
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New(apiErr.Message))
+		return
+	}
+
+	sizeChartRepo := s.DB.SizeChart()
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := sizeChartRepo.Create(c, tx, chart); err != nil {
+			return err
+		}
+
+		return sizeChartRepo.SetRows(c, tx, chart.ID, chart.Rows)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, gin.H{"id": chart.ID})
+}
+
+// updateSizeChart replaces the size chart along with its rows.
+func (s *Server) updateSizeChart(c *gin.Context) {
+	id := convStrToInt(c, c.Param("id"), "size chart id")
+	if id == 0 {
+		return
+	}
+
+	var req sizeChartReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	chart, apiErr := req.toSizeChart()
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New(apiErr.Message))
+		return
+	}
+	chart.ID = int32(id)
+
+	sizeChartRepo := s.DB.SizeChart()
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := sizeChartRepo.Update(c, tx, chart); err != nil {
+			return err
+		}
+
+		return sizeChartRepo.SetRows(c, tx, chart.ID, chart.Rows)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "size chart updated successfully")
+}
+
+func (s *Server) deleteSizeChart(c *gin.Context) {
+	id := convStrToInt(c, c.Param("id"), "size chart id")
+	if id == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	sizeChartRepo := s.DB.SizeChart()
+
+	err := sizeChartRepo.Delete(c, db, int32(id))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "size chart deleted successfully")
+}
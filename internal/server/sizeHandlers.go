@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
 )
@@ -115,6 +117,14 @@ func (s *Server) deleteSize(ctx *gin.Context) {
 	sizeRepo := s.DB.Size()
 
 	err := sizeRepo.Delete(ctx, db, int32(id))
+	if err != nil && database.IsDBForeignKeyErr(err) {
+		utils.Fail(
+			ctx,
+			utils.NewAPIError(http.StatusConflict, "can not delete this size while variants use it"),
+			err,
+		)
+		return
+	}
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(ctx, apiErr, err)
@@ -123,3 +133,99 @@ func (s *Server) deleteSize(ctx *gin.Context) {
 
 	utils.Success(ctx, nil)
 }
+
+func (s *Server) restoreSize(ctx *gin.Context) {
+	id := convStrToInt(ctx, ctx.Param("id"), "size_id")
+	if id == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	sizeRepo := s.DB.Size()
+
+	err := sizeRepo.Restore(ctx, db, int32(id))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, "size restored successfully")
+}
+
+func (s *Server) getSizeConversions(ctx *gin.Context) {
+	db := s.DB.Pool()
+	sizeRepo := s.DB.Size()
+
+	conversions, err := sizeRepo.GetConversions(ctx, db, 0)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, conversions)
+}
+
+type sizeConversionReq struct {
+	UK           float64  `json:"uk"           binding:"required,gt=0"`
+	USMen        float64  `json:"usMen"        binding:"required,gt=0"`
+	USWomen      float64  `json:"usWomen"      binding:"required,gt=0"`
+	FootLengthCm *float64 `json:"footLengthCm" binding:"omitempty,gt=0"`
+}
+
+// setSizeConversion sets the US and UK equivalents of an EU shoe size.
+func (s *Server) setSizeConversion(ctx *gin.Context) {
+	id := convStrToInt(ctx, ctx.Param("id"), "size_id")
+	if id == 0 {
+		return
+	}
+
+	var req sizeConversionReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(ctx, utils.ErrBadRequest, err)
+		return
+	}
+
+	conversion := models.ShoeSizeConversion{
+		SizeID:  int32(id),
+		UK:      req.UK,
+		USMen:   req.USMen,
+		USWomen: req.USWomen,
+	}
+	if req.FootLengthCm != nil {
+		conversion.FootLengthCm = pgtype.Float8{Float64: *req.FootLengthCm, Valid: true}
+	}
+
+	db := s.DB.Pool()
+	sizeRepo := s.DB.Size()
+
+	err = sizeRepo.SetConversion(ctx, db, &conversion)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, "size conversion updated successfully")
+}
+
+func (s *Server) deleteSizeConversion(ctx *gin.Context) {
+	id := convStrToInt(ctx, ctx.Param("id"), "size_id")
+	if id == 0 {
+		return
+	}
+
+	db := s.DB.Pool()
+	sizeRepo := s.DB.Size()
+
+	err := sizeRepo.DeleteConversion(ctx, db, int32(id))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(ctx, apiErr, err)
+		return
+	}
+
+	utils.Success(ctx, "size conversion deleted successfully")
+}
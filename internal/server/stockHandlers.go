@@ -0,0 +1,243 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+)
+
+// errOutOfStock is returned when a movement would take a variant quantity below 0.
+var errOutOfStock = utils.NewAPIError(http.StatusConflict, "there isn't enough stock")
+
+// setStockActor sets the user or the api key of the request as the actor of the movement.
+func setStockActor(c *gin.Context, m *models.StockMovement) {
+	claims, _ := c.Get("claims")
+	accessClaims, _ := claims.(*auth.AccessClaims)
+	if accessClaims == nil {
+		return
+	}
+
+	if accessClaims.IsAPIKey() {
+		m.APIKeyID = pgtype.Int4{Int32: accessClaims.APIKeyID, Valid: true}
+		return
+	}
+
+	if userID, err := strconv.Atoi(accessClaims.Subject); err == nil {
+		m.ActorID = pgtype.Int4{Int32: int32(userID), Valid: true}
+	}
+}
+
+// moveStock records the movement by the actor of the request and updates the low stock alert
+// of the variant, db should be a transaction along with the change that caused the movement.
+func (s *Server) moveStock(c *gin.Context, db database.Querier, m *models.StockMovement) error {
+	setStockActor(c, m)
+
+	stockRepo := s.DB.Stock()
+	if err := stockRepo.Record(c, db, m); err != nil {
+		return err
+	}
+
+	err := stockRepo.SyncAlert(c, db, m.VariantID, s.Env.LowStockThreshold)
+	if err != nil || m.Change <= 0 {
+		return err
+	}
+
+	// the added stock lets as many waiting subscribers be notified it's back.
+	_, err = s.DB.StockSubscription().Queue(c, db, m.VariantID)
+	return err
+}
+
+// createVariant creates the variant, restocks it with its quantity and notifies the
+// wishlisters of its product when it's cheaper, db should be a transaction.
+func (s *Server) createVariant(c *gin.Context, db database.Querier, pv *models.ProductVariant) error {
+	variantRepo := s.DB.ProductVariant()
+
+	previousPrice, err := variantRepo.GetLowestPrice(c, db, pv.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if err = variantRepo.Create(c, db, pv); err != nil {
+		return err
+	}
+
+	if err = s.notifyPriceDrops(c, db, pv.ProductID, previousPrice); err != nil {
+		return err
+	}
+
+	if pv.Quantity == 0 {
+		return s.DB.Stock().SyncAlert(c, db, pv.ID, s.Env.LowStockThreshold)
+	}
+
+	return s.moveStock(c, db, &models.StockMovement{
+		Change:    pv.Quantity,
+		Reason:    models.StockRestock,
+		Note:      pgtype.Text{String: "initial stock", Valid: true},
+		VariantID: pv.ID,
+	})
+}
+
+type stockAdjustmentReq struct {
+	// Change is added to the quantity, a negative one takes stock out.
+	Change int                        `json:"change" binding:"required"`
+	Reason models.StockMovementReason `json:"reason" binding:"required"`
+	Note   string                     `json:"note"   binding:"max=500"`
+}
+
+// adjustStock records a restock, damage or manual adjustment of the variant stock.
+func (s *Server) adjustStock(c *gin.Context) {
+	variantID := convStrToInt(c, c.Param("id"), "variant id")
+	if variantID == 0 {
+		return
+	}
+
+	var req stockAdjustmentReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	var reqErr string
+	switch {
+	case !req.Reason.IsManual():
+		reqErr = "the reason has to be restock, adjustment or damage"
+	case req.Reason == models.StockRestock && req.Change < 0:
+		reqErr = "a restock has to add stock"
+	case req.Reason == models.StockDamage && req.Change > 0:
+		reqErr = "a damage has to take stock out"
+	}
+	if reqErr != "" {
+		utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, reqErr), errors.New(reqErr))
+		return
+	}
+
+	m := models.StockMovement{
+		Change:    req.Change,
+		Reason:    req.Reason,
+		Note:      pgtype.Text{String: req.Note, Valid: req.Note != ""},
+		VariantID: int32(variantID),
+	}
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		return s.moveStock(c, tx, &m)
+	})
+	if database.IsDBConstraintErr(err, database.VariantQuantityCheck) {
+		utils.Fail(c, errOutOfStock, err)
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, m)
+}
+
+type stockHistoryRes struct {
+	Metadata  filters.Metadata       `json:"metadata"`
+	Movements []models.StockMovement `json:"movements"`
+}
+
+// getStockHistory serves a page of the stock movements of the variant, the latest first.
+func (s *Server) getStockHistory(c *gin.Context) {
+	variantID := convStrToInt(c, c.Param("id"), "variant id")
+	if variantID == 0 {
+		return
+	}
+
+	f, ok := s.getPaginationFilters(c, "-created_at", []string{"created_at", "-created_at"})
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+	stockRepo := s.DB.Stock()
+
+	movements, metadata, err := stockRepo.GetHistory(c, db, int32(variantID), f)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(movements) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, stockHistoryRes{
+		Metadata:  metadata,
+		Movements: movements,
+	})
+}
+
+type lowStockThresholdReq struct {
+	// Threshold is the stock the variant is alerted at, null goes back to the default one.
+	Threshold *int `json:"threshold" binding:"omitempty,min=0"`
+}
+
+func (s *Server) setLowStockThreshold(c *gin.Context) {
+	variantID := convStrToInt(c, c.Param("id"), "variant id")
+	if variantID == 0 {
+		return
+	}
+
+	var req lowStockThresholdReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	var threshold pgtype.Int4
+	if req.Threshold != nil {
+		threshold = pgtype.Int4{Int32: int32(*req.Threshold), Valid: true}
+	}
+
+	stockRepo := s.DB.Stock()
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := stockRepo.SetThreshold(c, tx, int32(variantID), threshold); err != nil {
+			return err
+		}
+
+		return stockRepo.SyncAlert(c, tx, int32(variantID), s.Env.LowStockThreshold)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "low stock threshold updated successfully")
+}
+
+// getStockAlerts lists the variants at or below their low stock threshold, the lowest stock first.
+func (s *Server) getStockAlerts(c *gin.Context) {
+	db := s.DB.Pool()
+	stockRepo := s.DB.Stock()
+
+	alerts, err := stockRepo.GetOpenAlerts(c, db, s.Env.LowStockThreshold)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(alerts) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, alerts)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils"
+)
+
+// stockSubscriptionLifetime is how long a subscription waits for a restock before it expires.
+const stockSubscriptionLifetime = 60 * 24 * time.Hour
+
+// errVariantInStock is returned when subscribing to a variant that can be bought already.
+var errVariantInStock = utils.NewAPIError(http.StatusConflict, "this variant is in stock")
+
+// subscribeToVariant subscribes the user to be notified once the out of stock variant is restocked,
+// subscribing again renews the subscription.
+func (s *Server) subscribeToVariant(c *gin.Context) {
+	variantID := int32(convStrToInt(c, c.Param("id"), "variant id"))
+	if variantID == 0 {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	db := s.DB.Pool()
+
+	variant, err := s.DB.ProductVariant().Get(c, db, variantID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+	if variant.Quantity > 0 {
+		utils.Fail(c, errVariantInStock, errors.New("subscribing to a variant in stock"))
+		return
+	}
+
+	sub := models.StockSubscription{
+		ExpiresAt: time.Now().Add(stockSubscriptionLifetime),
+		VariantID: variantID,
+		UserID:    userID,
+	}
+	err = s.DB.StockSubscription().Subscribe(c, db, &sub)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, sub)
+}
+
+// getStockSubscriptions serves the subscriptions of the user still waiting for a restock.
+func (s *Server) getStockSubscriptions(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	subs, err := s.DB.StockSubscription().GetAllOfUser(c, s.DB.Pool(), userID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, subs)
+}
+
+func (s *Server) deleteStockSubscription(c *gin.Context) {
+	subID := convStrToInt(c, c.Param("id"), "subscription id")
+	if subID == 0 {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		return
+	}
+
+	err := s.DB.StockSubscription().Delete(c, s.DB.Pool(), int32(subID), userID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.NoContent(c)
+}
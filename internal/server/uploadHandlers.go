@@ -0,0 +1,352 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/storage"
+	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/imageproc"
+)
+
+// getMissingUploads reports the uploads referenced by rows whose objects weren't found
+// in the storage by the last reconciliation.
+func (s *Server) getMissingUploads(c *gin.Context) {
+	db := s.DB.Pool()
+	uploadRepo := s.DB.Upload()
+
+	uploads, err := uploadRepo.GetMissing(c, db)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	if len(uploads) == 0 {
+		utils.NoContent(c)
+		return
+	}
+
+	utils.Success(c, uploads)
+}
+
+const (
+	// presignedUploadExpiry is how long a presigned upload url can be used.
+	presignedUploadExpiry = 15 * time.Minute
+
+	// maxPresignedUploadSize is the max size of a file uploaded straight to the storage,
+	// it's larger than the form uploads since the file doesn't pass through the API.
+	maxPresignedUploadSize = 20 << 20 // 20MB
+
+	// maxPresignedUploads is how many uploads can be presigned at once.
+	maxPresignedUploads = 20
+
+	// reviewImageWidth is the width the review images are stored at.
+	reviewImageWidth = 960
+
+	// sniffLen is how many bytes of a file are read to detect its type.
+	sniffLen = 512
+)
+
+type presignUploadFileReq struct {
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size"        binding:"required,min=1"`
+}
+
+type presignUploadsReq struct {
+	// Target is what the files are attached to once they're confirmed.
+	Target string                 `json:"target" binding:"required,oneof=product user review"`
+	Files  []presignUploadFileReq `json:"files"  binding:"required,min=1,dive"`
+}
+
+type presignedUploadRes struct {
+	ID int32 `json:"id"`
+	storage.PresignedUpload
+}
+
+// presignUploads hands out urls the client uploads the files to straight,
+// each upload has to be confirmed before it's attached to its target.
+func (s *Server) presignUploads(c *gin.Context) {
+	var req presignUploadsReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	if len(req.Files) > maxPresignedUploads {
+		utils.Fail(
+			c,
+			utils.NewAPIError(
+				http.StatusBadRequest,
+				fmt.Sprintf("at most %d files can be uploaded at once", maxPresignedUploads),
+			),
+			nil,
+		)
+		return
+	}
+
+	for _, file := range req.Files {
+		if imageproc.ExtensionOf(file.ContentType) == "" {
+			utils.Fail(
+				c,
+				utils.NewAPIError(http.StatusBadRequest, "this type of file is not allowed"),
+				nil,
+			)
+			return
+		}
+		if file.Size > maxPresignedUploadSize {
+			utils.Fail(
+				c,
+				utils.NewAPIError(http.StatusBadRequest, "one of the files exceeds the max size of 20MB"),
+				nil,
+			)
+			return
+		}
+	}
+
+	db := s.DB.Pool()
+	uploadRepo := s.DB.Upload()
+
+	res := make([]presignedUploadRes, 0, len(req.Files))
+	for _, file := range req.Files {
+		presigned, err := s.Storage.PresignUpload(
+			c,
+			imageproc.ExtensionOf(file.ContentType),
+			file.ContentType,
+			file.Size,
+			presignedUploadExpiry,
+		)
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+
+		// the upload is recorded before it's made, so it's garbage collected if it's never confirmed.
+		upload := models.Upload{
+			URL:                 presigned.URL,
+			OwnerType:           models.UploadOwner(req.Target),
+			ExpectedSize:        pgtype.Int8{Int64: file.Size, Valid: true},
+			ExpectedContentType: pgtype.Text{String: file.ContentType, Valid: true},
+		}
+		err = uploadRepo.CreatePresigned(c, db, &upload)
+		if err != nil {
+			apiErr := utils.MapDBErrorToAPIError(err)
+			utils.Fail(c, apiErr, err)
+			return
+		}
+
+		res = append(res, presignedUploadRes{
+			ID:              upload.ID,
+			PresignedUpload: presigned,
+		})
+	}
+
+	utils.Created(c, res)
+}
+
+type confirmUploadReq struct {
+	// TargetID is the id of the product, user or review the file is attached to.
+	TargetID int32 `json:"targetId" binding:"required"`
+
+	// AltText and ColorID only apply to the product images.
+	AltText string `json:"altText"`
+	ColorID int32  `json:"colorId"`
+}
+
+// confirmUpload checks that the uploaded file matches what was presigned and is the image
+// it claims to be, then attaches it to its target. A file that doesn't match is deleted,
+// it can be uploaded again while its url hasn't expired.
+func (s *Server) confirmUpload(c *gin.Context) {
+	uploadID := int32(convStrToInt(c, c.Param("id"), "upload id"))
+	if uploadID == 0 {
+		return
+	}
+
+	var req confirmUploadReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	db := s.DB.Pool()
+	uploadRepo := s.DB.Upload()
+
+	upload, err := uploadRepo.GetPending(c, db, uploadID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	head, err := s.Storage.Head(c, upload.URL, sniffLen)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.Fail(c, utils.NewAPIError(http.StatusConflict, "the file isn't uploaded yet"), err)
+		return
+	}
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	contentType, ok := imageproc.Sniff(head.Data)
+	if head.Size != upload.ExpectedSize.Int64 || !ok ||
+		contentType != upload.ExpectedContentType.String {
+		_ = s.Storage.Delete(c, upload.URL)
+		utils.Fail(
+			c,
+			utils.NewAPIError(
+				http.StatusBadRequest,
+				"the uploaded file doesn't match the presigned size and content type",
+			),
+			nil,
+		)
+		return
+	}
+
+	switch upload.OwnerType {
+	case models.UploadOwnerProduct:
+		s.attachProductImage(c, upload, req)
+	case models.UploadOwnerUser:
+		s.attachUserImage(c, upload, req)
+	case models.UploadOwnerReview:
+		s.attachReviewImage(c, upload, req)
+	default:
+		utils.Fail(c, utils.ErrInternal, fmt.Errorf("upload %d has no target", upload.ID))
+	}
+}
+
+// attachProductImage adds the upload to the product gallery as the original of a new image,
+// the image worker makes its renditions.
+func (s *Server) attachProductImage(c *gin.Context, upload *models.Upload, req confirmUploadReq) {
+	altText := strings.TrimSpace(req.AltText)
+	img := models.Image{
+		Original:  pgtype.Text{String: upload.URL, Valid: true},
+		AltText:   pgtype.Text{String: altText, Valid: altText != ""},
+		ColorID:   pgtype.Int4{Int32: req.ColorID, Valid: req.ColorID != 0},
+		ProductID: req.TargetID,
+	}
+
+	err := s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := s.DB.Upload().Confirm(c, tx, upload.ID); err != nil {
+			return err
+		}
+		if err := s.DB.Image().Create(c, tx, &img); err != nil {
+			return err
+		}
+		return s.DB.ImageJob().Create(c, tx, img.ID)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Created(c, img)
+}
+
+// readUploadedImage downloads the upload and encodes it as a single WebP no wider than width,
+// the upload itself is deleted once the WebP is attached.
+func (s *Server) readUploadedImage(
+	c *gin.Context,
+	upload *models.Upload,
+	width int,
+) (string, *utils.APIError) {
+	data, err := s.Storage.Download(c, upload.URL)
+	if err != nil {
+		return "", &utils.APIError{
+			Message: "failed to read image",
+			Code:    http.StatusInternalServerError,
+		}
+	}
+
+	return s.storeSingleImage(c, data, width, upload.OwnerType)
+}
+
+func (s *Server) attachUserImage(c *gin.Context, upload *models.Upload, req confirmUploadReq) {
+	db := s.DB.Pool()
+	userRepo := s.DB.User()
+
+	user, err := userRepo.Get(c, db, int(req.TargetID))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	imageURL, apiErr := s.readUploadedImage(c, upload, avatarWidth)
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New(apiErr.Message))
+		return
+	}
+
+	oldImage := user.Image
+	user.Image = pgtype.Text{String: imageURL, Valid: true}
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := s.DB.Upload().Confirm(c, tx, upload.ID); err != nil {
+			return err
+		}
+		return userRepo.Update(c, tx, user)
+	})
+	if err != nil {
+		_ = s.Storage.Delete(c, imageURL)
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	_ = s.Storage.Delete(c, upload.URL)
+	if oldImage.Valid {
+		_ = s.Storage.Delete(c, oldImage.String)
+	}
+
+	utils.Success(c, user)
+}
+
+type reviewImageRes struct {
+	Image string `json:"image"`
+}
+
+func (s *Server) attachReviewImage(c *gin.Context, upload *models.Upload, req confirmUploadReq) {
+	db := s.DB.Pool()
+	reviewRepo := s.DB.RatingReview()
+
+	_, err := reviewRepo.Get(c, db, req.TargetID)
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	imageURL, apiErr := s.readUploadedImage(c, upload, reviewImageWidth)
+	if apiErr != nil {
+		utils.Fail(c, apiErr, errors.New(apiErr.Message))
+		return
+	}
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		if err := s.DB.Upload().Confirm(c, tx, upload.ID); err != nil {
+			return err
+		}
+		return reviewRepo.AddImage(c, tx, req.TargetID, imageURL)
+	})
+	if err != nil {
+		_ = s.Storage.Delete(c, imageURL)
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	_ = s.Storage.Delete(c, upload.URL)
+
+	utils.Created(c, reviewImageRes{Image: imageURL})
+}
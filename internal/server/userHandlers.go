@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
 )
 
@@ -87,18 +88,23 @@ func (s *Server) updateUser(c *gin.Context) {
 		return
 	}
 
+	var newImageURL string
+	if imageUpload != nil {
+		defer imageUpload.File.Close()
+
+		newImageURL, apiErr = s.uploadSingleImage(c, imageUpload.File, avatarWidth, models.UploadOwnerUser)
+		if apiErr != nil {
+			utils.Fail(c, apiErr, errors.New(apiErr.Message))
+			return
+		}
+	}
+
 	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
 		// if image exists do the following:
 		// delete old image if exists
-		if imageUpload != nil {
+		if newImageURL != "" {
 			if user.Image.Valid {
-				_ = s.S3.DeleteImageByURL(c, user.Image.String)
-			}
-			// upload new image
-			var newImageURL string
-			newImageURL, err = s.S3.UploadImage(c, imageUpload.File, imageUpload.Header)
-			if err != nil {
-				return err
+				_ = s.Storage.Delete(c, user.Image.String)
 			}
 
 			// update image in user struct
@@ -151,7 +157,7 @@ func (s *Server) deleteUser(c *gin.Context) {
 	}
 
 	if u.Image.Valid {
-		_ = s.S3.DeleteImageByURL(c, u.Image.String)
+		_ = s.Storage.Delete(c, u.Image.String)
 	}
 
 	utils.Success(c, nil)
@@ -1,17 +1,65 @@
 package server
 
 import (
+	"errors"
+	"net/http"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/validator"
 )
 
 type variantReq struct {
-	Quantity  int   `json:"quantity"  binding:"required"`
+	Quantity  int   `json:"quantity"  binding:"min=0"`
 	Price     int   `json:"price"     binding:"required"`
 	ColorID   int32 `json:"colorId"   binding:"required"`
 	SizeID    int32 `json:"sizeId"    binding:"required"`
 	ProductID int32 `json:"productId" binding:"required"`
+	// SKU is generated when it's left empty.
+	SKU     string  `json:"sku"`
+	Barcode *string `json:"barcode"`
+}
+
+// bindVariantCodes validates the sku and barcode of the request and sets them on the variant,
+// a nil barcode or empty sku is left null. It fails the request and returns false if one is invalid.
+func bindVariantCodes(c *gin.Context, pv *models.ProductVariant, sku string, barcode *string) bool {
+	v := validator.New()
+
+	if sku = strings.ToUpper(strings.TrimSpace(sku)); sku != "" {
+		v.Check(
+			validator.Matches(sku, validator.SKURX),
+			"sku",
+			"the sku has to be up to 64 letters, digits, dots, dashes or underscores",
+		)
+		v.Check(!validator.Matches(sku, validator.BarcodeRX), "sku", "the sku can't be shaped like a barcode")
+		pv.SKU = pgtype.Text{String: sku, Valid: true}
+	}
+
+	if barcode != nil {
+		code := strings.TrimSpace(*barcode)
+		v.Check(
+			code == "" || validator.Barcode(code),
+			"barcode",
+			"the barcode has to be an EAN-8, UPC-A or EAN-13 with a valid check digit",
+		)
+		pv.Barcode = pgtype.Text{String: validator.NormalizeBarcode(code), Valid: true}
+	}
+
+	if !v.Valid() {
+		utils.Fail(c, &utils.APIError{
+			Code:    http.StatusBadRequest,
+			Message: "invalid sku or barcode",
+			Errors:  v.Errors,
+		}, errors.New("invalid sku or barcode"))
+		return false
+	}
+
+	return true
 }
 
 func (s *Server) addVariant(c *gin.Context) {
@@ -22,9 +70,6 @@ func (s *Server) addVariant(c *gin.Context) {
 		return
 	}
 
-	db := s.DB.Pool()
-	variantRepo := s.DB.ProductVariant()
-
 	pv := models.ProductVariant{
 		Quantity:  req.Quantity,
 		Price:     req.Price,
@@ -32,8 +77,13 @@ func (s *Server) addVariant(c *gin.Context) {
 		SizeID:    req.SizeID,
 		ProductID: req.ProductID,
 	}
+	if !bindVariantCodes(c, &pv, req.SKU, req.Barcode) {
+		return
+	}
 
-	err = variantRepo.Create(c, db, &pv)
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		return s.createVariant(c, tx, &pv)
+	})
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(c, apiErr, err)
@@ -81,11 +131,60 @@ func (s *Server) deleteVariant(c *gin.Context) {
 	utils.NoContent(c)
 }
 
+func (s *Server) restoreVariant(c *gin.Context) {
+	variantID := convStrToInt(c, c.Param("id"), "variant id")
+	if variantID == 0 {
+		return
+	}
+
+	// the restored variant may bring the lowest price of its product down.
+	err := s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		variantRepo := s.DB.ProductVariant()
+
+		productID, err := variantRepo.GetProductID(c, tx, int32(variantID))
+		if err != nil {
+			return err
+		}
+
+		previousPrice, err := variantRepo.GetLowestPrice(c, tx, productID)
+		if err != nil {
+			return err
+		}
+
+		if err = variantRepo.Restore(c, tx, variantID); err != nil {
+			return err
+		}
+
+		return s.notifyPriceDrops(c, tx, productID, previousPrice)
+	})
+	if err != nil && database.IsDBForeignKeyErr(err) {
+		utils.Fail(
+			c,
+			utils.NewAPIError(
+				http.StatusConflict,
+				"can not restore this variant while its product, color or size is deleted",
+			),
+			err,
+		)
+		return
+	}
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "variant restored successfully")
+}
+
+// productVariantReq updates a variant, its quantity is changed with stock adjustments.
+// The sku and barcode are kept when they're left out, an empty barcode removes it.
 type productVariantReq struct {
-	Quantity int   `json:"quantity"`
-	Price    int   `json:"price"`
-	ColorID  int32 `json:"colorId"`
-	SizeID   int32 `json:"sizeId"`
+	Price   int     `json:"price"   binding:"required,min=1"`
+	ColorID int32   `json:"colorId" binding:"required,min=1"`
+	SizeID  int32   `json:"sizeId"  binding:"required,min=1"`
+	SKU     string  `json:"sku"`
+	Barcode *string `json:"barcode"`
 }
 
 func (s *Server) updateVariant(c *gin.Context) {
@@ -101,23 +200,113 @@ func (s *Server) updateVariant(c *gin.Context) {
 		return
 	}
 
+	pv := models.ProductVariant{
+		ID:      variantID,
+		Price:   req.Price,
+		ColorID: req.ColorID,
+		SizeID:  req.SizeID,
+	}
+	if !bindVariantCodes(c, &pv, req.SKU, req.Barcode) {
+		return
+	}
+
+	err = s.DB.WithTransaction(c, func(tx pgx.Tx) error {
+		variantRepo := s.DB.ProductVariant()
+
+		productID, err := variantRepo.GetProductID(c, tx, variantID)
+		if err != nil {
+			return err
+		}
+
+		previousPrice, err := variantRepo.GetLowestPrice(c, tx, productID)
+		if err != nil {
+			return err
+		}
+
+		if err = variantRepo.Update(c, tx, &pv); err != nil {
+			return err
+		}
+
+		return s.notifyPriceDrops(c, tx, productID, previousPrice)
+	})
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, "updated successfully")
+}
+
+// notifyPriceDrops notifies the wishlisters of the product whose lowest price dropped enough
+// from previousPrice, db should be the transaction that changed the price.
+func (s *Server) notifyPriceDrops(
+	c *gin.Context,
+	db database.Querier,
+	productID int32,
+	previousPrice int,
+) error {
+	drops, err := s.DB.Wishlist().ClaimPriceDrops(c, db, productID, previousPrice, s.Env.PriceDropPercent)
+	if err != nil {
+		return err
+	}
+
+	notifications := make([]models.Notification, 0, len(drops))
+	for _, d := range drops {
+		notifications = append(notifications, models.Notification{
+			Type:    models.NotificationPriceDrop,
+			Message: "A product on your wishlist dropped in price",
+			Data: map[string]any{
+				"price":         d.Price,
+				"previousPrice": d.PreviousPrice,
+			},
+			UserID:    d.UserID,
+			ProductID: pgtype.Int4{Int32: d.ProductID, Valid: true},
+		})
+	}
+
+	return s.DB.Notification().CreateMany(c, db, notifications)
+}
+
+func (s *Server) getVariantPriceHistory(c *gin.Context) {
+	variantID := convStrToInt(c, c.Param("id"), "variant id")
+	if variantID == 0 {
+		return
+	}
+
 	db := s.DB.Pool()
 	variantRepo := s.DB.ProductVariant()
 
-	pv := models.ProductVariant{
-		ID:       variantID,
-		Quantity: req.Quantity,
-		Price:    req.Price,
-		ColorID:  req.ColorID,
-		SizeID:   req.SizeID,
+	if _, err := variantRepo.GetProductID(c, db, int32(variantID)); err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
 	}
 
-	err = variantRepo.Update(c, db, &pv)
+	prices, err := variantRepo.GetPriceHistory(c, db, int32(variantID))
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(c, apiErr, err)
 		return
 	}
 
-	utils.Success(c, "updated successfully")
+	utils.Success(c, prices)
+}
+
+// lookupVariant finds a variant by the sku or barcode in ?code=, as scanned in the warehouse.
+func (s *Server) lookupVariant(c *gin.Context) {
+	code := strings.TrimSpace(c.Query("code"))
+	if code == "" {
+		utils.Fail(c, utils.NewAPIError(http.StatusBadRequest, "code is required"), errors.New("code is required"))
+		return
+	}
+
+	variant, err := s.DB.ProductVariant().GetByCode(c, s.DB.Pool(), validator.NormalizeBarcode(code))
+	if err != nil {
+		apiErr := utils.MapDBErrorToAPIError(err)
+		utils.Fail(c, apiErr, err)
+		return
+	}
+
+	utils.Success(c, variant)
 }
@@ -5,10 +5,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/models"
 	"github.com/refine-software/afrad-api/internal/utils"
+	"github.com/refine-software/afrad-api/internal/utils/filters"
 )
 
+type wishlistRes struct {
+	Metadata filters.Metadata        `json:"metadata"`
+	Wishlist []database.WishlistItem `json:"wishlist"`
+}
+
 func (s *Server) getWishlist(c *gin.Context) {
 	claims := auth.GetAccessClaims(c)
 	if claims == nil {
@@ -21,9 +28,14 @@ func (s *Server) getWishlist(c *gin.Context) {
 		return
 	}
 
+	f, ok := s.getPaginationFilters(c, "-created_at", []string{"created_at", "-created_at"})
+	if !ok {
+		return
+	}
+
 	db := s.DB.Pool()
 	wishlistRepo := s.DB.Wishlist()
-	ws, err := wishlistRepo.GetAllOfUser(c, db, int32(userID))
+	ws, metadata, err := wishlistRepo.GetAllOfUser(c, db, int32(userID), f)
 	if err != nil {
 		apiErr := utils.MapDBErrorToAPIError(err)
 		utils.Fail(c, apiErr, err)
@@ -35,7 +47,10 @@ func (s *Server) getWishlist(c *gin.Context) {
 		return
 	}
 
-	utils.Success(c, ws)
+	utils.Success(c, wishlistRes{
+		Metadata: metadata,
+		Wishlist: ws,
+	})
 }
 
 func (s *Server) addToWishlist(c *gin.Context) {
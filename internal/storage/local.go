@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	conf "github.com/refine-software/afrad-api/config"
+)
+
+// LocalStorage stores the files in a directory on the disk, the API serves them under
+// LocalRoute. It's meant for development and CI where there is no object store.
+type LocalStorage struct {
+	root      *os.Root
+	publicURL string
+	// legacyURLs are the bases the files were stored under before the public url changed.
+	legacyURLs []string
+
+	// secret signs the presigned upload urls.
+	secret []byte
+}
+
+// NewLocalStorage opens the LOCAL_STORAGE_DIR directory, creating it if needed.
+func NewLocalStorage(env *conf.Env) (*LocalStorage, error) {
+	if err := os.MkdirAll(env.LocalStorageDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// every access goes through the root, so a url can't reach outside the directory.
+	root, err := os.OpenRoot(env.LocalStorageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	publicURL := env.StoragePublicURL
+	if publicURL == "" {
+		publicURL = fmt.Sprintf("http://localhost:%d%s", env.Port, LocalRoute)
+	}
+
+	return &LocalStorage{
+		root:       root,
+		publicURL:  publicURL,
+		legacyURLs: env.StorageLegacyURLs,
+		secret:     []byte(env.HashSecret),
+	}, nil
+}
+
+// key extracts the key out of the file url
+func (s *LocalStorage) key(fileURL string) (string, error) {
+	return keyFromURL(fileURL, append([]string{s.publicURL}, s.legacyURLs...)...)
+}
+
+// NewURL generates the url of a new file of the storage directory
+func (s *LocalStorage) NewURL(ext string) string {
+	return joinURL(s.publicURL, newKey(ext))
+}
+
+// Upload writes the file to the storage directory
+func (s *LocalStorage) Upload(
+	ctx context.Context,
+	fileURL string,
+	data []byte,
+	contentType string,
+) error {
+	key, err := s.key(fileURL)
+	if err != nil {
+		return err
+	}
+
+	if err = s.root.MkdirAll(path.Dir(key), 0o755); err != nil {
+		return err
+	}
+
+	return s.root.WriteFile(key, data, 0o644)
+}
+
+// Download reads a file of the storage directory using its url
+func (s *LocalStorage) Download(ctx context.Context, fileURL string) ([]byte, error) {
+	key, err := s.key(fileURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.root.ReadFile(key)
+}
+
+// Delete removes a file of the storage directory using its url
+func (s *LocalStorage) Delete(ctx context.Context, fileURL string) error {
+	key, err := s.key(fileURL)
+	if err != nil {
+		return err
+	}
+
+	err = s.root.Remove(key)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Exists checks for a file of the storage directory using its url
+func (s *LocalStorage) Exists(ctx context.Context, fileURL string) (bool, error) {
+	key, err := keyFromURL(fileURL, s.publicURL)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.root.Stat(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Head reads the size and the first n bytes of a file of the storage directory using its url
+func (s *LocalStorage) Head(ctx context.Context, fileURL string, n int64) (FileHead, error) {
+	key, err := keyFromURL(fileURL, s.publicURL)
+	if err != nil {
+		return FileHead{}, err
+	}
+
+	f, err := s.root.Open(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return FileHead{}, ErrNotFound
+	}
+	if err != nil {
+		return FileHead{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return FileHead{}, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, n))
+	if err != nil {
+		return FileHead{}, err
+	}
+
+	return FileHead{Size: info.Size(), Data: data}, nil
+}
+
+// PresignUpload signs a PUT of a new file to the API, UploadHandler checks the
+// signature along with the content length and type before writing it.
+func (s *LocalStorage) PresignUpload(
+	ctx context.Context,
+	ext, contentType string,
+	size int64,
+	expires time.Duration,
+) (PresignedUpload, error) {
+	key := newKey(ext)
+	expiresAt := time.Now().Add(expires)
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", s.sign(key, contentType, size, expiresAt.Unix()))
+
+	fileURL := joinURL(s.publicURL, key)
+	return PresignedUpload{
+		URL:       fileURL,
+		UploadURL: fileURL + "?" + query.Encode(),
+		Method:    http.MethodPut,
+		Headers: map[string]string{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(size, 10),
+		},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *LocalStorage) sign(key, contentType string, size, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%d", key, contentType, size, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// UploadHandler receives the presigned uploads, it has to be served under LocalRoute
+// with the route stripped from the request path.
+func (s *LocalStorage) UploadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		query := r.URL.Query()
+
+		expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+		if err != nil || time.Now().Unix() > expires {
+			http.Error(w, "the upload url expired", http.StatusForbidden)
+			return
+		}
+
+		signature := s.sign(key, r.Header.Get("Content-Type"), r.ContentLength, expires)
+		if !hmac.Equal([]byte(signature), []byte(query.Get("signature"))) {
+			http.Error(w, "the upload doesn't match its signature", http.StatusForbidden)
+			return
+		}
+
+		data, err := io.ReadAll(io.LimitReader(r.Body, r.ContentLength+1))
+		if err != nil || int64(len(data)) != r.ContentLength {
+			http.Error(w, "the upload doesn't match its content length", http.StatusBadRequest)
+			return
+		}
+
+		if err = s.root.MkdirAll(path.Dir(key), 0o755); err == nil {
+			err = s.root.WriteFile(key, data, 0o644)
+		}
+		if err != nil {
+			http.Error(w, "couldn't store the file", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	conf "github.com/refine-software/afrad-api/config"
+)
+
+// S3Storage stores the files in an S3 bucket, S3_ENDPOINT points it at an S3 compatible
+// store like MinIO, which usually needs S3_USE_PATH_STYLE too.
+type S3Storage struct {
+	client     *s3.Client
+	presigner  *s3.PresignClient
+	bucketName string
+
+	// publicURL is the base of the stored files urls, bucketURL is the bucket's own url
+	// which is also accepted, like the legacyURLs the files were stored under before.
+	publicURL  string
+	bucketURL  string
+	legacyURLs []string
+}
+
+// NewS3Storage initializes a new S3 client and wraps it
+func NewS3Storage(env *conf.Env) (*S3Storage, error) {
+	awsCfg, err := config.LoadDefaultConfig(
+		context.TODO(),
+		config.WithRegion(env.S3Region),
+		config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(env.S3AccessKey, env.S3SecretAccessKey, ""),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if env.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(env.S3Endpoint)
+		}
+		o.UsePathStyle = env.S3UsePathStyle
+	})
+
+	bucketURL, err := s3BucketURL(env)
+	if err != nil {
+		return nil, err
+	}
+
+	publicURL := env.StoragePublicURL
+	if publicURL == "" {
+		publicURL = bucketURL
+	}
+
+	return &S3Storage{
+		client:     client,
+		presigner:  s3.NewPresignClient(client),
+		bucketName: env.S3Bucket,
+		publicURL:  publicURL,
+		bucketURL:  bucketURL,
+		legacyURLs: env.StorageLegacyURLs,
+	}, nil
+}
+
+// s3BucketURL returns the url the bucket objects are reachable at.
+func s3BucketURL(env *conf.Env) (string, error) {
+	if env.S3Endpoint == "" {
+		if env.S3UsePathStyle {
+			return fmt.Sprintf("https://s3.%s.amazonaws.com/%s", env.S3Region, env.S3Bucket), nil
+		}
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com", env.S3Bucket, env.S3Region), nil
+	}
+
+	endpoint, err := url.Parse(env.S3Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return "", fmt.Errorf("invalid S3 endpoint: %s", env.S3Endpoint)
+	}
+
+	if env.S3UsePathStyle {
+		return joinURL(endpoint.String(), env.S3Bucket), nil
+	}
+	return fmt.Sprintf("%s://%s.%s", endpoint.Scheme, env.S3Bucket, endpoint.Host), nil
+}
+
+// objectKey extracts the object key out of the file url
+func (s *S3Storage) objectKey(fileURL string) (string, error) {
+	bases := append([]string{s.publicURL, s.bucketURL}, s.legacyURLs...)
+	return keyFromURL(fileURL, bases...)
+}
+
+// NewURL generates the url of a new object of the S3 bucket
+func (s *S3Storage) NewURL(ext string) string {
+	return joinURL(s.publicURL, newKey(ext))
+}
+
+// Upload uploads an in memory file to the S3 bucket
+func (s *S3Storage) Upload(
+	ctx context.Context,
+	fileURL string,
+	data []byte,
+	contentType string,
+) error {
+	objectKey, err := s.objectKey(fileURL)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(objectKey),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// Download reads a file from the S3 bucket using its url
+func (s *S3Storage) Download(ctx context.Context, fileURL string) ([]byte, error) {
+	objectKey, err := s.objectKey(fileURL)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+// Delete removes a file from the S3 bucket using its url
+func (s *S3Storage) Delete(ctx context.Context, fileURL string) error {
+	objectKey, err := s.objectKey(fileURL)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+// Exists checks for a file in the S3 bucket using its url
+func (s *S3Storage) Exists(ctx context.Context, fileURL string) (bool, error) {
+	objectKey, err := s.objectKey(fileURL)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(objectKey),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to head object: %w", err)
+	}
+
+	return true, nil
+}
+
+// Head reads the size and the first n bytes of a file in the S3 bucket using its url
+func (s *S3Storage) Head(ctx context.Context, fileURL string, n int64) (FileHead, error) {
+	objectKey, err := s.objectKey(fileURL)
+	if err != nil {
+		return FileHead{}, err
+	}
+
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(objectKey),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return FileHead{}, ErrNotFound
+	}
+	if err != nil {
+		return FileHead{}, fmt.Errorf("failed to head object: %w", err)
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	if size == 0 || n <= 0 {
+		return FileHead{Size: size}, nil
+	}
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(objectKey),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", n-1)),
+	})
+	if err != nil {
+		return FileHead{}, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(out.Body, n))
+	if err != nil {
+		return FileHead{}, err
+	}
+
+	return FileHead{Size: size, Data: data}, nil
+}
+
+// PresignUpload presigns a PUT of a new object, the content length and type are
+// part of the signature so S3 rejects any other file.
+func (s *S3Storage) PresignUpload(
+	ctx context.Context,
+	ext, contentType string,
+	size int64,
+	expires time.Duration,
+) (PresignedUpload, error) {
+	objectKey := newKey(ext)
+
+	req, err := s.presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucketName),
+		Key:           aws.String(objectKey),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to presign upload: %w", err)
+	}
+
+	headers := make(map[string]string, len(req.SignedHeader))
+	for name, values := range req.SignedHeader {
+		// the client sets the host itself.
+		if strings.EqualFold(name, "Host") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+
+	return PresignedUpload{
+		URL:       joinURL(s.publicURL, objectKey),
+		UploadURL: req.URL,
+		Method:    req.Method,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
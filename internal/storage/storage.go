@@ -0,0 +1,115 @@
+// Package storage stores the uploaded files in an object store and hands out their public urls.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	conf "github.com/refine-software/afrad-api/config"
+)
+
+const (
+	DriverS3    = "s3"
+	DriverLocal = "local"
+
+	// LocalRoute is where the API serves the files of the local storage.
+	LocalRoute = "/uploads"
+)
+
+type Storage interface {
+	// NewURL generates the public url of a new file with the given extension,
+	// the file is only stored once it's uploaded to it.
+	NewURL(ext string) string
+
+	// Upload stores data at a url given by NewURL.
+	Upload(ctx context.Context, fileURL string, data []byte, contentType string) error
+
+	// Download reads the whole file at the given url.
+	Download(ctx context.Context, fileURL string) ([]byte, error)
+
+	// Delete removes the file at the given url, deleting a missing file isn't an error.
+	Delete(ctx context.Context, fileURL string) error
+
+	// Exists tells whether there is a file at the given url.
+	Exists(ctx context.Context, fileURL string) (bool, error)
+
+	// Head reads the size and the first n bytes of the file at the given url.
+	// Returns: ErrNotFound when there is no such file.
+	Head(ctx context.Context, fileURL string, n int64) (FileHead, error)
+
+	// PresignUpload lets a client upload a file of exactly size bytes and of the given
+	// content type straight to the storage, until expires passes.
+	PresignUpload(
+		ctx context.Context,
+		ext, contentType string,
+		size int64,
+		expires time.Duration,
+	) (PresignedUpload, error)
+}
+
+var ErrNotFound = errors.New("file not found")
+
+type FileHead struct {
+	Size int64
+	Data []byte
+}
+
+// PresignedUpload tells the client how to upload a file, every header has to be sent as is.
+type PresignedUpload struct {
+	// URL is where the file is once it's uploaded.
+	URL       string            `json:"url"`
+	UploadURL string            `json:"uploadUrl"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// New creates the storage selected by STORAGE_DRIVER.
+func New(env *conf.Env) (Storage, error) {
+	switch env.StorageDriver {
+	case DriverS3:
+		return NewS3Storage(env)
+	case DriverLocal:
+		return NewLocalStorage(env)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %q", env.StorageDriver)
+	}
+}
+
+// newKey generates the key of a new file, the random part keeps the files uploaded
+// at the same time apart.
+func newKey(ext string) string {
+	random := make([]byte, 16)
+	// never fails, it crashes the program instead.
+	_, _ = rand.Read(random)
+
+	return fmt.Sprintf("images/%d-%s%s", time.Now().Unix(), hex.EncodeToString(random), ext)
+}
+
+// joinURL joins the base url and the key with a single slash.
+func joinURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}
+
+// keyFromURL extracts the key out of a file url under one of the given base urls,
+// so the files stored before the public url changed can still be found.
+func keyFromURL(fileURL string, bases ...string) (string, error) {
+	for _, base := range bases {
+		base = strings.TrimSpace(base)
+		if base == "" {
+			continue
+		}
+
+		prefix := strings.TrimSuffix(base, "/") + "/"
+		if key, ok := strings.CutPrefix(fileURL, prefix); ok && key != "" {
+			return key, nil
+		}
+	}
+
+	return "", fmt.Errorf("url isn't in the storage: %s", fileURL)
+}
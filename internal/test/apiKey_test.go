@@ -0,0 +1,88 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	rawKey, prefix, err := auth.GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rawKey, "afrad_"+prefix+"_"))
+
+	parsedPrefix, ok := auth.ParseAPIKeyPrefix(rawKey)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, parsedPrefix)
+
+	otherKey, otherPrefix, err := auth.GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, rawKey, otherKey)
+	assert.NotEqual(t, prefix, otherPrefix)
+}
+
+func TestParseAPIKeyPrefixRejectsMalformedKeys(t *testing.T) {
+	keys := []string{
+		"",
+		"afrad",
+		"afrad_abc_def",
+		"other_0123456789ab_0123456789abcdef0123456789abcdef0123456789abcdef",
+		"afrad_0123456789ab_short",
+	}
+
+	for _, key := range keys {
+		_, ok := auth.ParseAPIKeyPrefix(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestAdminRoutesRequireAuthentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "No credentials",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "Authorization header missing",
+		},
+		{
+			name:       "Malformed api key",
+			headers:    map[string]string{"X-API-Key": "not-a-key"},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "invalid api key",
+		},
+		{
+			name: "Unknown api key",
+			headers: map[string]string{
+				"X-API-Key": "afrad_0123456789ab_0123456789abcdef0123456789abcdef0123456789abcdef",
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "invalid api key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupTestServer(t)
+
+			req, _ := http.NewRequest("POST", "/admin/colors", strings.NewReader(`{"color":"x"}`))
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantStatus, resp.Code, "status code mismatch")
+			assert.Contains(t, resp.Body.String(), tt.wantBody, "body mismatch")
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/utils/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBarcodeCheckDigit(t *testing.T) {
+	valid := []string{
+		"4006381333931", // EAN-13
+		"036000291452",  // UPC-A
+		"96385074",      // EAN-8
+	}
+	for _, code := range valid {
+		assert.True(t, validator.Barcode(code), code)
+	}
+
+	invalid := []string{
+		"4006381333932", // wrong check digit
+		"036000291453",
+		"96385075",
+		"40063813339",    // 11 digits
+		"400638133393A",  // not a digit
+		"04006381333931", // GTIN-14 isn't accepted
+		"",
+	}
+	for _, code := range invalid {
+		assert.False(t, validator.Barcode(code), code)
+	}
+}
+
+func TestNormalizeBarcode(t *testing.T) {
+	assert.Equal(t, "0036000291452", validator.NormalizeBarcode("036000291452"), "a UPC-A gets a leading 0")
+	assert.Equal(t, "4006381333931", validator.NormalizeBarcode("4006381333931"))
+	assert.Equal(t, "96385074", validator.NormalizeBarcode("96385074"))
+	assert.Equal(t, "P12-BLA-42EU", validator.NormalizeBarcode("P12-BLA-42EU"))
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/refine-software/afrad-api/internal/utils/catalogsheet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postImport sends the rows to the import endpoint as a CSV sheet.
+func postImport(
+	t *testing.T,
+	router http.Handler,
+	authorization string,
+	rows []catalogsheet.Row,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var sheet bytes.Buffer
+	require.NoError(t, catalogsheet.Write(&sheet, catalogsheet.FormatCSV, rows))
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "products.csv")
+	require.NoError(t, err)
+	_, err = part.Write(sheet.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/products/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", authorization)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestImportQueuesImages(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	c := testContext()
+	db := testService.Pool()
+
+	categoryID := createTestCategory(t, 0)
+	var category string
+	require.NoError(t, db.QueryRow(c, `SELECT name FROM categories WHERE id = $1`, categoryID).Scan(&category))
+
+	seq := fixtureSeq.Add(1)
+	color := fixtureName("Color")
+	createTestColor(t, color)
+	size := fmt.Sprintf("%d", seq)
+	createTestSize(t, size, "EU")
+
+	name := fixtureName("Imported")
+	thumbnail := fmt.Sprintf("https://cdn.example.com/%d/thumbnail.jpg", seq)
+	gallery := fmt.Sprintf("https://cdn.example.com/%d/gallery.jpg", seq)
+	resp := postImport(t, router, admin, []catalogsheet.Row{{
+		Name:      name,
+		Brand:     fixtureName("Brand"),
+		Category:  category,
+		Color:     color,
+		Size:      size,
+		Price:     "25000",
+		Quantity:  "3",
+		Thumbnail: thumbnail,
+		Images:    catalogsheet.JoinImages([]string{gallery}),
+	}})
+	require.Equal(t, http.StatusAccepted, resp.Code, resp.Body.String())
+
+	report := decodeBody[struct {
+		Applied  bool `json:"applied"`
+		Products int  `json:"products"`
+		Images   int  `json:"images"`
+	}](t, resp)
+	assert.True(t, report.Applied)
+	assert.Equal(t, 1, report.Products)
+	assert.Equal(t, 2, report.Images)
+
+	// the product shows the thumbnail of the sheet until the worker processes its image.
+	var productID int32
+	var productThumbnail string
+	err := db.QueryRow(c, `SELECT id, thumbnail FROM products WHERE name = $1`, name).
+		Scan(&productID, &productThumbnail)
+	require.NoError(t, err)
+	assert.Equal(t, thumbnail, productThumbnail)
+
+	rows, err := db.Query(
+		c,
+		`SELECT i.source_url, i.status, j.id IS NOT NULL
+		FROM images i
+		LEFT JOIN image_jobs j ON j.image_id = i.id
+		WHERE i.product_id = $1
+		ORDER BY i.position`,
+		productID,
+	)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var sources []string
+	for rows.Next() {
+		var (
+			source string
+			status string
+			queued bool
+		)
+		require.NoError(t, rows.Scan(&source, &status, &queued))
+		assert.Equal(t, string(models.ImageProcessing), status)
+		assert.True(t, queued, source)
+		sources = append(sources, source)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{thumbnail, gallery}, sources)
+}
+
+func TestImportedThumbnailReplacedWhenReady(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	imageRepo := testService.Image()
+
+	productID := createTestProduct(t)
+	source := fmt.Sprintf("https://cdn.example.com/%d/thumbnail.jpg", fixtureSeq.Add(1))
+	_, err := db.Exec(c, `UPDATE products SET thumbnail = $2 WHERE id = $1`, productID, source)
+	require.NoError(t, err)
+
+	img := models.Image{
+		SourceURL: pgtype.Text{String: source, Valid: true},
+		ProductID: productID,
+	}
+	require.NoError(t, imageRepo.Create(c, db, &img))
+
+	original := fmt.Sprintf("https://mock-bucket/original%d.jpg", fixtureSeq.Add(1))
+	require.NoError(t, imageRepo.SetOriginal(c, db, img.ID, original))
+
+	img.Image = fmt.Sprintf("https://mock-bucket/large%d.webp", fixtureSeq.Add(1))
+	img.LowResImage = fmt.Sprintf("https://mock-bucket/small%d.webp", fixtureSeq.Add(1))
+	img.Srcset = []models.ImageRendition{{URL: img.LowResImage, Width: 320, Height: 320}}
+	require.NoError(t, imageRepo.CompleteProcessing(c, db, &img))
+
+	assert.Equal(t, img.Image, productThumbnail(t, productID))
+}
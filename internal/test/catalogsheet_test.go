@@ -0,0 +1,86 @@
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/utils/catalogsheet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogSheetRoundTrip(t *testing.T) {
+	rows := []catalogsheet.Row{
+		{
+			Name:      "Runner",
+			Details:   "Light, breathable",
+			Brand:     "Acme",
+			Category:  "Men/Shoes",
+			Status:    "published",
+			Color:     "Black",
+			Size:      "42",
+			Price:     "25000",
+			Quantity:  "3",
+			SKU:       "RUN-BLK-42",
+			Barcode:   "036000291452",
+			Thumbnail: "https://cdn.example.com/runner.webp",
+			Images:    catalogsheet.JoinImages([]string{"https://cdn.example.com/1.jpg", "https://cdn.example.com/2.jpg"}),
+		},
+		{Name: "Runner", Color: "White", Size: "43", SizeLabel: "EU", Price: "25000", Quantity: "0"},
+	}
+
+	for _, format := range []string{catalogsheet.FormatCSV, catalogsheet.FormatXLSX} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, catalogsheet.Write(&buf, format, rows))
+
+			read, err := catalogsheet.Read(&buf, format)
+			require.NoError(t, err)
+			require.Len(t, read, 2)
+
+			for i := range rows {
+				want := rows[i]
+				want.Line = i + 2
+				assert.Equal(t, want, read[i])
+			}
+			assert.Len(t, catalogsheet.SplitImages(read[0].Images), 2)
+		})
+	}
+}
+
+func TestCatalogSheetRead(t *testing.T) {
+	t.Run("Columns in any order", func(t *testing.T) {
+		sheet := "\ufeffPrice,name,brand,category,color,size,quantity\n" +
+			"100,Cap,Acme,Hats,Red,M,5\n" +
+			",,,,,,\n" +
+			"120,Cap,,,Blue,M,2\n"
+
+		rows, err := catalogsheet.Read(strings.NewReader(sheet), catalogsheet.FormatCSV)
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "100", rows[0].Price)
+		assert.Equal(t, "Cap", rows[0].Name)
+		// the blank line is skipped, the lines still match the sheet.
+		assert.Equal(t, 4, rows[1].Line)
+	})
+
+	t.Run("Missing column", func(t *testing.T) {
+		sheet := "name,brand,category,color,size,quantity\nCap,Acme,Hats,Red,M,5\n"
+
+		_, err := catalogsheet.Read(strings.NewReader(sheet), catalogsheet.FormatCSV)
+		assert.ErrorContains(t, err, `"price"`)
+	})
+
+	t.Run("No rows", func(t *testing.T) {
+		sheet := "name,brand,category,color,size,price,quantity\n"
+
+		_, err := catalogsheet.Read(strings.NewReader(sheet), catalogsheet.FormatCSV)
+		assert.ErrorIs(t, err, catalogsheet.ErrEmptySheet)
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := catalogsheet.FormatOf("products.ods")
+		assert.ErrorIs(t, err, catalogsheet.ErrUnknownFormat)
+	})
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestCategory creates a category under the parent, a root one when parentID is 0.
+func createTestCategory(t *testing.T, parentID int32) int32 {
+	t.Helper()
+
+	id, err := testService.Category().Create(testContext(), testService.Pool(), &models.Category{
+		Name:     fixtureName("Category"),
+		ParentID: pgtype.Int4{Int32: parentID, Valid: parentID != 0},
+	})
+	require.NoError(t, err)
+
+	return id
+}
+
+func moveTestCategory(router http.Handler, admin string, id, parentID int32) int {
+	path := fmt.Sprintf("/admin/category/%d/parent", id)
+	return doRequest(router, http.MethodPatch, path, fmt.Sprintf(`{"parentId":%d}`, parentID), admin).Code
+}
+
+func TestMoveCategory(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+
+	root := createTestCategory(t, 0)
+	child := createTestCategory(t, root)
+	grandchild := createTestCategory(t, child)
+
+	// a category can't be moved under itself or one of its descendants.
+	assert.Equal(t, http.StatusBadRequest, moveTestCategory(router, admin, root, root))
+	assert.Equal(t, http.StatusBadRequest, moveTestCategory(router, admin, root, child))
+	assert.Equal(t, http.StatusBadRequest, moveTestCategory(router, admin, root, grandchild))
+
+	// once the grandchild is out of the subtree, the root can go under it.
+	assert.Equal(t, http.StatusOK, moveTestCategory(router, admin, grandchild, 0))
+	assert.Equal(t, http.StatusOK, moveTestCategory(router, admin, root, grandchild))
+
+	path, err := testService.Category().GetPath(testContext(), testService.Pool(), child)
+	require.NoError(t, err)
+
+	var ids []int32
+	for _, c := range path {
+		ids = append(ids, c.ID)
+	}
+	assert.Equal(t, []int32{grandchild, root, child}, ids)
+
+	assert.Equal(t, http.StatusNotFound, moveTestCategory(router, admin, 1<<30, 0))
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := filters.Cursor{Sort: "-price", Value: "15000", ID: 42}
+
+	token := filters.EncodeCursor(cursor, "secret")
+	decoded, err := filters.DecodeCursor(token, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, cursor, *decoded)
+
+	_, err = filters.DecodeCursor(token, "another secret")
+	assert.ErrorIs(t, err, filters.ErrInvalidCursor)
+
+	_, err = filters.DecodeCursor("x"+token, "secret")
+	assert.ErrorIs(t, err, filters.ErrInvalidCursor)
+}
+
+func TestPaginate(t *testing.T) {
+	keys := []filters.Keyset{{Value: "1", ID: 1}, {Value: "2", ID: 2}, {Value: "3", ID: 3}}
+
+	t.Run("First page with more rows", func(t *testing.T) {
+		f := filters.Filters{Page: 1, PageSize: 2, Sort: "id", CursorSecret: "secret"}
+
+		rows, metadata := filters.Paginate(f, []int{1, 2, 3}, keys, 3)
+		assert.Equal(t, []int{1, 2}, rows)
+		assert.Equal(t, 2, metadata.LastPage)
+		assert.Empty(t, metadata.PrevCursor)
+
+		next, err := filters.DecodeCursor(metadata.NextCursor, "secret")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), next.ID)
+		assert.False(t, next.Prev)
+	})
+
+	t.Run("Backward page is put back in order", func(t *testing.T) {
+		f := filters.Filters{
+			PageSize:     2,
+			Sort:         "id",
+			CursorSecret: "secret",
+			Cursor:       &filters.Cursor{Sort: "id", Value: "4", ID: 4, Prev: true},
+		}
+
+		// read backwards the rows come last first.
+		rows, metadata := filters.Paginate(
+			f,
+			[]int{3, 2, 1},
+			[]filters.Keyset{keys[2], keys[1], keys[0]},
+			0,
+		)
+		assert.Equal(t, []int{2, 3}, rows)
+		assert.Zero(t, metadata.TotalRecords)
+
+		prev, err := filters.DecodeCursor(metadata.PrevCursor, "secret")
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), prev.ID)
+		assert.True(t, prev.Prev)
+
+		next, err := filters.DecodeCursor(metadata.NextCursor, "secret")
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), next.ID)
+	})
+}
+
+func TestCursorCondition(t *testing.T) {
+	f := filters.Filters{
+		Sort:         "-price",
+		SortSafeList: []string{"price", "-price"},
+		Cursor:       &filters.Cursor{Sort: "-price", Value: "100", ID: 7},
+	}
+
+	condition, args := f.CursorCondition("id", 5)
+	assert.Equal(t, "(price, id) < ($5, $6)", condition)
+	assert.Equal(t, []any{"100", int32(7)}, args)
+	assert.Equal(t, "price DESC, id DESC", f.OrderBy("id"))
+
+	f.Cursor.Prev = true
+	condition, _ = f.CursorCondition("id", 5)
+	assert.Equal(t, "(price, id) > ($5, $6)", condition)
+	assert.Equal(t, "price ASC, id ASC", f.OrderBy("id"))
+}
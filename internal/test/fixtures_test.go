@@ -0,0 +1,303 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/refine-software/afrad-api/config"
+	"github.com/refine-software/afrad-api/internal/auth"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureSeq numbers the names of the fixtures, the tables are only truncated once for all the tests.
+var fixtureSeq atomic.Int64
+
+func fixtureName(prefix string) string {
+	return fmt.Sprintf("%s %d", prefix, fixtureSeq.Add(1))
+}
+
+// testContext is a request context to call the repositories with directly.
+func testContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	return c
+}
+
+// bearerToken is the authorization header of a user with the given role.
+func bearerToken(t *testing.T, userID int32, role string) string {
+	t.Helper()
+
+	env := config.NewTestEnv()
+	token, err := auth.GenerateAccessToken(
+		strconv.Itoa(int(userID)),
+		role,
+		env.AccessTokenSecret,
+		env.AccessTokenExpInMin,
+	)
+	require.NoError(t, err)
+
+	return "Bearer " + token
+}
+
+// doRequest sends a JSON request to the router, authorization is left out when it's empty.
+func doRequest(
+	router http.Handler,
+	method, path, body, authorization string,
+) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = bytes.NewBufferString(body)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+// decodeBody decodes the JSON body of the response.
+func decodeBody[T any](t *testing.T, resp *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var body T
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body), resp.Body.String())
+	return body
+}
+
+// countNotifications counts the notifications of the type the user got.
+func countNotifications(t *testing.T, userID int32, notificationType models.NotificationType) int {
+	t.Helper()
+
+	var count int
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND type = $2`,
+		userID,
+		notificationType,
+	).Scan(&count)
+	require.NoError(t, err)
+
+	return count
+}
+
+func createTestUser(t *testing.T, role models.Role) int32 {
+	t.Helper()
+
+	var id int32
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`INSERT INTO users (first_name, email, role) VALUES ('Test', $1, $2) RETURNING id`,
+		fmt.Sprintf("user%d@example.com", fixtureSeq.Add(1)),
+		role,
+	).Scan(&id)
+	require.NoError(t, err)
+
+	return id
+}
+
+// createTestProduct creates a published product of a brand and category of its own.
+func createTestProduct(t *testing.T) int32 {
+	t.Helper()
+
+	c := testContext()
+	db := testService.Pool()
+
+	var brandID int32
+	err := db.QueryRow(c, `INSERT INTO brands (brand) VALUES ($1) RETURNING id`, fixtureName("Brand")).
+		Scan(&brandID)
+	require.NoError(t, err)
+
+	categoryID, err := testService.Category().Create(c, db, &models.Category{Name: fixtureName("Category")})
+	require.NoError(t, err)
+
+	var id int32
+	err = db.QueryRow(
+		c,
+		`INSERT INTO products (name, thumbnail, brand_id, product_category, status, published_at)
+		VALUES ($1, $2, $3, $4, 'published', NOW())
+		RETURNING id`,
+		fixtureName("Product"),
+		fmt.Sprintf("https://mock-bucket/thumbnail%d.webp", fixtureSeq.Add(1)),
+		brandID,
+		categoryID,
+	).Scan(&id)
+	require.NoError(t, err)
+
+	return id
+}
+
+func createTestColor(t *testing.T, color string) int32 {
+	t.Helper()
+
+	var id int32
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`INSERT INTO colors (color) VALUES ($1) RETURNING id`,
+		color,
+	).Scan(&id)
+	require.NoError(t, err)
+
+	return id
+}
+
+func createTestSize(t *testing.T, size, label string) int32 {
+	t.Helper()
+
+	var id int32
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`INSERT INTO sizes (size, label) VALUES ($1, $2) RETURNING id`,
+		size,
+		label,
+	).Scan(&id)
+	require.NoError(t, err)
+
+	return id
+}
+
+// createTestVariant creates a variant of the product in a color and size of its own.
+func createTestVariant(t *testing.T, productID int32, price, quantity int) int32 {
+	t.Helper()
+
+	c := testContext()
+	pv := models.ProductVariant{
+		Price:     price,
+		ProductID: productID,
+		ColorID:   createTestColor(t, fixtureName("Color")),
+		SizeID:    createTestSize(t, strconv.FormatInt(fixtureSeq.Add(1), 10), "EU"),
+	}
+	require.NoError(t, testService.ProductVariant().Create(c, testService.Pool(), &pv))
+
+	setTestQuantity(t, pv.ID, quantity)
+	return pv.ID
+}
+
+// setTestQuantity sets the quantity of the variant without a stock movement.
+func setTestQuantity(t *testing.T, variantID int32, quantity int) {
+	t.Helper()
+
+	_, err := testService.Pool().Exec(
+		testContext(),
+		`UPDATE product_variants SET quantity = $2 WHERE id = $1`,
+		variantID,
+		quantity,
+	)
+	require.NoError(t, err)
+}
+
+func createTestCity(t *testing.T) int32 {
+	t.Helper()
+
+	var id int32
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`INSERT INTO cities (city) VALUES ($1) RETURNING id`,
+		fixtureName("City"),
+	).Scan(&id)
+	require.NoError(t, err)
+
+	return id
+}
+
+// variantQuantity reads the quantity of the variant.
+func variantQuantity(t *testing.T, variantID int32) int {
+	t.Helper()
+
+	var quantity int
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT quantity FROM product_variants WHERE id = $1`,
+		variantID,
+	).Scan(&quantity)
+	require.NoError(t, err)
+
+	return quantity
+}
+
+// createTestOrder places a delivered order of the variant for the user,
+// its line is a snapshot of the variant like at checkout.
+func createTestOrder(t *testing.T, userID, variantID int32, quantity int) int32 {
+	t.Helper()
+
+	c := testContext()
+	db := testService.Pool()
+
+	orderID, err := testService.Order().Create(c, db, &models.Order{
+		Name:        "Test",
+		CityID:      createTestCity(t),
+		Town:        "Town",
+		Street:      "Street",
+		Address:     "Address",
+		PhoneNumber: "07700000000",
+		UserID:      userID,
+	})
+	require.NoError(t, err)
+
+	_, err = db.Exec(
+		c,
+		`INSERT INTO order_details (
+			product_id, quantity, total_price, order_id,
+			product_name, brand, color, size, thumbnail, unit_price
+		)
+		SELECT
+			pv.id, $3, pv.price * $3, $2,
+			p.name, b.brand, c.color, s.size || ' (' || s.label || ')', p.thumbnail, pv.price
+		FROM product_variants pv
+		JOIN products p ON p.id = pv.product_id
+		JOIN brands b ON b.id = p.brand_id
+		JOIN colors c ON c.id = pv.color_id
+		JOIN sizes s ON s.id = pv.size_id
+		WHERE pv.id = $1`,
+		variantID,
+		orderID,
+		quantity,
+	)
+	require.NoError(t, err)
+
+	_, err = db.Exec(c, `UPDATE orders SET order_status = 'delivered' WHERE id = $1`, orderID)
+	require.NoError(t, err)
+
+	return orderID
+}
+
+// createTestImage adds a ready image to the end of the product's gallery
+// and records it in the uploads ledger.
+func createTestImage(t *testing.T, productID int32) (int32, string) {
+	t.Helper()
+
+	c := testContext()
+	db := testService.Pool()
+	seq := fixtureSeq.Add(1)
+	url := fmt.Sprintf("https://mock-bucket/gallery%d.webp", seq)
+	lowResURL := fmt.Sprintf("https://mock-bucket/gallery%d-low.webp", seq)
+
+	var id int32
+	err := db.QueryRow(
+		c,
+		`INSERT INTO images (image, low_res_image, product_id, status, position)
+		VALUES ($1, $2, $3, 'ready', (SELECT COUNT(*) FROM images WHERE product_id = $3))
+		RETURNING id`,
+		url,
+		lowResURL,
+		productID,
+	).Scan(&id)
+	require.NoError(t, err)
+	require.NoError(t, testService.Upload().Create(c, db, url, models.UploadOwnerImage))
+	require.NoError(t, testService.Upload().Create(c, db, lowResURL, models.UploadOwnerImage))
+
+	return id, url
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMaxAttempts = 2
+
+// createTestImageJob queues the processing of a new image of the product.
+func createTestImageJob(t *testing.T, productID int32) int32 {
+	t.Helper()
+
+	c := testContext()
+	db := testService.Pool()
+
+	var imageID int32
+	err := db.QueryRow(
+		c,
+		`INSERT INTO images (original, product_id, status) VALUES ($1, $2, 'processing') RETURNING id`,
+		fixtureName("https://mock-bucket/original")+".jpg",
+		productID,
+	).Scan(&imageID)
+	require.NoError(t, err)
+	require.NoError(t, testService.ImageJob().Create(c, db, imageID))
+
+	return imageID
+}
+
+func imageStatus(t *testing.T, imageID int32) string {
+	t.Helper()
+
+	var status string
+	err := testService.Pool().QueryRow(testContext(), `SELECT status FROM images WHERE id = $1`, imageID).
+		Scan(&status)
+	require.NoError(t, err)
+	return status
+}
+
+// makeJobDue lets the job of the image run again right away, as if its backoff
+// or the lock of a crashed worker passed.
+func makeJobDue(t *testing.T, imageID int32) {
+	t.Helper()
+
+	_, err := testService.Pool().Exec(
+		testContext(),
+		`UPDATE image_jobs SET run_at = NOW() - INTERVAL '1 minute', locked_until = NOW() - INTERVAL '1 minute'
+		WHERE image_id = $1 AND run_at IS NOT NULL`,
+		imageID,
+	)
+	require.NoError(t, err)
+}
+
+func TestImageJobRetriesThenFails(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	jobRepo := testService.ImageJob()
+	imageID := createTestImageJob(t, createTestProduct(t))
+
+	job, err := jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+	require.NoError(t, err)
+	require.Equal(t, imageID, job.ImageID)
+	assert.Equal(t, 1, job.Attempts)
+
+	// a locked job isn't claimed twice.
+	_, err = jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+	assert.True(t, database.IsDBNotFoundErr(err))
+
+	failed, err := jobRepo.Fail(c, db, job.ID, "first error", testMaxAttempts)
+	require.NoError(t, err)
+	assert.False(t, failed, "the job is retried")
+	assert.Equal(t, "processing", imageStatus(t, imageID))
+
+	// the retry waits for its backoff.
+	_, err = jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+	assert.True(t, database.IsDBNotFoundErr(err))
+
+	makeJobDue(t, imageID)
+	job, err = jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.Attempts)
+
+	failed, err = jobRepo.Fail(c, db, job.ID, "second error", testMaxAttempts)
+	require.NoError(t, err)
+	assert.True(t, failed, "the job gave up")
+	assert.Equal(t, "failed", imageStatus(t, imageID))
+
+	makeJobDue(t, imageID)
+	_, err = jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+	assert.True(t, database.IsDBNotFoundErr(err))
+}
+
+func TestImageJobOfCrashedWorkerFails(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	jobRepo := testService.ImageJob()
+	productID := createTestProduct(t)
+	imageID := createTestImageJob(t, productID)
+
+	// the worker crashes on every attempt, so it never reports back.
+	for attempt := 1; attempt <= testMaxAttempts; attempt++ {
+		job, err := jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+		require.NoError(t, err)
+		require.Equal(t, attempt, job.Attempts)
+
+		exhausted, err := jobRepo.FailExhausted(c, db, testMaxAttempts)
+		require.NoError(t, err)
+		assert.Zero(t, exhausted, "a locked job is still being processed")
+
+		makeJobDue(t, imageID)
+	}
+
+	_, err := jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+	assert.True(t, database.IsDBNotFoundErr(err), "the job used up its attempts")
+
+	exhausted, err := jobRepo.FailExhausted(c, db, testMaxAttempts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), exhausted)
+	assert.Equal(t, "failed", imageStatus(t, imageID))
+
+	// a failed image is processed again once it's retried.
+	require.NoError(t, jobRepo.Retry(c, db, productID, imageID))
+	assert.Equal(t, "processing", imageStatus(t, imageID))
+
+	job, err := jobRepo.Claim(c, db, time.Minute, testMaxAttempts)
+	require.NoError(t, err)
+	assert.Equal(t, imageID, job.ImageID)
+	assert.Equal(t, 1, job.Attempts)
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/utils/imageproc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.NRGBA{R: 200, G: uint8(y % 256), B: 40, A: 255})
+		}
+	}
+	return img
+}
+
+func TestSniffImage(t *testing.T) {
+	var pngBuf bytes.Buffer
+	require.NoError(t, png.Encode(&pngBuf, testImage(4, 4)))
+
+	contentType, ok := imageproc.Sniff(pngBuf.Bytes())
+	assert.True(t, ok)
+	assert.Equal(t, "image/png", contentType)
+
+	// a script named like an image with an image Content-Type is still rejected.
+	_, ok = imageproc.Sniff([]byte("<script>alert(1)</script>"))
+	assert.False(t, ok)
+}
+
+func TestProcessImage(t *testing.T) {
+	var jpegBuf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpegBuf, testImage(600, 300), nil))
+
+	res, err := imageproc.Process(jpegBuf.Bytes(), []int{1600, 160, 480}, 80)
+	require.NoError(t, err)
+
+	// the 1600px rendition is skipped since the image is only 600px wide.
+	require.Len(t, res.Renditions, 2)
+	assert.Equal(t, 160, res.Renditions[0].Width)
+	assert.Equal(t, 80, res.Renditions[0].Height)
+	assert.Equal(t, 480, res.Renditions[1].Width)
+
+	for _, r := range res.Renditions {
+		contentType, _ := imageproc.Sniff(r.Data)
+		assert.Equal(t, imageproc.ContentType, contentType)
+	}
+
+	assert.NotEmpty(t, res.Placeholder.Blurhash)
+	assert.Regexp(t, `^#[0-9a-f]{6}$`, res.Placeholder.DominantColor)
+
+	// an image narrower than all the widths keeps its own width.
+	res, err = imageproc.Process(jpegBuf.Bytes(), []int{1600}, 80)
+	require.NoError(t, err)
+	require.Len(t, res.Renditions, 1)
+	assert.Equal(t, 600, res.Renditions[0].Width)
+}
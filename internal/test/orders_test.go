@@ -0,0 +1,57 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderBody struct {
+	Order models.Order          `json:"order"`
+	Lines []models.OrderDetails `json:"lines"`
+}
+
+func TestOrderSnapshotOutlivesCatalogChanges(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	userID := createTestUser(t, models.RoleUser)
+	user := bearerToken(t, userID, string(models.RoleUser))
+
+	productID := createTestProduct(t)
+	variantID := createTestVariant(t, productID, 15000, 5)
+	orderID := createTestOrder(t, userID, variantID, 2)
+	orderPath := fmt.Sprintf("/orders/%d", orderID)
+
+	resp := doRequest(router, http.MethodGet, orderPath, "", user)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	placed := decodeBody[orderBody](t, resp)
+	require.Len(t, placed.Lines, 1)
+	assert.Equal(t, 15000, placed.Lines[0].UnitPrice)
+	assert.Equal(t, 30000, placed.Lines[0].TotalPrice)
+
+	// renaming the product leaves the line as it was bought.
+	productPath := fmt.Sprintf("/admin/products/%d", productID)
+	body := fmt.Sprintf(`{"name":%q}`, fixtureName("Renamed Product"))
+	resp = doRequest(router, http.MethodPut, productPath, body, admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	resp = doRequest(router, http.MethodGet, orderPath, "", user)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, placed.Lines, decodeBody[orderBody](t, resp).Lines)
+
+	// so does deleting it, only the link to the variant may be gone.
+	resp = doRequest(router, http.MethodDelete, productPath, "", admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	resp = doRequest(router, http.MethodGet, orderPath, "", user)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	lines := decodeBody[orderBody](t, resp).Lines
+	require.Len(t, lines, 1)
+
+	placed.Lines[0].ProductID = lines[0].ProductID
+	assert.Equal(t, placed.Lines, lines)
+}
@@ -0,0 +1,211 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateTestPrice sets the price of the variant through the admin endpoint,
+// keeping its color and size.
+func updateTestPrice(t *testing.T, router http.Handler, admin string, variantID int32, price int) {
+	t.Helper()
+
+	var colorID, sizeID int32
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT color_id, size_id FROM product_variants WHERE id = $1`,
+		variantID,
+	).Scan(&colorID, &sizeID)
+	require.NoError(t, err)
+
+	path := fmt.Sprintf("/admin/products/variants/%d", variantID)
+	body := fmt.Sprintf(`{"price":%d,"colorId":%d,"sizeId":%d}`, price, colorID, sizeID)
+	resp := doRequest(router, http.MethodPut, path, body, admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
+
+// addTestVariant adds a variant of the product in a color and size of its own
+// through the admin endpoint.
+func addTestVariant(t *testing.T, router http.Handler, admin string, productID int32, price int) {
+	t.Helper()
+
+	body := fmt.Sprintf(
+		`{"quantity":1,"price":%d,"colorId":%d,"sizeId":%d,"productId":%d}`,
+		price,
+		createTestColor(t, fixtureName("Color")),
+		createTestSize(t, strconv.FormatInt(fixtureSeq.Add(1), 10), "EU"),
+		productID,
+	)
+	resp := doRequest(router, http.MethodPost, "/admin/products/variants", body, admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
+
+func wishlistTestProduct(t *testing.T, router http.Handler, user string, productID int32) {
+	t.Helper()
+
+	resp := doRequest(router, http.MethodPost, fmt.Sprintf("/wishlist/%d", productID), "", user)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
+
+func TestVariantPriceHistory(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	variantID := createTestVariant(t, createTestProduct(t), 1000, 1)
+
+	updateTestPrice(t, router, admin, variantID, 800)
+	// an update that keeps the price doesn't add to the history.
+	updateTestPrice(t, router, admin, variantID, 800)
+
+	path := fmt.Sprintf("/admin/products/variants/%d/prices", variantID)
+	resp := doRequest(router, http.MethodGet, path, "", admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	prices := decodeBody[[]models.VariantPrice](t, resp)
+	require.Len(t, prices, 2)
+	assert.Equal(t, 800, prices[0].Price)
+	assert.Equal(t, 1000, prices[1].Price)
+}
+
+func TestLowestPrice30Days(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	productID := createTestProduct(t)
+	variantID := createTestVariant(t, productID, 900, 1)
+
+	// 300 was replaced before the window started, 600 was still the price when it started.
+	_, err := db.Exec(c, `
+		UPDATE variant_prices SET created_at = NOW() - INTERVAL '1 day' WHERE variant_id = $1
+	`, variantID)
+	require.NoError(t, err)
+	_, err = db.Exec(c, `
+		INSERT INTO variant_prices (price, variant_id, created_at) VALUES
+			(300, $1, NOW() - INTERVAL '50 days'),
+			(600, $1, NOW() - INTERVAL '40 days'),
+			(700, $1, NOW() - INTERVAL '10 days')
+	`, variantID)
+	require.NoError(t, err)
+
+	details, err := testService.Product().GetDetails(c, db, int(productID), true)
+	require.NoError(t, err)
+	require.True(t, details.LowestPrice30Days.Valid)
+	assert.EqualValues(t, 600, details.LowestPrice30Days.Int32)
+
+	// a cheaper variant of the product counts too.
+	createTestVariant(t, productID, 550, 1)
+	details, err = testService.Product().GetDetails(c, db, int(productID), true)
+	require.NoError(t, err)
+	assert.EqualValues(t, 550, details.LowestPrice30Days.Int32)
+}
+
+func TestPriceDropNotifications(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	userID := createTestUser(t, models.RoleUser)
+	user := bearerToken(t, userID, string(models.RoleUser))
+
+	productID := createTestProduct(t)
+	variantID := createTestVariant(t, productID, 1000, 1)
+	wishlistTestProduct(t, router, user, productID)
+
+	// below the price it was wishlisted at.
+	updateTestPrice(t, router, admin, variantID, 990)
+	assert.Equal(t, 1, countNotifications(t, userID, models.NotificationPriceDrop))
+
+	// a rise, then a drop that stays above the price last notified of and is under 10%.
+	updateTestPrice(t, router, admin, variantID, 1100)
+	updateTestPrice(t, router, admin, variantID, 1050)
+	assert.Equal(t, 1, countNotifications(t, userID, models.NotificationPriceDrop))
+
+	// a cheaper variant added to the product.
+	addTestVariant(t, router, admin, productID, 800)
+	assert.Equal(t, 2, countNotifications(t, userID, models.NotificationPriceDrop))
+
+	// restoring the cheapest variant brings its price back.
+	var cheapestID int32
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT id FROM product_variants WHERE product_id = $1 AND price = 800`,
+		productID,
+	).Scan(&cheapestID)
+	require.NoError(t, err)
+
+	path := fmt.Sprintf("/admin/products/variants/%d", cheapestID)
+	resp := doRequest(router, http.MethodDelete, path, "", admin)
+	require.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	updateTestPrice(t, router, admin, variantID, 1200)
+
+	resp = doRequest(router, http.MethodPatch, path+"/restore", "", admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	// 800 isn't below the 800 last notified of, but it's a third off at once.
+	assert.Equal(t, 3, countNotifications(t, userID, models.NotificationPriceDrop))
+}
+
+func TestPriceDropOfProductWishlistedWithoutPrice(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	userID := createTestUser(t, models.RoleUser)
+	user := bearerToken(t, userID, string(models.RoleUser))
+
+	productID := createTestProduct(t)
+	wishlistTestProduct(t, router, user, productID)
+
+	// the first price isn't a drop, it's the one the wishlist starts from.
+	addTestVariant(t, router, admin, productID, 1000)
+	assert.Zero(t, countNotifications(t, userID, models.NotificationPriceDrop))
+
+	var addedPrice int
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT added_price FROM wishlists WHERE user_id = $1 AND product_id = $2`,
+		userID,
+		productID,
+	).Scan(&addedPrice)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, addedPrice)
+
+	var variantID int32
+	err = testService.Pool().QueryRow(
+		testContext(),
+		`SELECT id FROM product_variants WHERE product_id = $1`,
+		productID,
+	).Scan(&variantID)
+	require.NoError(t, err)
+
+	updateTestPrice(t, router, admin, variantID, 980)
+	assert.Equal(t, 1, countNotifications(t, userID, models.NotificationPriceDrop))
+}
+
+func TestPartialVariantUpdate(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	userID := createTestUser(t, models.RoleUser)
+	user := bearerToken(t, userID, string(models.RoleUser))
+
+	productID := createTestProduct(t)
+	variantID := createTestVariant(t, productID, 1000, 1)
+	wishlistTestProduct(t, router, user, productID)
+
+	// leaving out the color and size would zero them rather than keep them.
+	path := fmt.Sprintf("/admin/products/variants/%d", variantID)
+	resp := doRequest(router, http.MethodPut, path, `{"price":500}`, admin)
+	assert.Equal(t, http.StatusBadRequest, resp.Code, resp.Body.String())
+
+	resp = doRequest(router, http.MethodPut, path, `{"price":0,"colorId":1,"sizeId":1}`, admin)
+	assert.Equal(t, http.StatusBadRequest, resp.Code, resp.Body.String())
+
+	var price int
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT price FROM product_variants WHERE id = $1`,
+		variantID,
+	).Scan(&price)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, price)
+	assert.Zero(t, countNotifications(t, userID, models.NotificationPriceDrop))
+}
@@ -0,0 +1,44 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousIDHeader(t *testing.T) {
+	router := setupTestServer(t)
+	productID := createTestProduct(t)
+
+	viewProduct := func(anonymousID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/products/%d", productID), nil)
+		if anonymousID != "" {
+			req.Header.Set("X-Anonymous-ID", anonymousID)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		return resp
+	}
+
+	// a guest is given a signed id on its first view.
+	issued := viewProduct("").Header().Get("X-Anonymous-ID")
+	id, signature, found := strings.Cut(issued, ".")
+	require.True(t, found, issued)
+	assert.NotEmpty(t, id)
+	assert.NotEmpty(t, signature)
+
+	// the id is kept while the client sends it along.
+	assert.Empty(t, viewProduct(issued).Header().Get("X-Anonymous-ID"))
+
+	// an id the server didn't sign is replaced.
+	forged := viewProduct("another-guest." + signature).Header().Get("X-Anonymous-ID")
+	assert.NotEmpty(t, forged)
+	assert.NotEqual(t, issued, forged)
+	assert.NotEmpty(t, viewProduct(id).Header().Get("X-Anonymous-ID"))
+}
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func galleryOf(t *testing.T, productID int32) []int32 {
+	t.Helper()
+
+	imgs, err := testService.Image().GetAllOfProduct(testContext(), testService.Pool(), productID, false)
+	require.NoError(t, err)
+
+	ids := make([]int32, 0, len(imgs))
+	for _, img := range imgs {
+		ids = append(ids, img.ID)
+	}
+	return ids
+}
+
+func TestReorderProductImages(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+
+	productID := createTestProduct(t)
+	first, _ := createTestImage(t, productID)
+	second, _ := createTestImage(t, productID)
+	third, _ := createTestImage(t, productID)
+	path := fmt.Sprintf("/admin/products/%d/images/order", productID)
+
+	body := fmt.Sprintf(`{"imageIds":[%d,%d,%d]}`, third, first, second)
+	resp := doRequest(router, http.MethodPut, path, body, admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, []int32{third, first, second}, galleryOf(t, productID))
+
+	// every image has to be listed exactly once.
+	for _, body := range []string{
+		fmt.Sprintf(`{"imageIds":[%d,%d]}`, first, second),
+		fmt.Sprintf(`{"imageIds":[%d,%d,%d]}`, first, first, second),
+	} {
+		resp = doRequest(router, http.MethodPut, path, body, admin)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, body)
+	}
+	assert.Equal(t, []int32{third, first, second}, galleryOf(t, productID))
+}
+
+func TestSetProductThumbnail(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	c := testContext()
+	db := testService.Pool()
+
+	setThumbnail := func(productID, imageID int32) int {
+		path := fmt.Sprintf("/admin/products/%d/images/%d/thumbnail", productID, imageID)
+		return doRequest(router, http.MethodPatch, path, "", admin).Code
+	}
+
+	// the replaced thumbnail isn't part of the gallery, so its object is deleted.
+	productID := createTestProduct(t)
+	imageID, imageURL := createTestImage(t, productID)
+	oldThumbnail := productThumbnail(t, productID)
+
+	require.Equal(t, http.StatusOK, setThumbnail(productID, imageID))
+	p, err := testService.Product().Get(c, db, int(productID))
+	require.NoError(t, err)
+	assert.Equal(t, imageURL, p.Thumbnail)
+	assert.True(t, deletedFromStorage(oldThumbnail))
+
+	// a thumbnail an order still shows is kept.
+	orderedID := createTestProduct(t)
+	createTestOrder(t, createTestUser(t, models.RoleUser), createTestVariant(t, orderedID, 1000, 3), 1)
+	orderedImageID, _ := createTestImage(t, orderedID)
+	orderedThumbnail := productThumbnail(t, orderedID)
+
+	require.Equal(t, http.StatusOK, setThumbnail(orderedID, orderedImageID))
+	assert.False(t, deletedFromStorage(orderedThumbnail))
+
+	// an image of another product can't be its thumbnail.
+	assert.Equal(t, http.StatusNotFound, setThumbnail(productID, orderedImageID))
+}
+
+func TestDeleteProductImage(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	c := testContext()
+	db := testService.Pool()
+
+	productID := createTestProduct(t)
+	thumbnailID, _ := createTestImage(t, productID)
+	imageID, _ := createTestImage(t, productID)
+	img, err := testService.Image().Get(c, db, productID, imageID)
+	require.NoError(t, err)
+
+	resp := doRequest(
+		router,
+		http.MethodPatch,
+		fmt.Sprintf("/admin/products/%d/images/%d/thumbnail", productID, thumbnailID),
+		"",
+		admin,
+	)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	// the thumbnail can't be deleted.
+	path := fmt.Sprintf("/admin/products/%d/images/%d", productID, thumbnailID)
+	resp = doRequest(router, http.MethodDelete, path, "", admin)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	path = fmt.Sprintf("/admin/products/%d/images/%d", productID, imageID)
+	resp = doRequest(router, http.MethodDelete, path, "", admin)
+	require.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+	assert.Equal(t, []int32{thumbnailID}, galleryOf(t, productID))
+
+	// both the original and low resolution objects are deleted.
+	assert.True(t, deletedFromStorage(img.Image))
+	assert.True(t, deletedFromStorage(img.LowResImage))
+
+	resp = doRequest(router, http.MethodDelete, path, "", admin)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestDeleteOrderedProductImage(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	c := testContext()
+	db := testService.Pool()
+
+	// the image was the thumbnail when the order was placed.
+	productID := createTestProduct(t)
+	orderedID, _ := createTestImage(t, productID)
+	thumbnailID, _ := createTestImage(t, productID)
+	variantID := createTestVariant(t, productID, 1000, 3)
+
+	setThumbnail := func(imageID int32) {
+		path := fmt.Sprintf("/admin/products/%d/images/%d/thumbnail", productID, imageID)
+		resp := doRequest(router, http.MethodPatch, path, "", admin)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	}
+	setThumbnail(orderedID)
+	createTestOrder(t, createTestUser(t, models.RoleUser), variantID, 1)
+	setThumbnail(thumbnailID)
+
+	img, err := testService.Image().Get(c, db, productID, orderedID)
+	require.NoError(t, err)
+
+	path := fmt.Sprintf("/admin/products/%d/images/%d", productID, orderedID)
+	resp := doRequest(router, http.MethodDelete, path, "", admin)
+	require.Equal(t, http.StatusNoContent, resp.Code, resp.Body.String())
+
+	// the order keeps showing the original.
+	assert.False(t, deletedFromStorage(img.Image))
+	assert.True(t, deletedFromStorage(img.LowResImage))
+}
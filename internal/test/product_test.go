@@ -1 +1,95 @@
 package test
+
+import (
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/utils/filters"
+	"github.com/refine-software/afrad-api/internal/utils/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixTSQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		search string
+		want   string
+	}{
+		{name: "Single word", search: "jeans", want: "jeans:*"},
+		{name: "Multiple words", search: "blue  jeans", want: "blue:* & jeans:*"},
+		{name: "Arabic words", search: "قميص رجالي", want: "قميص:* & رجالي:*"},
+		{name: "Arabic with tashkeel", search: "قَمِيص", want: "قَمِيص:*"},
+		{name: "Tsquery operators are dropped", search: "shirt & !(pants):*", want: "shirt:* & pants:*"},
+		{name: "Only symbols", search: "&|!", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, filters.PrefixTSQuery(tt.search))
+		})
+	}
+}
+
+func TestFacetWhereClauseExcludesOwnFilter(t *testing.T) {
+	f := filters.ProductFilterOptions{
+		BrandIDs: []int{2},
+		ColorIDs: []int{1, 3},
+		InStock:  true,
+	}
+
+	whereSQL, args := f.GetFacetWhereClause(filters.FacetColor, "pv")
+	assert.Equal(
+		t,
+		"WHERE products.deleted_at IS NULL AND products.status = 'published' AND products.brand_id = ANY($1) AND pv.quantity > 0",
+		whereSQL,
+	)
+	assert.Equal(t, []any{[]int{2}}, args)
+
+	whereSQL, args = f.GetWhereClause()
+	assert.Contains(t, whereSQL, "pv.color_id = ANY($4)")
+	assert.Equal(t, []any{[]int{2}, []int{1, 3}}, args)
+}
+
+func TestWhereClauseStatuses(t *testing.T) {
+	f := filters.ProductFilterOptions{}
+
+	whereSQL, args := f.GetWhereClause()
+	assert.Equal(t, "WHERE products.deleted_at IS NULL AND products.status = 'published'", whereSQL)
+	assert.Empty(t, args)
+
+	f.Statuses = []string{"draft", "scheduled"}
+	whereSQL, args = f.GetWhereClause()
+	assert.Equal(
+		t,
+		"WHERE products.deleted_at IS NULL AND products.status = ANY($3::text[]::product_status[])",
+		whereSQL,
+	)
+	assert.Equal(t, []any{[]string{"draft", "scheduled"}}, args)
+}
+
+func TestAttributeFilters(t *testing.T) {
+	minWeight := 1.5
+	f := filters.ProductFilterOptions{
+		Attributes: []filters.AttributeFilter{
+			{AttributeID: 4, Values: []string{"Cotton", "linen"}},
+			{AttributeID: 7, Min: &minWeight},
+		},
+	}
+
+	whereSQL, args := f.GetWhereClause()
+	assert.Contains(t, whereSQL, "pa.attribute_id = $3")
+	assert.Contains(t, whereSQL, "lower(COALESCE(ao.value, pa.text_value)) = ANY($4::text[])")
+	assert.Contains(t, whereSQL, "pa.number_value >= $6")
+	assert.Equal(t, []any{int32(4), []string{"cotton", "linen"}, int32(7), 1.5}, args)
+
+	// the facet of an attribute leaves its own filter out.
+	whereSQL, args = f.GetFacetWhereClause(filters.AttributeFacet(4), "pv")
+	assert.NotContains(t, whereSQL, "pa.text_value")
+	assert.Contains(t, whereSQL, "pa.number_value >= $2")
+	assert.Equal(t, []any{int32(7), 1.5}, args)
+
+	maxWeight := 1.0
+	f.Attributes[1].Max = &maxWeight
+	v := validator.New()
+	filters.ValidateProductFilters(v, f)
+	assert.False(t, v.Valid())
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postTestQuestion asks a question about the product as the user.
+func postTestQuestion(t *testing.T, router http.Handler, token string, productID int32) models.ProductQuestion {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"productId":%d,"question":"Does it run small?"}`, productID)
+	resp := doRequest(router, http.MethodPost, "/user/questions", body, token)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	return decodeBody[models.ProductQuestion](t, resp)
+}
+
+func moderate(t *testing.T, router http.Handler, admin, path string, status models.ModerationStatus) {
+	t.Helper()
+
+	resp := doRequest(router, http.MethodPatch, path, fmt.Sprintf(`{"status":%q}`, status), admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
+
+func TestQuestionModeration(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	asker := bearerToken(t, createTestUser(t, models.RoleUser), string(models.RoleUser))
+
+	productID := createTestProduct(t)
+	q := postTestQuestion(t, router, asker, productID)
+	assert.Equal(t, models.ModerationPending, q.Status)
+
+	questionsPath := fmt.Sprintf("/products/%d/questions", productID)
+	answerPath := fmt.Sprintf("/user/questions/%d/answers", q.ID)
+	upvotePath := fmt.Sprintf("/user/questions/%d/upvote", q.ID)
+	statusPath := fmt.Sprintf("/admin/questions/%d/status", q.ID)
+
+	// a question waiting for moderation is neither listed, answered nor upvoted.
+	assert.Equal(t, http.StatusNoContent, doRequest(router, http.MethodGet, questionsPath, "", "").Code)
+	resp := doRequest(router, http.MethodPost, answerPath, `{"answer":"It fits true to size."}`, admin)
+	assert.Equal(t, http.StatusNotFound, resp.Code, resp.Body.String())
+	assert.Equal(t, http.StatusNotFound, doRequest(router, http.MethodPost, upvotePath, "", asker).Code)
+
+	// nor is a rejected one.
+	moderate(t, router, admin, statusPath, models.ModerationRejected)
+	assert.Equal(t, http.StatusNoContent, doRequest(router, http.MethodGet, questionsPath, "", "").Code)
+	resp = doRequest(router, http.MethodPost, answerPath, `{"answer":"It fits true to size."}`, admin)
+	assert.Equal(t, http.StatusNotFound, resp.Code, resp.Body.String())
+
+	moderate(t, router, admin, statusPath, models.ModerationApproved)
+	resp = doRequest(router, http.MethodGet, questionsPath, "", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), q.Question)
+
+	resp = doRequest(router, http.MethodPost, answerPath, `{"answer":"It fits true to size."}`, admin)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	// only the admins moderate.
+	resp = doRequest(router, http.MethodPatch, statusPath, `{"status":"rejected"}`, asker)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
+
+func TestAnswerQuestion(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	askerID := createTestUser(t, models.RoleUser)
+	asker := bearerToken(t, askerID, string(models.RoleUser))
+	buyerID := createTestUser(t, models.RoleUser)
+	buyer := bearerToken(t, buyerID, string(models.RoleUser))
+	stranger := bearerToken(t, createTestUser(t, models.RoleUser), string(models.RoleUser))
+
+	productID := createTestProduct(t)
+	createTestOrder(t, buyerID, createTestVariant(t, productID, 1000, 3), 1)
+
+	q := postTestQuestion(t, router, asker, productID)
+	moderate(t, router, admin, fmt.Sprintf("/admin/questions/%d/status", q.ID), models.ModerationApproved)
+	answerPath := fmt.Sprintf("/user/questions/%d/answers", q.ID)
+
+	// only the buyers of the product answer besides the admins.
+	resp := doRequest(router, http.MethodPost, answerPath, `{"answer":"No idea."}`, stranger)
+	assert.Equal(t, http.StatusForbidden, resp.Code, resp.Body.String())
+
+	// the answers of the buyers wait for moderation.
+	resp = doRequest(router, http.MethodPost, answerPath, `{"answer":"It runs a bit small."}`, buyer)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	buyerAnswer := decodeBody[models.ProductAnswer](t, resp)
+	assert.Equal(t, models.AnswerByBuyer, buyerAnswer.Author)
+	assert.Equal(t, models.ModerationPending, buyerAnswer.Status)
+	assert.Zero(t, countNotifications(t, askerID, models.NotificationQuestionAnswered))
+
+	// an answer that isn't approved can't be upvoted.
+	upvotePath := fmt.Sprintf("/user/answers/%d/upvote", buyerAnswer.ID)
+	assert.Equal(t, http.StatusNotFound, doRequest(router, http.MethodPost, upvotePath, "", asker).Code)
+
+	// the asker is notified once the answer is approved, approving it again doesn't notify twice.
+	answerStatusPath := fmt.Sprintf("/admin/answers/%d/status", buyerAnswer.ID)
+	moderate(t, router, admin, answerStatusPath, models.ModerationApproved)
+	moderate(t, router, admin, answerStatusPath, models.ModerationApproved)
+	assert.Equal(t, 1, countNotifications(t, askerID, models.NotificationQuestionAnswered))
+	assert.Equal(t, http.StatusOK, doRequest(router, http.MethodPost, upvotePath, "", asker).Code)
+
+	// the answers of the admins are approved right away.
+	resp = doRequest(router, http.MethodPost, answerPath, `{"answer":"Take a size up."}`, admin)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	adminAnswer := decodeBody[models.ProductAnswer](t, resp)
+	assert.Equal(t, models.AnswerByAdmin, adminAnswer.Author)
+	assert.Equal(t, models.ModerationApproved, adminAnswer.Status)
+	assert.Equal(t, 2, countNotifications(t, askerID, models.NotificationQuestionAnswered))
+}
+
+func TestUpvoteQuestion(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	asker := bearerToken(t, createTestUser(t, models.RoleUser), string(models.RoleUser))
+	voter := bearerToken(t, createTestUser(t, models.RoleUser), string(models.RoleUser))
+
+	q := postTestQuestion(t, router, asker, createTestProduct(t))
+	moderate(t, router, admin, fmt.Sprintf("/admin/questions/%d/status", q.ID), models.ModerationApproved)
+	upvotePath := fmt.Sprintf("/user/questions/%d/upvote", q.ID)
+
+	votes := func() int {
+		var count int
+		err := testService.Pool().QueryRow(
+			testContext(),
+			`SELECT COUNT(*) FROM question_votes WHERE question_id = $1`,
+			q.ID,
+		).Scan(&count)
+		require.NoError(t, err)
+		return count
+	}
+
+	// upvoting twice counts once.
+	assert.Equal(t, http.StatusOK, doRequest(router, http.MethodPost, upvotePath, "", voter).Code)
+	assert.Equal(t, http.StatusOK, doRequest(router, http.MethodPost, upvotePath, "", voter).Code)
+	assert.Equal(t, http.StatusOK, doRequest(router, http.MethodPost, upvotePath, "", asker).Code)
+	assert.Equal(t, 2, votes())
+
+	assert.Equal(t, http.StatusOK, doRequest(router, http.MethodDelete, upvotePath, "", voter).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(router, http.MethodDelete, upvotePath, "", voter).Code)
+	assert.Equal(t, 1, votes())
+}
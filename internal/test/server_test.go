@@ -1,29 +1,81 @@
 package test
 
 import (
-	"mime/multipart"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/refine-software/afrad-api/config"
 	"github.com/refine-software/afrad-api/internal/database"
 	"github.com/refine-software/afrad-api/internal/server"
+	"github.com/refine-software/afrad-api/internal/storage"
 )
 
-type MockS3 struct{}
+type MockStorage struct{}
 
-func (m *MockS3) UploadImage(
-	ctx *gin.Context,
-	file multipart.File,
-	header *multipart.FileHeader,
-) (string, error) {
-	return "https://mock-bucket/image.jpg", nil
+// mockUploads numbers the mock urls, the uploads ledger requires them to be unique.
+var mockUploads atomic.Int64
+
+// mockDeleted holds the urls deleted from the mock storage by any of the test servers.
+var mockDeleted sync.Map
+
+func (m *MockStorage) NewURL(ext string) string {
+	return fmt.Sprintf("https://mock-bucket/image%d%s", mockUploads.Add(1), ext)
+}
+
+func (m *MockStorage) Upload(
+	ctx context.Context,
+	url string,
+	data []byte,
+	contentType string,
+) error {
+	return nil
+}
+
+func (m *MockStorage) Download(ctx context.Context, url string) ([]byte, error) {
+	return nil, nil
 }
 
-func (m *MockS3) DeleteImageByURL(ctx *gin.Context, url string) error {
+func (m *MockStorage) Delete(ctx context.Context, url string) error {
+	mockDeleted.Store(url, struct{}{})
 	return nil
 }
 
+// deletedFromStorage reports whether the url was deleted from the mock storage.
+func deletedFromStorage(url string) bool {
+	_, ok := mockDeleted.Load(url)
+	return ok
+}
+
+func (m *MockStorage) Exists(ctx context.Context, url string) (bool, error) {
+	return true, nil
+}
+
+func (m *MockStorage) Head(ctx context.Context, url string, n int64) (storage.FileHead, error) {
+	return storage.FileHead{}, storage.ErrNotFound
+}
+
+func (m *MockStorage) PresignUpload(
+	ctx context.Context,
+	ext, contentType string,
+	size int64,
+	expires time.Duration,
+) (storage.PresignedUpload, error) {
+	url := fmt.Sprintf("https://mock-bucket/image%d%s", mockUploads.Add(1), ext)
+	return storage.PresignedUpload{
+		URL:       url,
+		UploadURL: url + "?signature=mock",
+		Method:    http.MethodPut,
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
+
 type MockEmail struct{}
 
 func (m *MockEmail) SendOtpEmail(userEmail, otp string) error {
@@ -37,10 +89,10 @@ func setupTestServer(t *testing.T) *gin.Engine {
 	db := database.New(env)
 
 	s := &server.Server{
-		Env:   env,
-		DB:    db,
-		S3:    &MockS3{},
-		Email: &MockEmail{},
+		Env:     env,
+		DB:      db,
+		Storage: &MockStorage{},
+		Email:   &MockEmail{},
 	}
 	gin.SetMode(gin.TestMode)
 	return s.RegisterRoutes().(*gin.Engine)
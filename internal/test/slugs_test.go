@@ -0,0 +1,86 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slugOf reads the slug of the row of the table.
+func slugOf(t *testing.T, table string, id int32) string {
+	t.Helper()
+
+	var slug string
+	err := testService.Pool().QueryRow(
+		testContext(),
+		fmt.Sprintf(`SELECT slug FROM %s WHERE id = $1`, table),
+		id,
+	).Scan(&slug)
+	require.NoError(t, err)
+
+	return slug
+}
+
+func TestCategorySlugs(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	seq := fixtureSeq.Add(1)
+
+	first := createTestCategory(t, 0)
+	second := createTestCategory(t, 0)
+	rename := func(id int32, name string) {
+		path := fmt.Sprintf("/admin/category/%d", id)
+		resp := doRequest(router, http.MethodPatch, path, fmt.Sprintf(`{"name":%q}`, name), admin)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	}
+
+	// names that slugify the same get a suffix.
+	rename(first, fmt.Sprintf("Summer Shoes %d", seq))
+	rename(second, fmt.Sprintf("Summer shoes! %d", seq))
+	slug := fmt.Sprintf("summer-shoes-%d", seq)
+	assert.Equal(t, slug, slugOf(t, "categories", first))
+	assert.Equal(t, slug+"-2", slugOf(t, "categories", second))
+
+	// the old slug redirects to the new one after a rename.
+	rename(first, fmt.Sprintf("Winter Boots %d", seq))
+	newSlug := fmt.Sprintf("winter-boots-%d", seq)
+	assert.Equal(t, newSlug, slugOf(t, "categories", first))
+
+	resp := doRequest(router, http.MethodGet, "/categories/by-slug/"+slug, "", "")
+	assert.Equal(t, http.StatusMovedPermanently, resp.Code)
+	assert.Equal(t, "/categories/by-slug/"+newSlug, resp.Header().Get("Location"))
+
+	resp = doRequest(router, http.MethodGet, "/categories/by-slug/"+newSlug, "", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = doRequest(router, http.MethodGet, fmt.Sprintf("/categories/by-slug/never-used-%d", seq), "", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestProductSlugRedirect(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+
+	productID := createTestProduct(t)
+	oldSlug := slugOf(t, "products", productID)
+
+	name := fixtureName("Renamed Product")
+	path := fmt.Sprintf("/admin/products/%d", productID)
+	resp := doRequest(router, http.MethodPut, path, fmt.Sprintf(`{"name":%q}`, name), admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	newSlug := slugOf(t, "products", productID)
+	assert.NotEqual(t, oldSlug, newSlug)
+
+	resp = doRequest(router, http.MethodGet, "/products/by-slug/"+oldSlug, "", "")
+	assert.Equal(t, http.StatusMovedPermanently, resp.Code)
+	assert.Equal(t, "/products/by-slug/"+newSlug, resp.Header().Get("Location"))
+
+	id, err := testService.Product().GetIDBySlug(testContext(), testService.Pool(), newSlug)
+	require.NoError(t, err)
+	assert.EqualValues(t, productID, id)
+}
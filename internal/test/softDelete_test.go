@@ -0,0 +1,91 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductRestore(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	productRepo := testService.Product()
+
+	productID := createTestProduct(t)
+	createTestVariant(t, productID, 1000, 3)
+
+	require.NoError(t, productRepo.Delete(c, db, productID))
+	_, err := productRepo.GetDetails(c, db, int(productID), false)
+	assert.True(t, database.IsDBNotFoundErr(err), "a deleted product is hidden")
+
+	require.NoError(t, productRepo.Restore(c, db, productID))
+	details, err := productRepo.GetDetails(c, db, int(productID), true)
+	require.NoError(t, err)
+	assert.Equal(t, productID, details.ID)
+
+	// a product that isn't deleted can't be restored.
+	err = productRepo.Restore(c, db, productID)
+	assert.True(t, database.IsDBNotFoundErr(err))
+}
+
+// productThumbnail reads the thumbnail of the product.
+func productThumbnail(t *testing.T, productID int32) string {
+	t.Helper()
+
+	var thumbnail string
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT thumbnail FROM products WHERE id = $1`,
+		productID,
+	).Scan(&thumbnail)
+	require.NoError(t, err)
+
+	return thumbnail
+}
+
+func TestProductPurgeKeepsOrderedImages(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	productRepo := testService.Product()
+
+	ordered := createTestProduct(t)
+	variantID := createTestVariant(t, ordered, 1000, 3)
+	orderID := createTestOrder(t, createTestUser(t, models.RoleUser), variantID, 1)
+	orderedThumbnail := productThumbnail(t, ordered)
+	_, galleryImage := createTestImage(t, ordered)
+
+	unordered := createTestProduct(t)
+	unorderedThumbnail := productThumbnail(t, unordered)
+
+	require.NoError(t, productRepo.Delete(c, db, ordered))
+	require.NoError(t, productRepo.Delete(c, db, unordered))
+	urls, err := productRepo.PurgeDeleted(c, db, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	var left int
+	err = db.QueryRow(c, `SELECT COUNT(*) FROM products WHERE id IN ($1, $2)`, ordered, unordered).
+		Scan(&left)
+	require.NoError(t, err)
+	assert.Zero(t, left, "the products are gone")
+
+	// the order line keeps its snapshot, only the variant it points at is gone.
+	var (
+		lineThumbnail string
+		lineVariant   pgtype.Int4
+	)
+	err = db.QueryRow(c, `SELECT thumbnail, product_id FROM order_details WHERE order_id = $1`, orderID).
+		Scan(&lineThumbnail, &lineVariant)
+	require.NoError(t, err)
+	assert.Equal(t, orderedThumbnail, lineThumbnail)
+	assert.False(t, lineVariant.Valid)
+
+	// the images are deleted from the storage, but the thumbnail the order shows.
+	assert.Contains(t, urls, galleryImage)
+	assert.Contains(t, urls, unorderedThumbnail)
+	assert.NotContains(t, urls, orderedThumbnail)
+}
@@ -0,0 +1,177 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribeTestUser subscribes the user to the variant, returning the status code.
+func subscribeTestUser(router http.Handler, user string, variantID int32) int {
+	path := fmt.Sprintf("/products/variants/%d/notify-me", variantID)
+	return doRequest(router, http.MethodPost, path, "", user).Code
+}
+
+// queuedSubscribers lists the users of the variant's subscriptions queued to be notified.
+func queuedSubscribers(t *testing.T, variantID int32) []int32 {
+	t.Helper()
+
+	rows, err := testService.Pool().Query(
+		testContext(),
+		`SELECT user_id FROM stock_subscriptions
+		WHERE variant_id = $1 AND queued_at IS NOT NULL AND notified_at IS NULL
+		ORDER BY created_at, id`,
+		variantID,
+	)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var users []int32
+	for rows.Next() {
+		var userID int32
+		require.NoError(t, rows.Scan(&userID))
+		users = append(users, userID)
+	}
+	require.NoError(t, rows.Err())
+
+	return users
+}
+
+func TestSubscribeToVariant(t *testing.T) {
+	router := setupTestServer(t)
+	userID := createTestUser(t, models.RoleUser)
+	user := bearerToken(t, userID, string(models.RoleUser))
+	variantID := createTestVariant(t, createTestProduct(t), 1000, 2)
+
+	// a variant in stock can be bought right away.
+	assert.Equal(t, http.StatusConflict, subscribeTestUser(router, user, variantID))
+
+	setTestQuantity(t, variantID, 0)
+	path := fmt.Sprintf("/products/variants/%d/notify-me", variantID)
+	resp := doRequest(router, http.MethodPost, path, "", user)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	sub := decodeBody[models.StockSubscription](t, resp)
+	assert.Equal(t, variantID, sub.VariantID)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 60), sub.ExpiresAt, time.Minute)
+
+	// subscribing again renews the subscription instead of adding one.
+	assert.Equal(t, http.StatusCreated, subscribeTestUser(router, user, variantID))
+
+	resp = doRequest(router, http.MethodGet, "/user/stock-subscriptions", "", user)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	subs := decodeBody[[]database.StockSubscriptionDetails](t, resp)
+	require.Len(t, subs, 1)
+	assert.Equal(t, sub.ID, subs[0].ID)
+}
+
+func TestRestockQueuesAsManySubscribersAsQuantity(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	variantID := createTestVariant(t, createTestProduct(t), 1000, 0)
+
+	var users []int32
+	for range 3 {
+		userID := createTestUser(t, models.RoleUser)
+		user := bearerToken(t, userID, string(models.RoleUser))
+		require.Equal(t, http.StatusCreated, subscribeTestUser(router, user, variantID))
+		users = append(users, userID)
+	}
+
+	// the oldest subscribers are queued, one per unit restocked.
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 2, "restock"))
+	assert.Equal(t, users[:2], queuedSubscribers(t, variantID))
+
+	claimed, err := testService.StockSubscription().ClaimQueued(c, db, 1000)
+	require.NoError(t, err)
+
+	var notified []int32
+	for _, sub := range claimed {
+		if sub.VariantID == variantID {
+			notified = append(notified, sub.UserID)
+		}
+	}
+	assert.Equal(t, users[:2], notified)
+
+	// the notified ones don't take a slot anymore, the next restock queues the last one.
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 1, "restock"))
+	assert.Equal(t, users[2:], queuedSubscribers(t, variantID))
+}
+
+func TestSoldOutReleasesQueuedSubscriptions(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	subRepo := testService.StockSubscription()
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	variantID := createTestVariant(t, createTestProduct(t), 1000, 0)
+
+	userID := createTestUser(t, models.RoleUser)
+	user := bearerToken(t, userID, string(models.RoleUser))
+	require.Equal(t, http.StatusCreated, subscribeTestUser(router, user, variantID))
+
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 1, "restock"))
+	require.Equal(t, []int32{userID}, queuedSubscribers(t, variantID))
+
+	// the stock is gone before the subscriber is notified.
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, -1, "damage"))
+
+	released, err := subRepo.ReleaseSoldOut(c, db)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, released, int64(1))
+	assert.Empty(t, queuedSubscribers(t, variantID))
+
+	claimed, err := subRepo.ClaimQueued(c, db, 1000)
+	require.NoError(t, err)
+	for _, sub := range claimed {
+		assert.NotEqual(t, variantID, sub.VariantID, "a variant out of stock isn't notified")
+	}
+
+	// the next restock queues the subscriber again.
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 1, "restock"))
+	assert.Equal(t, []int32{userID}, queuedSubscribers(t, variantID))
+}
+
+func TestStockSubscriptionsExpire(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	variantID := createTestVariant(t, createTestProduct(t), 1000, 0)
+
+	userID := createTestUser(t, models.RoleUser)
+	user := bearerToken(t, userID, string(models.RoleUser))
+	require.Equal(t, http.StatusCreated, subscribeTestUser(router, user, variantID))
+
+	_, err := db.Exec(
+		c,
+		`UPDATE stock_subscriptions SET expires_at = NOW() - INTERVAL '1 minute' WHERE variant_id = $1`,
+		variantID,
+	)
+	require.NoError(t, err)
+
+	resp := doRequest(router, http.MethodGet, "/user/stock-subscriptions", "", user)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Empty(t, decodeBody[[]database.StockSubscriptionDetails](t, resp))
+
+	// an expired subscription isn't queued by a restock.
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 1, "restock"))
+	assert.Empty(t, queuedSubscribers(t, variantID))
+
+	_, err = testService.StockSubscription().PurgeEnded(c, db, time.Now().AddDate(0, 0, -30))
+	require.NoError(t, err)
+
+	var left int
+	err = db.QueryRow(c, `SELECT COUNT(*) FROM stock_subscriptions WHERE variant_id = $1`, variantID).
+		Scan(&left)
+	require.NoError(t, err)
+	assert.Zero(t, left, "the expired subscription is purged")
+}
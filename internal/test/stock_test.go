@@ -0,0 +1,185 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stockMovements reads the ledger of the variant, the latest first.
+func stockMovements(t *testing.T, router http.Handler, admin string, variantID int32) []models.StockMovement {
+	t.Helper()
+
+	path := fmt.Sprintf("/admin/inventory/variants/%d/movements", variantID)
+	resp := doRequest(router, http.MethodGet, path, "", admin)
+	if resp.Code == http.StatusNoContent {
+		return nil
+	}
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	return decodeBody[struct {
+		Movements []models.StockMovement `json:"movements"`
+	}](t, resp).Movements
+}
+
+func adjustTestStock(router http.Handler, admin string, variantID int32, change int, reason string) int {
+	path := fmt.Sprintf("/admin/inventory/variants/%d/adjustments", variantID)
+	body := fmt.Sprintf(`{"change":%d,"reason":%q}`, change, reason)
+	return doRequest(router, http.MethodPost, path, body, admin).Code
+}
+
+func TestStockLedger(t *testing.T) {
+	router := setupTestServer(t)
+	adminID := createTestUser(t, models.RoleAdmin)
+	admin := bearerToken(t, adminID, string(models.RoleAdmin))
+	variantID := createTestVariant(t, createTestProduct(t), 1000, 0)
+
+	assert.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 5, "restock"))
+	assert.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, -2, "damage"))
+
+	// the quantity can't go below 0.
+	assert.Equal(t, http.StatusConflict, adjustTestStock(router, admin, variantID, -4, "adjustment"))
+
+	// the manual movements have to go the way of their reason.
+	assert.Equal(t, http.StatusBadRequest, adjustTestStock(router, admin, variantID, -1, "restock"))
+	assert.Equal(t, http.StatusBadRequest, adjustTestStock(router, admin, variantID, 1, "damage"))
+	assert.Equal(t, http.StatusBadRequest, adjustTestStock(router, admin, variantID, 1, "sale"))
+
+	assert.Equal(t, 3, variantQuantity(t, variantID))
+
+	movements := stockMovements(t, router, admin, variantID)
+	require.Len(t, movements, 2)
+	assert.Equal(t, models.StockDamage, movements[0].Reason)
+	assert.Equal(t, -2, movements[0].Change)
+	assert.Equal(t, 3, movements[0].QuantityAfter)
+	assert.Equal(t, models.StockRestock, movements[1].Reason)
+	assert.Equal(t, 5, movements[1].QuantityAfter)
+	assert.Equal(t, adminID, movements[1].ActorID.Int32)
+}
+
+func TestOrderStockRoundTrip(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	customerID := createTestUser(t, models.RoleUser)
+	customer := bearerToken(t, customerID, string(models.RoleUser))
+	otherCustomer := bearerToken(t, createTestUser(t, models.RoleUser), string(models.RoleUser))
+
+	variantID := createTestVariant(t, createTestProduct(t), 1000, 0)
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 3, "restock"))
+
+	orderBody := fmt.Sprintf(
+		`{"name":"Test","cityId":%d,"town":"Town","street":"Street","address":"Address",`+
+			`"phoneNumber":"07700000000","totalPrice":2000}`,
+		createTestCity(t),
+	)
+	placeOrder := func(token string) int {
+		body := fmt.Sprintf(`{"productId":%d,"quantity":2}`, variantID)
+		resp := doRequest(router, http.MethodPost, "/cart", body, token)
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+		return doRequest(router, http.MethodPost, "/orders", orderBody, token).Code
+	}
+
+	require.Equal(t, http.StatusOK, placeOrder(customer))
+	assert.Equal(t, 1, variantQuantity(t, variantID))
+
+	var orderID int32
+	err := testService.Pool().QueryRow(testContext(), `SELECT id FROM orders WHERE user_id = $1`, customerID).
+		Scan(&orderID)
+	require.NoError(t, err)
+
+	sale := stockMovements(t, router, admin, variantID)[0]
+	assert.Equal(t, models.StockSale, sale.Reason)
+	assert.Equal(t, -2, sale.Change)
+	assert.Equal(t, orderID, sale.OrderID.Int32)
+
+	// there isn't enough stock left for another order, nothing of it is kept.
+	assert.Equal(t, http.StatusConflict, placeOrder(otherCustomer))
+	assert.Equal(t, 1, variantQuantity(t, variantID))
+
+	statusPath := fmt.Sprintf("/admin/orders/%d/status", orderID)
+	resp := doRequest(router, http.MethodPatch, statusPath, `{"status":"cancelled"}`, admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, 3, variantQuantity(t, variantID))
+
+	returned := stockMovements(t, router, admin, variantID)[0]
+	assert.Equal(t, models.StockCancellationReturn, returned.Reason)
+	assert.Equal(t, 2, returned.Change)
+
+	// the stock is only returned once, and a cancelled order can't take it again.
+	resp = doRequest(router, http.MethodPatch, statusPath, `{"status":"cancelled"}`, admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	resp = doRequest(router, http.MethodPatch, statusPath, `{"status":"order_placed"}`, admin)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, 3, variantQuantity(t, variantID))
+	assert.Len(t, stockMovements(t, router, admin, variantID), 3)
+}
+
+func TestAddVariantRestocks(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+
+	productID := createTestProduct(t)
+	addVariant := func(quantity int) int {
+		body := fmt.Sprintf(
+			`{"quantity":%d,"price":1000,"colorId":%d,"sizeId":%d,"productId":%d}`,
+			quantity,
+			createTestColor(t, fixtureName("Color")),
+			createTestSize(t, fmt.Sprintf("%d", fixtureSeq.Add(1)), "EU"),
+			productID,
+		)
+		resp := doRequest(router, http.MethodPost, "/admin/products/variants", body, admin)
+		return resp.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, addVariant(-1))
+	assert.Equal(t, http.StatusOK, addVariant(0))
+	assert.Equal(t, http.StatusOK, addVariant(3))
+
+	var movements []int
+	rows, err := testService.Pool().Query(
+		testContext(),
+		`SELECT sm.change
+		FROM stock_movements sm
+		JOIN product_variants pv ON pv.id = sm.variant_id
+		WHERE pv.product_id = $1 AND sm.reason = 'restock'`,
+		productID,
+	)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var change int
+		require.NoError(t, rows.Scan(&change))
+		movements = append(movements, change)
+	}
+	require.NoError(t, rows.Err())
+
+	// a variant added without stock has nothing to record.
+	assert.Equal(t, []int{3}, movements)
+}
+
+func TestStockMovementsOutlivePurgedVariants(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	c := testContext()
+	db := testService.Pool()
+
+	productID := createTestProduct(t)
+	variantID := createTestVariant(t, productID, 1000, 0)
+	require.Equal(t, http.StatusCreated, adjustTestStock(router, admin, variantID, 4, "restock"))
+
+	require.NoError(t, testService.Product().Delete(c, db, productID))
+	_, err := testService.Product().PurgeDeleted(c, db, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	var movements int
+	err = db.QueryRow(c, `SELECT COUNT(*) FROM stock_movements WHERE variant_id = $1`, variantID).
+		Scan(&movements)
+	require.NoError(t, err)
+	assert.Equal(t, 1, movements)
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/refine-software/afrad-api/config"
+	"github.com/refine-software/afrad-api/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	env := &config.Env{
+		StorageDriver:    storage.DriverLocal,
+		StoragePublicURL: "http://cdn.test/files",
+		LocalStorageDir:  t.TempDir(),
+	}
+
+	store, err := storage.New(env)
+	require.NoError(t, err)
+
+	fileURL := store.NewURL(".txt")
+	assert.True(t, strings.HasPrefix(fileURL, "http://cdn.test/files/"))
+	assert.True(t, strings.HasSuffix(fileURL, ".txt"))
+	require.NoError(t, store.Upload(ctx, fileURL, []byte("content"), "text/plain"))
+
+	// files uploaded at the same time don't overwrite each other
+	otherURL := store.NewURL(".txt")
+	assert.NotEqual(t, fileURL, otherURL)
+	require.NoError(t, store.Upload(ctx, otherURL, []byte("other content"), "text/plain"))
+
+	data, err := store.Download(ctx, fileURL)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), data)
+
+	exists, err := store.Exists(ctx, fileURL)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, store.Delete(ctx, fileURL))
+	_, err = store.Download(ctx, fileURL)
+	assert.Error(t, err)
+
+	exists, err = store.Exists(ctx, fileURL)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// deleting a missing file isn't an error
+	assert.NoError(t, store.Delete(ctx, fileURL))
+
+	// urls of another host or escaping the directory are rejected
+	_, err = store.Download(ctx, "http://other.test/files/images/1.txt")
+	assert.Error(t, err)
+	_, err = store.Download(ctx, "http://cdn.test/files/../../etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestLocalStorageLegacyURLs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	old, err := storage.New(&config.Env{
+		StorageDriver:    storage.DriverLocal,
+		StoragePublicURL: "http://old-cdn.test/files",
+		LocalStorageDir:  dir,
+	})
+	require.NoError(t, err)
+
+	fileURL := old.NewURL(".txt")
+	require.NoError(t, old.Upload(ctx, fileURL, []byte("content"), "text/plain"))
+
+	// the cdn moved, the urls stored before still reach the files.
+	store, err := storage.New(&config.Env{
+		StorageDriver:     storage.DriverLocal,
+		StoragePublicURL:  "http://cdn.test/files",
+		StorageLegacyURLs: []string{"http://older-cdn.test", " http://old-cdn.test/files/"},
+		LocalStorageDir:   dir,
+	})
+	require.NoError(t, err)
+
+	data, err := store.Download(ctx, fileURL)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), data)
+
+	movedURL := strings.Replace(fileURL, "http://old-cdn.test", "http://cdn.test", 1)
+	data, err = store.Download(ctx, movedURL)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), data)
+
+	require.NoError(t, store.Delete(ctx, fileURL))
+	_, err = store.Download(ctx, movedURL)
+	assert.Error(t, err)
+
+	_, err = store.Download(ctx, "http://unknown-cdn.test/files/images/1.txt")
+	assert.Error(t, err)
+}
+
+func TestUnknownStorageDriver(t *testing.T) {
+	_, err := storage.New(&config.Env{StorageDriver: "ftp"})
+	assert.Error(t, err)
+}
+
+func TestLocalPresignedUpload(t *testing.T) {
+	ctx := context.Background()
+	env := &config.Env{
+		StorageDriver:    storage.DriverLocal,
+		StoragePublicURL: "http://api.test" + storage.LocalRoute,
+		LocalStorageDir:  t.TempDir(),
+		HashSecret:       "secret",
+	}
+
+	local, err := storage.NewLocalStorage(env)
+	require.NoError(t, err)
+	handler := http.StripPrefix(storage.LocalRoute, local.UploadHandler())
+
+	content := []byte("\x89PNG\r\n\x1a\n-not-really-a-png")
+	presigned, err := local.PresignUpload(ctx, ".png", "image/png", int64(len(content)), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPut, presigned.Method)
+
+	put := func(body []byte, contentType, uploadURL string) int {
+		req := httptest.NewRequest(presigned.Method, uploadURL, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// the signature binds the content type and length
+	assert.Equal(t, http.StatusForbidden, put(content, "image/jpeg", presigned.UploadURL))
+	assert.Equal(t, http.StatusForbidden, put(append(content, 'x'), "image/png", presigned.UploadURL))
+	assert.Equal(t, http.StatusForbidden, put(content, "image/png", presigned.URL))
+
+	_, err = local.Head(ctx, presigned.URL, 8)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	assert.Equal(t, http.StatusOK, put(content, "image/png", presigned.UploadURL))
+
+	head, err := local.Head(ctx, presigned.URL, 8)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), head.Size)
+	assert.Equal(t, content[:8], head.Data)
+}
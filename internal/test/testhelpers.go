@@ -10,12 +10,35 @@ func truncateAllTables(db database.Service) error {
 	ctx := context.Background()
 	_, err := db.Pool().Exec(ctx, `
         TRUNCATE TABLE
+            variant_prices,
+            stock_subscriptions,
+            stock_alerts,
+            stock_movements,
+            notifications,
+            answer_votes,
+            question_votes,
+            product_answers,
+            product_questions,
+            product_trend_scores,
+            product_events,
             order_details,
             orders,
             wishlists,
             cart_items,
             carts,
+            product_recommendations,
+            size_chart_rows,
+            size_charts,
+            shoe_size_conversions,
+            product_attributes,
+            attribute_categories,
+            attribute_options,
+            attributes,
+            uploads,
+            image_jobs,
+            image_renditions,
             images,
+            review_images,
             rating_review,
             product_variants,
             products,
@@ -31,7 +54,9 @@ func truncateAllTables(db database.Service) error {
             users,
             cities,
             discounts,
-            variant_discount
+            variant_discount,
+            api_keys,
+            slug_redirects
         RESTART IDENTITY CASCADE;
     `)
 	return err
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unreferencedUploads(t *testing.T) []string {
+	t.Helper()
+
+	orphans, err := testService.Upload().GetUnreferenced(
+		testContext(),
+		testService.Pool(),
+		time.Now().Add(time.Minute),
+		1000,
+	)
+	require.NoError(t, err)
+
+	var urls []string
+	for _, u := range orphans {
+		urls = append(urls, u.URL)
+	}
+	return urls
+}
+
+func TestUnreferencedUploads(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	uploadRepo := testService.Upload()
+
+	// the ledger row comes first, the rows meant to reference it never were.
+	failed := fmt.Sprintf("https://mock-bucket/failed%d.webp", fixtureSeq.Add(1))
+	require.NoError(t, uploadRepo.Create(c, db, failed, models.UploadOwnerImage))
+
+	productID := createTestProduct(t)
+	imageID, imageURL := createTestImage(t, productID)
+
+	// an order line still shows the thumbnail of a purged product.
+	ordered := createTestProduct(t)
+	createTestOrder(t, createTestUser(t, models.RoleUser), createTestVariant(t, ordered, 1000, 3), 1)
+	thumbnail := productThumbnail(t, ordered)
+	require.NoError(t, uploadRepo.Create(c, db, thumbnail, models.UploadOwnerProduct))
+	_, err := db.Exec(c, `DELETE FROM products WHERE id = $1`, ordered)
+	require.NoError(t, err)
+
+	urls := unreferencedUploads(t)
+	assert.Contains(t, urls, failed)
+	assert.NotContains(t, urls, imageURL)
+	assert.NotContains(t, urls, thumbnail)
+
+	_, err = db.Exec(c, `DELETE FROM images WHERE id = $1`, imageID)
+	require.NoError(t, err)
+	assert.Contains(t, unreferencedUploads(t), imageURL)
+}
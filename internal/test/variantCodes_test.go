@@ -0,0 +1,126 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/refine-software/afrad-api/internal/database"
+	"github.com/refine-software/afrad-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lookupTestVariant finds the variant of the code through the admin endpoint.
+func lookupTestVariant(t *testing.T, router http.Handler, admin, code string) database.VariantLookup {
+	t.Helper()
+
+	resp := doRequest(router, http.MethodGet, "/admin/variants/lookup?code="+url.QueryEscape(code), "", admin)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	return decodeBody[database.VariantLookup](t, resp)
+}
+
+func TestGeneratedVariantSKU(t *testing.T) {
+	c := testContext()
+	db := testService.Pool()
+	variantRepo := testService.ProductVariant()
+
+	productID := createTestProduct(t)
+	seq := fixtureSeq.Add(1)
+	sizeID := createTestSize(t, strconv.FormatInt(seq, 10), "EU")
+
+	// both colors have the code BLU.
+	blue := models.ProductVariant{
+		Price:     1000,
+		ProductID: productID,
+		ColorID:   createTestColor(t, fmt.Sprintf("Blue %d", seq)),
+		SizeID:    sizeID,
+	}
+	require.NoError(t, variantRepo.Create(c, db, &blue))
+	assert.Equal(t, fmt.Sprintf("P%d-BLU-%dEU", productID, seq), blue.SKU.String)
+
+	blush := models.ProductVariant{
+		Price:     1000,
+		ProductID: productID,
+		ColorID:   createTestColor(t, fmt.Sprintf("Blush %d", seq)),
+		SizeID:    sizeID,
+	}
+	require.NoError(t, variantRepo.Create(c, db, &blush))
+	assert.Equal(
+		t,
+		fmt.Sprintf("P%d-BLU-%dEU-%d-%d", productID, seq, blush.ColorID, sizeID),
+		blush.SKU.String,
+		"the ids are added to a sku that's taken",
+	)
+
+	// a sku that's given is kept.
+	given := models.ProductVariant{
+		Price:     1000,
+		ProductID: productID,
+		ColorID:   createTestColor(t, fixtureName("Color")),
+		SizeID:    sizeID,
+		SKU:       pgtype.Text{String: fmt.Sprintf("TEE-%d", seq), Valid: true},
+	}
+	require.NoError(t, variantRepo.Create(c, db, &given))
+	assert.Equal(t, fmt.Sprintf("TEE-%d", seq), given.SKU.String)
+}
+
+func TestLookupVariant(t *testing.T) {
+	router := setupTestServer(t)
+	admin := bearerToken(t, createTestUser(t, models.RoleAdmin), string(models.RoleAdmin))
+	productID := createTestProduct(t)
+	variantID := createTestVariant(t, productID, 1000, 1)
+
+	var colorID, sizeID int32
+	err := testService.Pool().QueryRow(
+		testContext(),
+		`SELECT color_id, size_id FROM product_variants WHERE id = $1`,
+		variantID,
+	).Scan(&colorID, &sizeID)
+	require.NoError(t, err)
+
+	path := fmt.Sprintf("/admin/products/variants/%d", variantID)
+	update := func(sku, barcode string) int {
+		body := fmt.Sprintf(
+			`{"price":1000,"colorId":%d,"sizeId":%d,"sku":%q,"barcode":%q}`,
+			colorID,
+			sizeID,
+			sku,
+			barcode,
+		)
+		return doRequest(router, http.MethodPut, path, body, admin).Code
+	}
+
+	// a sku shaped like a barcode would make a scanned code match two variants.
+	assert.Equal(t, http.StatusBadRequest, update("4006381333931", ""))
+
+	sku := fmt.Sprintf("tee-%d", fixtureSeq.Add(1))
+	require.Equal(t, http.StatusOK, update(sku, "036000291452"))
+
+	// the UPC-A is stored as its EAN-13 and found either way.
+	for _, code := range []string{"036000291452", "0036000291452", sku} {
+		v := lookupTestVariant(t, router, admin, code)
+		assert.Equal(t, variantID, v.ID, code)
+		assert.Equal(t, productID, v.ProductID, code)
+		assert.Equal(t, "0036000291452", v.Barcode.String, code)
+	}
+
+	resp := doRequest(router, http.MethodGet, "/admin/variants/lookup?code=NOPE-0", "", admin)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	// a legacy sku that's another variant's barcode finds the variant with the barcode.
+	legacyID := createTestVariant(t, productID, 1000, 1)
+	_, err = testService.Pool().Exec(
+		testContext(),
+		`UPDATE product_variants SET sku = '0036000291452' WHERE id = $1`,
+		legacyID,
+	)
+	require.NoError(t, err)
+
+	v := lookupTestVariant(t, router, admin, "036000291452")
+	assert.Equal(t, variantID, v.ID)
+}
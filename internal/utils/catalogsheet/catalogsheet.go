@@ -0,0 +1,287 @@
+// Package catalogsheet reads and writes the CSV and XLSX sheets the catalog is imported
+// from and exported to. A sheet has a row per product variant, the product columns can be
+// left empty on the rows after the first row of a product.
+package catalogsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+
+	// MaxRows is the most variant rows a sheet can have.
+	MaxRows = 5000
+
+	sheetName = "Products"
+)
+
+// Columns of the sheet in the order they're written, they're matched by name when read.
+var Columns = []string{
+	"name",
+	"details",
+	"brand",
+	"category",
+	"status",
+	"publish_at",
+	"color",
+	"size",
+	"size_label",
+	"price",
+	"quantity",
+	"sku",
+	"barcode",
+	"thumbnail",
+	"images",
+}
+
+// requiredColumns have to be in the sheet header, the other columns are optional.
+var requiredColumns = []string{"name", "brand", "category", "color", "size", "price", "quantity"}
+
+var (
+	ErrEmptySheet    = errors.New("the sheet has no rows")
+	ErrTooManyRows   = fmt.Errorf("the sheet has more than %d rows", MaxRows)
+	ErrUnknownFormat = errors.New("the sheet has to be a csv or xlsx file")
+)
+
+// Row is a variant row of the sheet, the values are kept as written so they can be reported as is.
+type Row struct {
+	// Line is the line of the row in the sheet, the header is line 1.
+	Line int `json:"line"`
+
+	Name    string `json:"name"`
+	Details string `json:"details"`
+	Brand   string `json:"brand"`
+	// Category is the path of the category from the root, e.g. Men/Shoes/Sneakers.
+	Category  string `json:"category"`
+	Status    string `json:"status"`
+	PublishAt string `json:"publishAt"`
+	Color     string `json:"color"`
+	Size      string `json:"size"`
+	SizeLabel string `json:"sizeLabel"`
+	Price     string `json:"price"`
+	Quantity  string `json:"quantity"`
+	// SKU is generated for the variant when it's left empty.
+	SKU       string `json:"sku"`
+	Barcode   string `json:"barcode"`
+	Thumbnail string `json:"thumbnail"`
+	// Images are the urls of the gallery images separated by |.
+	Images string `json:"images"`
+}
+
+// fields returns the row fields in the order of Columns.
+func (r *Row) fields() []*string {
+	return []*string{
+		&r.Name,
+		&r.Details,
+		&r.Brand,
+		&r.Category,
+		&r.Status,
+		&r.PublishAt,
+		&r.Color,
+		&r.Size,
+		&r.SizeLabel,
+		&r.Price,
+		&r.Quantity,
+		&r.SKU,
+		&r.Barcode,
+		&r.Thumbnail,
+		&r.Images,
+	}
+}
+
+// FormatOf returns the format of the sheet file by its extension.
+func FormatOf(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// SplitImages splits the images column into the image urls.
+func SplitImages(images string) []string {
+	var urls []string
+	for _, url := range strings.Split(images, "|") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// JoinImages joins the image urls into the images column.
+func JoinImages(urls []string) string {
+	return strings.Join(urls, "|")
+}
+
+// Read reads the variant rows of the sheet, the blank rows are skipped.
+func Read(r io.Reader, format string) ([]Row, error) {
+	var (
+		records [][]string
+		err     error
+	)
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err = reader.ReadAll()
+	case FormatXLSX:
+		records, err = readXLSX(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, ErrEmptySheet
+	}
+
+	// the columns are found by name, so they can be in any order.
+	// Spreadsheet apps may start a csv with a byte order mark.
+	indexes := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		indexes[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := indexes[name]; !ok {
+			return nil, fmt.Errorf("the sheet is missing the %q column", name)
+		}
+	}
+
+	var rows []Row
+	for i, record := range records[1:] {
+		row := Row{Line: i + 2}
+		blank := true
+		for j, field := range row.fields() {
+			index, ok := indexes[Columns[j]]
+			if !ok || index >= len(record) {
+				continue
+			}
+			*field = strings.TrimSpace(record[index])
+			blank = blank && *field == ""
+		}
+		if blank {
+			continue
+		}
+
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrEmptySheet
+	}
+
+	return rows, nil
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, ErrEmptySheet
+	}
+
+	// the products are read from the first sheet whatever its name is.
+	return f.GetRows(sheets[0])
+}
+
+// Write writes the header and the rows to the sheet.
+func Write(w io.Writer, format string, rows []Row) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, rows)
+	case FormatXLSX:
+		return writeXLSX(w, rows)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+func writeCSV(w io.Writer, rows []Row) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(Columns))
+	for _, row := range rows {
+		for i, field := range row.fields() {
+			record[i] = *field
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeXLSX(w io.Writer, rows []Row) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+		return err
+	}
+
+	// the stream writer keeps the memory flat for large catalogs.
+	sw, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		return err
+	}
+
+	header := make([]any, len(Columns))
+	for i, name := range Columns {
+		header[i] = name
+	}
+	if err = sw.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+
+		// every value is written as text, so the prices and sizes read back as written.
+		values := make([]any, len(Columns))
+		for j, field := range row.fields() {
+			values[j] = *field
+		}
+		if err = sw.SetRow(cell, values); err != nil {
+			return err
+		}
+	}
+
+	if err = sw.Flush(); err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
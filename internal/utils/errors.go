@@ -74,6 +74,18 @@ var (
 
 	// Role Errors
 	ErrRoleNotAllowed = NewAPIError(http.StatusForbidden, "role not allowed")
+
+	// API Key Errors
+	ErrInvalidAPIKey = NewAPIError(http.StatusUnauthorized, "invalid api key")
+	ErrAPIKeyRevoked = NewAPIError(http.StatusUnauthorized, "api key has been revoked")
+	ErrAPIKeyExpired = NewAPIError(http.StatusUnauthorized, "api key has expired")
+	ErrMissingScope  = func(scope string) *APIError {
+		return NewAPIError(http.StatusForbidden, fmt.Sprintf("api key is missing the %s scope", scope))
+	}
+	ErrAPIKeyNotAllowed = NewAPIError(
+		http.StatusForbidden,
+		"this resource can't be accessed with an api key",
+	)
 )
 
 func MapDBErrorToAPIError(err error) *APIError {
@@ -0,0 +1,122 @@
+package filters
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a row of a listing, it holds the sort the listing was read with and
+// the sort value and id of the row, the next page starts right after it.
+// When Prev is set the page ending right before the row is read instead.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"i"`
+	Prev  bool   `json:"p,omitempty"`
+}
+
+// Keyset is the position of a row in a listing, the listing query returns it
+// along with every row so the cursors can be built out of the first and last rows.
+type Keyset struct {
+	Value string
+	ID    int64
+}
+
+// EncodeCursor serializes the cursor into an opaque url safe token,
+// the token is signed so clients can't forge a position in the listing.
+func EncodeCursor(c Cursor, secret string) string {
+	payload, _ := json.Marshal(c)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + signCursor(encoded, secret)
+}
+
+// DecodeCursor verifies the token signature and deserializes the cursor out of it.
+func DecodeCursor(token, secret string) (*Cursor, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signCursor(encoded, secret))) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+func signCursor(encoded, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Paginate takes the rows a listing query read with Limit, along with their keysets.
+// It drops the extra row that's only read to know if there's more, puts the rows back
+// in the sort order when they were read backwards and builds the metadata cursors.
+func Paginate[T any](f Filters, rows []T, keys []Keyset, totalRecords int) ([]T, Metadata) {
+	hasMore := len(rows) > f.PageSize
+	if hasMore {
+		rows = rows[:f.PageSize]
+		keys = keys[:f.PageSize]
+	}
+
+	backward := f.Cursor != nil && f.Cursor.Prev
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	var metadata Metadata
+	if f.Cursor == nil {
+		metadata = CalculateMetadata(totalRecords, f.Page, f.PageSize)
+	} else {
+		metadata = Metadata{PageSize: f.PageSize}
+	}
+
+	if len(rows) == 0 {
+		return rows, metadata
+	}
+
+	// reading forward there's a next page when the extra row was found, and a previous
+	// page whenever we didn't start from the top. Reading backward it's the other way around.
+	hasNext, hasPrev := hasMore, f.Cursor != nil || f.Page > 1
+	if backward {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if hasNext {
+		last := keys[len(keys)-1]
+		metadata.NextCursor = EncodeCursor(
+			Cursor{Sort: f.Sort, Value: last.Value, ID: last.ID},
+			f.CursorSecret,
+		)
+	}
+	if hasPrev {
+		first := keys[0]
+		metadata.PrevCursor = EncodeCursor(
+			Cursor{Sort: f.Sort, Value: first.Value, ID: first.ID, Prev: true},
+			f.CursorSecret,
+		)
+	}
+
+	return rows, metadata
+}
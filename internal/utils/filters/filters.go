@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"fmt"
 	"math"
 	"strings"
 
@@ -12,15 +13,23 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafeList []string
+
+	// Cursor is set when the client continues from a cursor instead of asking for a page,
+	// the listing is then read by keyset and the total count is skipped.
+	Cursor *Cursor
+	// CursorSecret signs the cursors handed out in the metadata.
+	CursorSecret string
 }
 
 // Metadata holds pagination metadata.
 type Metadata struct {
-	CurrentPage  int `json:"currentPage,omitempty"`
-	PageSize     int `json:"pageSize,omitempty"`
-	FirstPage    int `json:"firstPage,omitempty"`
-	LastPage     int `json:"lastPage,omitempty"`
-	TotalRecords int `json:"totalRecords,omitempty"`
+	CurrentPage  int    `json:"currentPage,omitempty"`
+	PageSize     int    `json:"pageSize,omitempty"`
+	FirstPage    int    `json:"firstPage,omitempty"`
+	LastPage     int    `json:"lastPage,omitempty"`
+	TotalRecords int    `json:"totalRecords,omitempty"`
+	NextCursor   string `json:"nextCursor,omitempty"`
+	PrevCursor   string `json:"prevCursor,omitempty"`
 }
 
 // calculateMetadata calculates the appropriate pagination metadata values given the total number
@@ -43,32 +52,30 @@ func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
 
 // ValidateFilters runs validation checks on the Filters type.
 func ValidateFilters(v *validator.Validator, f Filters) {
-	// Check that page and page_size parameters contain sensible values.
-	v.Check(f.Page > 0, "page", "must be greater than 0")
-	v.Check(f.Page <= 10_000_0000, "page", "must be a maximum of 10 million")
+	// Check that page and page_size parameters contain sensible values,
+	// the page is ignored when reading from a cursor.
+	if f.Cursor == nil {
+		v.Check(f.Page > 0, "page", "must be greater than 0")
+		v.Check(f.Page <= 10_000_0000, "page", "must be a maximum of 10 million")
+	}
 	v.Check(f.PageSize > 0, "page_size", "must be greater than 0")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 
 	// Check that the sort parameter matches a value in the safelist.
 	v.Check(validator.In(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+
+	// A cursor only points at a position in the listing it was read from.
+	v.Check(f.Cursor == nil || f.Cursor.Sort == f.Sort, "cursor", "doesn't match the sort value")
 }
 
 // sortColumn checks that the client-provided Sort field matches one of the entries in our
 // SortSafeList and if it does, it extracts the column name from the Sort field by stripping the
-// leading hyphen character (if one exists).
+// leading hyphen character (if one exists). The listing queries select every sortable value
+// under the same name as its sort value, so the column can be used as is.
 func (f Filters) SortColumn() string {
 	for _, safeValue := range f.SortSafeList {
 		if f.Sort == safeValue {
-			column := strings.TrimPrefix(f.Sort, "-")
-
-			switch column {
-			case "price":
-				return "MIN(product_variants.price)"
-			case "rating":
-				return "COALESCE(ROUND(AVG(DISTINCT rating_review.rating)::numeric, 2), 0.00)"
-			default:
-				return "products." + column // assume default columns are in `products`
-			}
+			return strings.TrimPrefix(f.Sort, "-")
 		}
 	}
 
@@ -79,18 +86,74 @@ func (f Filters) SortColumn() string {
 }
 
 // sortDirection returns the sort direction ("ASC" or "DESC") depending on the prefix character
-// of the Sort field.
+// of the Sort field. Relevance is the exception, the best matches always come first.
 func (f Filters) SortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+	if strings.HasPrefix(f.Sort, "-") || f.Sort == "relevance" {
 		return "DESC"
 	}
 	return "ASC"
 }
 
+// readDirection is the direction the rows are read in, it's the sort direction
+// unless we're reading the page before a cursor.
+func (f Filters) readDirection() string {
+	direction := f.SortDirection()
+	if f.Cursor != nil && f.Cursor.Prev {
+		if direction == "ASC" {
+			return "DESC"
+		}
+		return "ASC"
+	}
+	return direction
+}
+
+// OrderBy returns the ORDER BY expressions of a listing, the id breaks the ties
+// so every row has a unique position a cursor can point at.
+func (f Filters) OrderBy(idColumn string) string {
+	direction := f.readDirection()
+	return fmt.Sprintf("%s %s, %s %s", f.SortColumn(), direction, idColumn, direction)
+}
+
+// CursorCondition returns the condition selecting the rows past the cursor, with its
+// placeholders numbered from argIndex. Without a cursor every row is selected.
+func (f Filters) CursorCondition(idColumn string, argIndex int) (string, []any) {
+	if f.Cursor == nil {
+		return "TRUE", nil
+	}
+
+	operator := ">"
+	if f.readDirection() == "DESC" {
+		operator = "<"
+	}
+
+	return fmt.Sprintf(
+		"(%s, %s) %s ($%d, $%d)",
+		f.SortColumn(),
+		idColumn,
+		operator,
+		argIndex,
+		argIndex+1,
+	), []any{f.Cursor.Value, f.Cursor.ID}
+}
+
+// Limit reads one more row than the page size, Paginate drops it
+// after using it to know if there's a next page.
 func (f Filters) Limit() int {
-	return f.PageSize
+	return f.PageSize + 1
 }
 
 func (f Filters) Offset() int {
+	if f.Cursor != nil {
+		return 0
+	}
 	return (f.Page - 1) * f.PageSize
 }
+
+// CountSQL is the expression selecting the total number of records, the count
+// is skipped when reading from a cursor since only the offset pages need it.
+func (f Filters) CountSQL() string {
+	if f.Cursor != nil {
+		return "0"
+	}
+	return "COUNT(*) OVER()"
+}
@@ -3,48 +3,320 @@ package filters
 import (
 	"fmt"
 	"strings"
+	"unicode"
+
+	"github.com/refine-software/afrad-api/internal/utils/validator"
 )
 
 type ProductFilterOptions struct {
 	CategoryID int
-	BrandID    int
+	BrandIDs   []int
 	Search     string
+	MinRating  float64
+
+	// Statuses the products can be in, customers only ever see the published ones
+	// so it's left empty for them, the admin listings set it to any of the statuses.
+	Statuses []string
+
+	// Deleted lists the soft deleted products instead of the live ones.
+	Deleted bool
+
+	// Variant level filters, a product matches when one of its variants
+	// satisfies all of them at once.
+	ColorIDs []int
+	SizeIDs  []int
+	MinPrice int
+	MaxPrice int
+	InStock  bool
+	OnSale   bool
+
+	// Attributes filter by the values of the filterable attributes, a product
+	// matches when it satisfies every one of them.
+	Attributes []AttributeFilter
+
+	// relevanceSQL is set by GetWhereClause when a search term is given.
+	relevanceSQL string
+}
+
+// AttributeFilter narrows the products down by their value of an attribute,
+// text and enum attributes match any of the Values and number attributes
+// fall between Min and Max when they're set.
+type AttributeFilter struct {
+	AttributeID int32
+	// Values are matched case insensitively.
+	Values []string
+	Min    *float64
+	Max    *float64
+}
+
+// Facet names a filter dimension, it's used to leave that dimension out when
+// counting its own facet, so selecting a color doesn't hide the other colors.
+type Facet string
+
+const (
+	FacetNone   Facet = ""
+	FacetBrand  Facet = "brand"
+	FacetRating Facet = "rating"
+	FacetColor  Facet = "color"
+	FacetSize   Facet = "size"
+	FacetPrice  Facet = "price"
+	FacetStock  Facet = "stock"
+	FacetSale   Facet = "sale"
+)
+
+// AttributeFacet names the filter dimension of an attribute.
+func AttributeFacet(attributeID int32) Facet {
+	return Facet(fmt.Sprintf("attribute:%d", attributeID))
+}
+
+// ValidateProductFilters runs validation checks on the ProductFilterOptions type.
+func ValidateProductFilters(v *validator.Validator, p ProductFilterOptions) {
+	v.Check(p.MinPrice >= 0, "min_price", "must be a positive number")
+	v.Check(p.MaxPrice >= 0, "max_price", "must be a positive number")
+	v.Check(
+		p.MaxPrice == 0 || p.MinPrice <= p.MaxPrice,
+		"max_price",
+		"must be greater than min_price",
+	)
+	v.Check(p.MinRating >= 0 && p.MinRating <= 5, "min_rating", "must be between 0 and 5")
+	for _, attr := range p.Attributes {
+		v.Check(
+			attr.Min == nil || attr.Max == nil || *attr.Min <= *attr.Max,
+			"attributes",
+			"the max of an attribute must be greater than its min",
+		)
+	}
+}
+
+// argList hands out positional placeholders starting at a given index.
+type argList struct {
+	next int
+	args []any
+}
+
+func (a *argList) add(arg any) string {
+	a.args = append(a.args, arg)
+	placeholder := fmt.Sprintf("$%d", a.next)
+	a.next++
+	return placeholder
 }
 
 func (p *ProductFilterOptions) GetWhereClause() (string, []any) {
-	var whereClauses []string
-	var args []any
-	argIndex := 3
+	a := &argList{next: 3}
+
+	whereClauses, relevanceSQL := p.productConditions(a, FacetNone)
+	p.relevanceSQL = relevanceSQL
+
+	if variantConditions := p.variantConditions(a, "pv", FacetNone); len(variantConditions) > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id AND pv.deleted_at IS NULL AND %s)",
+			strings.Join(variantConditions, " AND "),
+		))
+	}
+
+	// Join WHERE clause if needed
+	whereSQL := ""
+	if len(whereClauses) > 0 {
+		whereSQL = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	return whereSQL, a.args
+}
+
+// GetFacetWhereClause builds the where clause used to count the given facet. The
+// product level filters apply to the products table, while the variant level ones
+// apply to the product_variants row aliased as variantAlias, the excluded facet is
+// left out and the placeholders start at $1.
+func (p *ProductFilterOptions) GetFacetWhereClause(
+	exclude Facet,
+	variantAlias string,
+) (string, []any) {
+	a := &argList{next: 1}
+
+	whereClauses, _ := p.productConditions(a, exclude)
+	whereClauses = append(whereClauses, p.variantConditions(a, variantAlias, exclude)...)
+
+	whereSQL := ""
+	if len(whereClauses) > 0 {
+		whereSQL = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	return whereSQL, a.args
+}
 
-	// Example filters
+// productConditions returns the conditions on the products table, along with the
+// expression ranking them against the search term if there is one.
+func (p *ProductFilterOptions) productConditions(
+	a *argList,
+	exclude Facet,
+) (whereClauses []string, relevanceSQL string) {
+	if p.Deleted {
+		whereClauses = append(whereClauses, "products.deleted_at IS NOT NULL")
+	} else {
+		whereClauses = append(whereClauses, "products.deleted_at IS NULL")
+	}
+
+	if len(p.Statuses) > 0 {
+		whereClauses = append(
+			whereClauses,
+			"products.status = ANY("+a.add(p.Statuses)+"::text[]::product_status[])",
+		)
+	} else {
+		whereClauses = append(whereClauses, "products.status = 'published'")
+	}
+
+	// a category matches the products of all its subcategories too.
 	if p.CategoryID != 0 {
 		whereClauses = append(
 			whereClauses,
-			fmt.Sprintf("products.product_category = $%d", argIndex),
+			"products.product_category IN (SELECT category_subtree_ids("+a.add(p.CategoryID)+"))",
 		)
-		args = append(args, p.CategoryID)
-		argIndex++
 	}
 
-	if p.BrandID != 0 {
-		whereClauses = append(whereClauses, fmt.Sprintf("products.brand_id = $%d", argIndex))
-		args = append(args, p.BrandID)
-		argIndex++
+	if len(p.BrandIDs) > 0 && exclude != FacetBrand {
+		whereClauses = append(whereClauses, "products.brand_id = ANY("+a.add(p.BrandIDs)+")")
 	}
 
-	if p.Search != "" {
-		whereClauses = append(whereClauses, fmt.Sprintf("products.name ILIKE $%d", argIndex))
-		args = append(args, "%"+p.Search+"%")
-		argIndex++
+	if p.MinRating > 0 && exclude != FacetRating {
+		whereClauses = append(whereClauses, fmt.Sprintf(
+			"COALESCE((SELECT AVG(rr.rating) FROM rating_review rr WHERE rr.product_id = products.id), 0) >= %s",
+			a.add(p.MinRating),
+		))
 	}
 
-	// Join WHERE clause if needed
-	whereSQL := ""
-	if len(whereClauses) > 0 {
-		whereSQL = "WHERE " + strings.Join(whereClauses, " AND ")
+	for _, attr := range p.Attributes {
+		if exclude == AttributeFacet(attr.AttributeID) {
+			continue
+		}
+
+		conditions := []string{
+			"pa.product_id = products.id",
+			"pa.attribute_id = " + a.add(attr.AttributeID),
+		}
+		if len(attr.Values) > 0 {
+			values := make([]string, len(attr.Values))
+			for i, value := range attr.Values {
+				values[i] = strings.ToLower(value)
+			}
+			conditions = append(
+				conditions,
+				"lower(COALESCE(ao.value, pa.text_value)) = ANY("+a.add(values)+"::text[])",
+			)
+		}
+		if attr.Min != nil {
+			conditions = append(conditions, "pa.number_value >= "+a.add(*attr.Min))
+		}
+		if attr.Max != nil {
+			conditions = append(conditions, "pa.number_value <= "+a.add(*attr.Max))
+		}
+
+		whereClauses = append(whereClauses, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM product_attributes pa
+			LEFT JOIN attribute_options ao ON ao.id = pa.option_id
+			WHERE %s
+		)`, strings.Join(conditions, " AND ")))
+	}
+
+	if search := strings.TrimSpace(p.Search); search != "" {
+		// the trigram comparisons tolerate typos in the product name,
+		// while the tsvector matches whole words of the name, brand, category and details.
+		term := a.add(search)
+
+		trigramMatch := fmt.Sprintf(
+			"normalize_arabic(products.name) %% normalize_arabic(%s) OR normalize_arabic(%s) <%% normalize_arabic(products.name)",
+			term,
+			term,
+		)
+		similarity := fmt.Sprintf(
+			"similarity(normalize_arabic(products.name), normalize_arabic(%s))",
+			term,
+		)
+
+		if tsQuery := PrefixTSQuery(search); tsQuery != "" {
+			tsQuerySQL := fmt.Sprintf("to_tsquery('simple', normalize_arabic(%s))", a.add(tsQuery))
+			whereClauses = append(whereClauses, fmt.Sprintf(
+				"(products.search_vector @@ %s OR %s)",
+				tsQuerySQL,
+				trigramMatch,
+			))
+			relevanceSQL = fmt.Sprintf(
+				"ts_rank_cd(products.search_vector, %s) + %s",
+				tsQuerySQL,
+				similarity,
+			)
+		} else {
+			whereClauses = append(whereClauses, "("+trigramMatch+")")
+			relevanceSQL = similarity
+		}
 	}
 
-	fmt.Println(whereSQL)
+	return whereClauses, relevanceSQL
+}
+
+// variantConditions returns the conditions a single variant row, aliased as alias,
+// has to satisfy.
+func (p *ProductFilterOptions) variantConditions(a *argList, alias string, exclude Facet) []string {
+	var conditions []string
+
+	if len(p.ColorIDs) > 0 && exclude != FacetColor {
+		conditions = append(conditions, alias+".color_id = ANY("+a.add(p.ColorIDs)+")")
+	}
+
+	if len(p.SizeIDs) > 0 && exclude != FacetSize {
+		conditions = append(conditions, alias+".size_id = ANY("+a.add(p.SizeIDs)+")")
+	}
+
+	if exclude != FacetPrice {
+		if p.MinPrice > 0 {
+			conditions = append(conditions, alias+".price >= "+a.add(p.MinPrice))
+		}
+		if p.MaxPrice > 0 {
+			conditions = append(conditions, alias+".price <= "+a.add(p.MaxPrice))
+		}
+	}
+
+	if p.InStock && exclude != FacetStock {
+		conditions = append(conditions, alias+".quantity > 0")
+	}
+
+	if p.OnSale && exclude != FacetSale {
+		conditions = append(conditions, OnSaleCondition(alias))
+	}
+
+	return conditions
+}
+
+// OnSaleCondition checks that the variant aliased as alias has a discount running today.
+func OnSaleCondition(alias string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM variant_discount vd
+		JOIN discounts d ON d.id = vd.discount_id
+		WHERE vd.variant_id = %s.id AND CURRENT_DATE BETWEEN d.start_date AND d.end_date
+	)`, alias)
+}
+
+// RelevanceSQL returns the expression used to rank products against the search term,
+// it has to be called after GetWhereClause since it references its arguments.
+// Without a search term every product is equally relevant.
+func (p *ProductFilterOptions) RelevanceSQL() string {
+	if p.relevanceSQL == "" {
+		return "0::real"
+	}
+	return p.relevanceSQL
+}
+
+// PrefixTSQuery turns a free text search into a tsquery where every word is matched
+// as a prefix, so "jea sh" finds "jeans shorts". Characters that have a meaning in the
+// tsquery syntax are dropped, and an empty string is returned if no word is left.
+func PrefixTSQuery(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
 
-	return whereSQL, args
+	return strings.Join(terms, " & ")
 }
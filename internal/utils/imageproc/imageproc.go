@@ -0,0 +1,145 @@
+// Package imageproc turns uploaded images into the WebP renditions served to clients.
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"slices"
+
+	"github.com/buckket/go-blurhash"
+	"github.com/disintegration/imaging"
+	"github.com/gen2brain/webp"
+)
+
+const (
+	// ContentType and Extension of every rendition.
+	ContentType = "image/webp"
+	Extension   = ".webp"
+
+	// maxPixels guards against decompression bombs, a small file can declare huge dimensions.
+	maxPixels = 50_000_000
+)
+
+// allowedTypes maps the accepted image types to their file extension.
+var allowedTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+}
+
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+// Sniff detects the image type from its first bytes instead of trusting the
+// client Content-Type header, ok is false when it isn't an allowed image type.
+func Sniff(head []byte) (contentType string, ok bool) {
+	contentType = http.DetectContentType(head)
+	_, ok = allowedTypes[contentType]
+	return contentType, ok
+}
+
+// ExtensionOf returns the file extension of an allowed image type.
+func ExtensionOf(contentType string) string {
+	return allowedTypes[contentType]
+}
+
+type Rendition struct {
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Placeholder is shown while the renditions load.
+type Placeholder struct {
+	Blurhash      string
+	DominantColor string
+}
+
+type Result struct {
+	// Renditions ordered by width, the smallest first.
+	Renditions  []Rendition
+	Placeholder Placeholder
+}
+
+// Process decodes the image, rotating it upright according to its EXIF orientation,
+// and encodes a WebP rendition for every width smaller than the image. The image's own
+// width is used when it's narrower than all of them, so there is at least one rendition.
+// Re-encoding drops the EXIF data, including the GPS location, and any other metadata.
+func Process(data []byte, widths []int, quality int) (*Result, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+
+	originalWidth := img.Bounds().Dx()
+	targets := make([]int, 0, len(widths))
+	for _, w := range widths {
+		if w > 0 && w <= originalWidth {
+			targets = append(targets, w)
+		}
+	}
+	if len(targets) == 0 {
+		targets = append(targets, originalWidth)
+	}
+	slices.Sort(targets)
+	targets = slices.Compact(targets)
+
+	res := &Result{}
+	for _, w := range targets {
+		resized := img
+		if w != originalWidth {
+			resized = imaging.Resize(img, w, 0, imaging.Lanczos)
+		}
+
+		var buf bytes.Buffer
+		if err = webp.Encode(&buf, resized, webp.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("encode %dpx rendition: %w", w, err)
+		}
+
+		res.Renditions = append(res.Renditions, Rendition{
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+
+	res.Placeholder, err = placeholder(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// placeholder computes the blurhash out of a small copy of the image, the hash only
+// keeps a few color components so the details lost in resizing don't matter.
+func placeholder(img image.Image) (Placeholder, error) {
+	small := imaging.Resize(img, 32, 0, imaging.Box)
+
+	hash, err := blurhash.Encode(4, 3, small)
+	if err != nil {
+		return Placeholder{}, fmt.Errorf("encode blurhash: %w", err)
+	}
+
+	return Placeholder{
+		Blurhash:      hash,
+		DominantColor: averageColor(small),
+	}, nil
+}
+
+// averageColor returns the average color of the image as a hex color.
+func averageColor(img image.Image) string {
+	pixel := imaging.Resize(img, 1, 1, imaging.Box)
+	c := pixel.NRGBAAt(0, 0)
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
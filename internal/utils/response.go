@@ -23,6 +23,11 @@ func Created(c *gin.Context, data any) {
 	c.JSON(http.StatusCreated, data)
 }
 
+// Accepted responds with a summary of the work that goes on after the response.
+func Accepted(c *gin.Context, data any) {
+	c.JSON(http.StatusAccepted, data)
+}
+
 func NoContent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
@@ -60,3 +65,9 @@ func FailAndAbort(c *gin.Context, apiErr *APIError, loggedError error) {
 
 	c.AbortWithStatusJSON(apiErr.Code, apiErr)
 }
+
+// Unprocessable responds with the details of why a well formed request can't be applied,
+// like the report of an invalid import.
+func Unprocessable(c *gin.Context, data any) {
+	c.JSON(http.StatusUnprocessableEntity, data)
+}
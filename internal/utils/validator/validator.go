@@ -11,6 +11,13 @@ var EmailRX = regexp.MustCompile(
 	"^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$",
 )
 
+// SKURX is a regex for the format of the variant SKUs, they're kept upper case.
+var SKURX = regexp.MustCompile(`^[A-Z0-9][A-Z0-9._-]{0,63}$`)
+
+// BarcodeRX is a regex for the shape of the barcodes, a SKU can't have it
+// so a scanned code can't be both.
+var BarcodeRX = regexp.MustCompile(`^([0-9]{8}|[0-9]{12,13})$`)
+
 // Validator struct type contains a map of validation errors.
 type Validator struct {
 	Errors map[string]string
@@ -61,3 +68,37 @@ func Unique(values []string) bool {
 
 	return len(values) == len(uniqueValues)
 }
+
+// Barcode returns true if the value is an EAN-8, UPC-A or EAN-13 barcode with a valid check digit.
+func Barcode(value string) bool {
+	switch len(value) {
+	case 8, 12, 13:
+	default:
+		return false
+	}
+
+	// the digits are weighted 3 and 1 alternately from the right of the check digit,
+	// the check digit brings their sum to a multiple of 10.
+	sum := 0
+	for i := len(value) - 1; i >= 0; i-- {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+		digit := int(value[i] - '0')
+		if (len(value)-1-i)%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+// NormalizeBarcode returns the barcode as it's stored, a UPC-A is the EAN-13 with a leading 0,
+// so a product scanned either way is found. Other values are returned as they are.
+func NormalizeBarcode(value string) string {
+	if len(value) == 12 && Matches(value, BarcodeRX) {
+		return "0" + value
+	}
+	return value
+}